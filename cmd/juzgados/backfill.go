@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+)
+
+var backfillCommand = &command{
+	name:    "backfill",
+	summary: "Archive the bulletins of a past range of dates",
+	db:      true,
+	run:     runBackfill,
+}
+
+func runBackfill(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("backfill", "-from YYYY-mm-dd [-to YYYY-mm-dd] [-courts aux1,civ2] [-rate n] [-workers n] [-force]")
+	fromStr := fs.String("from", "", "The first date to archive")
+	toStr := fs.String("to", "", "The last date to archive, today by default")
+	courts := fs.String("courts", "", "Comma separated nature codes of the courts to archive, every court by default")
+	rate := fs.Float64("rate", 1, "Bulletins requested per second")
+	workers := fs.Int("workers", 2, "Bulletins downloaded at the same time")
+	force := fs.Bool("force", false, "Fetch again the bulletins already archived")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *fromStr == "" || *rate <= 0 || *workers <= 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	from, err := parseDate("From", *fromStr)
+
+	if err != nil {
+		return err
+	}
+
+	to, err := parseDate("To", *toStr)
+
+	if err != nil {
+		return err
+	}
+
+	if to.Before(from) {
+		return errors.New("The range ends before it starts")
+	}
+
+	opts := jobs.BackfillOptions{
+		From:    from,
+		To:      to,
+		Rate:    *rate,
+		Workers: *workers,
+		Force:   *force,
+	}
+
+	for _, code := range strings.Split(*courts, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			opts.Courts = append(opts.Courts, code)
+		}
+	}
+
+	log.Printf("Backfilling from %v to %v\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	store := db.NewStore()
+	var result *jobs.BackfillResult
+
+	_, err = jobs.Track(ctx, store, "backfill", db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		var err error
+
+		result, err = jobs.Backfill(ctx, store, opts)
+
+		if result != nil {
+			result.Record(run)
+		}
+
+		return err
+	})
+
+	if result != nil {
+		log.Printf(
+			"Fetched %v bulletins (%v missing, %v failed, %v skipped), archived %v new entries of %v read\n",
+			result.Fetched,
+			result.Missing,
+			result.Failed,
+			result.Skipped,
+			result.NewEntries,
+			result.Entries,
+		)
+
+		for court, msg := range result.CourtErrors {
+			log.Printf("Last error for %v: %v\n", court, msg)
+		}
+
+		if len(result.Errors) > 0 {
+			log.Printf("%v errors occurred while saving the progress", len(result.Errors))
+			log.Printf("Last error: %v", result.Errors[len(result.Errors)-1])
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("Backfill interrupted, run it again to continue: %w", err)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Backfill: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+)
+
+var cacheCommand = &command{
+	name:    "cache",
+	summary: "Inspect or clear the generated report files",
+	run:     runCache,
+}
+
+const cacheArgs = `<command> [args]
+
+Commands:
+  stats                      Print how many report files there are and their size
+  clear [-older-than 720h]   Remove the report files, only the older ones when given
+`
+
+// Where the generated reports are stored, relative to the config dir
+const reportsDir = "web/static/reports"
+
+func runCache(ctx context.Context, cfg *config.Config, args []string) error {
+	flags := newFlagSet("cache", cacheArgs)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	switch flags.Arg(0) {
+	case "stats":
+		var count, size int64
+
+		err := walkReports(func(path string, info fs.FileInfo) error {
+			count++
+			size += info.Size()
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%v report files, %.1f MB\n", count, float64(size)/(1<<20))
+	case "clear":
+		clearFlags := newFlagSet("cache clear", "[-older-than duration]")
+		olderThan := clearFlags.Duration("older-than", 0, "Only remove the files modified before this long ago")
+
+		if err := parseFlags(clearFlags, flags.Args()[1:]); err != nil {
+			return err
+		}
+
+		limit := time.Now().Add(-*olderThan)
+		removed := 0
+
+		err := walkReports(func(path string, info fs.FileInfo) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if info.ModTime().After(limit) {
+				return nil
+			}
+
+			removed++
+			return os.Remove(path)
+		})
+
+		log.Printf("Removed %v report files\n", removed)
+
+		return err
+	default:
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// walkReports calls fn for every file in the reports dir
+func walkReports(fn func(path string, info fs.FileInfo) error) error {
+	err := filepath.WalkDir(reportsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+
+		if err != nil {
+			return err
+		}
+
+		return fn(path, info)
+	})
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+var digestCommand = &command{
+	name:    "digest",
+	summary: "Queue and send the email digests that are due",
+	db:      true,
+	run:     runDigest,
+}
+
+func runDigest(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("digest", "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := cfg.Require("TSJ_SITE_HOSTNAME"); err != nil {
+		return err
+	}
+
+	store := db.NewStore()
+
+	_, err := jobs.Track(ctx, store, "digest", db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		return jobs.Digest(ctx, store, notify.Default(store), cfg.SiteHostname, run)
+	})
+
+	return err
+}
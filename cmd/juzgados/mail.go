@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/mailing"
+)
+
+var mailCommand = &command{
+	name:    "mail",
+	summary: "Send a test mail with the configured transport",
+	run:     runMail,
+}
+
+func runMail(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("mail", "-to address")
+	to := fs.String("to", "", "Who gets the test mail")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	mailCfg, err := mailing.ConfigFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	if err := mailing.Configure(mailCfg); err != nil {
+		return err
+	}
+
+	if err := mailing.Test(*to); err != nil {
+		return err
+	}
+
+	fmt.Printf("Sent a test mail to %v with the %v transport\n", *to, mailCfg.Transport)
+	return nil
+}
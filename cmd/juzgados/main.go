@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+// Exit codes
+const (
+	exitOk    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// errUsage is returned by the commands when their arguments are invalid,
+// the usage of the command has already been printed
+var errUsage = errors.New("usage")
+
+type command struct {
+	name    string
+	summary string
+	// Whether the command needs the database connection
+	db  bool
+	run func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+var commands = []*command{
+	serveCommand,
+	updateCommand,
+	reportCommand,
+	notifyCommand,
+	webhooksCommand,
+	digestCommand,
+	migrateCommand,
+	backfillCommand,
+	userCommand,
+	cacheCommand,
+	mailCommand,
+	telegramCommand,
+	vapidCommand,
+	smsCommand,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: juzgados [-env file] <command> [args]\n\nCommands:\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10v %v\n", cmd.name, cmd.summary)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nRun juzgados <command> -h for the arguments of a command\n")
+}
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	envFile := flag.String("env", "", "The .env file to load, $TSJ_DIR/.env by default")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		return exitUsage
+	}
+
+	var cmd *command
+
+	for _, c := range commands {
+		if c.name == flag.Arg(0) {
+			cmd = c
+		}
+	}
+
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", flag.Arg(0))
+		usage()
+		return exitUsage
+	}
+
+	cfg, err := config.Load(*envFile)
+
+	if err != nil {
+		log.Printf("Load config err: %v\n", err)
+		return exitError
+	}
+
+	logFile, err := cfg.SetupLogging(cmd.name)
+
+	if err != nil {
+		log.Printf("Setup logging err: %v\n", err)
+		return exitError
+	}
+	defer logFile.Close()
+
+	if cmd.db {
+		if err := cfg.Require("DATABASE_URL"); err != nil {
+			log.Println(err)
+			return exitError
+		}
+
+		dbPool, err := db.Connect()
+
+		if err != nil {
+			log.Printf("Error while connecting to DB: %v\n", err)
+			return exitError
+		}
+		defer dbPool.Close()
+	}
+
+	// Interrupting the command cancels the queries and downloads in flight
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = cmd.run(ctx, cfg, flag.Args()[1:])
+
+	if errors.Is(err, errUsage) {
+		return exitUsage
+	}
+
+	if err != nil {
+		log.Printf("%v err: %v\n", cmd.name, err)
+
+		// The log is in a file, whoever ran it must see why it failed too
+		if cfg.LogDir != "" {
+			fmt.Fprintf(os.Stderr, "%v err: %v\n", cmd.name, err)
+		}
+
+		return exitError
+	}
+
+	return exitOk
+}
+
+// newFlagSet returns a FlagSet for the command name that reports errors
+// instead of exiting, args describes its arguments in the usage
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: juzgados %v %v\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// parseFlags parses args into fs translating the errors to errUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+var migrateCommand = &command{
+	name:    "migrate",
+	summary: "Apply, revert or list the database migrations",
+	db:      true,
+	run:     runMigrate,
+}
+
+const migrateArgs = `<command>
+
+Commands:
+  up          Apply every pending migration
+  down [n]    Revert the last n applied migrations (1 by default)
+  status      List the migrations and whether they were applied
+`
+
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("migrate", migrateArgs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+
+		for _, m := range applied {
+			log.Printf("Applied %04d_%v\n", m.Version, m.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			log.Println("No pending migrations")
+		}
+	case "down":
+		steps := 1
+
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+
+			if err != nil || n < 1 {
+				log.Printf("Invalid number of migrations: %v\n", fs.Arg(1))
+				return errUsage
+			}
+
+			steps = n
+		}
+
+		reverted, err := db.MigrateDown(ctx, steps)
+
+		for _, m := range reverted {
+			log.Printf("Reverted %04d_%v\n", m.Version, m.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, s := range states {
+			appliedAt := "pending"
+
+			if s.Applied {
+				appliedAt = internal.FormatTimestampToString(s.AppliedAt.Time)
+			}
+
+			fmt.Printf("%04d_%-32v %v\n", s.Version, s.Name, appliedAt)
+		}
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+var notifyCommand = &command{
+	name:    "notify",
+	summary: "Deliver the queued notifications and retry the failed ones",
+	db:      true,
+	run:     runNotify,
+}
+
+func runNotify(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("notify", "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := cfg.Require("TSJ_SITE_HOSTNAME"); err != nil {
+		return err
+	}
+
+	store := db.NewStore()
+
+	_, err := jobs.Track(ctx, store, "notify", db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		return jobs.Notify(ctx, store, notify.Default(store), cfg.SiteHostname, run)
+	})
+
+	return err
+}
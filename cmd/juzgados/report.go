@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+var reportCommand = &command{
+	name:    "report",
+	summary: "Generate and send the automatic reports",
+	db:      true,
+	run:     runReport,
+}
+
+func runReport(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("report", "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if err := cfg.Require("TSJ_SITE_HOSTNAME"); err != nil {
+		return err
+	}
+
+	log.Println("Start auto-report")
+	store := db.NewStore()
+
+	_, err := jobs.Track(ctx, store, "report", db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		return jobs.Report(ctx, store, notify.Default(store), cfg.SiteHostname, run)
+	})
+
+	return err
+}
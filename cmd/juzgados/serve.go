@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/mailing"
+	"github.com/vladwithcode/juzgados/internal/notify"
+	"github.com/vladwithcode/juzgados/internal/routes"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "Start the web server",
+	db:      true,
+	run:     runServe,
+}
+
+func runServe(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("serve", "[-port port] [-scheduler]")
+	port := fs.String("port", cfg.Port, "The port to listen on, PORT by default")
+	withScheduler := fs.Bool("scheduler", cfg.Scheduler, "Run the scheduled jobs in the server, TSJ_SCHEDULER by default")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *port == "" {
+		return errors.New("Port is not set in env")
+	}
+
+	mailCfg, err := mailing.ConfigFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	if err := mailing.Configure(mailCfg); err != nil {
+		return err
+	}
+
+	log.Printf("Sending mail with the %v transport\n", mailCfg.Transport)
+
+	store := db.NewStore()
+	notifier := notify.Default(store)
+	builtinJobs, err := jobs.BuiltinJobs(store, notifier, cfg.SiteHostname)
+
+	if err != nil {
+		return err
+	}
+
+	// The jobs can always be run from the admin api, they only
+	// run on their own when the scheduler is started
+	scheduler := jobs.NewScheduler(store, builtinJobs...)
+
+	if *withScheduler {
+		log.Println("Starting the job scheduler")
+		scheduler.Start(ctx)
+	}
+
+	server := http.Server{
+		Addr:    fmt.Sprintf(":%s", *port),
+		Handler: routes.NewRouter(store, scheduler, notifier),
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		// Give the requests in flight some time to finish
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Server listening on http://localhost%s\n", server.Addr)
+	err = server.ListenAndServe()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		// The jobs were cancelled along ctx, let them clean up
+		scheduler.Wait()
+		return nil
+	}
+
+	return err
+}
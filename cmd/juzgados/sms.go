@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/sms"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+var smsCommand = &command{
+	name:    "sms",
+	summary: "Send a test SMS with the configured provider",
+	run:     runSMS,
+}
+
+func runSMS(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("sms", "-to phone [-text message]")
+	to := fs.String("to", "", "Who gets the test SMS")
+	text := fs.String("text", "Mensaje de prueba de Juzgados", "The text sent")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *to == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	smsCfg := sms.ConfigFromEnv()
+	phone, err := whatsapp.NormalizePhone(*to, smsCfg.DefaultCountryCode)
+
+	if err != nil {
+		return err
+	}
+
+	provider, err := sms.NewProvider(smsCfg)
+
+	if err != nil {
+		return err
+	}
+
+	body := sms.Compose(*text, "", "", smsCfg.MaxSegments)
+	id, err := provider.Send(ctx, phone, body)
+
+	if err != nil {
+		return err
+	}
+
+	encoding, segments := sms.Segments(body)
+	fmt.Printf("Sent a test SMS to %v with the %v provider (%v, %v segments, id %v)\n", phone, smsCfg.Provider, encoding, segments, id)
+	return nil
+}
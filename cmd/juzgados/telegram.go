@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/telegram"
+)
+
+var telegramCommand = &command{
+	name:    "telegram",
+	summary: "Set the webhook of the Telegram bot",
+	run:     runTelegram,
+}
+
+func runTelegram(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("telegram", "[-url webhook]")
+	webhookUrl := fs.String("url", "", "Where Telegram POSTs the messages, https://<host>/api/telegram/webhook by default")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *webhookUrl == "" {
+		if cfg.SiteHostname == "" {
+			fs.Usage()
+			return errUsage
+		}
+
+		*webhookUrl = fmt.Sprintf("https://%v/api/telegram/webhook", cfg.SiteHostname)
+	}
+
+	client := telegram.NewClient(telegram.ConfigFromEnv())
+
+	if err := client.SetWebhook(ctx, *webhookUrl); err != nil {
+		return err
+	}
+
+	fmt.Printf("The Telegram bot sends its messages to %v\n", *webhookUrl)
+	return nil
+}
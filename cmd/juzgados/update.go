@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+)
+
+var updateCommand = &command{
+	name:    "update",
+	summary: "Look for new accords of the followed cases and match the watches",
+	db:      true,
+	run:     runUpdate,
+}
+
+// parseDate parses dates given as YYYY-mm-dd, returning now for empty strings
+func parseDate(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+
+	if err != nil {
+		return date, fmt.Errorf("%v is invalid. Provide a date in format \"YYYY-mm-dd\"", name)
+	}
+
+	return date, nil
+}
+
+func runUpdate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("update", "[-d days] [-all] [-start-date YYYY-mm-dd] [-dry-run [-format table|json]]")
+	daysBack := fs.Uint("d", 0, "Number of days to search in the past, besides the days since the last check of every case")
+	checkAll := fs.Bool("all", false, "Check every followed case, ignoring the frequency chosen for them")
+	startDateStr := fs.String("start-date", "", "The date the update will start searching from (it searches from this date backwards)")
+	dryRun := fs.Bool("dry-run", false, "Print the changes the update would make to the cases without writing to the database")
+	format := fs.String("format", "table", "How the dry run prints the changes: table or json")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *format != "table" && *format != "json" {
+		fs.Usage()
+		return errUsage
+	}
+
+	startDate, err := parseDate("Start Date", *startDateStr)
+
+	if err != nil {
+		return err
+	}
+
+	store := db.NewStore()
+	opts := jobs.UpdateOptions{
+		DaysBack:  *daysBack,
+		StartDate: startDate,
+		CheckAll:  *checkAll,
+	}
+
+	// The dry run isn't recorded as a job run, it only reads from the database
+	if *dryRun {
+		log.Println("Start alert auto-update dry run")
+		plan, err := jobs.PlanUpdate(ctx, store, opts)
+
+		if err != nil {
+			return err
+		}
+
+		if *format == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(plan)
+		}
+
+		return printPlan(os.Stdout, plan)
+	}
+
+	log.Println("Start alert auto-update")
+
+	job := "update"
+	if *checkAll {
+		job = "update-all"
+	}
+
+	_, err = jobs.Track(ctx, store, job, db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		return jobs.Update(ctx, store, opts, run)
+	})
+
+	return err
+}
+
+// The longest accord printed in the plan table
+const planAccordLen = 60
+
+// printPlan writes plan as a table with a row per accord found
+func printPlan(out io.Writer, plan *jobs.UpdatePlan) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CASE\tCOURT\tCHANGE\tOLD DATE\tNEW DATE\tOLD ACCORD\tNEW ACCORD")
+
+	for _, diff := range plan.Diffs {
+		oldDate := "-"
+		if diff.OldAccordDate != nil {
+			oldDate = diff.OldAccordDate.Format("2006-01-02")
+		}
+
+		fmt.Fprintf(
+			w,
+			"%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			diff.CaseId,
+			diff.NatureCode,
+			diff.Change,
+			oldDate,
+			diff.NewAccordDate.Format("2006-01-02"),
+			shorten(diff.OldAccord, planAccordLen),
+			shorten(diff.NewAccord, planAccordLen),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(
+		out,
+		"\n%v cases checked: %v new, %v unchanged, %v older, %v not found. %v bulletins read, %v failed\n",
+		plan.CheckedCases,
+		plan.Count(jobs.DiffNew),
+		plan.Count(jobs.DiffUnchanged),
+		plan.Count(jobs.DiffOlder),
+		len(plan.NotFoundKeys),
+		plan.BulletinsFetched,
+		plan.FetchErrors,
+	)
+
+	for court, msg := range plan.CourtErrors {
+		fmt.Fprintf(out, "No bulletin could be read for %v: %v\n", court, msg)
+	}
+
+	return nil
+}
+
+// shorten collapses the spaces of s and cuts it to n runes
+func shorten(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+
+	if len(runes) <= n {
+		return s
+	}
+
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+var userCommand = &command{
+	name:    "user",
+	summary: "Create, verify and show users",
+	db:      true,
+	run:     runUser,
+}
+
+const userArgs = `<command> [args]
+
+Commands:
+  create -username u -email e [-name n] [-lastname l] [-phone p]
+              Create a user, the password is read from stdin
+  verify <username>
+              Mark the email of the user as verified
+  show <username>
+              Print the data of the user
+`
+
+func runUser(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("user", userArgs)
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	store := db.NewStore()
+
+	switch fs.Arg(0) {
+	case "create":
+		return createUser(ctx, store, fs.Args()[1:])
+	case "verify", "show":
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return errUsage
+		}
+
+		user, err := store.Users.GetUserByUsername(ctx, fs.Arg(1))
+
+		if err != nil {
+			return fmt.Errorf("Find user %v: %w", fs.Arg(1), err)
+		}
+
+		if fs.Arg(0) == "verify" {
+			return store.Users.VerifyUserEmail(ctx, user.Id)
+		}
+
+		fmt.Printf("Id:       %v\n", user.Id)
+		fmt.Printf("Name:     %v %v\n", user.Name, user.Lastname)
+		fmt.Printf("Username: %v\n", user.Username)
+		fmt.Printf("Email:    %v (verified: %v)\n", user.Email, user.EmailVerified)
+		fmt.Printf("Phone:    %v (verified: %v)\n", user.Phone.String, user.PhoneVerified)
+	default:
+		fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+func createUser(ctx context.Context, store *db.Store, args []string) error {
+	fs := newFlagSet("user create", "-username u -email e [-name n] [-lastname l] [-phone p]")
+	username := fs.String("username", "", "The username")
+	email := fs.String("email", "", "The email")
+	name := fs.String("name", "", "The name")
+	lastname := fs.String("lastname", "", "The lastname")
+	phone := fs.String("phone", "", "The phone number, in E.164 or 10 digits for Mexico")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *username == "" || *email == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *phone != "" {
+		normalized, err := whatsapp.NormalizePhone(*phone, whatsapp.ConfigFromEnv().DefaultCountryCode)
+
+		if err != nil {
+			return err
+		}
+
+		*phone = normalized
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	pw, err := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if err != nil && pw == "" {
+		return fmt.Errorf("Read password: %w", err)
+	}
+
+	pw = strings.TrimRight(pw, "\r\n")
+
+	if pw == "" {
+		return errors.New("The password can't be empty")
+	}
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	user := db.User{
+		Id:       id.String(),
+		Name:     *name,
+		Lastname: *lastname,
+		Username: *username,
+		Email:    *email,
+		Password: pw,
+		Phone:    sql.NullString{String: *phone, Valid: *phone != ""},
+	}
+
+	_, err = store.Users.CreateUser(ctx, &user)
+
+	if err != nil {
+		return err
+	}
+
+	if err = user.CreateReportDir(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created user %v with id %v\n", user.Username, user.Id)
+
+	return nil
+}
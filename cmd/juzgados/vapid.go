@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/webpush"
+)
+
+var vapidCommand = &command{
+	name:    "vapid",
+	summary: "Generate the VAPID keys for the browser notifications",
+	run:     runVapid,
+}
+
+func runVapid(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("vapid", "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	publicKey, privateKey, err := webpush.GenerateKeys()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("# Replacing the keys in use voids the browser subscriptions")
+	fmt.Printf("VAPID_PUBLIC_KEY=%v\nVAPID_PRIVATE_KEY=%v\n", publicKey, privateKey)
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"github.com/vladwithcode/juzgados/internal/config"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/webhooks"
+)
+
+var webhooksCommand = &command{
+	name:    "webhooks",
+	summary: "Send the queued webhook events and retry the failed ones",
+	db:      true,
+	run:     runWebhooks,
+}
+
+func runWebhooks(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlagSet("webhooks", "")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	store := db.NewStore()
+
+	_, err := jobs.Track(ctx, store, "webhooks", db.TriggerCommand, func(ctx context.Context, run *db.JobRun) error {
+		return jobs.Webhooks(ctx, store, webhooks.NewSender(), run)
+	})
+
+	return err
+}
@@ -27,8 +27,13 @@ type ReportData struct {
 	reportDate time.Time
 }
 
-func GenReportPdf(userId string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+// How long chromium may take to print a report, configurable with REPORT_TIMEOUT
+func reportTimeout() time.Duration {
+	return internal.EnvDuration("REPORT_TIMEOUT", 2*time.Minute)
+}
+
+func GenReportPdf(ctx context.Context, userId string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout())
 	defer cancel()
 
 	url := fmt.Sprintf("http://localhost:8080/api/alerts/report/%v", userId)
@@ -54,8 +59,8 @@ func GenReportPdf(userId string) (string, error) {
 	return fmt.Sprintf("/reports/%v/report.pdf", userId), nil
 }
 
-func GenReportPdfWithData(userData db.AutoReportUser) (docPath string, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*2)
+func GenReportPdfWithData(ctx context.Context, userData db.AutoReportUser) (docPath string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout())
 	defer cancel()
 
 	templ, err := template.New("layout.html").Funcs(template.FuncMap{
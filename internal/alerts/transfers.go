@@ -0,0 +1,203 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+// NewAlertForCase builds an active alert for the user filled
+// with the latest accord found for the case, if any
+func NewAlertForCase(ctx context.Context, userId, caseId, natureCode string) *db.Alert {
+	doc, _ := tsj.GetCaseData(ctx, caseId, natureCode, nil, tsj.DEFAULT_DAYS_BACK)
+	alert := db.Alert{
+		UserId:        userId,
+		CaseId:        db.TrimField(caseId),
+		NatureCode:    db.TrimField(natureCode),
+		LastCheckedAt: time.Now(),
+		LastUpdatedAt: time.Now(),
+		Active:        true,
+		AutoReport:    true,
+		Frequency:     db.DefaultFrequency,
+	}
+
+	if doc != nil {
+		alert.LastAccord.String = doc.Accord
+		alert.LastAccord.Valid = true
+		alert.LastAccordDate.Time = doc.AccordDate
+		alert.LastAccordDate.Valid = true
+		alert.Nature = doc.Nature
+	}
+
+	return &alert
+}
+
+// FindOrCreateAlert returns the alert the user already has for the case,
+// creating it when it doesn't exist
+func FindOrCreateAlert(ctx context.Context, repo db.AlertRepository, userId, caseId, natureCode string) (*db.Alert, error) {
+	alert, err := repo.FindUserAlertByCase(ctx, userId, caseId, natureCode)
+
+	if err == nil {
+		return alert, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	alert, err = repo.CreateAlertWithData(ctx, NewAlertForCase(ctx, userId, caseId, natureCode))
+
+	if err == nil {
+		return alert, nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return repo.FindUserAlertByCase(ctx, userId, caseId, natureCode)
+	}
+
+	return nil, err
+}
+
+// FollowTransfers looks for remissions to other courts in the accords
+// found by the update job. For every alert following a transferred case
+// a link to the receiving court is stored: when the accord orders the
+// remission and names both the court and the case number, an alert for it
+// is created right away, otherwise the link is left as a suggestion for
+// the user to confirm
+func FollowTransfers(ctx context.Context, store *db.Store, docs []*db.Doc) (linkedCount int, errs []error) {
+	for _, doc := range docs {
+		transfer := tsj.DetectTransfer(doc)
+
+		if transfer == nil {
+			continue
+		}
+
+		subscribers, err := store.Alerts.FindActiveAlertsByCase(ctx, doc.Case, doc.NatureCode)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, alert := range subscribers {
+			kind := db.LinkKindTransfer
+			if tsj.IsSala(transfer.ToNatureCode) {
+				kind = db.LinkKindAppeal
+			}
+
+			link := db.AlertLink{
+				UserId:       alert.UserId,
+				FromAlertId:  alert.Id,
+				Kind:         kind,
+				Status:       db.LinkStatusSuggested,
+				ToCaseId:     transfer.ToCaseId,
+				ToNatureCode: transfer.ToNatureCode,
+				Accord:       doc.Accord,
+				AccordDate:   doc.AccordDate,
+			}
+
+			if transfer.Confident {
+				target, err := FindOrCreateAlert(ctx, store.Alerts, alert.UserId, transfer.ToCaseId, transfer.ToNatureCode)
+
+				if err != nil {
+					errs = append(errs, fmt.Errorf("No se pudo crear la alerta para %v+%v: %w", transfer.ToCaseId, transfer.ToNatureCode, err))
+				} else {
+					link.Status = db.LinkStatusConfirmed
+					link.ToAlertId.String = target.Id
+					link.ToAlertId.Valid = true
+				}
+			}
+
+			_, created, err := store.Links.CreateAlertLink(ctx, &link)
+
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			if created {
+				linkedCount++
+			}
+		}
+	}
+
+	return
+}
+
+// LinkAppeals relates the alerts following a toca with the alert of the
+// case it was formed from, as mentioned in the entries of the Salas. When
+// the entry names the court and the expediente without doubt, the alert
+// for the original case is created when the user doesn't have it,
+// otherwise a link from the toca is left as a suggestion for the user to
+// confirm
+func LinkAppeals(ctx context.Context, store *db.Store, docs []*db.Doc) (linkedCount int, errs []error) {
+	for _, doc := range docs {
+		origin := tsj.DetectAppealOrigin(doc)
+
+		if origin == nil {
+			continue
+		}
+
+		subscribers, err := store.Alerts.FindActiveAlertsByCase(ctx, doc.Case, doc.NatureCode)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, tocaAlert := range subscribers {
+			link := db.AlertLink{
+				UserId:       tocaAlert.UserId,
+				FromAlertId:  tocaAlert.Id,
+				Kind:         db.LinkKindOrigin,
+				Status:       db.LinkStatusSuggested,
+				ToCaseId:     origin.CaseId,
+				ToNatureCode: origin.NatureCode,
+				Accord:       doc.Accord,
+				AccordDate:   doc.AccordDate,
+			}
+
+			if origin.Confident {
+				originAlert, err := FindOrCreateAlert(ctx, store.Alerts, tocaAlert.UserId, origin.CaseId, origin.NatureCode)
+
+				if err != nil {
+					errs = append(errs, fmt.Errorf("No se pudo crear la alerta para %v+%v: %w", origin.CaseId, origin.NatureCode, err))
+					continue
+				}
+
+				link = db.AlertLink{
+					UserId:       tocaAlert.UserId,
+					FromAlertId:  originAlert.Id,
+					Kind:         db.LinkKindAppeal,
+					Status:       db.LinkStatusConfirmed,
+					ToCaseId:     tocaAlert.CaseId,
+					ToNatureCode: tocaAlert.NatureCode,
+					Accord:       doc.Accord,
+					AccordDate:   doc.AccordDate,
+				}
+				link.ToAlertId.String = tocaAlert.Id
+				link.ToAlertId.Valid = true
+			}
+
+			_, created, err := store.Links.CreateAlertLink(ctx, &link)
+
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			if created {
+				linkedCount++
+			}
+		}
+	}
+
+	return
+}
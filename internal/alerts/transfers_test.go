@@ -0,0 +1,55 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+)
+
+func TestLinkAppealsSuggestsUnconfidentOrigins(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.New()
+
+	toca, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{UserId: "u1", CaseId: "45/2024", NatureCode: "seccu", Active: true})
+
+	if err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	// The court of the expediente isn't named
+	doc := db.Doc{
+		Case:       "45/2024",
+		NatureCode: "seccu",
+		Accord:     "Se radica el recurso de apelación interpuesto en el expediente 84/2023.",
+		AccordDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	linked, errs := LinkAppeals(ctx, store, []*db.Doc{&doc})
+
+	if linked != 1 || len(errs) != 0 {
+		t.Fatalf("got %v links, %v, want 1 without errors", linked, errs)
+	}
+
+	if alerts, _ := store.Alerts.FindAlertsByUser(ctx, "u1", false); len(alerts) != 1 {
+		t.Errorf("got %v alerts, want only the one of the toca", len(alerts))
+	}
+
+	links, err := store.Links.FindLinkedAlerts(ctx, toca.Id)
+
+	if err != nil || len(links) != 1 {
+		t.Fatalf("got %v links, %v, want 1", len(links), err)
+	}
+
+	got := links[0]
+
+	if got.Link.Kind != db.LinkKindOrigin || got.Link.Status != db.LinkStatusSuggested || got.Link.ToCaseId != "84/2023" || got.Alert != nil {
+		t.Errorf("got a %v %v link to %q, want a suggested origin link to 84/2023", got.Link.Status, got.Link.Kind, got.Link.ToCaseId)
+	}
+
+	if label := got.KindLabel(); label != "Expediente de origen" {
+		t.Errorf("got label %q, want Expediente de origen", label)
+	}
+}
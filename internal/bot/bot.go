@@ -0,0 +1,296 @@
+// Package bot answers the commands users send from their phones to
+// follow cases without opening the dashboard. It only deals with text,
+// the channels (e.g. WhatsApp) receive the messages and send the replies
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/alerts"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+// The commands understood
+const (
+	CmdAdd    = "alta"
+	CmdRemove = "baja"
+	CmdStatus = "estado"
+	CmdList   = "mis expedientes"
+	// Links the phone or chat to an account, each channel handles it
+	CmdLink = "vincular"
+	CmdHelp = "ayuda"
+)
+
+// The most cases listed by CmdList, WhatsApp cuts the long messages
+const maxListed = 30
+
+var caseIdExp = regexp.MustCompile(`^\d+/\d{4}$`)
+
+// The nature codes by their lowercase, so "MEROral" finds "merOral"
+var natureCodes = func() map[string]string {
+	codes := map[string]string{}
+
+	for code := range internal.CodesMap {
+		codes[strings.ToLower(code)] = code
+	}
+
+	return codes
+}()
+
+// A Command is a message parsed
+type Command struct {
+	// One of the Cmd constants, empty when the message wasn't understood
+	Name       string
+	CaseId     string
+	NatureCode string
+	// The words after the command
+	Args []string
+}
+
+// Parse reads the command in text. The case and the court can be written
+// in any order after the command, e.g. "alta 84/2003 fam2" or
+// "Alta fam2 84/2003". The commands can be written like in Telegram too:
+// "/alta@bot 84/2003 fam2", "/mis_expedientes" and "/start <code>", sent
+// by the links that open the chat, to link it
+func Parse(text string) Command {
+	fields := strings.Fields(strings.ToLower(internal.FoldAccents(text)))
+
+	if len(fields) == 0 {
+		return Command{}
+	}
+
+	if strings.HasPrefix(fields[0], "/") {
+		fields[0], _, _ = strings.Cut(fields[0][1:], "@")
+	}
+
+	cmd := Command{}
+
+	switch {
+	case fields[0] == "mis" && len(fields) > 1 && strings.HasPrefix(fields[1], "expediente"),
+		fields[0] == "mis_expedientes", fields[0] == "expedientes", fields[0] == "lista":
+		cmd.Name = CmdList
+		return cmd
+	case fields[0] == CmdAdd, fields[0] == CmdRemove, fields[0] == CmdStatus, fields[0] == CmdLink:
+		cmd.Name = fields[0]
+	case fields[0] == "start" && len(fields) > 1:
+		cmd.Name = CmdLink
+	case fields[0] == CmdHelp, fields[0] == "start", fields[0] == "hola", fields[0] == "menu":
+		cmd.Name = CmdHelp
+		return cmd
+	default:
+		return cmd
+	}
+
+	cmd.Args = fields[1:]
+
+	for _, arg := range cmd.Args {
+		if caseIdExp.MatchString(arg) {
+			cmd.CaseId = db.TrimField(arg)
+		} else if code, ok := natureCodes[arg]; ok {
+			cmd.NatureCode = code
+		}
+	}
+
+	return cmd
+}
+
+// A Bot answers the commands of the users with the alerts in its store
+type Bot struct {
+	store *db.Store
+	// Finds the latest accord of a case, tsj.GetCaseData unless replaced
+	FindCase func(ctx context.Context, caseId, natureCode string) (*db.Doc, error)
+}
+
+func New(store *db.Store) *Bot {
+	return &Bot{
+		store: store,
+		FindCase: func(ctx context.Context, caseId, natureCode string) (*db.Doc, error) {
+			return tsj.GetCaseData(ctx, caseId, natureCode, nil, tsj.DEFAULT_DAYS_BACK)
+		},
+	}
+}
+
+// Reply runs cmd for user and returns the answer. The errors are turned
+// into answers too, the user can't do anything else with them
+func (b *Bot) Reply(ctx context.Context, user *db.User, cmd Command) string {
+	switch cmd.Name {
+	case CmdAdd, CmdRemove, CmdStatus:
+		if cmd.CaseId == "" || cmd.NatureCode == "" {
+			return fmt.Sprintf("Escribe el expediente y el juzgado, p.ej. *%v 84/2003 fam2*\n\n%v", cmd.Name, courtsHelp())
+		}
+	}
+
+	switch cmd.Name {
+	case CmdAdd:
+		return b.add(ctx, user, cmd)
+	case CmdRemove:
+		return b.remove(ctx, user, cmd)
+	case CmdStatus:
+		return b.status(ctx, user, cmd)
+	case CmdList:
+		return b.list(ctx, user)
+	case CmdLink:
+		return "Tu cuenta ya está vinculada"
+	case CmdHelp:
+		return Help()
+	}
+
+	return "No entendí tu mensaje.\n\n" + Help()
+}
+
+// Help lists the commands
+func Help() string {
+	return strings.Join([]string{
+		"Puedes enviarme:",
+		"• *alta 84/2003 fam2* para seguir un expediente",
+		"• *baja 84/2003 fam2* para dejar de seguirlo",
+		"• *estado 84/2003 fam2* para ver su último acuerdo",
+		"• *mis expedientes* para ver los que sigues",
+		"",
+		courtsHelp(),
+	}, "\n")
+}
+
+func courtsHelp() string {
+	codes := make([]string, 0, len(internal.CodesMap))
+
+	for code := range internal.CodesMap {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	return "Los juzgados son: " + strings.Join(codes, ", ")
+}
+
+func caseName(caseId, natureCode string) string {
+	return fmt.Sprintf("%v %v", caseId, internal.CodesMap[natureCode])
+}
+
+func (b *Bot) add(ctx context.Context, user *db.User, cmd Command) string {
+	alert := alerts.NewAlertForCase(ctx, user.Id, cmd.CaseId, cmd.NatureCode)
+	_, err := b.store.Alerts.CreateAlertWithData(ctx, alert)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return fmt.Sprintf("Ya sigues el expediente %v", caseName(cmd.CaseId, cmd.NatureCode))
+		}
+
+		fmt.Printf("[Bot create err]: %v\n", err)
+		return "Ocurrió un error al crear la alerta, intenta más tarde"
+	}
+
+	reply := fmt.Sprintf("Listo, te avisaremos de los acuerdos nuevos del expediente %v", caseName(cmd.CaseId, cmd.NatureCode))
+
+	if alert.LastAccordDate.Valid {
+		reply += fmt.Sprintf("\n\nÚltimo acuerdo (%v):\n%v", internal.FormatDate(alert.LastAccordDate.Time), alert.LastAccord.String)
+	}
+
+	return reply
+}
+
+func (b *Bot) remove(ctx context.Context, user *db.User, cmd Command) string {
+	alert, err := b.store.Alerts.FindUserAlertByCase(ctx, user.Id, cmd.CaseId, cmd.NatureCode)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Sprintf("No sigues el expediente %v", caseName(cmd.CaseId, cmd.NatureCode))
+		}
+
+		fmt.Printf("[Bot find err]: %v\n", err)
+		return "Ocurrió un error al buscar la alerta, intenta más tarde"
+	}
+
+	err = b.store.Alerts.DeleteUserAlertById(ctx, alert.Id, user.Id)
+
+	if err != nil {
+		fmt.Printf("[Bot delete err]: %v\n", err)
+		return "Ocurrió un error al eliminar la alerta, intenta más tarde"
+	}
+
+	return fmt.Sprintf("Dejaste de seguir el expediente %v", caseName(cmd.CaseId, cmd.NatureCode))
+}
+
+func (b *Bot) status(ctx context.Context, user *db.User, cmd Command) string {
+	doc, err := b.FindCase(ctx, cmd.CaseId, cmd.NatureCode)
+
+	if err != nil {
+		var notFoundErr *tsj.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			return fmt.Sprintf("No se encontraron acuerdos del expediente %v en los últimos %v días", caseName(cmd.CaseId, cmd.NatureCode), tsj.DEFAULT_DAYS_BACK)
+		}
+
+		fmt.Printf("[Bot GetCaseData err]: %v\n", err)
+		return "No se pudieron consultar los boletines, intenta más tarde"
+	}
+
+	reply := fmt.Sprintf("*%v*\n", caseName(cmd.CaseId, cmd.NatureCode))
+
+	if doc.Nature != "" {
+		reply += doc.Nature + "\n"
+	}
+
+	reply += fmt.Sprintf("\nÚltimo acuerdo (%v):\n%v", internal.FormatDate(doc.AccordDate), doc.Accord)
+
+	_, err = b.store.Alerts.FindUserAlertByCase(ctx, user.Id, cmd.CaseId, cmd.NatureCode)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		reply += fmt.Sprintf("\n\nEnvía *alta %v %v* para recibir sus acuerdos nuevos", cmd.CaseId, cmd.NatureCode)
+	}
+
+	return reply
+}
+
+func (b *Bot) list(ctx context.Context, user *db.User) string {
+	found, err := b.store.Alerts.FindAlertsByUser(ctx, user.Id, true)
+
+	if err != nil {
+		fmt.Printf("[Bot find err]: %v\n", err)
+		return "Ocurrió un error al buscar tus expedientes, intenta más tarde"
+	}
+
+	if len(found) == 0 {
+		return "No sigues ningún expediente, envía *alta 84/2003 fam2* para seguir uno"
+	}
+
+	lines := []string{fmt.Sprintf("Sigues %v expedientes:", len(found))}
+
+	if len(found) == 1 {
+		lines[0] = "Sigues 1 expediente:"
+	}
+
+	for i, alert := range found {
+		if i == maxListed {
+			lines = append(lines, fmt.Sprintf("… y %v más en tu panel", len(found)-maxListed))
+			break
+		}
+
+		line := "• " + caseName(alert.CaseId, alert.NatureCode)
+
+		if alert.Alias.Valid && alert.Alias.String != "" {
+			line += " (" + alert.Alias.String + ")"
+		}
+
+		if alert.LastAccordDate.Valid {
+			line += ", último acuerdo el " + internal.FormatDate(alert.LastAccordDate.Time)
+		}
+
+		if alert.NewAccords > 0 {
+			line += fmt.Sprintf(", %v nuevos", alert.NewAccords)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
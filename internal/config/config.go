@@ -0,0 +1,131 @@
+// Package config loads the settings shared by every command of the
+// juzgados binary, so none of them depends on where it was deployed
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	// The directory holding .env and web/, the commands run from it.
+	// Taken from TSJ_DIR, the working directory when unset
+	Dir          string
+	EnvFile      string
+	DatabaseURL  string
+	Port         string
+	SiteHostname string
+	// Where the commands write their logs, stderr when empty
+	LogDir string
+	// Whether serve runs the scheduled jobs, from TSJ_SCHEDULER
+	Scheduler bool
+}
+
+// Load reads envFile, or .env inside TSJ_DIR when envFile is empty, into
+// the environment and returns the resulting Config. Variables already set
+// in the environment win over the ones in the file. A missing default .env
+// is not an error, the environment alone may hold everything
+func Load(envFile string) (*Config, error) {
+	cfg := Config{Dir: os.Getenv("TSJ_DIR")}
+
+	if cfg.Dir == "" {
+		dir, err := os.Getwd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.Dir = dir
+	}
+
+	explicit := envFile != ""
+
+	if !explicit {
+		envFile = filepath.Join(cfg.Dir, ".env")
+	}
+
+	err := godotenv.Load(envFile)
+
+	if err != nil && (explicit || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("No se pudo cargar %v: %w", envFile, err)
+	}
+
+	if err == nil {
+		cfg.EnvFile = envFile
+	}
+
+	// The .env may point to another directory
+	if dir := os.Getenv("TSJ_DIR"); dir != "" {
+		cfg.Dir = dir
+	}
+
+	err = os.Chdir(cfg.Dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Some packages still read TSJ_DIR on their own
+	os.Setenv("TSJ_DIR", cfg.Dir)
+
+	cfg.DatabaseURL = os.Getenv("DATABASE_URL")
+	cfg.Port = os.Getenv("PORT")
+	cfg.SiteHostname = os.Getenv("TSJ_SITE_HOSTNAME")
+	cfg.LogDir = os.Getenv("TSJ_LOG_DIR")
+	cfg.Scheduler, _ = strconv.ParseBool(os.Getenv("TSJ_SCHEDULER"))
+
+	return &cfg, nil
+}
+
+// Require returns an error naming every one of keys missing in the environment
+func (c *Config) Require(keys ...string) error {
+	missing := []string{}
+
+	for _, key := range keys {
+		if os.Getenv(key) == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("Faltan variables de entorno: %v", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// SetupLogging sends the standard logger to <LogDir>/<name>.log. Without
+// a LogDir it stays on the terminal. The output of the commands always
+// goes to stdout, e.g. the keys of vapid never end up in a log file. The
+// returned closer must be called before exiting
+func (c *Config) SetupLogging(name string) (io.Closer, error) {
+	log.SetPrefix(fmt.Sprintf("[%v] ", name))
+
+	if c.LogDir == "" {
+		return io.NopCloser(nil), nil
+	}
+
+	err := os.MkdirAll(c.LogDir, 0755)
+
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(c.LogDir, name+".log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.SetOutput(file)
+
+	return file, nil
+}
@@ -10,32 +10,47 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// An Alert is a user's subscription to a case as seen by the user:
+// the subscription preferences along with the state of the case
 type Alert struct {
 	Id             string         `json:"id" db:"id"`
 	UserId         string         `json:"userId" db:"user_id"`
+	CaseRef        string         `json:"caseRef" db:"case_ref"`
 	CaseId         string         `json:"caseId" db:"case_id"`
 	Nature         string         `json:"nature" db:"nature"`
 	NatureCode     string         `json:"natureCode" db:"nature_code"`
 	Active         bool           `json:"active" db:"active"`
 	Alias          sql.NullString `json:"alias" db:"alias"`
+	Notes          sql.NullString `json:"notes" db:"notes"`
+	AutoReport     bool           `json:"autoReport" db:"auto_report"`
+	Frequency      Frequency      `json:"frequency" db:"frequency"`
 	LastUpdatedAt  time.Time      `json:"lastUpdateAt" db:"last_updated_at"`
 	LastCheckedAt  time.Time      `json:"lastCheckedAt" db:"last_checked_at"`
 	LastAccord     sql.NullString `json:"lastAccord" db:"last_accord"`
 	LastAccordDate sql.NullTime   `json:"lastAccordDate" db:"last_accord_date"`
 	CreatedAt      time.Time      `json:"createdAt" db:"created_at"`
+	LastViewedAt   sql.NullTime   `json:"lastViewedAt" db:"last_viewed_at"`
+	// How many accords were found for the case since the user last viewed
+	// the alert, or since it was created when it was never viewed
+	NewAccords int `json:"newAccords" db:"new_accords"`
+}
 
-	/**
-	TODO: Future improvements
-	frequency: daily | ... | monthly
-	subscribers
-	...
-	*/
+// NextCheck returns when the case is due to be checked again
+// according to the frequency chosen by the user
+func (a *Alert) NextCheck() time.Time {
+	return a.Frequency.NextCheck(a.LastCheckedAt)
 }
 
+// Columns and tables to scan an Alert from the subscriptions of the users
+const (
+	alertColumns = "s.id, s.user_id, s.case_ref, c.case_id, c.nature, c.nature_code, s.active, s.alias, s.notes, s.auto_report, s.frequency, c.last_updated_at, c.last_checked_at, c.last_accord, c.last_accord_date, s.created_at, s.last_viewed_at, " +
+		"(SELECT COUNT(*) FROM case_changes ch WHERE ch.case_ref = c.id AND ch.detected_at > COALESCE(s.last_viewed_at, s.created_at))::int AS new_accords"
+	alertTables = "subscriptions s JOIN cases c ON c.id = s.case_ref"
+)
+
 func (a *Alert) GetCaseKey() string {
 	caseId := strings.TrimSpace(strings.TrimLeft(a.CaseId, "0"))
 	return fmt.Sprintf("%v+%v", caseId, a.NatureCode)
@@ -61,6 +76,8 @@ type AutoReportUser struct {
 	Email    string
 	Phone    string
 	Alerts   []AutoReportAlert
+	// The newest change in the alerts, empty when there are none
+	LastChangeId string
 }
 
 func TrimField(val string) string {
@@ -76,21 +93,21 @@ func GetCaseParams(cK string) (caseId, natureCode string) {
 	return
 }
 
-func FindAlertById(id string) (*Alert, error) {
-	conn, err := GetPool()
+func FindAlertById(ctx context.Context, id string) (*Alert, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	defer conn.Release()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	var alert Alert
 
 	row, err := conn.Query(
 		ctx,
-		"SELECT * FROM alerts WHERE id = $1 LIMIT 1",
+		"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.id = $1 LIMIT 1",
 		id,
 	)
 
@@ -107,14 +124,14 @@ func FindAlertById(id string) (*Alert, error) {
 	return &alert, nil
 }
 
-func FindAlertsByUser(userId string, findActive bool) ([]*Alert, error) {
-	conn, err := GetPool()
+func FindAlertsByUser(ctx context.Context, userId string, findActive bool) ([]*Alert, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	var rows pgx.Rows
@@ -122,13 +139,13 @@ func FindAlertsByUser(userId string, findActive bool) ([]*Alert, error) {
 	if findActive {
 		rows, err = conn.Query(
 			ctx,
-			"SELECT * FROM alerts WHERE user_id = $1 AND active = TRUE",
+			"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.user_id = $1 AND s.active = TRUE",
 			userId,
 		)
 	} else {
 		rows, err = conn.Query(
 			ctx,
-			"SELECT * FROM alerts WHERE user_id = $1 ORDER BY last_accord_date DESC NULLS LAST",
+			"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.user_id = $1 ORDER BY c.last_accord_date DESC NULLS LAST",
 			userId,
 		)
 	}
@@ -154,87 +171,118 @@ func FindAlertsByUser(userId string, findActive bool) ([]*Alert, error) {
 	return resAlerts, nil
 }
 
-func FindAutoReportAlertsForUser(userId string) (*[]Alert, error) {
-	conn, err := GetPool()
+// FindActiveAlertsByCase returns the active alerts of every user following the case
+func FindActiveAlertsByCase(ctx context.Context, caseId, natureCode string) ([]*Alert, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	var alerts []Alert
-
 	rows, err := conn.Query(
 		ctx,
-		"SELECT * FROM alerts WHERE user_id = $1 AND active = TRUE",
+		"SELECT "+alertColumns+" FROM "+alertTables+" WHERE c.case_id = $1 AND c.nature_code = $2 AND s.active = TRUE",
+		TrimField(caseId),
+		natureCode,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*Alert](rows, pgx.RowToAddrOfStructByName[Alert])
+}
+
+func FindUserAlertByCase(ctx context.Context, userId, caseId, natureCode string) (*Alert, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	row, err := conn.Query(
+		ctx,
+		"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.user_id = $1 AND c.case_id = $2 AND c.nature_code = $3 LIMIT 1",
 		userId,
+		TrimField(caseId),
+		natureCode,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Refer to https://stackoverflow.com/questions/61704842/how-to-scan-a-queryrow-into-a-struct-with-pgx
-	alerts, err = pgx.CollectRows[Alert](rows, pgx.RowToStructByName[Alert])
+	alert, err := pgx.CollectOneRow[Alert](row, pgx.RowToStructByName[Alert])
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &alerts, nil
+	return &alert, nil
 }
 
-func FindDistinctActiveAlerts(searchDate time.Time) ([]*Alert, error) {
-	conn, err := GetPool()
+func FindAutoReportAlertsForUser(ctx context.Context, userId string) (*[]Alert, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpScan)
 	defer cancel()
 
-	var alerts []*Alert
+	var alerts []Alert
 
 	rows, err := conn.Query(
 		ctx,
-		"SELECT DISTINCT ON (case_id, nature_code) case_id, nature_code FROM alerts WHERE active = true AND (last_accord_date < $1 OR last_accord_date IS NULL) ORDER BY nature_code, case_id DESC",
-		searchDate,
+		"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.user_id = $1 AND s.active = TRUE AND s.auto_report = TRUE",
+		userId,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		var alert Alert
-		err = rows.Scan(&alert.CaseId, &alert.NatureCode)
-
-		if err != nil {
-			return nil, err
-		}
+	// Refer to https://stackoverflow.com/questions/61704842/how-to-scan-a-queryrow-into-a-struct-with-pgx
+	alerts, err = pgx.CollectRows[Alert](rows, pgx.RowToStructByName[Alert])
 
-		alerts = append(alerts, &alert)
+	if err != nil {
+		return nil, err
 	}
 
-	return alerts, nil
+	return &alerts, nil
 }
 
-func FindAutoReportAlertsWithUserData() ([]*AutoReportUser, error) {
-	conn, err := GetPool()
+// The id of the newest change in the active auto report subscriptions
+// of the user in the outer query, empty when there are none
+const lastChangeQuery = "COALESCE((SELECT ch.id::text FROM subscriptions sc JOIN case_changes ch ON ch.case_ref = sc.case_ref WHERE sc.user_id = users.id AND sc.active = TRUE AND sc.auto_report = TRUE ORDER BY ch.detected_at DESC, ch.id DESC LIMIT 1), '')"
+
+// Selects the active auto report subscriptions of the user in the outer
+// query with a change found after the last notification sent for them
+const changedSinceNotifiedQuery = "SELECT 1 FROM subscriptions sc JOIN case_changes ch ON ch.case_ref = sc.case_ref WHERE sc.user_id = users.id AND sc.active = TRUE AND sc.auto_report = TRUE AND ch.detected_at > COALESCE(sc.last_notified_at, sc.created_at)"
+
+// FindAutoReportAlertsWithUserData returns the users with a phone and
+// their active auto report alerts. With changedOnly it only returns the
+// users with a change in one of those alerts since they were last notified
+func FindAutoReportAlertsWithUserData(ctx context.Context, changedOnly bool) ([]*AutoReportUser, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpScan)
 	defer cancel()
 
 	var resultUsers = []*AutoReportUser{}
 
-	rows, err := conn.Query(ctx, "SELECT users.id, users.name, users.lastname, users.email, users.phone_number, ARRAY_AGG((alerts.id, alerts.case_id, alerts.nature_code, alerts.last_accord, alerts.last_accord_date)) AS alerts FROM users LEFT JOIN alerts ON users.id = alerts.user_id WHERE alerts.active = true AND users.phone_number IS NOT NULL GROUP BY users.id, users.id, users.name, users.lastname, users.email, users.phone_number;")
+	rows, err := conn.Query(ctx, "SELECT users.id, users.name, users.lastname, users.email, users.phone_number, ARRAY_AGG((s.id, c.case_id, c.nature_code, c.last_accord, c.last_accord_date)) AS alerts, "+lastChangeQuery+" AS last_change_id FROM users JOIN subscriptions s ON users.id = s.user_id JOIN cases c ON c.id = s.case_ref WHERE s.active = true AND s.auto_report = true AND users.phone_number IS NOT NULL AND ($1 = FALSE OR EXISTS ("+changedSinceNotifiedQuery+")) GROUP BY users.id, users.id, users.name, users.lastname, users.email, users.phone_number;", changedOnly)
 
 	if err != nil {
 		return nil, err
@@ -244,7 +292,7 @@ func FindAutoReportAlertsWithUserData() ([]*AutoReportUser, error) {
 		var user AutoReportUser
 		var tempArr pgtype.Array[AutoReportAlert]
 
-		err = rows.Scan(&user.Id, &user.Name, &user.Lastname, &user.Email, &user.Phone, &tempArr)
+		err = rows.Scan(&user.Id, &user.Name, &user.Lastname, &user.Email, &user.Phone, &tempArr, &user.LastChangeId)
 
 		if err != nil {
 			return nil, err
@@ -258,34 +306,79 @@ func FindAutoReportAlertsWithUserData() ([]*AutoReportUser, error) {
 	return resultUsers, nil
 }
 
-func CreateAlertWithData(data *Alert) (*Alert, error) {
-	conn, err := GetPool()
+// CreateAlertWithData subscribes the user to the case in data. The case is
+// created when nobody follows it yet, otherwise its state is kept unless
+// data holds a new accord for it. data is filled with the state of the case
+func CreateAlertWithData(ctx context.Context, data *Alert) (*Alert, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
+	caseRef, err := uuid.NewV7()
+
+	if err != nil {
+		return nil, err
+	}
+
 	id, err := uuid.NewV7()
 
 	if err != nil {
 		return nil, err
 	}
 
-	t, err := conn.Exec(
+	tx, err := conn.Begin(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(
 		ctx,
-		"INSERT INTO alerts (id, user_id, case_id, nature_code, active, last_accord, last_accord_date, alias, nature) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
-		id,
-		data.UserId,
+		"INSERT INTO cases (id, case_id, nature_code, nature, last_accord, last_accord_date) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (case_id, nature_code) DO NOTHING",
+		caseRef,
 		data.CaseId,
 		data.NatureCode,
-		data.Active,
+		data.Nature,
 		data.LastAccord,
 		data.LastAccordDate,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A case already followed only takes the accord when it's new to it,
+	// so its other subscribers get notified of the change
+	if data.LastAccord.Valid && data.LastAccordDate.Valid {
+		queryBatch := pgx.Batch{}
+
+		if err := queueAccord(&queryBatch, alertDoc(data), &CaseUpdates{}); err != nil {
+			return nil, err
+		}
+
+		if err := tx.SendBatch(ctx, &queryBatch).Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := tx.Exec(
+		ctx,
+		"INSERT INTO subscriptions (id, user_id, case_ref, alias, notes, active, auto_report, frequency) SELECT $1, $2, id, $3, $4, $5, $6, $7 FROM cases WHERE case_id = $8 AND nature_code = $9",
+		id,
+		data.UserId,
 		data.Alias,
-		data.Nature,
+		data.Notes,
+		data.Active,
+		data.AutoReport,
+		ParseFrequency(string(data.Frequency)),
+		data.CaseId,
+		data.NatureCode,
 	)
 
 	if err != nil {
@@ -296,184 +389,240 @@ func CreateAlertWithData(data *Alert) (*Alert, error) {
 		return nil, errors.New("No se creó la alerta")
 	}
 
-	data.Id = id.String()
-	return data, nil
-}
+	row, err := tx.Query(
+		ctx,
+		"SELECT "+alertColumns+" FROM "+alertTables+" WHERE s.id = $1",
+		id,
+	)
 
-func CreateAlert(userId string, caseId string, natureCode string) (*Alert, error) {
-	conn, err := GetPool()
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Release()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
 
-	var alert Alert
-	id, err := uuid.NewV7()
+	alert, err := pgx.CollectExactlyOneRow[Alert](row, pgx.RowToStructByName[Alert])
 
 	if err != nil {
 		return nil, err
 	}
 
-	row, err := conn.Query(
-		ctx,
-		"INSERT INTO alerts (id, user_id, case_id, nature_code, active) VALUES ($1, $2, $3, $4, $5) RETURNING *",
-		id,
-		userId,
-		caseId,
-		natureCode,
-		true,
-	)
+	hookData, err := AlertWebhookData(&alert)
 
 	if err != nil {
 		return nil, err
 	}
 
-	alert, err = pgx.CollectExactlyOneRow[Alert](row, pgx.RowToStructByName[Alert])
+	_, err = tx.Exec(ctx, queueWebhookQuery, alert.UserId, WebhookAlertCreated, WebhookAlertCreated+":"+alert.Id, hookData)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &alert, nil
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	*data = alert
+	return data, nil
 }
 
-func UpdateAlertsForCases(caseData []*Doc) (err error, updatedCount int, errs []error) {
-	conn, err := GetPool()
+func CreateAlert(ctx context.Context, userId string, caseId string, natureCode string) (*Alert, error) {
+	return CreateAlertWithData(ctx, &Alert{
+		UserId:     userId,
+		CaseId:     caseId,
+		NatureCode: natureCode,
+		Active:     true,
+		AutoReport: true,
+		Frequency:  DefaultFrequency,
+	})
+}
+
+// UpdateUserSubscription saves the preferences the user has for the alert
+func UpdateUserSubscription(ctx context.Context, id, userId string, alias, notes sql.NullString, autoReport bool, frequency Frequency) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
-		return
+		return err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	var queryBatch pgx.Batch
-
-	for _, c := range caseData {
-		queryBatch.Queue(
-			"UPDATE alerts SET last_checked_at = NOW(), last_accord = $1, last_accord_date = $2, nature = $3 WHERE case_id = $4 AND nature_code = $5",
-			c.Accord,
-			c.AccordDate,
-			c.Nature,
-			c.Case,
-			c.NatureCode,
-		).Exec(func(ct pgconn.CommandTag) error {
-			if ct.RowsAffected() == 0 {
-				errs = append(errs, errors.New(fmt.Sprintf(
-					"No se encontró información nueva para el caso %v+%v",
-					c.Case,
-					c.NatureCode,
-				)))
-			} else {
-				updatedCount++
-			}
-
-			return nil
-		})
-	}
-
-	err = conn.SendBatch(ctx, &queryBatch).Close()
+	res, err := conn.Exec(
+		ctx,
+		"UPDATE subscriptions SET alias = $1, notes = $2, auto_report = $3, frequency = $4 WHERE id = $5 AND user_id = $6",
+		alias,
+		notes,
+		autoReport,
+		frequency,
+		id,
+		userId,
+	)
 
 	if err != nil {
-		fmt.Printf("Batch update error: %v\n", err)
-		return
+		return err
 	}
 
-	return
+	if res.RowsAffected() == 0 {
+		return errors.New("No se encontró la alerta solicitada")
+	}
+
+	return nil
 }
 
-func UpdateAlertAccords(alertsData []*Alert) error {
-	conn, err := GetPool()
+// UpdateAlertsForCases applies the accords found to their cases, every
+// subscriber of a case sees the update. Only the accords that change the
+// state of a case (see Case.IsNewAccord) update it and are recorded as a
+// change, the rest only count as unchanged. Every accord is also added to
+// the history of its case
+func UpdateAlertsForCases(ctx context.Context, caseData []*Doc) (*CaseUpdates, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpBulk)
 	defer cancel()
 
+	updates := CaseUpdates{}
 	queryBatch := pgx.Batch{}
-	var errs []error
 
-	for _, alert := range alertsData {
-		queryBatch.Queue(
-			"UPDATE alerts SET last_updated_at = NOW(), last_checked_at = NOW(), last_accord = $1, last_accord_date = $2, nature = $3 WHERE user_id = $4 AND case_id = $5 AND nature_code = $6",
-			alert.LastAccord.String,
-			alert.LastAccordDate.Time,
-			alert.Nature,
-			alert.UserId,
-			alert.CaseId,
-			alert.NatureCode,
-		).Exec(func(ct pgconn.CommandTag) error {
-			if ct.RowsAffected() == 0 {
-				cK := alert.CaseId + "+" + alert.NatureCode
-				errs = append(errs, errors.New(fmt.Sprintf("No se pudo actualizar alerta para el caso %v", cK)))
-			}
-
-			return nil
-		})
+	for _, doc := range caseData {
+		if err := queueAccord(&queryBatch, doc, &updates); err != nil {
+			return nil, err
+		}
 	}
 
 	err = conn.SendBatch(ctx, &queryBatch).Close()
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &updates, nil
+}
+
+// UpdateAlertAccords applies the accords of the alerts of a user to their
+// cases like UpdateAlertsForCases does. Alerts without an accord are skipped
+func UpdateAlertAccords(ctx context.Context, alertsData []*Alert) error {
+	docs := []*Doc{}
+
+	for _, alert := range alertsData {
+		if alert.LastAccord.Valid && alert.LastAccordDate.Valid {
+			docs = append(docs, alertDoc(alert))
+		}
+	}
+
+	_, err := UpdateAlertsForCases(ctx, docs)
+
+	return err
 }
 
-func UpdateAlertAccord(userId, caseId, natureCode string, updatedAlert *Alert) error {
-	conn, err := GetPool()
+// UpdateAlertAccord applies the accord in updatedAlert to the case the
+// user follows, like UpdateAlertsForCases does
+func UpdateAlertAccord(ctx context.Context, userId, caseId, natureCode string, updatedAlert *Alert) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	res, err := conn.Exec(
+	var following bool
+
+	err = conn.QueryRow(
 		ctx,
-		"UPDATE alerts SET last_accord = $1, last_updated_at = $2, last_checked_at = $3, nature = $4 WHERE user_id = $5 AND case_id = $6 AND nature_code = $7",
-		updatedAlert.LastAccord,
-		updatedAlert.LastUpdatedAt,
-		updatedAlert.LastCheckedAt,
-		updatedAlert.Nature,
+		"SELECT EXISTS (SELECT 1 FROM "+alertTables+" WHERE s.user_id = $1 AND c.case_id = $2 AND c.nature_code = $3)",
 		userId,
 		caseId,
 		natureCode,
-	)
+	).Scan(&following)
 
 	if err != nil {
 		return err
 	}
 
-	rowsAffected := res.RowsAffected()
-
-	if rowsAffected == 0 {
+	if !following {
 		return errors.New("No se encontró la alerta solicitada")
 	}
 
-	return nil
+	if !updatedAlert.LastAccord.Valid || !updatedAlert.LastAccordDate.Valid {
+		return nil
+	}
+
+	doc := alertDoc(updatedAlert)
+	doc.Case = caseId
+	doc.NatureCode = natureCode
+
+	queryBatch := pgx.Batch{}
+
+	if err := queueAccord(&queryBatch, doc, &CaseUpdates{}); err != nil {
+		return err
+	}
+
+	return conn.SendBatch(ctx, &queryBatch).Close()
+}
+
+// alertDoc returns the accord of alert as a doc
+func alertDoc(alert *Alert) *Doc {
+	return &Doc{
+		Case:       alert.CaseId,
+		Nature:     alert.Nature,
+		NatureCode: alert.NatureCode,
+		Accord:     alert.LastAccord.String,
+		AccordDate: alert.LastAccordDate.Time,
+	}
+}
+
+// MarkAlertViewed records that the user saw the accords of the alert,
+// which resets its count of new accords
+func MarkAlertViewed(ctx context.Context, id, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "UPDATE subscriptions SET last_viewed_at = NOW() WHERE id = $1 AND user_id = $2", id, userId)
+
+	return err
 }
 
-func DeleteAlertById(id string) error {
-	conn, err := GetPool()
+// MarkAlertsNotified records that the changes of the alerts with the given
+// ids found until notifiedAt were sent to their users
+func MarkAlertsNotified(ctx context.Context, ids []string, notifiedAt time.Time) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "UPDATE subscriptions SET last_notified_at = $2 WHERE id = ANY($1)", ids, notifiedAt)
+
+	return err
+}
+
+func DeleteAlertById(ctx context.Context, id string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	res, err := conn.Exec(
 		ctx,
-		"DELETE FROM alerts WHERE id = $1",
+		"DELETE FROM subscriptions WHERE id = $1",
 		id,
 	)
 
@@ -488,30 +637,51 @@ func DeleteAlertById(id string) error {
 	return nil
 }
 
-func DeleteUserAlertById(id, userId string) error {
-	conn, err := GetPool()
+func DeleteUserAlertById(ctx context.Context, id, userId string) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	res, err := conn.Exec(
+	tx, err := conn.Begin(ctx)
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	alert := Alert{Id: id, UserId: userId}
+
+	err = tx.QueryRow(
 		ctx,
-		"DELETE FROM alerts WHERE id = $1 AND user_id = $2",
+		"DELETE FROM subscriptions s USING cases c WHERE s.id = $1 AND s.user_id = $2 AND c.id = s.case_ref RETURNING c.case_id, c.nature_code, c.nature",
 		id,
 		userId,
-	)
+	).Scan(&alert.CaseId, &alert.NatureCode, &alert.Nature)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("No se encontro alerta con el id especificado")
+	}
 
 	if err != nil {
 		return err
 	}
 
-	if res.RowsAffected() == 0 {
-		return errors.New("No se encontro alerta con el id especificado")
+	hookData, err := AlertWebhookData(&alert)
+
+	if err != nil {
+		return err
 	}
 
-	return nil
+	_, err = tx.Exec(ctx, queueWebhookQuery, userId, WebhookAlertDeleted, WebhookAlertDeleted+":"+id, hookData)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	CheckpointDone    = "done"
+	CheckpointMissing = "missing"
+	CheckpointFailed  = "failed"
+)
+
+// A BackfillCheckpoint records what the backfill found for the bulletin
+// of a court on a date
+type BackfillCheckpoint struct {
+	NatureCode   string         `json:"natureCode" db:"nature_code"`
+	BulletinDate time.Time      `json:"bulletinDate" db:"bulletin_date"`
+	Status       string         `json:"status" db:"status"`
+	Entries      int            `json:"entries" db:"entries"`
+	Attempts     int            `json:"attempts" db:"attempts"`
+	Error        sql.NullString `json:"error" db:"error"`
+	UpdatedAt    time.Time      `json:"updatedAt" db:"updated_at"`
+}
+
+// Final reports whether the bulletin doesn't need to be fetched again.
+// A missing bulletin is only final once it was checked after its date
+// was over, the courts publish their bulletins during the day
+func (c *BackfillCheckpoint) Final() bool {
+	switch c.Status {
+	case CheckpointDone:
+		return true
+	case CheckpointMissing:
+		return c.UpdatedAt.After(c.BulletinDate.AddDate(0, 0, 1))
+	default:
+		return false
+	}
+}
+
+// Key identifies the bulletin of the checkpoint, see CheckpointKey
+func (c *BackfillCheckpoint) Key() string {
+	return CheckpointKey(c.NatureCode, c.BulletinDate)
+}
+
+func CheckpointKey(natureCode string, date time.Time) string {
+	return natureCode + "+" + date.Format("2006-01-02")
+}
+
+// FindBackfillCheckpoints returns the checkpoints of the given courts
+// between from and to (inclusive) by their Key
+func FindBackfillCheckpoints(ctx context.Context, natureCodes []string, from, to time.Time) (map[string]*BackfillCheckpoint, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpScan)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM backfill_checkpoints WHERE nature_code = ANY($1) AND bulletin_date BETWEEN $2::date AND $3::date",
+		natureCodes,
+		from.Format("2006-01-02"),
+		to.Format("2006-01-02"),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := pgx.CollectRows[*BackfillCheckpoint](rows, pgx.RowToAddrOfStructByName[BackfillCheckpoint])
+
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := map[string]*BackfillCheckpoint{}
+
+	for _, cp := range found {
+		checkpoints[cp.Key()] = cp
+	}
+
+	return checkpoints, nil
+}
+
+// SaveBackfillCheckpoint creates or replaces the checkpoint for the
+// bulletin of cp, counting the attempts made
+func SaveBackfillCheckpoint(ctx context.Context, cp *BackfillCheckpoint) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	row := conn.QueryRow(
+		ctx,
+		`INSERT INTO backfill_checkpoints (nature_code, bulletin_date, status, entries, error)
+		VALUES ($1, $2::date, $3, $4, $5)
+		ON CONFLICT (nature_code, bulletin_date) DO UPDATE SET status = EXCLUDED.status, entries = EXCLUDED.entries,
+		error = EXCLUDED.error, attempts = backfill_checkpoints.attempts + 1, updated_at = NOW()
+		RETURNING attempts, updated_at`,
+		cp.NatureCode,
+		cp.BulletinDate.Format("2006-01-02"),
+		cp.Status,
+		cp.Entries,
+		cp.Error,
+	)
+
+	return row.Scan(&cp.Attempts, &cp.UpdatedAt)
+}
+
+// ArchiveDocs stores the entries of a bulletin as docs, skipping the ones
+// already archived, and returns how many were new. The entries are told
+// apart by their court, date, case and text. The entries of followed cases
+// are added to their history too
+func ArchiveDocs(ctx context.Context, docs []*Doc) (int, error) {
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpBulk)
+	defer cancel()
+
+	batch := pgx.Batch{}
+
+	for _, doc := range docs {
+		batch.Queue(
+			`INSERT INTO docs (id, case_id, nature, nature_code, accord, accord_date, entry_key, search_vector)
+			VALUES ($1, $2, $3, $4, $5, $6, md5($2::text || '|' || $3::text || '|' || $5::text), `+searchVectorExpr+`)
+			ON CONFLICT (nature_code, accord_date, entry_key) WHERE entry_key IS NOT NULL DO NOTHING`,
+			doc.ID,
+			doc.Case,
+			doc.Nature,
+			doc.NatureCode,
+			doc.Accord,
+			doc.AccordDate,
+		)
+
+		err = queueCaseAccord(&batch, doc.Case, doc.NatureCode, doc.Nature, doc.Accord, doc.AccordDate)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	results := conn.SendBatch(ctx, &batch)
+	defer results.Close()
+
+	inserted := 0
+
+	for range docs {
+		tag, err := results.Exec()
+
+		if err != nil {
+			return inserted, err
+		}
+
+		inserted += int(tag.RowsAffected())
+
+		if _, err := results.Exec(); err != nil {
+			return inserted, err
+		}
+	}
+
+	return inserted, results.Close()
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// A Case holds the state fetched from the TSJ for a case in a court.
+// There's only one Case per case number and court no matter how many
+// users follow it, each user follows it through a subscription
+type Case struct {
+	Id             string         `json:"id" db:"id"`
+	CaseId         string         `json:"caseId" db:"case_id"`
+	NatureCode     string         `json:"natureCode" db:"nature_code"`
+	Nature         string         `json:"nature" db:"nature"`
+	LastAccord     sql.NullString `json:"lastAccord" db:"last_accord"`
+	LastAccordDate sql.NullTime   `json:"lastAccordDate" db:"last_accord_date"`
+	LastCheckedAt  time.Time      `json:"lastCheckedAt" db:"last_checked_at"`
+	LastUpdatedAt  time.Time      `json:"lastUpdateAt" db:"last_updated_at"`
+	CreatedAt      time.Time      `json:"createdAt" db:"created_at"`
+	// The most frequent schedule among the active subscriptions of the case
+	Frequency Frequency `json:"frequency" db:"frequency"`
+}
+
+func (c *Case) NextCheck() time.Time {
+	return c.Frequency.NextCheck(c.LastCheckedAt)
+}
+
+func (c *Case) GetCaseKey() string {
+	caseId := strings.TrimSpace(strings.TrimLeft(c.CaseId, "0"))
+	return fmt.Sprintf("%v+%v", caseId, c.NatureCode)
+}
+
+// A CaseAccord is an entry in the history of accords found for a case
+type CaseAccord struct {
+	Id         string    `json:"id" db:"id"`
+	CaseRef    string    `json:"caseRef" db:"case_ref"`
+	Nature     string    `json:"nature" db:"nature"`
+	Accord     string    `json:"accord" db:"accord"`
+	AccordDate time.Time `json:"accordDate" db:"accord_date"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Adds the accord to the history of the case, the history keeps
+// a single entry for the same accord on the same date
+const insertCaseAccordQuery = "INSERT INTO case_accords (id, case_ref, nature, accord, accord_date) SELECT $1, id, $2, $3, $4 FROM cases WHERE case_id = $5 AND nature_code = $6 ON CONFLICT DO NOTHING"
+
+func queueCaseAccord(batch *pgx.Batch, caseId, natureCode, nature, accord string, accordDate time.Time) error {
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	batch.Queue(insertCaseAccordQuery, id, nature, accord, accordDate, caseId, natureCode)
+
+	return nil
+}
+
+// Selects the frequency of the active subscription checked most often,
+// cases without active subscriptions don't produce a row
+const subscribedFrequencyQuery = "SELECT s.frequency FROM subscriptions s WHERE s.case_ref = c.id AND s.active = TRUE ORDER BY " + frequencyRankExpr + " LIMIT 1"
+
+// FindActiveCases returns the cases with at least one active subscription
+// whose last accord is older than searchDate
+func FindActiveCases(ctx context.Context, searchDate time.Time) ([]*Case, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpScan)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT c.*, f.frequency FROM cases c JOIN LATERAL ("+subscribedFrequencyQuery+") f ON TRUE WHERE (c.last_accord_date < $1 OR c.last_accord_date IS NULL) ORDER BY c.nature_code, c.case_id DESC",
+		searchDate,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*Case](rows, pgx.RowToAddrOfStructByName[Case])
+}
+
+// FindDueCases works like FindActiveCases but only returns the cases
+// whose schedule says they must be checked at now
+func FindDueCases(ctx context.Context, now time.Time) ([]*Case, error) {
+	cases, err := FindActiveCases(ctx, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	due := []*Case{}
+
+	for _, c := range cases {
+		if c.Frequency.IsDue(c.LastCheckedAt, now) {
+			due = append(due, c)
+		}
+	}
+
+	return due, nil
+}
+
+// MarkCasesChecked sets the check time of the cases with the given ids
+// so the schedule moves forward even when no new accords were found
+func MarkCasesChecked(ctx context.Context, ids []string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(
+		ctx,
+		"UPDATE cases SET last_checked_at = NOW() WHERE id = ANY($1)",
+		ids,
+	)
+
+	return err
+}
+
+// FindCaseAccords returns the latest accords registered for the case,
+// newest first
+func FindCaseAccords(ctx context.Context, caseRef string, limit int) ([]*CaseAccord, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM case_accords WHERE case_ref = $1 ORDER BY accord_date DESC, created_at DESC LIMIT $2",
+		caseRef,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*CaseAccord](rows, pgx.RowToAddrOfStructByName[CaseAccord])
+}
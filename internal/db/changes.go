@@ -0,0 +1,175 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// An AccordChange records a new accord found for a case
+// along with the state the case had before it
+type AccordChange struct {
+	Id                 string         `json:"id" db:"id"`
+	CaseRef            string         `json:"caseRef" db:"case_ref"`
+	CaseId             string         `json:"caseId" db:"case_id"`
+	NatureCode         string         `json:"natureCode" db:"nature_code"`
+	Nature             string         `json:"nature" db:"nature"`
+	Accord             string         `json:"accord" db:"accord"`
+	AccordDate         time.Time      `json:"accordDate" db:"accord_date"`
+	PreviousAccord     sql.NullString `json:"previousAccord" db:"previous_accord"`
+	PreviousAccordDate sql.NullTime   `json:"previousAccordDate" db:"previous_accord_date"`
+	DetectedAt         time.Time      `json:"detectedAt" db:"detected_at"`
+}
+
+// Doc returns the change as the doc it was found in
+func (c *AccordChange) Doc() *Doc {
+	return &Doc{
+		ID:         c.Id,
+		Case:       c.CaseId,
+		Nature:     c.Nature,
+		NatureCode: c.NatureCode,
+		Accord:     c.Accord,
+		AccordDate: c.AccordDate,
+	}
+}
+
+// CaseUpdates tells what happened with the accords found for the cases
+type CaseUpdates struct {
+	// The accords that changed the state of their case
+	Changes []*AccordChange
+	// How many accords were already known, or older than the known one
+	Unchanged int
+	// The keys (case_id+nature_code) of the accords without a followed case
+	Missing []string
+}
+
+// Docs returns the docs of the accords that changed their case
+func (u *CaseUpdates) Docs() []*Doc {
+	docs := []*Doc{}
+
+	for _, change := range u.Changes {
+		docs = append(docs, change.Doc())
+	}
+
+	return docs
+}
+
+// IsNewAccord reports whether an accord found for the case changes its
+// state: it's newer than the known one, or from the same date with a
+// different text. Older accords never replace the known one
+func (c *Case) IsNewAccord(accord string, date time.Time) bool {
+	if !c.LastAccordDate.Valid {
+		return true
+	}
+
+	if date.After(c.LastAccordDate.Time) {
+		return true
+	}
+
+	return date.Equal(c.LastAccordDate.Time) && (!c.LastAccord.Valid || c.LastAccord.String != accord)
+}
+
+// Applies an accord ($1 to $3) to the case $4+$5 following the same rules
+// as Case.IsNewAccord. When the state changes the change is recorded with
+// the id $6, and for every active subscriber except the ones that just
+// subscribed a NotificationAccord is queued in the outbox and a
+// WebhookAccordNew for the endpoints that want it. Returns a row for
+// the case, the change columns are NULL when the state didn't change, and
+// no rows when nobody follows the case
+const applyAccordQuery = `WITH prev AS (
+	SELECT id, last_accord, last_accord_date FROM cases WHERE case_id = $4 AND nature_code = $5 FOR UPDATE
+), changed AS (
+	UPDATE cases c SET last_accord = $1, last_accord_date = $2, nature = $3, last_updated_at = NOW(), last_checked_at = NOW()
+	FROM prev WHERE c.id = prev.id AND (
+		prev.last_accord_date IS NULL OR $2 > prev.last_accord_date OR
+		($2 = prev.last_accord_date AND prev.last_accord IS DISTINCT FROM $1::text)
+	)
+	RETURNING c.id, prev.last_accord, prev.last_accord_date
+), recorded AS (
+	INSERT INTO case_changes (id, case_ref, nature, accord, accord_date, previous_accord, previous_accord_date)
+	SELECT $6, id, $3, $1, $2, last_accord, last_accord_date FROM changed
+	RETURNING id, case_ref, detected_at, previous_accord, previous_accord_date
+), subscribers AS (
+	SELECT s.id, s.user_id, recorded.id AS change_id, jsonb_build_object(
+		'id', recorded.id, 'caseRef', recorded.case_ref, 'caseId', $4::text, 'natureCode', $5::text,
+		'nature', $3::text, 'accord', $1::text, 'accordDate', $2::timestamptz, 'detectedAt', recorded.detected_at
+	) AS change
+	FROM recorded JOIN subscriptions s ON s.case_ref = recorded.case_ref
+	WHERE s.active = TRUE AND s.created_at < recorded.detected_at
+), queued AS (
+	INSERT INTO notification_outbox (id, user_id, event, idempotency_key, payload)
+	SELECT gen_random_uuid(), user_id, 'accord', 'accord:' || change_id || ':' || user_id,
+		jsonb_build_object('alertId', id, 'change', change)
+	FROM subscribers
+	ON CONFLICT (idempotency_key) DO NOTHING
+), hooked AS (
+	INSERT INTO webhook_deliveries (id, endpoint_id, event, event_id, payload)
+	SELECT gen_random_uuid(), e.id, 'accord.new', 'accord.new:' || s.change_id || ':' || s.user_id,
+		jsonb_build_object('alertId', s.id, 'userId', s.user_id, 'change', s.change)
+	FROM subscribers s JOIN webhook_endpoints e ON (e.user_id = s.user_id OR e.user_id IS NULL)
+	WHERE cardinality(e.events) = 0 OR 'accord.new' = ANY(e.events)
+	ON CONFLICT (endpoint_id, event_id) DO NOTHING
+)
+SELECT prev.id::text, recorded.id::text, recorded.detected_at, recorded.previous_accord, recorded.previous_accord_date
+FROM prev LEFT JOIN recorded ON recorded.case_ref = prev.id`
+
+// queueAccord queues in batch the queries that apply doc to its case and
+// add it to the history of the case. The outcome is added to updates
+// once the batch is sent
+func queueAccord(batch *pgx.Batch, doc *Doc, updates *CaseUpdates) error {
+	changeId, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	batch.Queue(
+		applyAccordQuery,
+		doc.Accord,
+		doc.AccordDate,
+		doc.Nature,
+		doc.Case,
+		doc.NatureCode,
+		changeId,
+	).QueryRow(func(row pgx.Row) error {
+		change := AccordChange{
+			CaseId:     doc.Case,
+			NatureCode: doc.NatureCode,
+			Nature:     doc.Nature,
+			Accord:     doc.Accord,
+			AccordDate: doc.AccordDate,
+		}
+		var (
+			id         sql.NullString
+			detectedAt sql.NullTime
+		)
+
+		err := row.Scan(&change.CaseRef, &id, &detectedAt, &change.PreviousAccord, &change.PreviousAccordDate)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			updates.Missing = append(updates.Missing, fmt.Sprintf("%v+%v", doc.Case, doc.NatureCode))
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !id.Valid {
+			updates.Unchanged++
+			return nil
+		}
+
+		change.Id = id.String
+		change.DetectedAt = detectedAt.Time
+		updates.Changes = append(updates.Changes, &change)
+
+		return nil
+	})
+
+	return queueCaseAccord(batch, doc.Case, doc.NatureCode, doc.Nature, doc.Accord, doc.AccordDate)
+}
@@ -20,8 +20,10 @@ func Connect() (*pgxpool.Pool, error) {
 	return DB, nil
 }
 
-func GetPool() (*pgxpool.Conn, error) {
-	conn, err := DB.Acquire(context.Background())
+// GetPool acquires a connection from the pool, waiting at most
+// until ctx is done
+func GetPool(ctx context.Context) (*pgxpool.Conn, error) {
+	conn, err := DB.Acquire(ctx)
 
 	if err != nil {
 		return nil, err
@@ -31,7 +33,7 @@ func GetPool() (*pgxpool.Conn, error) {
 }
 
 func GetTxAndPool(ctx context.Context) (pgx.Tx, *pgxpool.Conn, error) {
-	conn, err := DB.Acquire(context.Background())
+	conn, err := DB.Acquire(ctx)
 
 	if err != nil {
 		return nil, nil, err
@@ -39,6 +41,7 @@ func GetTxAndPool(ctx context.Context) (pgx.Tx, *pgxpool.Conn, error) {
 
 	tx, err := conn.Begin(ctx)
 	if err != nil {
+		conn.Release()
 		return nil, nil, err
 	}
 
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal"
+)
+
+// The frequencies a digest can be sent with
+var DigestFrequencies = []Frequency{FrequencyDaily, FrequencyWeekly}
+
+// DigestSettings tells when a user gets the email digest of the new
+// accords in their cases
+type DigestSettings struct {
+	UserId    string    `json:"userId" db:"user_id"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	Frequency Frequency `json:"frequency" db:"frequency"`
+	// The local hour the digest is sent at
+	Hour int `json:"hour" db:"hour"`
+	// The day the weekly digests are sent, 0 is sunday like time.Weekday
+	Weekday   int  `json:"weekday" db:"weekday"`
+	AttachPdf bool `json:"attachPdf" db:"attach_pdf"`
+	// The changes detected until here were already in a digest
+	LastSentAt time.Time `json:"lastSentAt" db:"last_sent_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// Next returns the first time after after the digest is scheduled for
+func (s *DigestSettings) Next(after time.Time) time.Time {
+	after = after.In(time.Local)
+	y, m, d := after.Date()
+	next := time.Date(y, m, d, s.Hour, 0, 0, 0, time.Local)
+
+	for !next.After(after) || (s.Frequency == FrequencyWeekly && int(next.Weekday()) != s.Weekday) {
+		d++
+		next = time.Date(y, m, d, s.Hour, 0, 0, 0, time.Local)
+	}
+
+	return next
+}
+
+// IsDue reports whether the digest must be sent at now
+func (s *DigestSettings) IsDue(now time.Time) bool {
+	return s.Enabled && !s.Next(s.LastSentAt).After(now)
+}
+
+// A DigestChange is a change in a case followed by the user of a digest
+type DigestChange struct {
+	AccordChange
+	AlertId string         `json:"alertId" db:"alert_id"`
+	Alias   sql.NullString `json:"alias" db:"alias"`
+}
+
+// A Digest lists the new accords of the cases of a user grouped by court
+type Digest struct {
+	Since  time.Time      `json:"since"`
+	Until  time.Time      `json:"until"`
+	Courts []*DigestCourt `json:"courts"`
+	// How many accords there are in all the courts
+	Accords int `json:"accords"`
+}
+
+type DigestCourt struct {
+	NatureCode string        `json:"natureCode"`
+	Court      string        `json:"court"`
+	Cases      []*DigestCase `json:"cases"`
+}
+
+type DigestCase struct {
+	AlertId string `json:"alertId"`
+	CaseId  string `json:"caseId"`
+	Alias   string `json:"alias"`
+	// Where the case can be seen in the site
+	Link    string          `json:"link"`
+	Accords []*AccordChange `json:"accords"`
+}
+
+// NewDigest groups changes by court, then by case. The courts are sorted
+// by name and the accords of every case from the oldest
+func NewDigest(changes []*DigestChange, since, until time.Time) *Digest {
+	digest := Digest{Since: since, Until: until, Courts: []*DigestCourt{}}
+	courts := map[string]*DigestCourt{}
+	cases := map[string]*DigestCase{}
+
+	for _, ch := range changes {
+		court, ok := courts[ch.NatureCode]
+
+		if !ok {
+			court = &DigestCourt{NatureCode: ch.NatureCode, Court: internal.CodesMap[ch.NatureCode]}
+			courts[ch.NatureCode] = court
+			digest.Courts = append(digest.Courts, court)
+		}
+
+		c, ok := cases[ch.AlertId]
+
+		if !ok {
+			c = &DigestCase{AlertId: ch.AlertId, CaseId: ch.CaseId, Alias: ch.Alias.String}
+			cases[ch.AlertId] = c
+			court.Cases = append(court.Cases, c)
+		}
+
+		change := ch.AccordChange
+		c.Accords = append(c.Accords, &change)
+		digest.Accords++
+	}
+
+	sort.SliceStable(digest.Courts, func(i, j int) bool {
+		return digest.Courts[i].Court < digest.Courts[j].Court
+	})
+
+	for _, court := range digest.Courts {
+		for _, c := range court.Cases {
+			sort.SliceStable(c.Accords, func(i, j int) bool {
+				return c.Accords[i].DetectedAt.Before(c.Accords[j].DetectedAt)
+			})
+		}
+	}
+
+	return &digest
+}
+
+// CaseCount returns how many cases have new accords in the digest
+func (d *Digest) CaseCount() int {
+	count := 0
+
+	for _, court := range d.Courts {
+		count += len(court.Cases)
+	}
+
+	return count
+}
+
+// FindDigestSettings returns the settings of the user, pgx.ErrNoRows when
+// they never enabled the digest
+func FindDigestSettings(ctx context.Context, userId string) (*DigestSettings, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT * FROM digest_settings WHERE user_id = $1", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow[*DigestSettings](rows, pgx.RowToAddrOfStructByName[DigestSettings])
+}
+
+// SaveDigestSettings creates or replaces the settings of the user. A new
+// digest covers the changes detected from the moment it's saved
+func SaveDigestSettings(ctx context.Context, settings *DigestSettings) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	row := conn.QueryRow(
+		ctx,
+		`INSERT INTO digest_settings (user_id, enabled, frequency, hour, weekday, attach_pdf) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, frequency = EXCLUDED.frequency, hour = EXCLUDED.hour,
+		weekday = EXCLUDED.weekday, attach_pdf = EXCLUDED.attach_pdf, updated_at = NOW()
+		RETURNING last_sent_at, updated_at`,
+		settings.UserId,
+		settings.Enabled,
+		settings.Frequency,
+		settings.Hour,
+		settings.Weekday,
+		settings.AttachPdf,
+	)
+
+	return row.Scan(&settings.LastSentAt, &settings.UpdatedAt)
+}
+
+// FindEnabledDigests returns the settings of every user with the digest on
+func FindEnabledDigests(ctx context.Context) ([]*DigestSettings, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT * FROM digest_settings WHERE enabled = TRUE ORDER BY user_id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*DigestSettings](rows, pgx.RowToAddrOfStructByName[DigestSettings])
+}
+
+// FindDigestChanges returns the changes in the active alerts of the user
+// detected after since, or after the alert was created, until until.
+// They are ordered by court, case and detection
+func FindDigestChanges(ctx context.Context, userId string, since, until time.Time) ([]*DigestChange, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT ch.id, ch.case_ref, c.case_id, c.nature_code, ch.nature, ch.accord, ch.accord_date, ch.previous_accord,
+			ch.previous_accord_date, ch.detected_at, s.id::text AS alert_id, s.alias
+		FROM subscriptions s JOIN cases c ON c.id = s.case_ref JOIN case_changes ch ON ch.case_ref = s.case_ref
+		WHERE s.user_id = $1 AND s.active = TRUE AND ch.detected_at > GREATEST($2::timestamptz, s.created_at) AND ch.detected_at <= $3
+		ORDER BY c.nature_code, c.case_id, ch.detected_at`,
+		userId,
+		since,
+		until,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*DigestChange](rows, pgx.RowToAddrOfStructByName[DigestChange])
+}
+
+// MarkDigestSent records that the changes detected until sentAt were
+// already in a digest of the user
+func MarkDigestSent(ctx context.Context, userId string, sentAt time.Time) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "UPDATE digest_settings SET last_sent_at = $2 WHERE user_id = $1", userId, sentAt)
+
+	return err
+}
@@ -19,22 +19,29 @@ type Doc struct {
 func FetchDocForCase(caseID string) {
 }
 
-func GetDocs() ([]Doc, error) {
-	conn, err := GetPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+// GetDocs returns the docs of the cases the user has alerts on
+func GetDocs(ctx context.Context, userId string) ([]Doc, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
 
-	rows, err := conn.Query(ctx, "SELECT * FROM docs")
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT d.id, d.case_id, d.nature, d.nature_code, d.accord, d.accord_date FROM docs d WHERE EXISTS (SELECT 1 FROM "+alertTables+" WHERE s.user_id = $1 AND c.case_id = d.case_id AND c.nature_code = d.nature_code)",
+		userId,
+	)
 	docs := []Doc{}
 
 	if err != nil {
 		fmt.Println("Query Err")
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		doc := Doc{}
@@ -54,23 +61,24 @@ func GetDocs() ([]Doc, error) {
 		docs = append(docs, doc)
 	}
 
-	return docs, err
+	return docs, rows.Err()
 }
 
-func GetDocByID(id string) (*Doc, error) {
-	conn, err := GetPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func GetDocByID(ctx context.Context, id string) (*Doc, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
 
 	doc := Doc{}
 
-	row := conn.QueryRow(ctx, "SELECT * FROM docs WHERE id = $1", id)
+	row := conn.QueryRow(ctx, "SELECT id, case_id, nature, nature_code, accord, accord_date FROM docs WHERE id = $1", id)
 
-	err = row.Scan(&doc.ID, &doc.Nature, &doc.NatureCode, &doc.Case, &doc.Accord, &doc.AccordDate)
+	err = row.Scan(&doc.ID, &doc.Case, &doc.Nature, &doc.NatureCode, &doc.Accord, &doc.AccordDate)
 
 	if err != nil {
 		return nil, err
@@ -79,20 +87,21 @@ func GetDocByID(id string) (*Doc, error) {
 	return &doc, nil
 }
 
-func GetDocByCase(caseID string) (*Doc, error) {
-	conn, err := GetPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func GetDocByCase(ctx context.Context, caseID string) (*Doc, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
 
 	doc := Doc{}
 
-	row := conn.QueryRow(ctx, "SELECT * FROM docs WHERE case_id = $1", caseID)
+	row := conn.QueryRow(ctx, "SELECT id, case_id, nature, nature_code, accord, accord_date FROM docs WHERE case_id = $1", caseID)
 
-	err = row.Scan(&doc.ID, &doc.Nature, &doc.NatureCode, &doc.Case, &doc.Accord, &doc.AccordDate)
+	err = row.Scan(&doc.ID, &doc.Case, &doc.Nature, &doc.NatureCode, &doc.Accord, &doc.AccordDate)
 
 	if err != nil {
 		return nil, err
@@ -101,18 +110,19 @@ func GetDocByCase(caseID string) (*Doc, error) {
 	return &doc, nil
 }
 
-func CreateDoc(id, case_id, nature, natureCode, accord string, date time.Time) error {
-	conn, err := GetPool()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func CreateDoc(ctx context.Context, id, case_id, nature, natureCode, accord string, date time.Time) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return err
 	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
 
 	_, err = conn.Exec(
 		ctx,
-		"INSERT INTO docs (id, case_id, nature, nature_code, accord, accord_date) VALUES ($1, $2, $3, $4, $5, $6)",
+		"INSERT INTO docs (id, case_id, nature, nature_code, accord, accord_date, search_vector) VALUES ($1, $2, $3, $4, $5, $6, "+searchVectorExpr+")",
 		id,
 		case_id,
 		nature,
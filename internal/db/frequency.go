@@ -0,0 +1,77 @@
+package db
+
+import (
+	"time"
+)
+
+// Frequency is how often the TSJ is checked for new accords of a case
+type Frequency string
+
+const (
+	// Every hour of the days bulletins are published (monday to friday)
+	FrequencyHourly Frequency = "hourly"
+	FrequencyDaily  Frequency = "daily"
+	FrequencyWeekly Frequency = "weekly"
+
+	DefaultFrequency = FrequencyDaily
+)
+
+var Frequencies = []Frequency{FrequencyHourly, FrequencyDaily, FrequencyWeekly}
+
+var frequencyLabels = map[Frequency]string{
+	FrequencyHourly: "Cada hora en días hábiles",
+	FrequencyDaily:  "Diario",
+	FrequencyWeekly: "Semanal",
+}
+
+// ParseFrequency returns the Frequency named by s or DefaultFrequency
+// when s is not a known frequency
+func ParseFrequency(s string) Frequency {
+	f := Frequency(s)
+
+	if _, ok := frequencyLabels[f]; ok {
+		return f
+	}
+
+	return DefaultFrequency
+}
+
+func (f Frequency) Label() string {
+	if label, ok := frequencyLabels[f]; ok {
+		return label
+	}
+
+	return frequencyLabels[DefaultFrequency]
+}
+
+// NextCheck returns when a case last checked at lastChecked is due again
+func (f Frequency) NextCheck(lastChecked time.Time) time.Time {
+	switch f {
+	case FrequencyHourly:
+		next := lastChecked.Add(time.Hour)
+
+		for !isPublicationDay(next) {
+			y, m, d := next.Date()
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, next.Location())
+		}
+
+		return next
+	case FrequencyWeekly:
+		return lastChecked.AddDate(0, 0, 7)
+	default:
+		return lastChecked.AddDate(0, 0, 1)
+	}
+}
+
+// IsDue reports whether a case last checked at lastChecked must be checked at now
+func (f Frequency) IsDue(lastChecked, now time.Time) bool {
+	return !f.NextCheck(lastChecked).After(now)
+}
+
+func isPublicationDay(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// The rank of each frequency in SQL, lower ranks are checked more often.
+// Used to pick the most frequent schedule among the subscribers of a case
+const frequencyRankExpr = "CASE s.frequency WHEN 'hourly' THEN 0 WHEN 'daily' THEN 1 ELSE 2 END"
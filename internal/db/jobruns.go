@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// Who started a job run
+const (
+	TriggerScheduler = "scheduler"
+	TriggerCommand   = "command"
+	TriggerAdmin     = "admin"
+)
+
+// A JobRun records a single run of a job and what it found
+type JobRun struct {
+	Id               string            `json:"id" db:"id"`
+	Job              string            `json:"job" db:"job"`
+	TriggeredBy      string            `json:"triggeredBy" db:"triggered_by"`
+	Status           string            `json:"status" db:"status"`
+	StartedAt        time.Time         `json:"startedAt" db:"started_at"`
+	FinishedAt       sql.NullTime      `json:"finishedAt" db:"finished_at"`
+	DurationMs       int64             `json:"durationMs" db:"duration_ms"`
+	CasesChecked     int               `json:"casesChecked" db:"cases_checked"`
+	CasesFound       int               `json:"casesFound" db:"cases_found"`
+	CasesUpdated     int               `json:"casesUpdated" db:"cases_updated"`
+	CasesUnchanged   int               `json:"casesUnchanged" db:"cases_unchanged"`
+	CasesNotFound    int               `json:"casesNotFound" db:"cases_not_found"`
+	BulletinsFetched int               `json:"bulletinsFetched" db:"bulletins_fetched"`
+	FetchErrors      int               `json:"fetchErrors" db:"fetch_errors"`
+	NotFoundKeys     []string          `json:"notFoundKeys" db:"not_found_keys"`
+	CourtErrors      map[string]string `json:"courtErrors" db:"court_errors"`
+	Error            sql.NullString    `json:"error" db:"error"`
+	// The notifications or webhook events queued, tried, delivered and not
+	// delivered by the jobs that send them
+	ItemsQueued    int `json:"itemsQueued" db:"items_queued"`
+	ItemsProcessed int `json:"itemsProcessed" db:"items_processed"`
+	ItemsSent      int `json:"itemsSent" db:"items_sent"`
+	ItemsFailed    int `json:"itemsFailed" db:"items_failed"`
+}
+
+func (r *JobRun) Duration() time.Duration {
+	return time.Duration(r.DurationMs) * time.Millisecond
+}
+
+// Finish sets the final status of the run from the error it returned
+func (r *JobRun) Finish(err error, now time.Time) {
+	r.FinishedAt.Time = now
+	r.FinishedAt.Valid = true
+	r.DurationMs = now.Sub(r.StartedAt).Milliseconds()
+	r.Status = JobRunSucceeded
+
+	if err != nil {
+		r.Status = JobRunFailed
+		r.Error.String = err.Error()
+		r.Error.Valid = true
+	}
+}
+
+// A JobRunDay sums up the runs of a job during a day
+type JobRunDay struct {
+	Job           string    `json:"job" db:"job"`
+	Day           time.Time `json:"day" db:"day"`
+	Runs          int       `json:"runs" db:"runs"`
+	Failed        int       `json:"failed" db:"failed"`
+	AvgDurationMs int64     `json:"avgDurationMs" db:"avg_duration_ms"`
+	CasesChecked  int       `json:"casesChecked" db:"cases_checked"`
+	CasesUpdated  int       `json:"casesUpdated" db:"cases_updated"`
+	CasesNotFound int       `json:"casesNotFound" db:"cases_not_found"`
+	FetchErrors   int       `json:"fetchErrors" db:"fetch_errors"`
+	ItemsSent     int       `json:"itemsSent" db:"items_sent"`
+	ItemsFailed   int       `json:"itemsFailed" db:"items_failed"`
+}
+
+func (d *JobRunDay) AvgDuration() time.Duration {
+	return time.Duration(d.AvgDurationMs) * time.Millisecond
+}
+
+type JobRunFilter struct {
+	// Only the runs of this job, every job when empty
+	Job string
+	// Only the runs that didn't find the case with this key (see Case.GetCaseKey)
+	NotFoundKey string
+	Limit       int
+}
+
+// CreateJobRun stores run as running, filling its id and start time
+func CreateJobRun(ctx context.Context, run *JobRun) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	run.Id = id.String()
+	run.Status = JobRunRunning
+
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now()
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		"INSERT INTO job_runs (id, job, triggered_by, status, started_at) VALUES ($1, $2, $3, $4, $5)",
+		run.Id,
+		run.Job,
+		run.TriggeredBy,
+		run.Status,
+		run.StartedAt,
+	)
+
+	return err
+}
+
+// FinishJobRun stores the final state of run
+func FinishJobRun(ctx context.Context, run *JobRun) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	notFoundKeys := run.NotFoundKeys
+	if notFoundKeys == nil {
+		notFoundKeys = []string{}
+	}
+
+	courtErrors := run.CourtErrors
+	if courtErrors == nil {
+		courtErrors = map[string]string{}
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		`UPDATE job_runs SET status = $2, finished_at = $3, duration_ms = $4, cases_checked = $5, cases_found = $6,
+		cases_updated = $7, cases_not_found = $8, bulletins_fetched = $9, fetch_errors = $10, not_found_keys = $11,
+		court_errors = $12, error = $13, items_queued = $14, items_processed = $15, items_sent = $16, items_failed = $17,
+		cases_unchanged = $18 WHERE id = $1`,
+		run.Id,
+		run.Status,
+		run.FinishedAt,
+		run.DurationMs,
+		run.CasesChecked,
+		run.CasesFound,
+		run.CasesUpdated,
+		run.CasesNotFound,
+		run.BulletinsFetched,
+		run.FetchErrors,
+		notFoundKeys,
+		courtErrors,
+		run.Error,
+		run.ItemsQueued,
+		run.ItemsProcessed,
+		run.ItemsSent,
+		run.ItemsFailed,
+		run.CasesUnchanged,
+	)
+
+	return err
+}
+
+// FindJobRuns returns the runs matching filter, newest first
+func FindJobRuns(ctx context.Context, filter JobRunFilter) ([]*JobRun, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM job_runs WHERE ($1 = '' OR job = $1) AND ($2 = '' OR $2 = ANY(not_found_keys)) ORDER BY started_at DESC LIMIT $3",
+		filter.Job,
+		filter.NotFoundKey,
+		filter.Limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*JobRun](rows, pgx.RowToAddrOfStructByName[JobRun])
+}
+
+// FindJobRunDays sums up the runs of every job per day since the given date,
+// newest days first
+func FindJobRunDays(ctx context.Context, since time.Time) ([]*JobRunDay, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpScan)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`SELECT job, date_trunc('day', started_at) AS day, COUNT(*)::int AS runs,
+		COUNT(*) FILTER (WHERE status = 'failed')::int AS failed,
+		COALESCE(AVG(duration_ms) FILTER (WHERE status <> 'running'), 0)::bigint AS avg_duration_ms,
+		SUM(cases_checked)::int AS cases_checked, SUM(cases_updated)::int AS cases_updated,
+		SUM(cases_not_found)::int AS cases_not_found, SUM(fetch_errors)::int AS fetch_errors,
+		SUM(items_sent)::int AS items_sent, SUM(items_failed)::int AS items_failed
+		FROM job_runs WHERE started_at >= $1 GROUP BY job, day ORDER BY day DESC, job`,
+		since,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*JobRunDay](rows, pgx.RowToAddrOfStructByName[JobRunDay])
+}
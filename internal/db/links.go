@@ -0,0 +1,314 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// The case was sent to another court (declined jurisdiction, auxiliary court...)
+	LinkKindTransfer = "transfer"
+	// The case is being reviewed by a Sala in a toca, the link always
+	// goes from the alert of the original case to the alert of the toca
+	LinkKindAppeal = "appeal"
+	// A Sala entry mentions the case a toca was formed from, the link goes
+	// from the alert of the toca to the case in the lower court
+	LinkKindOrigin = "origin"
+)
+
+const (
+	// Detected by the update job, waiting for the user to confirm it
+	LinkStatusSuggested = "suggested"
+	LinkStatusConfirmed = "confirmed"
+	LinkStatusDismissed = "dismissed"
+)
+
+// An AlertLink relates the alert of a case with the alert following the
+// same matter in another court. ToAlertId is only set once the link is
+// confirmed, suggested links keep the court and case number detected
+type AlertLink struct {
+	Id           string         `json:"id" db:"id"`
+	UserId       string         `json:"userId" db:"user_id"`
+	FromAlertId  string         `json:"fromAlertId" db:"from_alert_id"`
+	ToAlertId    sql.NullString `json:"toAlertId" db:"to_alert_id"`
+	Kind         string         `json:"kind" db:"kind"`
+	Status       string         `json:"status" db:"status"`
+	ToCaseId     string         `json:"toCaseId" db:"to_case_id"`
+	ToNatureCode string         `json:"toNatureCode" db:"to_nature_code"`
+	Accord       string         `json:"accord" db:"accord"`
+	AccordDate   time.Time      `json:"accordDate" db:"accord_date"`
+	CreatedAt    time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// A LinkedAlert is the alert on the other end of an AlertLink,
+// as seen from the alert the links were requested for
+type LinkedAlert struct {
+	Link AlertLink
+	// Whether the link points to this alert from another one
+	Incoming bool
+	// nil for links that haven't been confirmed yet
+	Alert *Alert
+}
+
+func (l *LinkedAlert) KindLabel() string {
+	if l.Link.Kind == LinkKindOrigin {
+		if l.Incoming {
+			return "Apelación (toca)"
+		}
+
+		return "Expediente de origen"
+	}
+
+	if l.Link.Kind == LinkKindAppeal {
+		if l.Incoming {
+			return "Expediente de origen"
+		}
+
+		return "Apelación (toca)"
+	}
+
+	if l.Incoming {
+		return "Proviene de"
+	}
+
+	return "Remitido a"
+}
+
+// AppealInstances returns the alert of the original case and the one of
+// its toca when alert is part of a confirmed appeal link. Both are nil
+// when there's none
+func AppealInstances(alert *Alert, links []*LinkedAlert) (first, second *Alert) {
+	for _, l := range links {
+		if (l.Link.Kind != LinkKindAppeal && l.Link.Kind != LinkKindOrigin) || l.Alert == nil {
+			continue
+		}
+
+		// Origin links go the other way around
+		if l.Incoming != (l.Link.Kind == LinkKindOrigin) {
+			return l.Alert, alert
+		}
+
+		return alert, l.Alert
+	}
+
+	return nil, nil
+}
+
+// CreateAlertLink stores link unless the same link was detected before,
+// in which case it returns the stored one and false
+func CreateAlertLink(ctx context.Context, link *AlertLink) (*AlertLink, bool, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	row, err := conn.Query(
+		ctx,
+		"INSERT INTO alert_links (id, user_id, from_alert_id, to_alert_id, kind, status, to_case_id, to_nature_code, accord, accord_date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (from_alert_id, kind, to_nature_code, to_case_id) DO NOTHING RETURNING *",
+		id,
+		link.UserId,
+		link.FromAlertId,
+		link.ToAlertId,
+		link.Kind,
+		link.Status,
+		link.ToCaseId,
+		link.ToNatureCode,
+		link.Accord,
+		link.AccordDate,
+	)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	created, err := pgx.CollectExactlyOneRow[AlertLink](row, pgx.RowToStructByName[AlertLink])
+
+	if err == nil {
+		return &created, true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, err
+	}
+
+	row, err = conn.Query(
+		ctx,
+		"SELECT * FROM alert_links WHERE from_alert_id = $1 AND kind = $2 AND to_nature_code = $3 AND to_case_id = $4",
+		link.FromAlertId,
+		link.Kind,
+		link.ToNatureCode,
+		link.ToCaseId,
+	)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := pgx.CollectExactlyOneRow[AlertLink](row, pgx.RowToStructByName[AlertLink])
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &existing, false, nil
+}
+
+func FindAlertLinkById(ctx context.Context, id, userId string) (*AlertLink, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	row, err := conn.Query(
+		ctx,
+		"SELECT * FROM alert_links WHERE id = $1 AND user_id = $2",
+		id,
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := pgx.CollectExactlyOneRow[AlertLink](row, pgx.RowToStructByName[AlertLink])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// FindLinkedAlerts returns every non dismissed link from or to the alert
+// with the given id along with the alert on the other end of the link
+func FindLinkedAlerts(ctx context.Context, alertId string) ([]*LinkedAlert, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM alert_links WHERE (from_alert_id = $1 OR to_alert_id = $1) AND status <> $2 ORDER BY accord_date",
+		alertId,
+		LinkStatusDismissed,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := pgx.CollectRows[AlertLink](rows, pgx.RowToStructByName[AlertLink])
+
+	if err != nil {
+		return nil, err
+	}
+
+	linked := []*LinkedAlert{}
+
+	for _, link := range links {
+		la := LinkedAlert{
+			Link:     link,
+			Incoming: link.FromAlertId != alertId,
+		}
+
+		otherId := link.ToAlertId.String
+		if la.Incoming {
+			otherId = link.FromAlertId
+		}
+
+		if otherId != "" {
+			la.Alert, err = FindAlertById(ctx, otherId)
+
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return nil, err
+			}
+		}
+
+		linked = append(linked, &la)
+	}
+
+	return linked, nil
+}
+
+func ConfirmAlertLink(ctx context.Context, id, userId, toAlertId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	res, err := conn.Exec(
+		ctx,
+		"UPDATE alert_links SET status = $1, to_alert_id = $2 WHERE id = $3 AND user_id = $4",
+		LinkStatusConfirmed,
+		toAlertId,
+		id,
+		userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errors.New("No se encontró la relación solicitada")
+	}
+
+	return nil
+}
+
+func DismissAlertLink(ctx context.Context, id, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	res, err := conn.Exec(
+		ctx,
+		"UPDATE alert_links SET status = $1 WHERE id = $2 AND user_id = $3",
+		LinkStatusDismissed,
+		id,
+		userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errors.New("No se encontró la relación solicitada")
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+)
+
+// Namespace of the advisory locks taken by name, keeps them apart from
+// the lock taken while migrating
+const namedLockSpace = 7346521
+
+// TryAdvisoryLock tries to take the session advisory lock identified by
+// name without waiting. When it's taken, the connection holding it stays
+// out of the pool until unlock is called. Every process sharing the
+// database sees the lock, so it can be used to run something only once
+// across several app servers
+func TryAdvisoryLock(ctx context.Context, name string) (unlock func(), ok bool, err error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	qCtx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	err = conn.QueryRow(qCtx, "SELECT pg_try_advisory_lock($1, hashtext($2))", namedLockSpace, name).Scan(&ok)
+
+	if err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+
+	unlock = func() {
+		// The lock must be released even when ctx is done
+		uCtx, cancel := WithTimeout(context.WithoutCancel(ctx), OpQuery)
+		defer cancel()
+
+		_, err := conn.Exec(uCtx, "SELECT pg_advisory_unlock($1, hashtext($2))", namedLockSpace, name)
+
+		if err != nil {
+			// Closing the session is the only other way to release it
+			conn.Conn().Close(uCtx)
+		}
+
+		conn.Release()
+	}
+
+	return unlock, true, nil
+}
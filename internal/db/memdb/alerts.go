@@ -0,0 +1,596 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type alertRepo struct {
+	d *DB
+}
+
+// The rank of each frequency, lower ranks are checked more often
+var frequencyRank = map[db.Frequency]int{
+	db.FrequencyHourly: 0,
+	db.FrequencyDaily:  1,
+	db.FrequencyWeekly: 2,
+}
+
+// alertOf joins the subscription with its case, expects the lock to be held
+func (d *DB) alertOf(s *subscription) *db.Alert {
+	c := d.cases[s.CaseRef]
+
+	return &db.Alert{
+		Id:             s.Id,
+		UserId:         s.UserId,
+		CaseRef:        s.CaseRef,
+		CaseId:         c.CaseId,
+		Nature:         c.Nature,
+		NatureCode:     c.NatureCode,
+		Active:         s.Active,
+		Alias:          s.Alias,
+		Notes:          s.Notes,
+		AutoReport:     s.AutoReport,
+		Frequency:      s.Frequency,
+		LastUpdatedAt:  c.LastUpdatedAt,
+		LastCheckedAt:  c.LastCheckedAt,
+		LastAccord:     c.LastAccord,
+		LastAccordDate: c.LastAccordDate,
+		CreatedAt:      s.CreatedAt,
+		LastViewedAt:   s.LastViewedAt,
+		NewAccords:     d.changesSince(c.Id, s.LastViewedAt, s.CreatedAt),
+	}
+}
+
+// changesSince counts the changes of the case detected after since, or
+// after def when since is NULL. Expects the lock to be held
+func (d *DB) changesSince(caseRef string, since sql.NullTime, def time.Time) int {
+	if since.Valid {
+		def = since.Time
+	}
+
+	count := 0
+
+	for _, ch := range d.changes {
+		if ch.CaseRef == caseRef && ch.DetectedAt.After(def) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// applyAccord applies the accord in doc to its case when it's new to the
+// case (see Case.IsNewAccord), recording the change, and adds it to the
+// history of the case. Expects the lock to be held
+func (d *DB) applyAccord(doc *db.Doc, updates *db.CaseUpdates) {
+	c := d.findCase(doc.Case, doc.NatureCode)
+
+	if c == nil {
+		updates.Missing = append(updates.Missing, fmt.Sprintf("%v+%v", doc.Case, doc.NatureCode))
+		return
+	}
+
+	if c.IsNewAccord(doc.Accord, doc.AccordDate) {
+		now := d.now()
+		change := db.AccordChange{
+			Id:                 newId(),
+			CaseRef:            c.Id,
+			CaseId:             doc.Case,
+			NatureCode:         doc.NatureCode,
+			Nature:             doc.Nature,
+			Accord:             doc.Accord,
+			AccordDate:         doc.AccordDate,
+			PreviousAccord:     c.LastAccord,
+			PreviousAccordDate: c.LastAccordDate,
+			DetectedAt:         now,
+		}
+
+		c.LastAccord = sql.NullString{String: doc.Accord, Valid: true}
+		c.LastAccordDate = sql.NullTime{Time: doc.AccordDate, Valid: true}
+		c.Nature = doc.Nature
+		c.LastUpdatedAt = now
+		c.LastCheckedAt = now
+
+		d.changes = append(d.changes, &change)
+		stored := change
+		updates.Changes = append(updates.Changes, &stored)
+		d.queueAccordNotifications(&change)
+		d.queueAccordWebhooks(&change)
+	} else {
+		updates.Unchanged++
+	}
+
+	d.addCaseAccord(c, doc.Nature, doc.Accord, doc.AccordDate)
+}
+
+// accordDoc returns the accord of alert as a doc
+func accordDoc(alert *db.Alert) *db.Doc {
+	return &db.Doc{
+		Case:       alert.CaseId,
+		Nature:     alert.Nature,
+		NatureCode: alert.NatureCode,
+		Accord:     alert.LastAccord.String,
+		AccordDate: alert.LastAccordDate.Time,
+	}
+}
+
+// findCase expects the lock to be held
+func (d *DB) findCase(caseId, natureCode string) *db.Case {
+	for _, c := range d.cases {
+		if c.CaseId == caseId && c.NatureCode == natureCode {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// findAlerts returns the alerts whose subscription and case pass keep,
+// ordered by creation. Expects the lock to be held
+func (d *DB) findAlerts(keep func(s *subscription, c *db.Case) bool) []*db.Alert {
+	alerts := []*db.Alert{}
+
+	for _, s := range d.subscriptions {
+		if keep(s, d.cases[s.CaseRef]) {
+			alerts = append(alerts, d.alertOf(s))
+		}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Id < alerts[j].Id
+	})
+
+	return alerts
+}
+
+// addCaseAccord stores the accord in the history of the case unless it's
+// already there, expects the lock to be held
+func (d *DB) addCaseAccord(c *db.Case, nature, accord string, accordDate time.Time) {
+	for _, a := range d.accords {
+		if a.CaseRef == c.Id && a.AccordDate.Equal(accordDate) && a.Accord == accord {
+			return
+		}
+	}
+
+	d.accords = append(d.accords, &db.CaseAccord{
+		Id:         newId(),
+		CaseRef:    c.Id,
+		Nature:     nature,
+		Accord:     accord,
+		AccordDate: accordDate,
+		CreatedAt:  d.now(),
+	})
+}
+
+func (r alertRepo) FindAlertById(ctx context.Context, id string) (*db.Alert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	s, ok := r.d.subscriptions[id]
+
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	return r.d.alertOf(s), nil
+}
+
+func (r alertRepo) FindAlertsByUser(ctx context.Context, userId string, findActive bool) ([]*db.Alert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	alerts := r.d.findAlerts(func(s *subscription, _ *db.Case) bool {
+		return s.UserId == userId && (!findActive || s.Active)
+	})
+
+	if !findActive {
+		sort.SliceStable(alerts, func(i, j int) bool {
+			a, b := alerts[i].LastAccordDate, alerts[j].LastAccordDate
+
+			if a.Valid != b.Valid {
+				return a.Valid
+			}
+
+			return a.Time.After(b.Time)
+		})
+	}
+
+	return alerts, nil
+}
+
+func (r alertRepo) FindActiveAlertsByCase(ctx context.Context, caseId, natureCode string) ([]*db.Alert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	caseId = db.TrimField(caseId)
+
+	return r.d.findAlerts(func(s *subscription, c *db.Case) bool {
+		return s.Active && c.CaseId == caseId && c.NatureCode == natureCode
+	}), nil
+}
+
+func (r alertRepo) FindUserAlertByCase(ctx context.Context, userId, caseId, natureCode string) (*db.Alert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	caseId = db.TrimField(caseId)
+
+	alerts := r.d.findAlerts(func(s *subscription, c *db.Case) bool {
+		return s.UserId == userId && c.CaseId == caseId && c.NatureCode == natureCode
+	})
+
+	if len(alerts) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return alerts[0], nil
+}
+
+func (r alertRepo) FindAutoReportAlertsForUser(ctx context.Context, userId string) (*[]db.Alert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	alerts := []db.Alert{}
+
+	for _, a := range r.d.findAlerts(func(s *subscription, _ *db.Case) bool {
+		return s.UserId == userId && s.Active && s.AutoReport
+	}) {
+		alerts = append(alerts, *a)
+	}
+
+	return &alerts, nil
+}
+
+func (r alertRepo) FindAutoReportAlertsWithUserData(ctx context.Context, changedOnly bool) ([]*db.AutoReportUser, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	byUser := map[string]*db.AutoReportUser{}
+	users := []*db.AutoReportUser{}
+	changed := map[string]bool{}
+
+	lastChange := map[string]*db.AccordChange{}
+
+	for _, s := range r.d.subscriptions {
+		if !s.Active || !s.AutoReport {
+			continue
+		}
+
+		if r.d.changesSince(s.CaseRef, s.LastNotifiedAt, s.CreatedAt) > 0 {
+			changed[s.UserId] = true
+		}
+
+		for _, ch := range r.d.changes {
+			last := lastChange[s.UserId]
+
+			if ch.CaseRef == s.CaseRef && (last == nil || ch.DetectedAt.After(last.DetectedAt) ||
+				(ch.DetectedAt.Equal(last.DetectedAt) && ch.Id > last.Id)) {
+				lastChange[s.UserId] = ch
+			}
+		}
+	}
+
+	for _, a := range r.d.findAlerts(func(s *subscription, _ *db.Case) bool {
+		return s.Active && s.AutoReport
+	}) {
+		u, ok := r.d.users[a.UserId]
+
+		if !ok || !u.Phone.Valid || (changedOnly && !changed[u.Id]) {
+			continue
+		}
+
+		reportUser, ok := byUser[u.Id]
+
+		if !ok {
+			reportUser = &db.AutoReportUser{
+				Id:       u.Id,
+				Name:     u.Name,
+				Lastname: u.Lastname,
+				Email:    u.Email,
+				Phone:    u.Phone.String,
+			}
+			if ch, ok := lastChange[u.Id]; ok {
+				reportUser.LastChangeId = ch.Id
+			}
+
+			byUser[u.Id] = reportUser
+			users = append(users, reportUser)
+		}
+
+		reportUser.Alerts = append(reportUser.Alerts, db.AutoReportAlert{
+			Id:             a.Id,
+			CaseId:         a.CaseId,
+			NatureCode:     a.NatureCode,
+			LastAccord:     a.LastAccord,
+			LastAccordDate: a.LastAccordDate,
+		})
+	}
+
+	return users, nil
+}
+
+func (r alertRepo) CreateAlertWithData(ctx context.Context, data *db.Alert) (*db.Alert, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	now := r.d.now()
+	c := r.d.findCase(data.CaseId, data.NatureCode)
+
+	if c == nil {
+		c = &db.Case{
+			Id:             newId(),
+			CaseId:         data.CaseId,
+			NatureCode:     data.NatureCode,
+			Nature:         data.Nature,
+			LastAccord:     data.LastAccord,
+			LastAccordDate: data.LastAccordDate,
+			LastCheckedAt:  now,
+			LastUpdatedAt:  now,
+			CreatedAt:      now,
+		}
+	}
+
+	for _, s := range r.d.subscriptions {
+		if s.UserId == data.UserId && s.CaseRef == c.Id {
+			return nil, uniqueViolation("subscriptions_user_id_case_ref_key")
+		}
+	}
+
+	r.d.cases[c.Id] = c
+
+	// A case already followed only takes the accord when it's new to it
+	if data.LastAccord.Valid && data.LastAccordDate.Valid {
+		r.d.applyAccord(accordDoc(data), &db.CaseUpdates{})
+	}
+
+	s := subscription{
+		Id:         newId(),
+		UserId:     data.UserId,
+		CaseRef:    c.Id,
+		Alias:      data.Alias,
+		Notes:      data.Notes,
+		Active:     data.Active,
+		AutoReport: data.AutoReport,
+		Frequency:  db.ParseFrequency(string(data.Frequency)),
+		CreatedAt:  now,
+	}
+	r.d.subscriptions[s.Id] = &s
+
+	*data = *r.d.alertOf(&s)
+	r.d.queueAlertWebhook(db.WebhookAlertCreated, data)
+
+	return data, nil
+}
+
+func (r alertRepo) UpdateUserSubscription(ctx context.Context, id, userId string, alias, notes sql.NullString, autoReport bool, frequency db.Frequency) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	s, ok := r.d.subscriptions[id]
+
+	if !ok || s.UserId != userId {
+		return errors.New("No se encontró la alerta solicitada")
+	}
+
+	s.Alias = alias
+	s.Notes = notes
+	s.AutoReport = autoReport
+	s.Frequency = frequency
+
+	return nil
+}
+
+func (r alertRepo) UpdateAlertsForCases(ctx context.Context, caseData []*db.Doc) (*db.CaseUpdates, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	updates := db.CaseUpdates{}
+
+	for _, doc := range caseData {
+		r.d.applyAccord(doc, &updates)
+	}
+
+	return &updates, nil
+}
+
+// userCase returns the case the user is subscribed to, expects the lock to be held
+func (d *DB) userCase(userId, caseId, natureCode string) *db.Case {
+	for _, s := range d.subscriptions {
+		c := d.cases[s.CaseRef]
+
+		if s.UserId == userId && c.CaseId == caseId && c.NatureCode == natureCode {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func (r alertRepo) UpdateAlertAccords(ctx context.Context, alertsData []*db.Alert) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, alert := range alertsData {
+		if alert.LastAccord.Valid && alert.LastAccordDate.Valid {
+			r.d.applyAccord(accordDoc(alert), &db.CaseUpdates{})
+		}
+	}
+
+	return nil
+}
+
+func (r alertRepo) UpdateAlertAccord(ctx context.Context, userId, caseId, natureCode string, updatedAlert *db.Alert) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if r.d.userCase(userId, caseId, natureCode) == nil {
+		return errors.New("No se encontró la alerta solicitada")
+	}
+
+	if updatedAlert.LastAccord.Valid && updatedAlert.LastAccordDate.Valid {
+		doc := accordDoc(updatedAlert)
+		doc.Case = caseId
+		doc.NatureCode = natureCode
+		r.d.applyAccord(doc, &db.CaseUpdates{})
+	}
+
+	return nil
+}
+
+func (r alertRepo) MarkAlertViewed(ctx context.Context, id, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if s, ok := r.d.subscriptions[id]; ok && s.UserId == userId {
+		s.LastViewedAt = sql.NullTime{Time: r.d.now(), Valid: true}
+	}
+
+	return nil
+}
+
+func (r alertRepo) MarkAlertsNotified(ctx context.Context, ids []string, notifiedAt time.Time) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, id := range ids {
+		if s, ok := r.d.subscriptions[id]; ok {
+			s.LastNotifiedAt = sql.NullTime{Time: notifiedAt, Valid: true}
+		}
+	}
+
+	return nil
+}
+
+func (r alertRepo) DeleteUserAlertById(ctx context.Context, id, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	s, ok := r.d.subscriptions[id]
+
+	if !ok || s.UserId != userId {
+		return errors.New("No se encontro alerta con el id especificado")
+	}
+
+	alert := r.d.alertOf(s)
+	delete(r.d.subscriptions, id)
+	r.d.queueAlertWebhook(db.WebhookAlertDeleted, alert)
+
+	links := r.d.links[:0]
+	for _, l := range r.d.links {
+		if l.FromAlertId != id && l.ToAlertId.String != id {
+			links = append(links, l)
+		}
+	}
+	r.d.links = links
+
+	return nil
+}
+
+func (r alertRepo) FindActiveCases(ctx context.Context, searchDate time.Time) ([]*db.Case, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	frequencies := map[string]db.Frequency{}
+
+	for _, s := range r.d.subscriptions {
+		if !s.Active {
+			continue
+		}
+
+		current, ok := frequencies[s.CaseRef]
+
+		if !ok || frequencyRank[s.Frequency] < frequencyRank[current] {
+			frequencies[s.CaseRef] = s.Frequency
+		}
+	}
+
+	cases := []*db.Case{}
+
+	for ref, frequency := range frequencies {
+		c := *r.d.cases[ref]
+
+		if c.LastAccordDate.Valid && !c.LastAccordDate.Time.Before(searchDate) {
+			continue
+		}
+
+		c.Frequency = frequency
+		cases = append(cases, &c)
+	}
+
+	sort.Slice(cases, func(i, j int) bool {
+		if cases[i].NatureCode != cases[j].NatureCode {
+			return cases[i].NatureCode < cases[j].NatureCode
+		}
+
+		return cases[i].CaseId > cases[j].CaseId
+	})
+
+	return cases, nil
+}
+
+func (r alertRepo) FindDueCases(ctx context.Context, now time.Time) ([]*db.Case, error) {
+	cases, err := r.FindActiveCases(ctx, now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	due := []*db.Case{}
+
+	for _, c := range cases {
+		if c.Frequency.IsDue(c.LastCheckedAt, now) {
+			due = append(due, c)
+		}
+	}
+
+	return due, nil
+}
+
+func (r alertRepo) MarkCasesChecked(ctx context.Context, ids []string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, id := range ids {
+		if c, ok := r.d.cases[id]; ok {
+			c.LastCheckedAt = r.d.now()
+		}
+	}
+
+	return nil
+}
+
+func (r alertRepo) FindCaseAccords(ctx context.Context, caseRef string, limit int) ([]*db.CaseAccord, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	accords := []*db.CaseAccord{}
+
+	for _, a := range r.d.accords {
+		if a.CaseRef == caseRef {
+			accord := *a
+			accords = append(accords, &accord)
+		}
+	}
+
+	sort.SliceStable(accords, func(i, j int) bool {
+		if !accords[i].AccordDate.Equal(accords[j].AccordDate) {
+			return accords[i].AccordDate.After(accords[j].AccordDate)
+		}
+
+		return accords[i].CreatedAt.After(accords[j].CreatedAt)
+	})
+
+	if len(accords) > limit {
+		accords = accords[:limit]
+	}
+
+	return accords, nil
+}
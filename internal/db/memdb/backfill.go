@@ -0,0 +1,80 @@
+package memdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type backfillRepo struct {
+	d *DB
+}
+
+func (r backfillRepo) FindBackfillCheckpoints(ctx context.Context, natureCodes []string, from, to time.Time) (map[string]*db.BackfillCheckpoint, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	checkpoints := map[string]*db.BackfillCheckpoint{}
+	fromDay, toDay := from.Format("2006-01-02"), to.Format("2006-01-02")
+
+	for _, code := range natureCodes {
+		for key, cp := range r.d.checkpoints {
+			day := cp.BulletinDate.Format("2006-01-02")
+
+			if cp.NatureCode != code || day < fromDay || day > toDay {
+				continue
+			}
+
+			found := *cp
+			checkpoints[key] = &found
+		}
+	}
+
+	return checkpoints, nil
+}
+
+func (r backfillRepo) SaveBackfillCheckpoint(ctx context.Context, cp *db.BackfillCheckpoint) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	cp.Attempts = 1
+	cp.UpdatedAt = r.d.now()
+
+	if stored, ok := r.d.checkpoints[cp.Key()]; ok {
+		cp.Attempts = stored.Attempts + 1
+	}
+
+	stored := *cp
+	r.d.checkpoints[cp.Key()] = &stored
+
+	return nil
+}
+
+func (r backfillRepo) ArchiveDocs(ctx context.Context, docs []*db.Doc) (int, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	inserted := 0
+
+	for _, doc := range docs {
+		key := doc.NatureCode + "|" + doc.AccordDate.Format(time.RFC3339) + "|" + doc.Case + "|" + doc.Nature + "|" + doc.Accord
+
+		if r.d.entryKeys[key] {
+			continue
+		}
+
+		r.d.entryKeys[key] = true
+		stored := *doc
+		r.d.docs = append(r.d.docs, &stored)
+		inserted++
+
+		for _, c := range r.d.cases {
+			if c.CaseId == doc.Case && c.NatureCode == doc.NatureCode {
+				r.d.addCaseAccord(c, doc.Nature, doc.Accord, doc.AccordDate)
+			}
+		}
+	}
+
+	return inserted, nil
+}
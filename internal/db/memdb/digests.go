@@ -0,0 +1,125 @@
+package memdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type digestRepo struct {
+	d *DB
+}
+
+func (r digestRepo) FindDigestSettings(ctx context.Context, userId string) (*db.DigestSettings, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	stored, ok := r.d.digests[userId]
+
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	settings := *stored
+	return &settings, nil
+}
+
+func (r digestRepo) SaveDigestSettings(ctx context.Context, settings *db.DigestSettings) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	now := r.d.now()
+	settings.LastSentAt = now
+
+	if stored, ok := r.d.digests[settings.UserId]; ok {
+		settings.LastSentAt = stored.LastSentAt
+	}
+
+	settings.UpdatedAt = now
+
+	stored := *settings
+	r.d.digests[settings.UserId] = &stored
+
+	return nil
+}
+
+func (r digestRepo) FindEnabledDigests(ctx context.Context) ([]*db.DigestSettings, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	found := []*db.DigestSettings{}
+
+	for _, stored := range r.d.digests {
+		if stored.Enabled {
+			settings := *stored
+			found = append(found, &settings)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].UserId < found[j].UserId
+	})
+
+	return found, nil
+}
+
+func (r digestRepo) FindDigestChanges(ctx context.Context, userId string, since, until time.Time) ([]*db.DigestChange, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	changes := []*db.DigestChange{}
+
+	for _, s := range r.d.subscriptions {
+		if s.UserId != userId || !s.Active {
+			continue
+		}
+
+		after := since
+		if s.CreatedAt.After(after) {
+			after = s.CreatedAt
+		}
+
+		for _, ch := range r.d.changes {
+			if ch.CaseRef != s.CaseRef || !ch.DetectedAt.After(after) || ch.DetectedAt.After(until) {
+				continue
+			}
+
+			change := db.DigestChange{AccordChange: *ch, AlertId: s.Id, Alias: s.Alias}
+			c := r.d.cases[s.CaseRef]
+			change.CaseId = c.CaseId
+			change.NatureCode = c.NatureCode
+
+			changes = append(changes, &change)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+
+		if a.NatureCode != b.NatureCode {
+			return a.NatureCode < b.NatureCode
+		}
+
+		if a.CaseId != b.CaseId {
+			return a.CaseId < b.CaseId
+		}
+
+		return a.DetectedAt.Before(b.DetectedAt)
+	})
+
+	return changes, nil
+}
+
+func (r digestRepo) MarkDigestSent(ctx context.Context, userId string, sentAt time.Time) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if stored, ok := r.d.digests[userId]; ok {
+		stored.LastSentAt = sentAt
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package memdb
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type docRepo struct {
+	d *DB
+}
+
+func (r docRepo) GetDocs(ctx context.Context, userId string) ([]db.Doc, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	alerted := map[string]bool{}
+
+	for _, s := range r.d.subscriptions {
+		if c := r.d.cases[s.CaseRef]; s.UserId == userId && c != nil {
+			alerted[c.CaseId+"+"+c.NatureCode] = true
+		}
+	}
+
+	docs := []db.Doc{}
+
+	for _, doc := range r.d.docs {
+		if alerted[doc.Case+"+"+doc.NatureCode] {
+			docs = append(docs, *doc)
+		}
+	}
+
+	return docs, nil
+}
+
+func (r docRepo) GetDocByID(ctx context.Context, id string) (*db.Doc, error) {
+	return r.findDoc(func(doc *db.Doc) bool { return doc.ID == id })
+}
+
+func (r docRepo) GetDocByCase(ctx context.Context, caseID string) (*db.Doc, error) {
+	return r.findDoc(func(doc *db.Doc) bool { return doc.Case == caseID })
+}
+
+func (r docRepo) findDoc(match func(doc *db.Doc) bool) (*db.Doc, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	for _, doc := range r.d.docs {
+		if match(doc) {
+			found := *doc
+			return &found, nil
+		}
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+func (r docRepo) CreateDoc(ctx context.Context, id, caseId, nature, natureCode, accord string, date time.Time) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, doc := range r.d.docs {
+		if doc.ID == id {
+			return uniqueViolation("docs_pkey")
+		}
+	}
+
+	r.d.docs = append(r.d.docs, &db.Doc{
+		ID:         id,
+		Case:       caseId,
+		Nature:     nature,
+		NatureCode: natureCode,
+		Accord:     accord,
+		AccordDate: date,
+	})
+
+	return nil
+}
+
+var queryTermExp = regexp.MustCompile(`-?"[^"]*"|\S+`)
+
+// A searchQuery approximates websearch_to_tsquery: every term or
+// "quoted phrase" must appear and terms prefixed with - must not.
+// There's no stemming, terms are matched as whole normalized words
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+func parseSearchQuery(q string) searchQuery {
+	var sq searchQuery
+
+	for _, term := range queryTermExp.FindAllString(q, -1) {
+		excluded := strings.HasPrefix(term, "-")
+		term = internal.NormalizeText(strings.TrimPrefix(term, "-"))
+
+		if term == "" || term == "or" {
+			continue
+		}
+
+		if excluded {
+			sq.exclude = append(sq.exclude, term)
+		} else {
+			sq.include = append(sq.include, term)
+		}
+	}
+
+	return sq
+}
+
+// rank returns how many times the included terms appear in text and
+// whether text matches the query at all. text must be normalized
+func (sq searchQuery) rank(text string) (int, bool) {
+	text = " " + text + " "
+
+	for _, term := range sq.exclude {
+		if strings.Contains(text, " "+term+" ") {
+			return 0, false
+		}
+	}
+
+	total := 0
+
+	for _, term := range sq.include {
+		count := strings.Count(text, " "+term+" ")
+
+		if count == 0 {
+			return 0, false
+		}
+
+		total += count
+	}
+
+	return total, true
+}
+
+func (r docRepo) SearchDocs(ctx context.Context, params db.DocSearchParams) (*db.DocSearchResult, error) {
+	params.Normalize()
+
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	query := parseSearchQuery(params.Query)
+	nature := internal.NormalizeText(params.Nature)
+
+	type ranked struct {
+		doc  *db.Doc
+		rank int
+	}
+
+	matches := []ranked{}
+
+	for _, doc := range r.d.docs {
+		if len(params.NatureCodes) > 0 && !contains(params.NatureCodes, doc.NatureCode) {
+			continue
+		}
+
+		if nature != "" && !strings.Contains(internal.NormalizeText(doc.Nature), nature) {
+			continue
+		}
+
+		if params.CaseId != "" && doc.Case != params.CaseId {
+			continue
+		}
+
+		if !params.FromDate.IsZero() && doc.AccordDate.Before(params.FromDate) {
+			continue
+		}
+
+		if !params.ToDate.IsZero() && !doc.AccordDate.Before(params.ToDate.AddDate(0, 0, 1)) {
+			continue
+		}
+
+		rank, ok := query.rank(internal.NormalizeText(doc.Nature + " " + doc.Accord))
+
+		if !ok {
+			continue
+		}
+
+		found := *doc
+		matches = append(matches, ranked{&found, rank})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+
+		switch params.Sort {
+		case db.SortRelevance:
+			if a.rank != b.rank {
+				return a.rank > b.rank
+			}
+			return a.doc.AccordDate.After(b.doc.AccordDate)
+		case db.SortDateAsc:
+			if !a.doc.AccordDate.Equal(b.doc.AccordDate) {
+				return a.doc.AccordDate.Before(b.doc.AccordDate)
+			}
+		default:
+			if !a.doc.AccordDate.Equal(b.doc.AccordDate) {
+				return a.doc.AccordDate.After(b.doc.AccordDate)
+			}
+		}
+
+		return a.doc.Case < b.doc.Case
+	})
+
+	result := db.DocSearchResult{
+		Docs:     []*db.Doc{},
+		Total:    len(matches),
+		Page:     params.Page,
+		PageSize: params.PageSize,
+	}
+	result.TotalPages = (result.Total + params.PageSize - 1) / params.PageSize
+
+	start := (params.Page - 1) * params.PageSize
+
+	for i := start; i < len(matches) && i < start+params.PageSize; i++ {
+		result.Docs = append(result.Docs, matches[i].doc)
+	}
+
+	return &result, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
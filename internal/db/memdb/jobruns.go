@@ -0,0 +1,151 @@
+package memdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type jobRunRepo struct {
+	d *DB
+}
+
+func (r jobRunRepo) CreateJobRun(ctx context.Context, run *db.JobRun) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	run.Id = newId()
+	run.Status = db.JobRunRunning
+
+	if run.StartedAt.IsZero() {
+		run.StartedAt = r.d.now()
+	}
+
+	stored := *run
+	r.d.jobRuns = append(r.d.jobRuns, &stored)
+
+	return nil
+}
+
+func (r jobRunRepo) FinishJobRun(ctx context.Context, run *db.JobRun) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for i, stored := range r.d.jobRuns {
+		if stored.Id == run.Id {
+			finished := *run
+			finished.NotFoundKeys = append([]string{}, run.NotFoundKeys...)
+			finished.CourtErrors = map[string]string{}
+
+			for court, msg := range run.CourtErrors {
+				finished.CourtErrors[court] = msg
+			}
+
+			r.d.jobRuns[i] = &finished
+		}
+	}
+
+	return nil
+}
+
+func (r jobRunRepo) FindJobRuns(ctx context.Context, filter db.JobRunFilter) ([]*db.JobRun, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	runs := []*db.JobRun{}
+
+	for _, run := range r.d.jobRuns {
+		if filter.Job != "" && run.Job != filter.Job {
+			continue
+		}
+
+		if filter.NotFoundKey != "" && !contains(run.NotFoundKeys, filter.NotFoundKey) {
+			continue
+		}
+
+		found := *run
+		runs = append(runs, &found)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	if len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+
+	return runs, nil
+}
+
+func (r jobRunRepo) FindJobRunDays(ctx context.Context, since time.Time) ([]*db.JobRunDay, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	type dayKey struct {
+		job string
+		day time.Time
+	}
+
+	byDay := map[dayKey]*db.JobRunDay{}
+	durations := map[dayKey][]int64{}
+	days := []*db.JobRunDay{}
+
+	for _, run := range r.d.jobRuns {
+		if run.StartedAt.Before(since) {
+			continue
+		}
+
+		y, m, d := run.StartedAt.Date()
+		key := dayKey{run.Job, time.Date(y, m, d, 0, 0, 0, 0, run.StartedAt.Location())}
+		day, ok := byDay[key]
+
+		if !ok {
+			day = &db.JobRunDay{Job: key.job, Day: key.day}
+			byDay[key] = day
+			days = append(days, day)
+		}
+
+		day.Runs++
+		day.CasesChecked += run.CasesChecked
+		day.CasesUpdated += run.CasesUpdated
+		day.CasesNotFound += run.CasesNotFound
+		day.FetchErrors += run.FetchErrors
+		day.ItemsSent += run.ItemsSent
+		day.ItemsFailed += run.ItemsFailed
+
+		if run.Status == db.JobRunFailed {
+			day.Failed++
+		}
+
+		if run.Status != db.JobRunRunning {
+			durations[key] = append(durations[key], run.DurationMs)
+		}
+	}
+
+	for key, ds := range durations {
+		var total int64
+
+		for _, d := range ds {
+			total += d
+		}
+
+		byDay[key].AvgDurationMs = total / int64(len(ds))
+	}
+
+	sort.SliceStable(days, func(i, j int) bool {
+		if !days[i].Day.Equal(days[j].Day) {
+			return days[i].Day.After(days[j].Day)
+		}
+
+		return days[i].Job < days[j].Job
+	})
+
+	return days, nil
+}
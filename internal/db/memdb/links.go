@@ -0,0 +1,131 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type linkRepo struct {
+	d *DB
+}
+
+func (r linkRepo) CreateAlertLink(ctx context.Context, link *db.AlertLink) (*db.AlertLink, bool, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, l := range r.d.links {
+		if l.FromAlertId == link.FromAlertId && l.Kind == link.Kind && l.ToNatureCode == link.ToNatureCode && l.ToCaseId == link.ToCaseId {
+			existing := *l
+			return &existing, false, nil
+		}
+	}
+
+	created := *link
+	created.Id = newId()
+	created.CreatedAt = r.d.now()
+
+	stored := created
+	r.d.links = append(r.d.links, &stored)
+
+	return &created, true, nil
+}
+
+// findLink expects the lock to be held
+func (d *DB) findLink(id, userId string) *db.AlertLink {
+	for _, l := range d.links {
+		if l.Id == id && l.UserId == userId {
+			return l
+		}
+	}
+
+	return nil
+}
+
+func (r linkRepo) FindAlertLinkById(ctx context.Context, id, userId string) (*db.AlertLink, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	l := r.d.findLink(id, userId)
+
+	if l == nil {
+		return nil, pgx.ErrNoRows
+	}
+
+	link := *l
+	return &link, nil
+}
+
+func (r linkRepo) FindLinkedAlerts(ctx context.Context, alertId string) ([]*db.LinkedAlert, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	links := []db.AlertLink{}
+
+	for _, l := range r.d.links {
+		if (l.FromAlertId == alertId || l.ToAlertId.String == alertId) && l.Status != db.LinkStatusDismissed {
+			links = append(links, *l)
+		}
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].AccordDate.Before(links[j].AccordDate)
+	})
+
+	linked := []*db.LinkedAlert{}
+
+	for _, link := range links {
+		la := db.LinkedAlert{
+			Link:     link,
+			Incoming: link.FromAlertId != alertId,
+		}
+
+		otherId := link.ToAlertId.String
+		if la.Incoming {
+			otherId = link.FromAlertId
+		}
+
+		if s, ok := r.d.subscriptions[otherId]; ok {
+			la.Alert = r.d.alertOf(s)
+		}
+
+		linked = append(linked, &la)
+	}
+
+	return linked, nil
+}
+
+func (r linkRepo) ConfirmAlertLink(ctx context.Context, id, userId, toAlertId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	l := r.d.findLink(id, userId)
+
+	if l == nil {
+		return errors.New("No se encontró la relación solicitada")
+	}
+
+	l.Status = db.LinkStatusConfirmed
+	l.ToAlertId.String = toAlertId
+	l.ToAlertId.Valid = true
+
+	return nil
+}
+
+func (r linkRepo) DismissAlertLink(ctx context.Context, id, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	l := r.d.findLink(id, userId)
+
+	if l == nil {
+		return errors.New("No se encontró la relación solicitada")
+	}
+
+	l.Status = db.LinkStatusDismissed
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package memdb
+
+import (
+	"context"
+)
+
+type lockRepo struct {
+	d *DB
+}
+
+func (r lockRepo) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if r.d.locks[name] {
+		return nil, false, nil
+	}
+
+	r.d.locks[name] = true
+
+	unlock := func() {
+		r.d.mu.Lock()
+		defer r.d.mu.Unlock()
+
+		delete(r.d.locks, name)
+	}
+
+	return unlock, true, nil
+}
@@ -0,0 +1,133 @@
+// Package memdb implements the repositories of internal/db in memory so
+// handlers and jobs can run without a Postgres server. It reports missing
+// rows and unique violations with the same errors pgx does
+package memdb
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type subscription struct {
+	Id             string
+	UserId         string
+	CaseRef        string
+	Alias          sql.NullString
+	Notes          sql.NullString
+	Active         bool
+	AutoReport     bool
+	Frequency      db.Frequency
+	CreatedAt      time.Time
+	LastViewedAt   sql.NullTime
+	LastNotifiedAt sql.NullTime
+}
+
+// DB holds every table in memory, it's safe for concurrent use
+type DB struct {
+	mu            sync.RWMutex
+	users         map[string]*db.User
+	cases         map[string]*db.Case
+	subscriptions map[string]*subscription
+	accords       []*db.CaseAccord
+	changes       []*db.AccordChange
+	docs          []*db.Doc
+	otlinks       []*db.OTLink
+	watches       []*db.Watch
+	watchMatches  []*db.WatchMatch
+	links         []*db.AlertLink
+	jobRuns       []*db.JobRun
+	checkpoints   map[string]*db.BackfillCheckpoint
+	// The keys of the archived docs, see backfillRepo.ArchiveDocs
+	entryKeys map[string]bool
+	// The notification preferences by user+event+channel
+	preferences map[string]*db.NotificationPreference
+	deliveries  []*db.NotificationDelivery
+	outbox      []*db.OutboxItem
+	digests     map[string]*db.DigestSettings
+	whatsapp    []*db.WhatsAppMessage
+	// The Telegram chats by user
+	telegram          map[string]*db.TelegramChat
+	webhookEndpoints  []*db.WebhookEndpoint
+	webhookDeliveries []*db.WebhookDelivery
+	push              []*db.PushSubscription
+	// When every phone opted out of the SMS
+	smsOptOuts map[string]time.Time
+	locks      map[string]bool
+
+	// Used instead of time.Now when set, so tests can control the clock
+	Now func() time.Time
+}
+
+func NewDB() *DB {
+	return &DB{
+		users:         map[string]*db.User{},
+		cases:         map[string]*db.Case{},
+		subscriptions: map[string]*subscription{},
+		checkpoints:   map[string]*db.BackfillCheckpoint{},
+		entryKeys:     map[string]bool{},
+		preferences:   map[string]*db.NotificationPreference{},
+		digests:       map[string]*db.DigestSettings{},
+		telegram:      map[string]*db.TelegramChat{},
+		smsOptOuts:    map[string]time.Time{},
+		locks:         map[string]bool{},
+	}
+}
+
+// New returns a Store backed by an empty in-memory DB
+func New() *db.Store {
+	return NewDB().Store()
+}
+
+func (d *DB) Store() *db.Store {
+	return &db.Store{
+		Users:         userRepo{d},
+		Alerts:        alertRepo{d},
+		Docs:          docRepo{d},
+		OTLinks:       otlinkRepo{d},
+		Watches:       watchRepo{d},
+		Links:         linkRepo{d},
+		JobRuns:       jobRunRepo{d},
+		Backfill:      backfillRepo{d},
+		Notifications: notificationRepo{d},
+		Outbox:        outboxRepo{d},
+		Digests:       digestRepo{d},
+		WhatsApp:      whatsappRepo{d},
+		Telegram:      telegramRepo{d},
+		Webhooks:      webhookRepo{d},
+		Push:          pushRepo{d},
+		SMS:           smsRepo{d},
+		Locks:         lockRepo{d},
+	}
+}
+
+func (d *DB) now() time.Time {
+	if d.Now != nil {
+		return d.Now()
+	}
+
+	return time.Now()
+}
+
+func newId() string {
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return uuid.NewString()
+	}
+
+	return id.String()
+}
+
+func uniqueViolation(constraint string) error {
+	return &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        "duplicate key value violates unique constraint",
+		ConstraintName: constraint,
+	}
+}
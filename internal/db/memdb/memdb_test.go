@@ -0,0 +1,313 @@
+package memdb_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+)
+
+// newStore returns a Store with a clock that only moves with advance
+func newStore() (store *db.Store, advance func(time.Duration)) {
+	d := memdb.NewDB()
+	now := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	d.Now = func() time.Time { return now }
+
+	return d.Store(), func(by time.Duration) { now = now.Add(by) }
+}
+
+func createUser(t *testing.T, store *db.Store, id, username string) {
+	t.Helper()
+
+	_, err := store.Users.CreateUser(context.Background(), &db.User{
+		Id:       id,
+		Username: username,
+		Email:    username + "@example.com",
+		Password: "secreto",
+	})
+
+	if err != nil {
+		t.Fatalf("CreateUser(%v): %v", username, err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore()
+
+	createUser(t, store, "u1", "ana")
+
+	user, err := store.Users.GetUserByUsername(ctx, "ana")
+
+	if err != nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+
+	if user.Id != "u1" || user.Password == "secreto" {
+		t.Errorf("got id %q and password %q, want u1 and a hash", user.Id, user.Password)
+	}
+
+	_, err = store.Users.CreateUser(ctx, &db.User{Id: "u2", Username: "ana", Email: "otra@example.com"})
+	pgErr := &pgconn.PgError{}
+
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Errorf("duplicate username: got %v, want a unique violation", err)
+	}
+
+	if _, err := store.Users.GetUserById(ctx, "u404"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("missing user: got %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestAccordChangeQueuesNotifications(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newStore()
+	createUser(t, store, "u1", "ana")
+
+	endpoint := db.WebhookEndpoint{
+		UserId: sql.NullString{String: "u1", Valid: true},
+		Url:    "https://example.com/hook",
+		Secret: "s",
+		Events: []string{db.WebhookAccordNew},
+	}
+
+	if err := store.Webhooks.CreateWebhookEndpoint(ctx, &endpoint); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+
+	alert, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{
+		UserId:     "u1",
+		CaseId:     "123/2024",
+		Nature:     "Civil",
+		NatureCode: "civ",
+		Active:     true,
+	})
+
+	if err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	advance(time.Hour)
+
+	doc := &db.Doc{
+		Case:       "123/2024",
+		Nature:     "Civil",
+		NatureCode: "civ",
+		Accord:     "Se admite la demanda",
+		AccordDate: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+	other := &db.Doc{Case: "9/2024", NatureCode: "civ", Accord: "Otro", AccordDate: doc.AccordDate}
+
+	updates, err := store.Alerts.UpdateAlertsForCases(ctx, []*db.Doc{doc, other})
+
+	if err != nil {
+		t.Fatalf("UpdateAlertsForCases: %v", err)
+	}
+
+	if len(updates.Changes) != 1 || len(updates.Missing) != 1 || updates.Missing[0] != "9/2024+civ" {
+		t.Fatalf("got %v changes and missing %v, want 1 change and 9/2024+civ", len(updates.Changes), updates.Missing)
+	}
+
+	// The same accord again is already known
+	updates, err = store.Alerts.UpdateAlertsForCases(ctx, []*db.Doc{doc})
+
+	if err != nil {
+		t.Fatalf("UpdateAlertsForCases: %v", err)
+	}
+
+	if len(updates.Changes) != 0 || updates.Unchanged != 1 {
+		t.Errorf("repeated accord: got %v changes and %v unchanged, want 0 and 1", len(updates.Changes), updates.Unchanged)
+	}
+
+	found, err := store.Alerts.FindAlertById(ctx, alert.Id)
+
+	if err != nil {
+		t.Fatalf("FindAlertById: %v", err)
+	}
+
+	if found.LastAccord.String != doc.Accord || found.NewAccords != 1 {
+		t.Errorf("got accord %q and %v new accords, want %q and 1", found.LastAccord.String, found.NewAccords, doc.Accord)
+	}
+
+	items, err := store.Outbox.ClaimDueNotifications(ctx, 10, time.Minute)
+
+	if err != nil {
+		t.Fatalf("ClaimDueNotifications: %v", err)
+	}
+
+	if len(items) != 1 || items[0].UserId != "u1" || items[0].Event != db.NotificationAccord {
+		t.Fatalf("got %v outbox items, want a single accord notification for u1", len(items))
+	}
+
+	deliveries, err := store.Webhooks.ClaimDueWebhookDeliveries(ctx, 10, time.Minute)
+
+	if err != nil {
+		t.Fatalf("ClaimDueWebhookDeliveries: %v", err)
+	}
+
+	// The endpoint only wants the new accords, not the alert created
+	if len(deliveries) != 1 || deliveries[0].Event != db.WebhookAccordNew {
+		t.Errorf("got %v webhook deliveries, want a single %v", len(deliveries), db.WebhookAccordNew)
+	}
+}
+
+func TestDocsOfAlertedCases(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore()
+	createUser(t, store, "u1", "ana")
+	createUser(t, store, "u2", "beto")
+
+	_, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{UserId: "u1", CaseId: "1/2024", NatureCode: "civ", Active: true})
+
+	if err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	docs := []*db.Doc{
+		{ID: "d1", Case: "1/2024", NatureCode: "civ", AccordDate: date},
+		{ID: "d2", Case: "1/2024", NatureCode: "fam1", AccordDate: date},
+		{ID: "d3", Case: "2/2024", NatureCode: "civ", AccordDate: date},
+	}
+
+	for _, doc := range docs {
+		if err := store.Docs.CreateDoc(ctx, doc.ID, doc.Case, doc.Nature, doc.NatureCode, doc.Accord, doc.AccordDate); err != nil {
+			t.Fatalf("CreateDoc(%v): %v", doc.ID, err)
+		}
+	}
+
+	tests := []struct {
+		userId string
+		want   int
+	}{
+		{"u1", 1},
+		{"u2", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := store.Docs.GetDocs(ctx, tt.userId)
+
+		if err != nil || len(got) != tt.want || (tt.want == 1 && got[0].ID != "d1") {
+			t.Errorf("%v: got %v, %v, want %v docs", tt.userId, got, err, tt.want)
+		}
+	}
+}
+
+func TestOutboxClaim(t *testing.T) {
+	ctx := context.Background()
+	store, advance := newStore()
+
+	item := db.OutboxItem{UserId: "u1", Event: db.NotificationAccord, IdempotencyKey: "k1", Payload: []byte(`{}`)}
+	queued, err := store.Outbox.EnqueueNotification(ctx, &item)
+
+	if err != nil || !queued {
+		t.Fatalf("EnqueueNotification: got %v, %v, want true", queued, err)
+	}
+
+	queued, err = store.Outbox.EnqueueNotification(ctx, &db.OutboxItem{UserId: "u1", IdempotencyKey: "k1"})
+
+	if err != nil || queued {
+		t.Errorf("repeated key: got %v, %v, want false", queued, err)
+	}
+
+	claimed, err := store.Outbox.ClaimDueNotifications(ctx, 10, time.Minute)
+
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 1 {
+		t.Fatalf("ClaimDueNotifications: got %v items, %v, want 1 with an attempt", len(claimed), err)
+	}
+
+	// Locked while it's being sent
+	if claimed, _ := store.Outbox.ClaimDueNotifications(ctx, 10, time.Minute); len(claimed) != 0 {
+		t.Errorf("claimed %v locked items", len(claimed))
+	}
+
+	// And claimed again when the lock expires without an answer
+	advance(2 * time.Minute)
+
+	if claimed, _ := store.Outbox.ClaimDueNotifications(ctx, 10, time.Minute); len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Errorf("expired lock: got %v items, want 1 with 2 attempts", len(claimed))
+	}
+}
+
+func TestPushSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore()
+
+	first := db.PushSubscription{UserId: "u1", Endpoint: "https://push.example.com/1", P256dh: "a", Auth: "b"}
+
+	if err := store.Push.SavePushSubscription(ctx, &first); err != nil {
+		t.Fatalf("SavePushSubscription: %v", err)
+	}
+
+	// The same browser signed in with another account
+	again := db.PushSubscription{UserId: "u2", Endpoint: first.Endpoint, P256dh: "c", Auth: "d"}
+
+	if err := store.Push.SavePushSubscription(ctx, &again); err != nil {
+		t.Fatalf("SavePushSubscription: %v", err)
+	}
+
+	if again.Id != first.Id {
+		t.Errorf("got id %q, want the one of the endpoint %q", again.Id, first.Id)
+	}
+
+	if subs, _ := store.Push.FindPushSubscriptions(ctx, "u1"); len(subs) != 0 {
+		t.Errorf("u1 kept %v subscriptions", len(subs))
+	}
+
+	if err := store.Push.DeletePushSubscription(ctx, "u1", first.Endpoint); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("delete by another user: got %v, want pgx.ErrNoRows", err)
+	}
+
+	if err := store.Push.DeletePushSubscription(ctx, "u2", first.Endpoint); err != nil {
+		t.Errorf("DeletePushSubscription: %v", err)
+	}
+}
+
+func TestSMSOptOuts(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore()
+	phone := "+526181234567"
+
+	if err := store.SMS.OptOutSMS(ctx, phone); err != nil {
+		t.Fatalf("OptOutSMS: %v", err)
+	}
+
+	if out, _ := store.SMS.IsSMSOptedOut(ctx, phone); !out {
+		t.Errorf("%v didn't opt out", phone)
+	}
+
+	if err := store.SMS.OptInSMS(ctx, phone); err != nil {
+		t.Fatalf("OptInSMS: %v", err)
+	}
+
+	if out, _ := store.SMS.IsSMSOptedOut(ctx, phone); out {
+		t.Errorf("%v is still opted out", phone)
+	}
+}
+
+func TestLocks(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newStore()
+
+	unlock, ok, err := store.Locks.TryLock(ctx, "update")
+
+	if err != nil || !ok {
+		t.Fatalf("TryLock: got %v, %v, want the lock", ok, err)
+	}
+
+	if _, ok, _ := store.Locks.TryLock(ctx, "update"); ok {
+		t.Errorf("took a lock that was held")
+	}
+
+	unlock()
+
+	if _, ok, _ := store.Locks.TryLock(ctx, "update"); !ok {
+		t.Errorf("the lock wasn't released")
+	}
+}
@@ -0,0 +1,82 @@
+package memdb
+
+import (
+	"context"
+	"sort"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type notificationRepo struct {
+	d *DB
+}
+
+func preferenceKey(userId, event, channel string) string {
+	return userId + "+" + event + "+" + channel
+}
+
+func (r notificationRepo) FindNotificationPreferences(ctx context.Context, userId string) ([]*db.NotificationPreference, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	prefs := []*db.NotificationPreference{}
+
+	for _, p := range r.d.preferences {
+		if p.UserId == userId {
+			pref := *p
+			prefs = append(prefs, &pref)
+		}
+	}
+
+	sort.Slice(prefs, func(i, j int) bool {
+		if prefs[i].Event != prefs[j].Event {
+			return prefs[i].Event < prefs[j].Event
+		}
+
+		return prefs[i].Channel < prefs[j].Channel
+	})
+
+	return prefs, nil
+}
+
+func (r notificationRepo) SaveNotificationPreference(ctx context.Context, pref *db.NotificationPreference) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	pref.UpdatedAt = r.d.now()
+
+	stored := *pref
+	r.d.preferences[preferenceKey(pref.UserId, pref.Event, pref.Channel)] = &stored
+
+	return nil
+}
+
+func (r notificationRepo) CreateNotificationDelivery(ctx context.Context, delivery *db.NotificationDelivery) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	delivery.Id = newId()
+	delivery.CreatedAt = r.d.now()
+
+	stored := *delivery
+	r.d.deliveries = append(r.d.deliveries, &stored)
+
+	return nil
+}
+
+func (r notificationRepo) FindNotificationDeliveries(ctx context.Context, userId string, limit int) ([]*db.NotificationDelivery, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	deliveries := []*db.NotificationDelivery{}
+
+	// Newest first, they are stored in the order they were created
+	for i := len(r.d.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if r.d.deliveries[i].UserId == userId {
+			delivery := *r.d.deliveries[i]
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,138 @@
+package memdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type otlinkRepo struct {
+	d *DB
+}
+
+func (r otlinkRepo) CreateOTLink(ctx context.Context, userId uuid.UUID, action string) (*db.OTLink, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	otl := db.OTLink{
+		Id:        uuid.MustParse(newId()),
+		Code:      uuid.MustParse(newId()),
+		Action:    action,
+		UserId:    userId,
+		ExpiresAt: r.d.now().Add(15 * time.Minute),
+		CreatedAt: r.d.now(),
+	}
+
+	stored := otl
+	r.d.otlinks = append(r.d.otlinks, &stored)
+
+	return &otl, nil
+}
+
+// findOTLink expects the lock to be held
+func (d *DB) findOTLink(code, userId uuid.UUID) (*db.OTLink, error) {
+	for _, otl := range d.otlinks {
+		if otl.Code == code && otl.UserId == userId {
+			return otl, nil
+		}
+	}
+
+	return nil, &db.NonExistentOTLError{
+		Code: code.String(),
+		User: userId.String(),
+	}
+}
+
+func (r otlinkRepo) FindUserOTLinkByCode(ctx context.Context, code, userId uuid.UUID) (*db.OTLink, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	otl, err := r.d.findOTLink(code, userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	found := *otl
+	return &found, nil
+}
+
+func (r otlinkRepo) MarkOTLinkAsUsed(ctx context.Context, code, userId uuid.UUID) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	otl, err := r.d.findOTLink(code, userId)
+
+	if err != nil {
+		return err
+	}
+
+	otl.Used = true
+	return nil
+}
+
+func (r otlinkRepo) UseVerifyOTL(ctx context.Context, code, userId uuid.UUID) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	otl, err := r.d.findOTLink(code, userId)
+
+	if err != nil {
+		return err
+	}
+
+	if otl.Used {
+		return &db.NonExistentOTLError{
+			Code: code.String(),
+			User: userId.String(),
+		}
+	}
+
+	if err = r.d.verifyUserEmail(userId.String()); err != nil {
+		return err
+	}
+
+	otl.Used = true
+	return nil
+}
+
+func (r otlinkRepo) UsePhoneOTL(ctx context.Context, code uuid.UUID, phone string) (string, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, otl := range r.d.otlinks {
+		if otl.Code != code || otl.Action != db.OTLActionPhone || otl.Used || !otl.ExpiresAt.After(r.d.now()) {
+			continue
+		}
+
+		if err := r.d.verifyUserPhone(otl.UserId.String(), phone); err != nil {
+			return "", err
+		}
+
+		otl.Used = true
+		return otl.UserId.String(), nil
+	}
+
+	return "", &db.NonExistentOTLError{Code: code.String()}
+}
+
+func (r otlinkRepo) UseTelegramOTL(ctx context.Context, code uuid.UUID, chat *db.TelegramChat) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, otl := range r.d.otlinks {
+		if otl.Code != code || otl.Action != db.OTLActionTelegram || otl.Used || !otl.ExpiresAt.After(r.d.now()) {
+			continue
+		}
+
+		chat.UserId = otl.UserId.String()
+		r.d.linkTelegramChat(chat)
+
+		otl.Used = true
+		return nil
+	}
+
+	return &db.NonExistentOTLError{Code: code.String()}
+}
@@ -0,0 +1,175 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type outboxRepo struct {
+	d *DB
+}
+
+// enqueue stores item unless its key is taken, expects the lock to be held
+func (d *DB) enqueue(item *db.OutboxItem) bool {
+	for _, stored := range d.outbox {
+		if stored.IdempotencyKey == item.IdempotencyKey {
+			return false
+		}
+	}
+
+	item.Id = newId()
+	item.Status = db.OutboxPending
+	item.CreatedAt = d.now()
+	item.NextAttemptAt = item.CreatedAt
+	item.DeliveredChannels = []string{}
+
+	stored := *item
+	d.outbox = append(d.outbox, &stored)
+
+	return true
+}
+
+// queueAccordNotifications queues a db.NotificationAccord for every active
+// subscriber of the case of change that subscribed before it was detected,
+// like applyAccordQuery does. Expects the lock to be held
+func (d *DB) queueAccordNotifications(change *db.AccordChange) {
+	for _, s := range d.subscriptions {
+		if s.CaseRef != change.CaseRef || !s.Active || !s.CreatedAt.Before(change.DetectedAt) {
+			continue
+		}
+
+		payload, err := json.Marshal(map[string]any{
+			"alertId": s.Id,
+			"change":  change,
+		})
+
+		if err != nil {
+			continue
+		}
+
+		d.enqueue(&db.OutboxItem{
+			UserId:         s.UserId,
+			Event:          db.NotificationAccord,
+			IdempotencyKey: "accord:" + change.Id + ":" + s.UserId,
+			Payload:        payload,
+		})
+	}
+}
+
+func (r outboxRepo) EnqueueNotification(ctx context.Context, item *db.OutboxItem) (bool, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	return r.d.enqueue(item), nil
+}
+
+func (r outboxRepo) ClaimDueNotifications(ctx context.Context, limit int, lockFor time.Duration) ([]*db.OutboxItem, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	now := r.d.now()
+	due := []*db.OutboxItem{}
+
+	for _, item := range r.d.outbox {
+		if item.Status == db.OutboxPending && !item.NextAttemptAt.After(now) &&
+			(!item.LockedUntil.Valid || item.LockedUntil.Time.Before(now)) {
+			due = append(due, item)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := []*db.OutboxItem{}
+
+	for _, item := range due {
+		item.LockedUntil = sql.NullTime{Time: now.Add(lockFor), Valid: true}
+		item.Attempts++
+
+		copied := *item
+		copied.DeliveredChannels = append([]string{}, item.DeliveredChannels...)
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (r outboxRepo) FinishNotification(ctx context.Context, item *db.OutboxItem) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.outbox {
+		if stored.Id == item.Id {
+			stored.Status = item.Status
+			stored.DeliveredChannels = append([]string{}, item.DeliveredChannels...)
+			stored.LastError = item.LastError
+			stored.NextAttemptAt = item.NextAttemptAt
+			stored.SentAt = item.SentAt
+			stored.LockedUntil = sql.NullTime{}
+		}
+	}
+
+	return nil
+}
+
+func (r outboxRepo) RequeueNotification(ctx context.Context, id string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.outbox {
+		if stored.Id == id && stored.Status == db.OutboxDead {
+			stored.Status = db.OutboxPending
+			stored.Attempts = 0
+			stored.NextAttemptAt = r.d.now()
+			stored.LockedUntil = sql.NullTime{}
+			return nil
+		}
+	}
+
+	return pgx.ErrNoRows
+}
+
+func (r outboxRepo) FindNotifications(ctx context.Context, filter db.OutboxFilter) ([]*db.OutboxItem, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	items := []*db.OutboxItem{}
+
+	// Newest first, they are stored in the order they were created
+	for i := len(r.d.outbox) - 1; i >= 0 && len(items) < filter.Limit; i-- {
+		if filter.Status == "" || r.d.outbox[i].Status == filter.Status {
+			item := *r.d.outbox[i]
+			items = append(items, &item)
+		}
+	}
+
+	return items, nil
+}
+
+func (r outboxRepo) CountNotifications(ctx context.Context) (map[string]int, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	counts := map[string]int{db.OutboxPending: 0, db.OutboxSent: 0, db.OutboxDead: 0}
+
+	for _, item := range r.d.outbox {
+		counts[item.Status]++
+	}
+
+	return counts, nil
+}
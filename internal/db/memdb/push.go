@@ -0,0 +1,73 @@
+package memdb
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type pushRepo struct {
+	d *DB
+}
+
+func (r pushRepo) SavePushSubscription(ctx context.Context, sub *db.PushSubscription) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.push {
+		if stored.Endpoint == sub.Endpoint {
+			stored.UserId = sub.UserId
+			stored.P256dh = sub.P256dh
+			stored.Auth = sub.Auth
+			stored.UserAgent = sub.UserAgent
+
+			sub.Id = stored.Id
+			sub.CreatedAt = stored.CreatedAt
+			return nil
+		}
+	}
+
+	sub.Id = newId()
+	sub.CreatedAt = r.d.now()
+
+	stored := *sub
+	r.d.push = append(r.d.push, &stored)
+
+	return nil
+}
+
+func (r pushRepo) FindPushSubscriptions(ctx context.Context, userId string) ([]*db.PushSubscription, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	found := []*db.PushSubscription{}
+
+	for _, stored := range r.d.push {
+		if stored.UserId == userId {
+			sub := *stored
+			found = append(found, &sub)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].CreatedAt.Before(found[j].CreatedAt)
+	})
+
+	return found, nil
+}
+
+func (r pushRepo) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for i, stored := range r.d.push {
+		if stored.UserId == userId && stored.Endpoint == endpoint {
+			r.d.push = append(r.d.push[:i], r.d.push[i+1:]...)
+			return nil
+		}
+	}
+
+	return pgx.ErrNoRows
+}
@@ -0,0 +1,34 @@
+package memdb
+
+import "context"
+
+type smsRepo struct {
+	d *DB
+}
+
+func (r smsRepo) OptOutSMS(ctx context.Context, phone string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if _, ok := r.d.smsOptOuts[phone]; !ok {
+		r.d.smsOptOuts[phone] = r.d.now()
+	}
+
+	return nil
+}
+
+func (r smsRepo) OptInSMS(ctx context.Context, phone string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	delete(r.d.smsOptOuts, phone)
+	return nil
+}
+
+func (r smsRepo) IsSMSOptedOut(ctx context.Context, phone string) (bool, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	_, ok := r.d.smsOptOuts[phone]
+	return ok, nil
+}
@@ -0,0 +1,69 @@
+package memdb
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type telegramRepo struct {
+	d *DB
+}
+
+func (r telegramRepo) FindTelegramChat(ctx context.Context, userId string) (*db.TelegramChat, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	chat, ok := r.d.telegram[userId]
+
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	found := *chat
+	return &found, nil
+}
+
+func (r telegramRepo) GetUserByTelegramChat(ctx context.Context, chatId int64) (*db.User, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	for _, chat := range r.d.telegram {
+		if chat.ChatId != chatId {
+			continue
+		}
+
+		if u, ok := r.d.users[chat.UserId]; ok {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+func (r telegramRepo) DeleteTelegramChat(ctx context.Context, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if _, ok := r.d.telegram[userId]; !ok {
+		return pgx.ErrNoRows
+	}
+
+	delete(r.d.telegram, userId)
+	return nil
+}
+
+// linkTelegramChat expects the lock to be held
+func (d *DB) linkTelegramChat(chat *db.TelegramChat) {
+	for userId, other := range d.telegram {
+		if other.ChatId == chat.ChatId && userId != chat.UserId {
+			delete(d.telegram, userId)
+		}
+	}
+
+	chat.LinkedAt = d.now()
+	stored := *chat
+	d.telegram[chat.UserId] = &stored
+}
@@ -0,0 +1,129 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type userRepo struct {
+	d *DB
+}
+
+func (r userRepo) CreateUser(ctx context.Context, user *db.User) (string, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if _, ok := r.d.users[user.Id]; ok {
+		return "", uniqueViolation("users_pkey")
+	}
+
+	for _, u := range r.d.users {
+		if u.Username == user.Username {
+			return "", uniqueViolation("users_username_key")
+		}
+
+		if u.Email == user.Email {
+			return "", uniqueViolation("users_email_key")
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return "", err
+	}
+
+	stored := *user
+	stored.Password = string(hashedPassword)
+	stored.SubscriptionExpiresAt = r.d.now()
+	stored.Alerts = nil
+	r.d.users[stored.Id] = &stored
+
+	return user.Id, nil
+}
+
+func (r userRepo) GetUserById(ctx context.Context, id string) (*db.User, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	u, ok := r.d.users[id]
+
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	user := *u
+	return &user, nil
+}
+
+func (r userRepo) GetUserByUsername(ctx context.Context, username string) (*db.User, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	for _, u := range r.d.users {
+		if u.Username == username {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+func (r userRepo) VerifyUserEmail(ctx context.Context, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	return r.d.verifyUserEmail(userId)
+}
+
+// verifyUserEmail expects the lock to be held
+func (d *DB) verifyUserEmail(userId string) error {
+	u, ok := d.users[userId]
+
+	if !ok {
+		return errors.New(fmt.Sprintf("No se encontró usuario con id %v", userId))
+	}
+
+	u.EmailVerified = true
+	return nil
+}
+
+func (r userRepo) GetUserByPhone(ctx context.Context, phone string) (*db.User, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	for _, u := range r.d.users {
+		if u.PhoneVerified && u.Phone.String == phone {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+// verifyUserPhone expects the lock to be held
+func (d *DB) verifyUserPhone(userId, phone string) error {
+	u, ok := d.users[userId]
+
+	if !ok {
+		return errors.New(fmt.Sprintf("No se encontró usuario con id %v", userId))
+	}
+
+	for _, other := range d.users {
+		if other.Id != userId && other.Phone.String == phone {
+			other.PhoneVerified = false
+		}
+	}
+
+	u.Phone = sql.NullString{String: phone, Valid: true}
+	u.PhoneVerified = true
+	return nil
+}
@@ -0,0 +1,192 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type watchRepo struct {
+	d *DB
+}
+
+func (r watchRepo) CreateWatch(ctx context.Context, data *db.Watch) (*db.Watch, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	watch := *data
+	watch.Id = newId()
+	watch.CreatedAt = r.d.now()
+
+	if watch.Kind == "" {
+		watch.Kind = db.WatchKindText
+	}
+
+	watch.NatureCodes = append([]string{}, data.NatureCodes...)
+
+	stored := watch
+	r.d.watches = append(r.d.watches, &stored)
+
+	return &watch, nil
+}
+
+func (r watchRepo) findWatches(keep func(w *db.Watch) bool) []*db.Watch {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	watches := []*db.Watch{}
+
+	for _, w := range r.d.watches {
+		if keep(w) {
+			watch := *w
+			watches = append(watches, &watch)
+		}
+	}
+
+	return watches
+}
+
+func (r watchRepo) FindWatchesByUser(ctx context.Context, userId string) ([]*db.Watch, error) {
+	watches := r.findWatches(func(w *db.Watch) bool { return w.UserId == userId })
+
+	sort.SliceStable(watches, func(i, j int) bool {
+		return watches[i].CreatedAt.After(watches[j].CreatedAt)
+	})
+
+	return watches, nil
+}
+
+func (r watchRepo) FindActiveWatches(ctx context.Context) ([]*db.Watch, error) {
+	return r.findWatches(func(w *db.Watch) bool { return w.Active }), nil
+}
+
+func (r watchRepo) DeleteUserWatchById(ctx context.Context, id, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for i, w := range r.d.watches {
+		if w.Id == id && w.UserId == userId {
+			r.d.watches = append(r.d.watches[:i], r.d.watches[i+1:]...)
+
+			matches := r.d.watchMatches[:0]
+			for _, m := range r.d.watchMatches {
+				if m.WatchId != id {
+					matches = append(matches, m)
+				}
+			}
+			r.d.watchMatches = matches
+
+			return nil
+		}
+	}
+
+	return errors.New("No se encontro vigilancia con el id especificado")
+}
+
+func (r watchRepo) CreateWatchMatches(ctx context.Context, matches []*db.WatchMatch) (created []*db.WatchMatch, err error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, m := range matches {
+		exists := false
+
+		for _, stored := range r.d.watchMatches {
+			if stored.WatchId == m.WatchId && stored.NatureCode == m.NatureCode && stored.CaseId == m.CaseId && stored.AccordDate.Equal(m.AccordDate) {
+				exists = true
+				break
+			}
+		}
+
+		if exists {
+			continue
+		}
+
+		m.Id = newId()
+		m.CreatedAt = r.d.now()
+
+		stored := *m
+		r.d.watchMatches = append(r.d.watchMatches, &stored)
+		created = append(created, m)
+
+		for _, w := range r.d.watches {
+			if w.Id == m.WatchId {
+				w.LastMatchAt.Time = r.d.now()
+				w.LastMatchAt.Valid = true
+			}
+		}
+	}
+
+	return created, nil
+}
+
+func (r watchRepo) FindWatchMatchesByUser(ctx context.Context, userId string, limit int) ([]*db.WatchMatch, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	patterns := map[string]string{}
+	for _, w := range r.d.watches {
+		patterns[w.Id] = w.Pattern
+	}
+
+	matches := []*db.WatchMatch{}
+
+	for _, m := range r.d.watchMatches {
+		if m.UserId == userId {
+			match := *m
+			match.Pattern = patterns[m.WatchId]
+			matches = append(matches, &match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].AccordDate.Equal(matches[j].AccordDate) {
+			return matches[i].AccordDate.After(matches[j].AccordDate)
+		}
+
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+func (r watchRepo) FindPendingWatchMatches(ctx context.Context) ([]*db.WatchMatch, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	patterns := map[string]string{}
+	for _, w := range r.d.watches {
+		patterns[w.Id] = w.Pattern
+	}
+
+	matches := []*db.WatchMatch{}
+
+	for _, m := range r.d.watchMatches {
+		if !m.NotifiedAt.Valid {
+			match := *m
+			match.Pattern = patterns[m.WatchId]
+			matches = append(matches, &match)
+		}
+	}
+
+	return matches, nil
+}
+
+func (r watchRepo) MarkWatchMatchesAsNotified(ctx context.Context, ids []string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, m := range r.d.watchMatches {
+		if contains(ids, m.Id) && !m.NotifiedAt.Valid {
+			m.NotifiedAt.Time = r.d.now()
+			m.NotifiedAt.Valid = true
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,280 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type webhookRepo struct {
+	d *DB
+}
+
+// queueWebhookEvent queues event for the endpoints that want it, like
+// queueWebhookQuery does, and returns how many it was queued for. Expects
+// the lock to be held
+func (d *DB) queueWebhookEvent(event *db.WebhookEvent) int {
+	queued := 0
+
+	for _, endpoint := range d.webhookEndpoints {
+		if endpoint.UserId.Valid && endpoint.UserId.String != event.UserId || !endpoint.Wants(event.Type) {
+			continue
+		}
+
+		taken := false
+
+		for _, stored := range d.webhookDeliveries {
+			if stored.EndpointId == endpoint.Id && stored.EventId == event.Id {
+				taken = true
+				break
+			}
+		}
+
+		if taken {
+			continue
+		}
+
+		now := d.now()
+		d.webhookDeliveries = append(d.webhookDeliveries, &db.WebhookDelivery{
+			Id:            newId(),
+			EndpointId:    endpoint.Id,
+			Event:         event.Type,
+			EventId:       event.Id,
+			Payload:       append(json.RawMessage{}, event.Data...),
+			Status:        db.WebhookPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+		queued++
+	}
+
+	return queued
+}
+
+// queueAlertWebhook queues the event about alert, expects the lock to be held
+func (d *DB) queueAlertWebhook(event string, alert *db.Alert) {
+	data, err := db.AlertWebhookData(alert)
+
+	if err != nil {
+		return
+	}
+
+	d.queueWebhookEvent(&db.WebhookEvent{
+		Id:     event + ":" + alert.Id,
+		Type:   event,
+		UserId: alert.UserId,
+		Data:   data,
+	})
+}
+
+// queueAccordWebhooks queues a db.WebhookAccordNew for the subscribers of
+// the case of change, like applyAccordQuery does. Expects the lock to be held
+func (d *DB) queueAccordWebhooks(change *db.AccordChange) {
+	for _, s := range d.subscriptions {
+		if s.CaseRef != change.CaseRef || !s.Active || !s.CreatedAt.Before(change.DetectedAt) {
+			continue
+		}
+
+		data, err := json.Marshal(map[string]any{
+			"alertId": s.Id,
+			"userId":  s.UserId,
+			"change": map[string]any{
+				"id":         change.Id,
+				"caseRef":    change.CaseRef,
+				"caseId":     change.CaseId,
+				"natureCode": change.NatureCode,
+				"nature":     change.Nature,
+				"accord":     change.Accord,
+				"accordDate": change.AccordDate,
+				"detectedAt": change.DetectedAt,
+			},
+		})
+
+		if err != nil {
+			continue
+		}
+
+		d.queueWebhookEvent(&db.WebhookEvent{
+			Id:     db.WebhookAccordNew + ":" + change.Id + ":" + s.UserId,
+			Type:   db.WebhookAccordNew,
+			UserId: s.UserId,
+			Data:   data,
+		})
+	}
+}
+
+func (r webhookRepo) CreateWebhookEndpoint(ctx context.Context, endpoint *db.WebhookEndpoint) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	if endpoint.Events == nil {
+		endpoint.Events = []string{}
+	}
+
+	endpoint.Id = newId()
+	endpoint.CreatedAt = r.d.now()
+
+	stored := *endpoint
+	stored.Events = append([]string{}, endpoint.Events...)
+	r.d.webhookEndpoints = append(r.d.webhookEndpoints, &stored)
+
+	return nil
+}
+
+func (r webhookRepo) FindWebhookEndpoints(ctx context.Context, userId string) ([]*db.WebhookEndpoint, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	endpoints := []*db.WebhookEndpoint{}
+
+	for _, endpoint := range r.d.webhookEndpoints {
+		if endpoint.UserId.String == userId {
+			copied := *endpoint
+			endpoints = append(endpoints, &copied)
+		}
+	}
+
+	return endpoints, nil
+}
+
+func (r webhookRepo) FindWebhookEndpointById(ctx context.Context, id string) (*db.WebhookEndpoint, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	for _, endpoint := range r.d.webhookEndpoints {
+		if endpoint.Id == id {
+			copied := *endpoint
+			return &copied, nil
+		}
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+func (r webhookRepo) DeleteWebhookEndpoint(ctx context.Context, id, userId string) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for i, endpoint := range r.d.webhookEndpoints {
+		if endpoint.Id != id || endpoint.UserId.String != userId {
+			continue
+		}
+
+		r.d.webhookEndpoints = append(r.d.webhookEndpoints[:i], r.d.webhookEndpoints[i+1:]...)
+
+		deliveries := r.d.webhookDeliveries[:0]
+		for _, delivery := range r.d.webhookDeliveries {
+			if delivery.EndpointId != id {
+				deliveries = append(deliveries, delivery)
+			}
+		}
+		r.d.webhookDeliveries = deliveries
+
+		return nil
+	}
+
+	return pgx.ErrNoRows
+}
+
+func (r webhookRepo) EnqueueWebhookEvent(ctx context.Context, event *db.WebhookEvent) (int, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	return r.d.queueWebhookEvent(event), nil
+}
+
+func (r webhookRepo) CreateWebhookDelivery(ctx context.Context, delivery *db.WebhookDelivery) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.webhookDeliveries {
+		if stored.EndpointId == delivery.EndpointId && stored.EventId == delivery.EventId {
+			return uniqueViolation("webhook_deliveries_endpoint_id_event_id_key")
+		}
+	}
+
+	delivery.Id = newId()
+	delivery.Status = db.WebhookPending
+	delivery.CreatedAt = r.d.now()
+	delivery.NextAttemptAt = delivery.CreatedAt
+
+	stored := *delivery
+	r.d.webhookDeliveries = append(r.d.webhookDeliveries, &stored)
+
+	return nil
+}
+
+func (r webhookRepo) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lockFor time.Duration) ([]*db.WebhookDelivery, error) {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	now := r.d.now()
+	due := []*db.WebhookDelivery{}
+
+	for _, delivery := range r.d.webhookDeliveries {
+		if delivery.Status == db.WebhookPending && !delivery.NextAttemptAt.After(now) &&
+			(!delivery.LockedUntil.Valid || delivery.LockedUntil.Time.Before(now)) {
+			due = append(due, delivery)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := []*db.WebhookDelivery{}
+
+	for _, delivery := range due {
+		delivery.LockedUntil = sql.NullTime{Time: now.Add(lockFor), Valid: true}
+		delivery.Attempts++
+
+		copied := *delivery
+		claimed = append(claimed, &copied)
+	}
+
+	return claimed, nil
+}
+
+func (r webhookRepo) FinishWebhookDelivery(ctx context.Context, delivery *db.WebhookDelivery) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.webhookDeliveries {
+		if stored.Id == delivery.Id {
+			stored.Status = delivery.Status
+			stored.ResponseStatus = delivery.ResponseStatus
+			stored.LastError = delivery.LastError
+			stored.NextAttemptAt = delivery.NextAttemptAt
+			stored.SentAt = delivery.SentAt
+			stored.LockedUntil = sql.NullTime{}
+		}
+	}
+
+	return nil
+}
+
+func (r webhookRepo) FindWebhookDeliveries(ctx context.Context, endpointId string, limit int) ([]*db.WebhookDelivery, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	deliveries := []*db.WebhookDelivery{}
+
+	// Newest first, they are stored in the order they were created
+	for i := len(r.d.webhookDeliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if r.d.webhookDeliveries[i].EndpointId == endpointId {
+			delivery := *r.d.webhookDeliveries[i]
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,82 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+type whatsappRepo struct {
+	d *DB
+}
+
+func (r whatsappRepo) CreateWhatsAppMessage(ctx context.Context, msg *db.WhatsAppMessage) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, stored := range r.d.whatsapp {
+		if stored.Id == msg.Id {
+			return uniqueViolation("whatsapp_messages_pkey")
+		}
+	}
+
+	if msg.Status == "" {
+		msg.Status = db.WhatsAppSent
+	}
+
+	msg.CreatedAt = r.d.now()
+	msg.UpdatedAt = msg.CreatedAt
+
+	stored := *msg
+	r.d.whatsapp = append(r.d.whatsapp, &stored)
+
+	return nil
+}
+
+func (r whatsappRepo) UpdateWhatsAppMessageStatus(ctx context.Context, id, status string, at time.Time, errMsg sql.NullString) error {
+	r.d.mu.Lock()
+	defer r.d.mu.Unlock()
+
+	for _, msg := range r.d.whatsapp {
+		if msg.Id != id || db.WhatsAppStatusRank[status] <= db.WhatsAppStatusRank[msg.Status] {
+			continue
+		}
+
+		msg.Status = status
+
+		if errMsg.Valid {
+			msg.Error = errMsg
+		}
+
+		if (status == db.WhatsAppDelivered || status == db.WhatsAppRead) && !msg.DeliveredAt.Valid {
+			msg.DeliveredAt = sql.NullTime{Time: at, Valid: true}
+		}
+
+		if status == db.WhatsAppRead && !msg.ReadAt.Valid {
+			msg.ReadAt = sql.NullTime{Time: at, Valid: true}
+		}
+
+		msg.UpdatedAt = r.d.now()
+	}
+
+	return nil
+}
+
+func (r whatsappRepo) FindWhatsAppMessages(ctx context.Context, userId string, limit int) ([]*db.WhatsAppMessage, error) {
+	r.d.mu.RLock()
+	defer r.d.mu.RUnlock()
+
+	messages := []*db.WhatsAppMessage{}
+
+	// Newest first, they are stored in the order they were created
+	for i := len(r.d.whatsapp) - 1; i >= 0 && len(messages) < limit; i-- {
+		if r.d.whatsapp[i].UserId.String == userId {
+			msg := *r.d.whatsapp[i]
+			messages = append(messages, &msg)
+		}
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Arbitrary key for the advisory lock held while migrating so two
+// processes can't apply the same migrations at once
+const migrationLockKey = 7346520
+
+var migrationNameExp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationState struct {
+	*Migration
+	Applied   bool
+	AppliedAt sql.NullTime
+}
+
+// LoadMigrations returns the migrations embedded in the binary sorted by version
+func LoadMigrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		m := migrationNameExp.FindStringSubmatch(entry.Name())
+
+		if m == nil {
+			return nil, fmt.Errorf("Nombre de migración inválido: %v", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		content, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("La versión %v tiene migraciones con nombres distintos", version)
+		}
+
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []*Migration{}
+
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("La migración %04d_%v no tiene archivo up", migration.Version, migration.Name)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrationStatus returns every known migration and whether it was applied
+func MigrationStatus(ctx context.Context) ([]*MigrationState, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	return migrationStatus(ctx, conn)
+}
+
+func migrationStatus(ctx context.Context, conn *pgxpool.Conn) ([]*MigrationState, error) {
+	migrations, err := LoadMigrations()
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT NOW())")
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	states := []*MigrationState{}
+
+	for _, migration := range migrations {
+		state := MigrationState{Migration: migration}
+		state.AppliedAt.Time, state.Applied = applied[migration.Version]
+		state.AppliedAt.Valid = state.Applied
+
+		states = append(states, &state)
+	}
+
+	return states, nil
+}
+
+// MigrateUp applies every pending migration in order, each one in its own
+// transaction. It returns the migrations applied before any error
+func MigrateUp(ctx context.Context) ([]*Migration, error) {
+	return runMigrations(ctx, true, func(states []*MigrationState) []*Migration {
+		pending := []*Migration{}
+
+		for _, state := range states {
+			if !state.Applied {
+				pending = append(pending, state.Migration)
+			}
+		}
+
+		return pending
+	})
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first
+func MigrateDown(ctx context.Context, steps int) ([]*Migration, error) {
+	return runMigrations(ctx, false, func(states []*MigrationState) []*Migration {
+		toRevert := []*Migration{}
+
+		for i := len(states) - 1; i >= 0 && len(toRevert) < steps; i-- {
+			if states[i].Applied {
+				toRevert = append(toRevert, states[i].Migration)
+			}
+		}
+
+		return toRevert
+	})
+}
+
+// runMigrations takes the migration lock, then reads the status and runs
+// the migrations pick chooses from it, so two processes can't both see
+// the same migrations pending
+func runMigrations(ctx context.Context, up bool, pick func([]*MigrationState) []*Migration) ([]*Migration, error) {
+	done := []*Migration{}
+
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return done, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpMigration)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey)
+
+	if err != nil {
+		return done, err
+	}
+	// The lock is released even when ctx was cancelled mid migration
+	defer conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	states, err := migrationStatus(ctx, conn)
+
+	if err != nil {
+		return done, err
+	}
+
+	for _, migration := range pick(states) {
+		script := migration.Up
+
+		if !up {
+			script = migration.Down
+		}
+
+		if script == "" {
+			return done, fmt.Errorf("La migración %04d_%v no se puede revertir", migration.Version, migration.Name)
+		}
+
+		tx, err := conn.Begin(ctx)
+
+		if err != nil {
+			return done, err
+		}
+
+		// Without arguments the script runs through the simple protocol,
+		// which allows several statements in a single call
+		_, err = tx.Exec(ctx, script)
+
+		if err == nil {
+			if up {
+				_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+			} else {
+				_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			}
+		}
+
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+
+		if err != nil {
+			tx.Rollback(ctx)
+			return done, fmt.Errorf("Falló la migración %04d_%v: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS otlinks;
+DROP TABLE IF EXISTS docs;
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS users;
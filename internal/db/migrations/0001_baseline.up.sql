@@ -0,0 +1,55 @@
+-- Tables as they existed before the schema was versioned. IF NOT EXISTS
+-- lets databases created by hand adopt the migrations without changes.
+-- The column order matters: users is read with SELECT * and scanned by position
+
+CREATE TABLE IF NOT EXISTS users (
+    id                      uuid PRIMARY KEY,
+    name                    text NOT NULL,
+    lastname                text NOT NULL,
+    password                text NOT NULL,
+    subscription_active     boolean NOT NULL DEFAULT FALSE,
+    subscription_expires_at timestamptz NOT NULL DEFAULT NOW(),
+    username                text NOT NULL UNIQUE,
+    email                   text NOT NULL UNIQUE,
+    phone_number            text,
+    email_verified          boolean NOT NULL DEFAULT FALSE,
+    phone_verified          boolean NOT NULL DEFAULT FALSE,
+    golden_boy              boolean NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS alerts (
+    id               uuid PRIMARY KEY,
+    user_id          uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id          text NOT NULL,
+    nature           text NOT NULL DEFAULT '',
+    nature_code      text NOT NULL,
+    active           boolean NOT NULL DEFAULT TRUE,
+    alias            text,
+    last_updated_at  timestamptz NOT NULL DEFAULT NOW(),
+    last_checked_at  timestamptz NOT NULL DEFAULT NOW(),
+    last_accord      text,
+    last_accord_date timestamptz,
+    created_at       timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, case_id, nature_code)
+);
+
+CREATE TABLE IF NOT EXISTS docs (
+    id          text PRIMARY KEY,
+    case_id     text NOT NULL,
+    nature      text NOT NULL DEFAULT '',
+    nature_code text NOT NULL,
+    accord      text NOT NULL DEFAULT '',
+    accord_date timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS otlinks (
+    id         uuid PRIMARY KEY,
+    code       uuid NOT NULL,
+    used       boolean NOT NULL DEFAULT FALSE,
+    user_id    uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    action     text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS otlinks_code_user_idx ON otlinks (code, user_id);
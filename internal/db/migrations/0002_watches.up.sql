@@ -0,0 +1,30 @@
+CREATE TABLE watches (
+    id            uuid PRIMARY KEY,
+    user_id       uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pattern       text NOT NULL,
+    kind          text NOT NULL CHECK (kind IN ('text', 'rfc')),
+    alias         text,
+    nature_codes  text[] NOT NULL DEFAULT '{}',
+    active        boolean NOT NULL DEFAULT TRUE,
+    last_match_at timestamptz,
+    created_at    timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX watches_user_idx ON watches (user_id);
+
+CREATE TABLE watch_matches (
+    id          uuid PRIMARY KEY,
+    watch_id    uuid NOT NULL REFERENCES watches (id) ON DELETE CASCADE,
+    user_id     uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id     text NOT NULL,
+    nature_code text NOT NULL,
+    nature      text NOT NULL DEFAULT '',
+    accord      text NOT NULL DEFAULT '',
+    accord_date timestamptz NOT NULL,
+    notified_at timestamptz,
+    created_at  timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (watch_id, nature_code, case_id, accord_date)
+);
+
+CREATE INDEX watch_matches_user_idx ON watch_matches (user_id, created_at DESC);
+CREATE INDEX watch_matches_pending_idx ON watch_matches (id) WHERE notified_at IS NULL;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS docs_accord_date_idx;
+DROP INDEX IF EXISTS docs_case_idx;
+DROP INDEX IF EXISTS docs_search_vector_idx;
+
+ALTER TABLE docs DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+ALTER TABLE docs ADD COLUMN search_vector tsvector;
+
+UPDATE docs SET search_vector = to_tsvector('spanish', unaccent(coalesce(nature, '') || ' ' || coalesce(accord, '')));
+
+CREATE INDEX docs_search_vector_idx ON docs USING GIN (search_vector);
+CREATE INDEX docs_case_idx ON docs (nature_code, case_id);
+CREATE INDEX docs_accord_date_idx ON docs (accord_date);
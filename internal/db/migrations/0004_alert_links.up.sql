@@ -0,0 +1,18 @@
+CREATE TABLE alert_links (
+    id             uuid PRIMARY KEY,
+    user_id        uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    from_alert_id  uuid NOT NULL,
+    to_alert_id    uuid,
+    kind           text NOT NULL CHECK (kind IN ('transfer', 'appeal', 'origin')),
+    status         text NOT NULL CHECK (status IN ('suggested', 'confirmed', 'dismissed')),
+    to_case_id     text NOT NULL DEFAULT '',
+    to_nature_code text NOT NULL DEFAULT '',
+    accord         text NOT NULL DEFAULT '',
+    accord_date    timestamptz NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT alert_links_from_alert_fkey FOREIGN KEY (from_alert_id) REFERENCES alerts (id) ON DELETE CASCADE,
+    CONSTRAINT alert_links_to_alert_fkey FOREIGN KEY (to_alert_id) REFERENCES alerts (id) ON DELETE CASCADE,
+    UNIQUE (from_alert_id, kind, to_nature_code, to_case_id)
+);
+
+CREATE INDEX alert_links_to_alert_idx ON alert_links (to_alert_id);
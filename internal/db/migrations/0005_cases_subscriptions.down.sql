@@ -0,0 +1,29 @@
+CREATE TABLE alerts (
+    id               uuid PRIMARY KEY,
+    user_id          uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_id          text NOT NULL,
+    nature           text NOT NULL DEFAULT '',
+    nature_code      text NOT NULL,
+    active           boolean NOT NULL DEFAULT TRUE,
+    alias            text,
+    last_updated_at  timestamptz NOT NULL DEFAULT NOW(),
+    last_checked_at  timestamptz NOT NULL DEFAULT NOW(),
+    last_accord      text,
+    last_accord_date timestamptz,
+    created_at       timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, case_id, nature_code)
+);
+
+INSERT INTO alerts (id, user_id, case_id, nature, nature_code, active, alias, last_updated_at, last_checked_at, last_accord, last_accord_date, created_at)
+SELECT s.id, s.user_id, c.case_id, c.nature, c.nature_code, s.active, s.alias, c.last_updated_at, c.last_checked_at, c.last_accord, c.last_accord_date, s.created_at
+FROM subscriptions s
+JOIN cases c ON c.id = s.case_ref;
+
+ALTER TABLE alert_links DROP CONSTRAINT alert_links_from_alert_fkey;
+ALTER TABLE alert_links DROP CONSTRAINT alert_links_to_alert_fkey;
+ALTER TABLE alert_links ADD CONSTRAINT alert_links_from_alert_fkey FOREIGN KEY (from_alert_id) REFERENCES alerts (id) ON DELETE CASCADE;
+ALTER TABLE alert_links ADD CONSTRAINT alert_links_to_alert_fkey FOREIGN KEY (to_alert_id) REFERENCES alerts (id) ON DELETE CASCADE;
+
+DROP TABLE case_accords;
+DROP TABLE subscriptions;
+DROP TABLE cases;
@@ -0,0 +1,66 @@
+-- Splits alerts into the state of each case, shared by every user
+-- following it, and the subscriptions of the users to the cases.
+-- Subscriptions keep the id of the alert they come from so links and
+-- urls like /alerta/:id keep working
+
+CREATE TABLE cases (
+    id               uuid PRIMARY KEY,
+    case_id          text NOT NULL,
+    nature_code      text NOT NULL,
+    nature           text NOT NULL DEFAULT '',
+    last_accord      text,
+    last_accord_date timestamptz,
+    last_checked_at  timestamptz NOT NULL DEFAULT NOW(),
+    last_updated_at  timestamptz NOT NULL DEFAULT NOW(),
+    created_at       timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (case_id, nature_code)
+);
+
+CREATE TABLE subscriptions (
+    id          uuid PRIMARY KEY,
+    user_id     uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    case_ref    uuid NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    alias       text,
+    notes       text,
+    active      boolean NOT NULL DEFAULT TRUE,
+    auto_report boolean NOT NULL DEFAULT TRUE,
+    created_at  timestamptz NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, case_ref)
+);
+
+CREATE INDEX subscriptions_case_idx ON subscriptions (case_ref);
+
+CREATE TABLE case_accords (
+    id          uuid PRIMARY KEY,
+    case_ref    uuid NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    nature      text NOT NULL DEFAULT '',
+    accord      text NOT NULL,
+    accord_date timestamptz NOT NULL,
+    created_at  timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX case_accords_unique_idx ON case_accords (case_ref, accord_date, md5(accord));
+
+-- Every copy of a case keeps the most recent accord found
+INSERT INTO cases (id, case_id, nature_code, nature, last_accord, last_accord_date, last_checked_at, last_updated_at, created_at)
+SELECT DISTINCT ON (case_id, nature_code)
+    gen_random_uuid(), case_id, nature_code, nature, last_accord, last_accord_date, last_checked_at, last_updated_at, created_at
+FROM alerts
+ORDER BY case_id, nature_code, last_accord_date DESC NULLS LAST, last_checked_at DESC;
+
+INSERT INTO subscriptions (id, user_id, case_ref, alias, active, created_at)
+SELECT a.id, a.user_id, c.id, a.alias, a.active, a.created_at
+FROM alerts a
+JOIN cases c ON c.case_id = a.case_id AND c.nature_code = a.nature_code;
+
+INSERT INTO case_accords (id, case_ref, nature, accord, accord_date)
+SELECT gen_random_uuid(), id, nature, last_accord, last_accord_date
+FROM cases
+WHERE last_accord IS NOT NULL AND last_accord_date IS NOT NULL;
+
+ALTER TABLE alert_links DROP CONSTRAINT alert_links_from_alert_fkey;
+ALTER TABLE alert_links DROP CONSTRAINT alert_links_to_alert_fkey;
+ALTER TABLE alert_links ADD CONSTRAINT alert_links_from_alert_fkey FOREIGN KEY (from_alert_id) REFERENCES subscriptions (id) ON DELETE CASCADE;
+ALTER TABLE alert_links ADD CONSTRAINT alert_links_to_alert_fkey FOREIGN KEY (to_alert_id) REFERENCES subscriptions (id) ON DELETE CASCADE;
+
+DROP TABLE alerts;
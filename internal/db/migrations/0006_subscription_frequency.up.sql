@@ -0,0 +1,2 @@
+ALTER TABLE subscriptions
+    ADD COLUMN frequency text NOT NULL DEFAULT 'daily' CHECK (frequency IN ('hourly', 'daily', 'weekly'));
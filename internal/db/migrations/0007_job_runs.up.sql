@@ -0,0 +1,32 @@
+CREATE TABLE job_runs (
+    id                uuid PRIMARY KEY,
+    job               text NOT NULL,
+    -- Who started the run: the scheduler, a command or an admin
+    triggered_by      text NOT NULL DEFAULT '',
+    status            text NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed')),
+    started_at        timestamptz NOT NULL DEFAULT NOW(),
+    finished_at       timestamptz,
+    duration_ms       bigint NOT NULL DEFAULT 0,
+    cases_checked     integer NOT NULL DEFAULT 0,
+    cases_found       integer NOT NULL DEFAULT 0,
+    cases_updated     integer NOT NULL DEFAULT 0,
+    cases_not_found   integer NOT NULL DEFAULT 0,
+    -- The accords the update found that were already known
+    cases_unchanged   integer NOT NULL DEFAULT 0,
+    bulletins_fetched integer NOT NULL DEFAULT 0,
+    fetch_errors      integer NOT NULL DEFAULT 0,
+    -- The cases (case_id+nature_code) missing from every bulletin checked
+    not_found_keys    text[] NOT NULL DEFAULT '{}',
+    -- The last error of every court without a readable bulletin
+    court_errors      jsonb NOT NULL DEFAULT '{}',
+    -- What the jobs that don't check cases went through: the notifications
+    -- or webhook events they queued, tried, delivered and failed to deliver
+    items_queued      integer NOT NULL DEFAULT 0,
+    items_processed   integer NOT NULL DEFAULT 0,
+    items_sent        integer NOT NULL DEFAULT 0,
+    items_failed      integer NOT NULL DEFAULT 0,
+    error             text
+);
+
+CREATE INDEX job_runs_job_started_idx ON job_runs (job, started_at DESC);
+CREATE INDEX job_runs_started_idx ON job_runs (started_at DESC);
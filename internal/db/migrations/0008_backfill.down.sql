@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS backfill_checkpoints;
+
+DROP INDEX IF EXISTS docs_entry_key_idx;
+ALTER TABLE docs DROP COLUMN IF EXISTS entry_key;
@@ -0,0 +1,18 @@
+-- Identifies an archived bulletin entry within its court and date so the
+-- backfill can store the same bulletin twice without duplicating docs.
+-- The docs created before the archive keep it NULL
+ALTER TABLE docs ADD COLUMN entry_key text;
+
+CREATE UNIQUE INDEX docs_entry_key_idx ON docs (nature_code, accord_date, entry_key) WHERE entry_key IS NOT NULL;
+
+-- What the backfill found for the bulletin of a court on a date
+CREATE TABLE backfill_checkpoints (
+    nature_code   text NOT NULL,
+    bulletin_date date NOT NULL,
+    status        text NOT NULL CHECK (status IN ('done', 'missing', 'failed')),
+    entries       integer NOT NULL DEFAULT 0,
+    attempts      integer NOT NULL DEFAULT 1,
+    error         text,
+    updated_at    timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (nature_code, bulletin_date)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS last_notified_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS last_viewed_at;
+
+DROP TABLE IF EXISTS case_changes;
@@ -0,0 +1,17 @@
+-- Every accord that changed the state of a case, the rechecks that find
+-- the same accord again don't add rows
+CREATE TABLE case_changes (
+    id                   uuid PRIMARY KEY,
+    case_ref             uuid NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    nature               text NOT NULL DEFAULT '',
+    accord               text NOT NULL,
+    accord_date          timestamptz NOT NULL,
+    previous_accord      text,
+    previous_accord_date timestamptz,
+    detected_at          timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX case_changes_case_idx ON case_changes (case_ref, detected_at DESC);
+
+ALTER TABLE subscriptions ADD COLUMN last_viewed_at timestamptz;
+ALTER TABLE subscriptions ADD COLUMN last_notified_at timestamptz;
@@ -0,0 +1,25 @@
+-- The channels a user chose for every kind of notification. Without a row
+-- the defaults of db.DefaultChannels apply
+CREATE TABLE notification_preferences (
+    user_id    uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event      text NOT NULL,
+    channel    text NOT NULL,
+    enabled    boolean NOT NULL DEFAULT true,
+    -- Where the channel delivers when it doesn't use the contact data of the user
+    target     text,
+    updated_at timestamptz NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, event, channel)
+);
+
+-- Every attempt to deliver a notification through a channel
+CREATE TABLE notification_deliveries (
+    id         uuid PRIMARY KEY,
+    user_id    uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event      text NOT NULL,
+    channel    text NOT NULL,
+    status     text NOT NULL CHECK (status IN ('sent', 'failed', 'skipped')),
+    error      text,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notification_deliveries_user_idx ON notification_deliveries (user_id, created_at DESC);
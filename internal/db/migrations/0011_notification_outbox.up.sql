@@ -0,0 +1,25 @@
+-- The notifications waiting to be delivered. They are written in the same
+-- statement that records what they notify about, so none gets lost, and
+-- idempotency_key keeps the same notification from being queued twice
+CREATE TABLE notification_outbox (
+    id                 uuid PRIMARY KEY,
+    user_id            uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event              text NOT NULL,
+    idempotency_key    text NOT NULL UNIQUE,
+    -- The notify.Message to deliver, without its user
+    payload            jsonb NOT NULL DEFAULT '{}',
+    -- dead: it failed too many times and waits for an admin to requeue it
+    status             text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts           integer NOT NULL DEFAULT 0,
+    next_attempt_at    timestamptz NOT NULL DEFAULT NOW(),
+    -- Set while a worker delivers it, the lock expires if the worker dies
+    locked_until       timestamptz,
+    -- The channels that already got it, retries skip them
+    delivered_channels text[] NOT NULL DEFAULT '{}',
+    last_error         text,
+    created_at         timestamptz NOT NULL DEFAULT NOW(),
+    sent_at            timestamptz
+);
+
+CREATE INDEX notification_outbox_due_idx ON notification_outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX notification_outbox_status_idx ON notification_outbox (status, created_at DESC);
@@ -0,0 +1,14 @@
+-- When every user gets the email digest of the new accords in their cases.
+-- Users without a row get no digest
+CREATE TABLE digest_settings (
+    user_id      uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    enabled      boolean NOT NULL DEFAULT true,
+    frequency    text NOT NULL DEFAULT 'daily' CHECK (frequency IN ('daily', 'weekly')),
+    -- The local hour it's sent at, and the day of the week (0 is sunday) for the weekly ones
+    hour         smallint NOT NULL DEFAULT 8 CHECK (hour BETWEEN 0 AND 23),
+    weekday      smallint NOT NULL DEFAULT 1 CHECK (weekday BETWEEN 0 AND 6),
+    attach_pdf   boolean NOT NULL DEFAULT false,
+    -- The changes detected until here were already in a digest
+    last_sent_at timestamptz NOT NULL DEFAULT NOW(),
+    updated_at   timestamptz NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,16 @@
+-- The WhatsApp messages sent and what the webhook told about them
+CREATE TABLE whatsapp_messages (
+    -- The id WhatsApp gave the message, its webhooks come with it
+    id           text PRIMARY KEY,
+    user_id      uuid REFERENCES users (id) ON DELETE SET NULL,
+    phone        text NOT NULL,
+    template     text NOT NULL,
+    status       text NOT NULL DEFAULT 'sent' CHECK (status IN ('sent', 'delivered', 'read', 'failed')),
+    error        text,
+    delivered_at timestamptz,
+    read_at      timestamptz,
+    created_at   timestamptz NOT NULL DEFAULT NOW(),
+    updated_at   timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX whatsapp_messages_user_idx ON whatsapp_messages (user_id, created_at DESC);
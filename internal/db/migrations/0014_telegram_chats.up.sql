@@ -0,0 +1,9 @@
+-- The Telegram chats linked to the users, they get their notifications
+-- and can use the bot from them
+CREATE TABLE telegram_chats (
+    user_id   uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    chat_id   bigint NOT NULL UNIQUE,
+    -- The username in Telegram when the chat was linked, it may have none
+    username  text,
+    linked_at timestamptz NOT NULL DEFAULT NOW()
+);
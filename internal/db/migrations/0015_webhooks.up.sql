@@ -0,0 +1,43 @@
+-- The URLs that receive the events of a user as signed JSON. The ones
+-- without a user belong to the organization, they are managed by the
+-- admins and receive the events of every user
+CREATE TABLE webhook_endpoints (
+    id          uuid PRIMARY KEY,
+    user_id     uuid REFERENCES users (id) ON DELETE CASCADE,
+    url         text NOT NULL,
+    -- The key of the HMAC in the signature header
+    secret      text NOT NULL,
+    -- The events sent to the endpoint, all of them when empty
+    events      text[] NOT NULL DEFAULT '{}',
+    description text NOT NULL DEFAULT '',
+    created_at  timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_endpoints_user_idx ON webhook_endpoints (user_id);
+
+-- Every event sent to an endpoint. Like the notification outbox, the
+-- events are written in the same statement that changes the state they
+-- tell about, and event_id keeps an endpoint from getting one twice
+CREATE TABLE webhook_deliveries (
+    id              uuid PRIMARY KEY,
+    endpoint_id     uuid NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event           text NOT NULL,
+    -- The same for every endpoint that gets the event
+    event_id        text NOT NULL,
+    -- The data of the event, sent inside the envelope of webhooks.Payload
+    payload         jsonb NOT NULL DEFAULT '{}',
+    -- dead: it failed too many times
+    status          text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts        integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT NOW(),
+    locked_until    timestamptz,
+    -- The HTTP status of the last response, NULL when there was none
+    response_status integer,
+    last_error      text,
+    created_at      timestamptz NOT NULL DEFAULT NOW(),
+    sent_at         timestamptz,
+    UNIQUE (endpoint_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at DESC);
@@ -0,0 +1,15 @@
+-- The browsers subscribed with the Push API, a user may have several.
+-- The endpoint identifies the subscription, when another user subscribes
+-- the same browser it moves to them
+CREATE TABLE push_subscriptions (
+    id         uuid PRIMARY KEY,
+    user_id    uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    endpoint   text NOT NULL UNIQUE,
+    -- The keys of the browser to encrypt the messages, base64url
+    p256dh     text NOT NULL,
+    auth       text NOT NULL,
+    user_agent text,
+    created_at timestamptz NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX push_subscriptions_user_idx ON push_subscriptions (user_id);
@@ -0,0 +1,7 @@
+-- The phones that replied BAJA to the SMS, they get no more messages
+-- until they reply ALTA. They are kept by phone and not by user because
+-- the phone is who asked
+CREATE TABLE sms_opt_outs (
+    phone        text PRIMARY KEY,
+    opted_out_at timestamptz NOT NULL DEFAULT NOW()
+);
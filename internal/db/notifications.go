@@ -0,0 +1,211 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// The events a user can be notified about
+const (
+	// A new accord in a followed case
+	NotificationAccord = "accord"
+	// New accords in the cases with an automatic report
+	NotificationReport = "report"
+	// The patterns of a watch were found in a bulletin
+	NotificationWatchMatch = "watch_match"
+	// The new accords in the cases of the user since their last digest,
+	// sent as scheduled in their DigestSettings
+	NotificationDigest = "digest"
+	// The link to verify the email of a new user. It's always sent
+	// through its default channels, preferences don't apply to it
+	NotificationVerification = "verification"
+)
+
+// The channels the notifications are delivered through
+const (
+	ChannelEmail    = "email"
+	ChannelWhatsApp = "whatsapp"
+	// Sends the notification to the Telegram chat linked by the user
+	ChannelTelegram = "telegram"
+	// Shows the notification in the browsers the user subscribed
+	ChannelPush = "push"
+	// Sends a short text to the verified phone of the user, unless the
+	// phone opted out
+	ChannelSMS = "sms"
+)
+
+// What happened with a delivery
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+	// The channel couldn't deliver the event, or the user has no
+	// address for it (e.g. no phone for WhatsApp)
+	DeliverySkipped = "skipped"
+)
+
+// The channels used for every event when the user didn't choose any
+var DefaultChannels = map[string][]string{
+	NotificationAccord:       {ChannelEmail},
+	NotificationReport:       {ChannelWhatsApp},
+	NotificationWatchMatch:   {ChannelWhatsApp},
+	NotificationDigest:       {ChannelEmail},
+	NotificationVerification: {ChannelEmail},
+}
+
+// The events users can choose channels for, with their description
+var NotificationEvents = []struct {
+	Event string
+	Label string
+}{
+	{NotificationAccord, "Cada acuerdo nuevo"},
+	{NotificationReport, "Reporte de acuerdos nuevos"},
+	{NotificationWatchMatch, "Coincidencias de vigilancias"},
+}
+
+// The channels users can choose, with their description
+var NotificationChannels = []struct {
+	Channel string
+	Label   string
+}{
+	{ChannelEmail, "Correo"},
+	{ChannelWhatsApp, "WhatsApp"},
+	{ChannelTelegram, "Telegram"},
+	{ChannelPush, "Navegador"},
+	{ChannelSMS, "SMS"},
+}
+
+// A NotificationPreference turns a channel on or off for an event
+type NotificationPreference struct {
+	UserId  string `json:"userId" db:"user_id"`
+	Event   string `json:"event" db:"event"`
+	Channel string `json:"channel" db:"channel"`
+	Enabled bool   `json:"enabled" db:"enabled"`
+	// Where the channel delivers, for the channels that need one. None
+	// does since the webhooks became WebhookEndpoints
+	Target    sql.NullString `json:"target" db:"target"`
+	UpdatedAt time.Time      `json:"updatedAt" db:"updated_at"`
+}
+
+// A NotificationDelivery records an attempt to notify a user through a channel
+type NotificationDelivery struct {
+	Id        string         `json:"id" db:"id"`
+	UserId    string         `json:"userId" db:"user_id"`
+	Event     string         `json:"event" db:"event"`
+	Channel   string         `json:"channel" db:"channel"`
+	Status    string         `json:"status" db:"status"`
+	Error     sql.NullString `json:"error" db:"error"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// FindNotificationPreferences returns every preference the user saved
+func FindNotificationPreferences(ctx context.Context, userId string) ([]*NotificationPreference, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM notification_preferences WHERE user_id = $1 ORDER BY event, channel",
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*NotificationPreference](rows, pgx.RowToAddrOfStructByName[NotificationPreference])
+}
+
+// SaveNotificationPreference creates or replaces the preference
+// of the user for the event and channel of pref
+func SaveNotificationPreference(ctx context.Context, pref *NotificationPreference) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	row := conn.QueryRow(
+		ctx,
+		`INSERT INTO notification_preferences (user_id, event, channel, enabled, target) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, event, channel) DO UPDATE SET enabled = EXCLUDED.enabled, target = EXCLUDED.target, updated_at = NOW()
+		RETURNING updated_at`,
+		pref.UserId,
+		pref.Event,
+		pref.Channel,
+		pref.Enabled,
+		pref.Target,
+	)
+
+	return row.Scan(&pref.UpdatedAt)
+}
+
+// CreateNotificationDelivery stores delivery, filling its id and date
+func CreateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	delivery.Id = id.String()
+
+	row := conn.QueryRow(
+		ctx,
+		"INSERT INTO notification_deliveries (id, user_id, event, channel, status, error) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at",
+		delivery.Id,
+		delivery.UserId,
+		delivery.Event,
+		delivery.Channel,
+		delivery.Status,
+		delivery.Error,
+	)
+
+	return row.Scan(&delivery.CreatedAt)
+}
+
+// FindNotificationDeliveries returns the latest deliveries to the user, newest first
+func FindNotificationDeliveries(ctx context.Context, userId string, limit int) ([]*NotificationDelivery, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM notification_deliveries WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2",
+		userId,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*NotificationDelivery](rows, pgx.RowToAddrOfStructByName[NotificationDelivery])
+}
@@ -38,15 +38,19 @@ const (
 	OTLActionVerify    = "XXVERIFY"
 	OTLActionLogin     = "XXXLOGIN"
 	OTLActionResetPass = "RESTPASS"
+	// Links a phone to the user, the code is sent from the phone
+	OTLActionPhone = "XXXPHONE"
+	// Links a Telegram chat to the user, the code is sent from the chat
+	OTLActionTelegram = "TELEGRAM"
 )
 
-func CreateOTLink(userId uuid.UUID, action string) (*OTLink, error) {
-	conn, err := GetPool()
+func CreateOTLink(ctx context.Context, userId uuid.UUID, action string) (*OTLink, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	otlId, err := uuid.NewV7()
@@ -90,16 +94,16 @@ func CreateOTLink(userId uuid.UUID, action string) (*OTLink, error) {
 	return otLink, nil
 }
 
-func CreateVerifyOTL(userId uuid.UUID) (*OTLink, error) {
-	return CreateOTLink(userId, OTLActionVerify)
+func CreateVerifyOTL(ctx context.Context, userId uuid.UUID) (*OTLink, error) {
+	return CreateOTLink(ctx, userId, OTLActionVerify)
 }
 
-func CreateLoginOTL(userId uuid.UUID) (*OTLink, error) {
-	return CreateOTLink(userId, OTLActionLogin)
+func CreateLoginOTL(ctx context.Context, userId uuid.UUID) (*OTLink, error) {
+	return CreateOTLink(ctx, userId, OTLActionLogin)
 }
 
-func CreateResetPassOTL(userId uuid.UUID) (*OTLink, error) {
-	return CreateOTLink(userId, OTLActionResetPass)
+func CreateResetPassOTL(ctx context.Context, userId uuid.UUID) (*OTLink, error) {
+	return CreateOTLink(ctx, userId, OTLActionResetPass)
 }
 
 func TxFindUserOTLinkByCode(ctx context.Context, tx pgx.Tx, code, userId uuid.UUID) (*OTLink, error) {
@@ -158,13 +162,13 @@ func TxMarkOTLinkAsUsed(ctx context.Context, tx pgx.Tx, code, userId uuid.UUID)
 	return nil
 }
 
-func MarkOTLinkAsUsed(code uuid.UUID, userId uuid.UUID) error {
-	conn, err := GetPool()
+func MarkOTLinkAsUsed(ctx context.Context, code uuid.UUID, userId uuid.UUID) error {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	tag, err := conn.Exec(
@@ -186,3 +190,98 @@ func MarkOTLinkAsUsed(code uuid.UUID, userId uuid.UUID) error {
 
 	return nil
 }
+
+func FindUserOTLinkByCode(ctx context.Context, code, userId uuid.UUID) (*OTLink, error) {
+	tx, conn, err := GetTxAndPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+	defer tx.Rollback(ctx)
+
+	return TxFindUserOTLinkByCode(ctx, tx, code, userId)
+}
+
+// UseVerifyOTL marks the link as used and verifies the email of its user
+// in a single transaction. Links already used are reported as non existent
+func UseVerifyOTL(ctx context.Context, code, userId uuid.UUID) error {
+	tx, conn, err := GetTxAndPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		"UPDATE otlinks SET used = TRUE WHERE code = $1 AND user_id = $2 AND used = FALSE",
+		code,
+		userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return &NonExistentOTLError{
+			Code: code.String(),
+			User: userId.String(),
+		}
+	}
+
+	err = TxVerifyUserEmail(ctx, tx, userId.String())
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UsePhoneOTL marks the phone link with code as used and makes phone the
+// verified phone of its user in a single transaction, returning the user.
+// Links used, expired or for other actions are reported as non existent
+func UsePhoneOTL(ctx context.Context, code uuid.UUID, phone string) (string, error) {
+	tx, conn, err := GetTxAndPool(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+	defer tx.Rollback(ctx)
+
+	var userId string
+
+	err = tx.QueryRow(
+		ctx,
+		`UPDATE otlinks SET used = TRUE WHERE code = $1 AND action = $2 AND used = FALSE AND expires_at > NOW()
+		RETURNING user_id::text`,
+		code,
+		OTLActionPhone,
+	).Scan(&userId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &NonExistentOTLError{Code: code.String()}
+		}
+
+		return "", err
+	}
+
+	err = TxVerifyUserPhone(ctx, tx, userId, phone)
+
+	if err != nil {
+		return "", err
+	}
+
+	return userId, tx.Commit(ctx)
+}
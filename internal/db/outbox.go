@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	// It failed too many times, it waits for an admin to requeue it
+	OutboxDead = "dead"
+)
+
+// An OutboxItem is a notification waiting to be delivered, or the
+// record of one that was. See notify.Message for the payload
+type OutboxItem struct {
+	Id             string          `json:"id" db:"id"`
+	UserId         string          `json:"userId" db:"user_id"`
+	Event          string          `json:"event" db:"event"`
+	IdempotencyKey string          `json:"idempotencyKey" db:"idempotency_key"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"nextAttemptAt" db:"next_attempt_at"`
+	LockedUntil    sql.NullTime    `json:"lockedUntil" db:"locked_until"`
+	// The channels that already got the notification
+	DeliveredChannels []string       `json:"deliveredChannels" db:"delivered_channels"`
+	LastError         sql.NullString `json:"lastError" db:"last_error"`
+	CreatedAt         time.Time      `json:"createdAt" db:"created_at"`
+	SentAt            sql.NullTime   `json:"sentAt" db:"sent_at"`
+}
+
+type OutboxFilter struct {
+	// Only the items with this status, every status when empty
+	Status string
+	Limit  int
+}
+
+// EnqueueNotification queues item unless another one has its
+// IdempotencyKey, and reports whether it was queued
+func EnqueueNotification(ctx context.Context, item *OutboxItem) (bool, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return false, err
+	}
+
+	tag, err := conn.Exec(
+		ctx,
+		`INSERT INTO notification_outbox (id, user_id, event, idempotency_key, payload) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (idempotency_key) DO NOTHING`,
+		id,
+		item.UserId,
+		item.Event,
+		item.IdempotencyKey,
+		item.Payload,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	item.Id = id.String()
+	item.Status = OutboxPending
+
+	return true, nil
+}
+
+// ClaimDueNotifications locks up to limit pending items due by now for
+// lockFor and counts the attempt. Workers running at the same time never
+// claim the same item, and an item whose worker died is claimed again
+// once its lock expires
+func ClaimDueNotifications(ctx context.Context, limit int, lockFor time.Duration) ([]*OutboxItem, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`UPDATE notification_outbox SET locked_until = NOW() + $2::interval, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM notification_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		) RETURNING *`,
+		limit,
+		lockFor,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*OutboxItem](rows, pgx.RowToAddrOfStructByName[OutboxItem])
+}
+
+// FinishNotification stores the outcome of the delivery of a claimed
+// item: its status, channels delivered, error and next attempt
+func FinishNotification(ctx context.Context, item *OutboxItem) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	channels := item.DeliveredChannels
+	if channels == nil {
+		channels = []string{}
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		`UPDATE notification_outbox SET status = $2, delivered_channels = $3, last_error = $4, next_attempt_at = $5,
+		sent_at = $6, locked_until = NULL WHERE id = $1`,
+		item.Id,
+		item.Status,
+		channels,
+		item.LastError,
+		item.NextAttemptAt,
+		item.SentAt,
+	)
+
+	return err
+}
+
+// RequeueNotification makes a dead item pending again with its
+// attempts reset. It fails with pgx.ErrNoRows for any other item
+func RequeueNotification(ctx context.Context, id string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(
+		ctx,
+		"UPDATE notification_outbox SET status = 'pending', attempts = 0, next_attempt_at = NOW(), locked_until = NULL WHERE id = $1 AND status = 'dead'",
+		id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// FindNotifications returns the items matching filter, newest first
+func FindNotifications(ctx context.Context, filter OutboxFilter) ([]*OutboxItem, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM notification_outbox WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC LIMIT $2",
+		filter.Status,
+		filter.Limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*OutboxItem](rows, pgx.RowToAddrOfStructByName[OutboxItem])
+}
+
+// CountNotifications returns how many items there are with every status
+func CountNotifications(ctx context.Context) (map[string]int, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT status, COUNT(*)::int FROM notification_outbox GROUP BY status")
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{OutboxPending: 0, OutboxSent: 0, OutboxDead: 0}
+
+	for rows.Next() {
+		var (
+			status string
+			count  int
+		)
+
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+
+		counts[status] = count
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// A PushSubscription is a browser of the user subscribed with the Push API
+type PushSubscription struct {
+	Id       string `json:"id" db:"id"`
+	UserId   string `json:"userId" db:"user_id"`
+	Endpoint string `json:"endpoint" db:"endpoint"`
+	// The keys of the browser, base64url
+	P256dh    string         `json:"p256dh" db:"p256dh"`
+	Auth      string         `json:"auth" db:"auth"`
+	UserAgent sql.NullString `json:"userAgent" db:"user_agent"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// SavePushSubscription stores the subscription, or updates the one with
+// the same endpoint, which moves to sub.UserId if it belonged to another
+// user. It fills sub.Id and sub.CreatedAt
+func SavePushSubscription(ctx context.Context, sub *PushSubscription) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	return conn.QueryRow(
+		ctx,
+		`INSERT INTO push_subscriptions (id, user_id, endpoint, p256dh, auth, user_agent) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (endpoint) DO UPDATE SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth, user_agent = EXCLUDED.user_agent
+		RETURNING id::text, created_at`,
+		id,
+		sub.UserId,
+		sub.Endpoint,
+		sub.P256dh,
+		sub.Auth,
+		sub.UserAgent,
+	).Scan(&sub.Id, &sub.CreatedAt)
+}
+
+// FindPushSubscriptions returns the browsers subscribed by the user
+func FindPushSubscriptions(ctx context.Context, userId string) ([]*PushSubscription, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM push_subscriptions WHERE user_id = $1 ORDER BY created_at",
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*PushSubscription](rows, pgx.RowToAddrOfStructByName[PushSubscription])
+}
+
+// DeletePushSubscription removes the subscription of the user with the
+// endpoint, it fails with pgx.ErrNoRows when they have none
+func DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(
+		ctx,
+		"DELETE FROM push_subscriptions WHERE user_id = $1 AND endpoint = $2",
+		userId,
+		endpoint,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,516 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The repositories hide the storage from the handlers and jobs. The
+// Postgres implementation delegates to the functions of this package,
+// internal/db/memdb keeps everything in memory for tests
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *User) (string, error)
+	GetUserById(ctx context.Context, id string) (*User, error)
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	VerifyUserEmail(ctx context.Context, userId string) error
+	GetUserByPhone(ctx context.Context, phone string) (*User, error)
+}
+
+type AlertRepository interface {
+	FindAlertById(ctx context.Context, id string) (*Alert, error)
+	FindAlertsByUser(ctx context.Context, userId string, findActive bool) ([]*Alert, error)
+	FindActiveAlertsByCase(ctx context.Context, caseId, natureCode string) ([]*Alert, error)
+	FindUserAlertByCase(ctx context.Context, userId, caseId, natureCode string) (*Alert, error)
+	FindAutoReportAlertsForUser(ctx context.Context, userId string) (*[]Alert, error)
+	FindAutoReportAlertsWithUserData(ctx context.Context, changedOnly bool) ([]*AutoReportUser, error)
+	CreateAlertWithData(ctx context.Context, data *Alert) (*Alert, error)
+	UpdateUserSubscription(ctx context.Context, id, userId string, alias, notes sql.NullString, autoReport bool, frequency Frequency) error
+	UpdateAlertsForCases(ctx context.Context, caseData []*Doc) (*CaseUpdates, error)
+	UpdateAlertAccords(ctx context.Context, alertsData []*Alert) error
+	UpdateAlertAccord(ctx context.Context, userId, caseId, natureCode string, updatedAlert *Alert) error
+	DeleteUserAlertById(ctx context.Context, id, userId string) error
+	MarkAlertViewed(ctx context.Context, id, userId string) error
+	MarkAlertsNotified(ctx context.Context, ids []string, notifiedAt time.Time) error
+
+	FindActiveCases(ctx context.Context, searchDate time.Time) ([]*Case, error)
+	FindDueCases(ctx context.Context, now time.Time) ([]*Case, error)
+	MarkCasesChecked(ctx context.Context, ids []string) error
+	FindCaseAccords(ctx context.Context, caseRef string, limit int) ([]*CaseAccord, error)
+}
+
+type DocRepository interface {
+	GetDocs(ctx context.Context, userId string) ([]Doc, error)
+	GetDocByID(ctx context.Context, id string) (*Doc, error)
+	GetDocByCase(ctx context.Context, caseID string) (*Doc, error)
+	CreateDoc(ctx context.Context, id, caseId, nature, natureCode, accord string, date time.Time) error
+	SearchDocs(ctx context.Context, params DocSearchParams) (*DocSearchResult, error)
+}
+
+type OTLinkRepository interface {
+	CreateOTLink(ctx context.Context, userId uuid.UUID, action string) (*OTLink, error)
+	FindUserOTLinkByCode(ctx context.Context, code, userId uuid.UUID) (*OTLink, error)
+	MarkOTLinkAsUsed(ctx context.Context, code, userId uuid.UUID) error
+	UseVerifyOTL(ctx context.Context, code, userId uuid.UUID) error
+	UsePhoneOTL(ctx context.Context, code uuid.UUID, phone string) (string, error)
+	UseTelegramOTL(ctx context.Context, code uuid.UUID, chat *TelegramChat) error
+}
+
+type WatchRepository interface {
+	CreateWatch(ctx context.Context, data *Watch) (*Watch, error)
+	FindWatchesByUser(ctx context.Context, userId string) ([]*Watch, error)
+	FindActiveWatches(ctx context.Context) ([]*Watch, error)
+	DeleteUserWatchById(ctx context.Context, id, userId string) error
+	CreateWatchMatches(ctx context.Context, matches []*WatchMatch) ([]*WatchMatch, error)
+	FindWatchMatchesByUser(ctx context.Context, userId string, limit int) ([]*WatchMatch, error)
+	FindPendingWatchMatches(ctx context.Context) ([]*WatchMatch, error)
+	MarkWatchMatchesAsNotified(ctx context.Context, ids []string) error
+}
+
+type LinkRepository interface {
+	CreateAlertLink(ctx context.Context, link *AlertLink) (*AlertLink, bool, error)
+	FindAlertLinkById(ctx context.Context, id, userId string) (*AlertLink, error)
+	FindLinkedAlerts(ctx context.Context, alertId string) ([]*LinkedAlert, error)
+	ConfirmAlertLink(ctx context.Context, id, userId, toAlertId string) error
+	DismissAlertLink(ctx context.Context, id, userId string) error
+}
+
+type JobRunRepository interface {
+	CreateJobRun(ctx context.Context, run *JobRun) error
+	FinishJobRun(ctx context.Context, run *JobRun) error
+	FindJobRuns(ctx context.Context, filter JobRunFilter) ([]*JobRun, error)
+	FindJobRunDays(ctx context.Context, since time.Time) ([]*JobRunDay, error)
+}
+
+type BackfillRepository interface {
+	FindBackfillCheckpoints(ctx context.Context, natureCodes []string, from, to time.Time) (map[string]*BackfillCheckpoint, error)
+	SaveBackfillCheckpoint(ctx context.Context, cp *BackfillCheckpoint) error
+	ArchiveDocs(ctx context.Context, docs []*Doc) (int, error)
+}
+
+type NotificationRepository interface {
+	FindNotificationPreferences(ctx context.Context, userId string) ([]*NotificationPreference, error)
+	SaveNotificationPreference(ctx context.Context, pref *NotificationPreference) error
+	CreateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error
+	FindNotificationDeliveries(ctx context.Context, userId string, limit int) ([]*NotificationDelivery, error)
+}
+
+type OutboxRepository interface {
+	EnqueueNotification(ctx context.Context, item *OutboxItem) (bool, error)
+	ClaimDueNotifications(ctx context.Context, limit int, lockFor time.Duration) ([]*OutboxItem, error)
+	FinishNotification(ctx context.Context, item *OutboxItem) error
+	RequeueNotification(ctx context.Context, id string) error
+	FindNotifications(ctx context.Context, filter OutboxFilter) ([]*OutboxItem, error)
+	CountNotifications(ctx context.Context) (map[string]int, error)
+}
+
+type DigestRepository interface {
+	FindDigestSettings(ctx context.Context, userId string) (*DigestSettings, error)
+	SaveDigestSettings(ctx context.Context, settings *DigestSettings) error
+	FindEnabledDigests(ctx context.Context) ([]*DigestSettings, error)
+	FindDigestChanges(ctx context.Context, userId string, since, until time.Time) ([]*DigestChange, error)
+	MarkDigestSent(ctx context.Context, userId string, sentAt time.Time) error
+}
+
+type WhatsAppRepository interface {
+	CreateWhatsAppMessage(ctx context.Context, msg *WhatsAppMessage) error
+	UpdateWhatsAppMessageStatus(ctx context.Context, id, status string, at time.Time, errMsg sql.NullString) error
+	FindWhatsAppMessages(ctx context.Context, userId string, limit int) ([]*WhatsAppMessage, error)
+}
+
+type TelegramRepository interface {
+	FindTelegramChat(ctx context.Context, userId string) (*TelegramChat, error)
+	GetUserByTelegramChat(ctx context.Context, chatId int64) (*User, error)
+	DeleteTelegramChat(ctx context.Context, userId string) error
+}
+
+type WebhookRepository interface {
+	CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	FindWebhookEndpoints(ctx context.Context, userId string) ([]*WebhookEndpoint, error)
+	FindWebhookEndpointById(ctx context.Context, id string) (*WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, id, userId string) error
+	EnqueueWebhookEvent(ctx context.Context, event *WebhookEvent) (int, error)
+	CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	ClaimDueWebhookDeliveries(ctx context.Context, limit int, lockFor time.Duration) ([]*WebhookDelivery, error)
+	FinishWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	FindWebhookDeliveries(ctx context.Context, endpointId string, limit int) ([]*WebhookDelivery, error)
+}
+
+type PushRepository interface {
+	SavePushSubscription(ctx context.Context, sub *PushSubscription) error
+	FindPushSubscriptions(ctx context.Context, userId string) ([]*PushSubscription, error)
+	DeletePushSubscription(ctx context.Context, userId, endpoint string) error
+}
+
+type SMSRepository interface {
+	OptOutSMS(ctx context.Context, phone string) error
+	OptInSMS(ctx context.Context, phone string) error
+	IsSMSOptedOut(ctx context.Context, phone string) (bool, error)
+}
+
+type Locker interface {
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// A Store groups the repositories used by the app
+type Store struct {
+	Users         UserRepository
+	Alerts        AlertRepository
+	Docs          DocRepository
+	OTLinks       OTLinkRepository
+	Watches       WatchRepository
+	Links         LinkRepository
+	JobRuns       JobRunRepository
+	Backfill      BackfillRepository
+	Notifications NotificationRepository
+	Outbox        OutboxRepository
+	Digests       DigestRepository
+	WhatsApp      WhatsAppRepository
+	Telegram      TelegramRepository
+	Webhooks      WebhookRepository
+	Push          PushRepository
+	SMS           SMSRepository
+	Locks         Locker
+}
+
+// NewStore returns a Store backed by the Postgres pool in DB
+func NewStore() *Store {
+	return &Store{
+		Users:         pgUsers{},
+		Alerts:        pgAlerts{},
+		Docs:          pgDocs{},
+		OTLinks:       pgOTLinks{},
+		Watches:       pgWatches{},
+		Links:         pgLinks{},
+		JobRuns:       pgJobRuns{},
+		Backfill:      pgBackfill{},
+		Notifications: pgNotifications{},
+		Outbox:        pgOutbox{},
+		Digests:       pgDigests{},
+		WhatsApp:      pgWhatsApp{},
+		Telegram:      pgTelegram{},
+		Webhooks:      pgWebhooks{},
+		Push:          pgPush{},
+		SMS:           pgSMS{},
+		Locks:         pgLocks{},
+	}
+}
+
+type pgUsers struct{}
+
+func (pgUsers) CreateUser(ctx context.Context, user *User) (string, error) {
+	return CreateUser(ctx, user)
+}
+func (pgUsers) GetUserById(ctx context.Context, id string) (*User, error) {
+	return GetUserById(ctx, id)
+}
+func (pgUsers) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	return GetUserByUsername(ctx, username)
+}
+func (pgUsers) VerifyUserEmail(ctx context.Context, userId string) error {
+	return VerifyUserEmail(ctx, userId)
+}
+func (pgUsers) GetUserByPhone(ctx context.Context, phone string) (*User, error) {
+	return GetUserByPhone(ctx, phone)
+}
+
+type pgAlerts struct{}
+
+func (pgAlerts) FindAlertById(ctx context.Context, id string) (*Alert, error) {
+	return FindAlertById(ctx, id)
+}
+func (pgAlerts) FindAlertsByUser(ctx context.Context, userId string, findActive bool) ([]*Alert, error) {
+	return FindAlertsByUser(ctx, userId, findActive)
+}
+func (pgAlerts) FindActiveAlertsByCase(ctx context.Context, caseId, natureCode string) ([]*Alert, error) {
+	return FindActiveAlertsByCase(ctx, caseId, natureCode)
+}
+func (pgAlerts) FindUserAlertByCase(ctx context.Context, userId, caseId, natureCode string) (*Alert, error) {
+	return FindUserAlertByCase(ctx, userId, caseId, natureCode)
+}
+func (pgAlerts) FindAutoReportAlertsForUser(ctx context.Context, userId string) (*[]Alert, error) {
+	return FindAutoReportAlertsForUser(ctx, userId)
+}
+func (pgAlerts) FindAutoReportAlertsWithUserData(ctx context.Context, changedOnly bool) ([]*AutoReportUser, error) {
+	return FindAutoReportAlertsWithUserData(ctx, changedOnly)
+}
+func (pgAlerts) CreateAlertWithData(ctx context.Context, data *Alert) (*Alert, error) {
+	return CreateAlertWithData(ctx, data)
+}
+func (pgAlerts) UpdateUserSubscription(ctx context.Context, id, userId string, alias, notes sql.NullString, autoReport bool, frequency Frequency) error {
+	return UpdateUserSubscription(ctx, id, userId, alias, notes, autoReport, frequency)
+}
+func (pgAlerts) UpdateAlertsForCases(ctx context.Context, caseData []*Doc) (*CaseUpdates, error) {
+	return UpdateAlertsForCases(ctx, caseData)
+}
+func (pgAlerts) UpdateAlertAccords(ctx context.Context, alertsData []*Alert) error {
+	return UpdateAlertAccords(ctx, alertsData)
+}
+func (pgAlerts) UpdateAlertAccord(ctx context.Context, userId, caseId, natureCode string, updatedAlert *Alert) error {
+	return UpdateAlertAccord(ctx, userId, caseId, natureCode, updatedAlert)
+}
+func (pgAlerts) DeleteUserAlertById(ctx context.Context, id, userId string) error {
+	return DeleteUserAlertById(ctx, id, userId)
+}
+func (pgAlerts) MarkAlertViewed(ctx context.Context, id, userId string) error {
+	return MarkAlertViewed(ctx, id, userId)
+}
+func (pgAlerts) MarkAlertsNotified(ctx context.Context, ids []string, notifiedAt time.Time) error {
+	return MarkAlertsNotified(ctx, ids, notifiedAt)
+}
+func (pgAlerts) FindActiveCases(ctx context.Context, searchDate time.Time) ([]*Case, error) {
+	return FindActiveCases(ctx, searchDate)
+}
+func (pgAlerts) FindDueCases(ctx context.Context, now time.Time) ([]*Case, error) {
+	return FindDueCases(ctx, now)
+}
+func (pgAlerts) MarkCasesChecked(ctx context.Context, ids []string) error {
+	return MarkCasesChecked(ctx, ids)
+}
+func (pgAlerts) FindCaseAccords(ctx context.Context, caseRef string, limit int) ([]*CaseAccord, error) {
+	return FindCaseAccords(ctx, caseRef, limit)
+}
+
+type pgDocs struct{}
+
+func (pgDocs) GetDocs(ctx context.Context, userId string) ([]Doc, error) { return GetDocs(ctx, userId) }
+func (pgDocs) GetDocByID(ctx context.Context, id string) (*Doc, error)   { return GetDocByID(ctx, id) }
+func (pgDocs) GetDocByCase(ctx context.Context, caseID string) (*Doc, error) {
+	return GetDocByCase(ctx, caseID)
+}
+func (pgDocs) CreateDoc(ctx context.Context, id, caseId, nature, natureCode, accord string, date time.Time) error {
+	return CreateDoc(ctx, id, caseId, nature, natureCode, accord, date)
+}
+func (pgDocs) SearchDocs(ctx context.Context, params DocSearchParams) (*DocSearchResult, error) {
+	return SearchDocs(ctx, params)
+}
+
+type pgOTLinks struct{}
+
+func (pgOTLinks) CreateOTLink(ctx context.Context, userId uuid.UUID, action string) (*OTLink, error) {
+	return CreateOTLink(ctx, userId, action)
+}
+func (pgOTLinks) FindUserOTLinkByCode(ctx context.Context, code, userId uuid.UUID) (*OTLink, error) {
+	return FindUserOTLinkByCode(ctx, code, userId)
+}
+func (pgOTLinks) MarkOTLinkAsUsed(ctx context.Context, code, userId uuid.UUID) error {
+	return MarkOTLinkAsUsed(ctx, code, userId)
+}
+func (pgOTLinks) UseVerifyOTL(ctx context.Context, code, userId uuid.UUID) error {
+	return UseVerifyOTL(ctx, code, userId)
+}
+func (pgOTLinks) UsePhoneOTL(ctx context.Context, code uuid.UUID, phone string) (string, error) {
+	return UsePhoneOTL(ctx, code, phone)
+}
+func (pgOTLinks) UseTelegramOTL(ctx context.Context, code uuid.UUID, chat *TelegramChat) error {
+	return UseTelegramOTL(ctx, code, chat)
+}
+
+type pgWatches struct{}
+
+func (pgWatches) CreateWatch(ctx context.Context, data *Watch) (*Watch, error) {
+	return CreateWatch(ctx, data)
+}
+func (pgWatches) FindWatchesByUser(ctx context.Context, userId string) ([]*Watch, error) {
+	return FindWatchesByUser(ctx, userId)
+}
+func (pgWatches) FindActiveWatches(ctx context.Context) ([]*Watch, error) {
+	return FindActiveWatches(ctx)
+}
+func (pgWatches) DeleteUserWatchById(ctx context.Context, id, userId string) error {
+	return DeleteUserWatchById(ctx, id, userId)
+}
+func (pgWatches) CreateWatchMatches(ctx context.Context, matches []*WatchMatch) ([]*WatchMatch, error) {
+	return CreateWatchMatches(ctx, matches)
+}
+func (pgWatches) FindWatchMatchesByUser(ctx context.Context, userId string, limit int) ([]*WatchMatch, error) {
+	return FindWatchMatchesByUser(ctx, userId, limit)
+}
+func (pgWatches) FindPendingWatchMatches(ctx context.Context) ([]*WatchMatch, error) {
+	return FindPendingWatchMatches(ctx)
+}
+func (pgWatches) MarkWatchMatchesAsNotified(ctx context.Context, ids []string) error {
+	return MarkWatchMatchesAsNotified(ctx, ids)
+}
+
+type pgLinks struct{}
+
+func (pgLinks) CreateAlertLink(ctx context.Context, link *AlertLink) (*AlertLink, bool, error) {
+	return CreateAlertLink(ctx, link)
+}
+func (pgLinks) FindAlertLinkById(ctx context.Context, id, userId string) (*AlertLink, error) {
+	return FindAlertLinkById(ctx, id, userId)
+}
+func (pgLinks) FindLinkedAlerts(ctx context.Context, alertId string) ([]*LinkedAlert, error) {
+	return FindLinkedAlerts(ctx, alertId)
+}
+func (pgLinks) ConfirmAlertLink(ctx context.Context, id, userId, toAlertId string) error {
+	return ConfirmAlertLink(ctx, id, userId, toAlertId)
+}
+func (pgLinks) DismissAlertLink(ctx context.Context, id, userId string) error {
+	return DismissAlertLink(ctx, id, userId)
+}
+
+type pgJobRuns struct{}
+
+func (pgJobRuns) CreateJobRun(ctx context.Context, run *JobRun) error { return CreateJobRun(ctx, run) }
+func (pgJobRuns) FinishJobRun(ctx context.Context, run *JobRun) error { return FinishJobRun(ctx, run) }
+func (pgJobRuns) FindJobRuns(ctx context.Context, filter JobRunFilter) ([]*JobRun, error) {
+	return FindJobRuns(ctx, filter)
+}
+func (pgJobRuns) FindJobRunDays(ctx context.Context, since time.Time) ([]*JobRunDay, error) {
+	return FindJobRunDays(ctx, since)
+}
+
+type pgBackfill struct{}
+
+func (pgBackfill) FindBackfillCheckpoints(ctx context.Context, natureCodes []string, from, to time.Time) (map[string]*BackfillCheckpoint, error) {
+	return FindBackfillCheckpoints(ctx, natureCodes, from, to)
+}
+func (pgBackfill) SaveBackfillCheckpoint(ctx context.Context, cp *BackfillCheckpoint) error {
+	return SaveBackfillCheckpoint(ctx, cp)
+}
+func (pgBackfill) ArchiveDocs(ctx context.Context, docs []*Doc) (int, error) {
+	return ArchiveDocs(ctx, docs)
+}
+
+type pgNotifications struct{}
+
+func (pgNotifications) FindNotificationPreferences(ctx context.Context, userId string) ([]*NotificationPreference, error) {
+	return FindNotificationPreferences(ctx, userId)
+}
+func (pgNotifications) SaveNotificationPreference(ctx context.Context, pref *NotificationPreference) error {
+	return SaveNotificationPreference(ctx, pref)
+}
+func (pgNotifications) CreateNotificationDelivery(ctx context.Context, delivery *NotificationDelivery) error {
+	return CreateNotificationDelivery(ctx, delivery)
+}
+func (pgNotifications) FindNotificationDeliveries(ctx context.Context, userId string, limit int) ([]*NotificationDelivery, error) {
+	return FindNotificationDeliveries(ctx, userId, limit)
+}
+
+type pgOutbox struct{}
+
+func (pgOutbox) EnqueueNotification(ctx context.Context, item *OutboxItem) (bool, error) {
+	return EnqueueNotification(ctx, item)
+}
+func (pgOutbox) ClaimDueNotifications(ctx context.Context, limit int, lockFor time.Duration) ([]*OutboxItem, error) {
+	return ClaimDueNotifications(ctx, limit, lockFor)
+}
+func (pgOutbox) FinishNotification(ctx context.Context, item *OutboxItem) error {
+	return FinishNotification(ctx, item)
+}
+func (pgOutbox) RequeueNotification(ctx context.Context, id string) error {
+	return RequeueNotification(ctx, id)
+}
+func (pgOutbox) FindNotifications(ctx context.Context, filter OutboxFilter) ([]*OutboxItem, error) {
+	return FindNotifications(ctx, filter)
+}
+func (pgOutbox) CountNotifications(ctx context.Context) (map[string]int, error) {
+	return CountNotifications(ctx)
+}
+
+type pgDigests struct{}
+
+func (pgDigests) FindDigestSettings(ctx context.Context, userId string) (*DigestSettings, error) {
+	return FindDigestSettings(ctx, userId)
+}
+func (pgDigests) SaveDigestSettings(ctx context.Context, settings *DigestSettings) error {
+	return SaveDigestSettings(ctx, settings)
+}
+func (pgDigests) FindEnabledDigests(ctx context.Context) ([]*DigestSettings, error) {
+	return FindEnabledDigests(ctx)
+}
+func (pgDigests) FindDigestChanges(ctx context.Context, userId string, since, until time.Time) ([]*DigestChange, error) {
+	return FindDigestChanges(ctx, userId, since, until)
+}
+func (pgDigests) MarkDigestSent(ctx context.Context, userId string, sentAt time.Time) error {
+	return MarkDigestSent(ctx, userId, sentAt)
+}
+
+type pgWhatsApp struct{}
+
+func (pgWhatsApp) CreateWhatsAppMessage(ctx context.Context, msg *WhatsAppMessage) error {
+	return CreateWhatsAppMessage(ctx, msg)
+}
+func (pgWhatsApp) UpdateWhatsAppMessageStatus(ctx context.Context, id, status string, at time.Time, errMsg sql.NullString) error {
+	return UpdateWhatsAppMessageStatus(ctx, id, status, at, errMsg)
+}
+func (pgWhatsApp) FindWhatsAppMessages(ctx context.Context, userId string, limit int) ([]*WhatsAppMessage, error) {
+	return FindWhatsAppMessages(ctx, userId, limit)
+}
+
+type pgTelegram struct{}
+
+func (pgTelegram) FindTelegramChat(ctx context.Context, userId string) (*TelegramChat, error) {
+	return FindTelegramChat(ctx, userId)
+}
+func (pgTelegram) GetUserByTelegramChat(ctx context.Context, chatId int64) (*User, error) {
+	return GetUserByTelegramChat(ctx, chatId)
+}
+func (pgTelegram) DeleteTelegramChat(ctx context.Context, userId string) error {
+	return DeleteTelegramChat(ctx, userId)
+}
+
+type pgWebhooks struct{}
+
+func (pgWebhooks) CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	return CreateWebhookEndpoint(ctx, endpoint)
+}
+func (pgWebhooks) FindWebhookEndpoints(ctx context.Context, userId string) ([]*WebhookEndpoint, error) {
+	return FindWebhookEndpoints(ctx, userId)
+}
+func (pgWebhooks) FindWebhookEndpointById(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	return FindWebhookEndpointById(ctx, id)
+}
+func (pgWebhooks) DeleteWebhookEndpoint(ctx context.Context, id, userId string) error {
+	return DeleteWebhookEndpoint(ctx, id, userId)
+}
+func (pgWebhooks) EnqueueWebhookEvent(ctx context.Context, event *WebhookEvent) (int, error) {
+	return EnqueueWebhookEvent(ctx, event)
+}
+func (pgWebhooks) CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	return CreateWebhookDelivery(ctx, delivery)
+}
+func (pgWebhooks) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lockFor time.Duration) ([]*WebhookDelivery, error) {
+	return ClaimDueWebhookDeliveries(ctx, limit, lockFor)
+}
+func (pgWebhooks) FinishWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	return FinishWebhookDelivery(ctx, delivery)
+}
+func (pgWebhooks) FindWebhookDeliveries(ctx context.Context, endpointId string, limit int) ([]*WebhookDelivery, error) {
+	return FindWebhookDeliveries(ctx, endpointId, limit)
+}
+
+type pgPush struct{}
+
+func (pgPush) SavePushSubscription(ctx context.Context, sub *PushSubscription) error {
+	return SavePushSubscription(ctx, sub)
+}
+func (pgPush) FindPushSubscriptions(ctx context.Context, userId string) ([]*PushSubscription, error) {
+	return FindPushSubscriptions(ctx, userId)
+}
+func (pgPush) DeletePushSubscription(ctx context.Context, userId, endpoint string) error {
+	return DeletePushSubscription(ctx, userId, endpoint)
+}
+
+type pgSMS struct{}
+
+func (pgSMS) OptOutSMS(ctx context.Context, phone string) error {
+	return OptOutSMS(ctx, phone)
+}
+func (pgSMS) OptInSMS(ctx context.Context, phone string) error {
+	return OptInSMS(ctx, phone)
+}
+func (pgSMS) IsSMSOptedOut(ctx context.Context, phone string) (bool, error) {
+	return IsSMSOptedOut(ctx, phone)
+}
+
+type pgLocks struct{}
+
+func (pgLocks) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	return TryAdvisoryLock(ctx, name)
+}
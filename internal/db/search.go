@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Builds the full text vector for a doc from its nature ($3)
+// and accord ($5), which is where the parties are listed
+const searchVectorExpr = "to_tsvector('spanish', unaccent(coalesce($3::text, '') || ' ' || coalesce($5::text, '')))"
+
+const (
+	SortRelevance = "relevance"
+	SortDateDesc  = "date_desc"
+	SortDateAsc   = "date_asc"
+)
+
+const (
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+)
+
+type DocSearchParams struct {
+	// Full text query, supports the web search syntax ("quoted phrases", -excluded, or)
+	Query       string
+	NatureCodes []string
+	// Matched as a substring of the nature of the doc (e.g. "divorcio")
+	Nature   string
+	CaseId   string
+	FromDate time.Time
+	ToDate   time.Time
+	Sort     string
+	Page     int
+	PageSize int
+}
+
+type DocSearchResult struct {
+	Docs       []*Doc `json:"docs"`
+	Total      int    `json:"total"`
+	Page       int    `json:"page"`
+	PageSize   int    `json:"pageSize"`
+	TotalPages int    `json:"totalPages"`
+}
+
+func (r *DocSearchResult) HasPrev() bool {
+	return r.Page > 1
+}
+
+func (r *DocSearchResult) HasNext() bool {
+	return r.Page < r.TotalPages
+}
+
+// Normalize trims the filters and fills the paging and sorting defaults
+func (p *DocSearchParams) Normalize() {
+	p.Query = strings.TrimSpace(p.Query)
+	p.Nature = strings.TrimSpace(p.Nature)
+	p.CaseId = TrimField(p.CaseId)
+
+	if p.Page < 1 {
+		p.Page = 1
+	}
+
+	if p.PageSize < 1 {
+		p.PageSize = DefaultSearchPageSize
+	}
+
+	if p.PageSize > MaxSearchPageSize {
+		p.PageSize = MaxSearchPageSize
+	}
+
+	switch p.Sort {
+	case SortDateAsc, SortDateDesc:
+	case SortRelevance:
+		if p.Query == "" {
+			p.Sort = SortDateDesc
+		}
+	default:
+		if p.Query != "" {
+			p.Sort = SortRelevance
+		} else {
+			p.Sort = SortDateDesc
+		}
+	}
+}
+
+// SearchDocs queries the stored bulletin archive. Filters left empty
+// are ignored, so an empty DocSearchParams returns the latest docs
+func SearchDocs(ctx context.Context, params DocSearchParams) (*DocSearchResult, error) {
+	params.Normalize()
+
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	var (
+		conds []string
+		args  []any
+	)
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rank := "0::real"
+
+	if params.Query != "" {
+		q := arg(params.Query)
+		conds = append(conds, fmt.Sprintf("search_vector @@ websearch_to_tsquery('spanish', unaccent(%v))", q))
+		rank = fmt.Sprintf("ts_rank(search_vector, websearch_to_tsquery('spanish', unaccent(%v)))", q)
+	}
+
+	if len(params.NatureCodes) > 0 {
+		conds = append(conds, fmt.Sprintf("nature_code = ANY(%v)", arg(params.NatureCodes)))
+	}
+
+	if params.Nature != "" {
+		conds = append(conds, fmt.Sprintf("unaccent(nature) ILIKE '%%' || unaccent(%v) || '%%'", arg(params.Nature)))
+	}
+
+	if params.CaseId != "" {
+		conds = append(conds, fmt.Sprintf("case_id = %v", arg(params.CaseId)))
+	}
+
+	if !params.FromDate.IsZero() {
+		conds = append(conds, fmt.Sprintf("accord_date >= %v", arg(params.FromDate)))
+	}
+
+	if !params.ToDate.IsZero() {
+		conds = append(conds, fmt.Sprintf("accord_date < %v", arg(params.ToDate.AddDate(0, 0, 1))))
+	}
+
+	where := ""
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var orderBy string
+	switch params.Sort {
+	case SortRelevance:
+		orderBy = "rank DESC, accord_date DESC"
+	case SortDateAsc:
+		orderBy = "accord_date ASC, case_id"
+	default:
+		orderBy = "accord_date DESC, case_id"
+	}
+
+	result := DocSearchResult{
+		Docs:     []*Doc{},
+		Page:     params.Page,
+		PageSize: params.PageSize,
+	}
+
+	err = conn.QueryRow(ctx, "SELECT COUNT(*) FROM docs "+where, args...).Scan(&result.Total)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.TotalPages = (result.Total + params.PageSize - 1) / params.PageSize
+
+	query := fmt.Sprintf(
+		"SELECT id, case_id, nature, nature_code, accord, accord_date, %v AS rank FROM docs %v ORDER BY %v LIMIT %v OFFSET %v",
+		rank,
+		where,
+		orderBy,
+		arg(params.PageSize),
+		arg((params.Page-1)*params.PageSize),
+	)
+
+	rows, err := conn.Query(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var (
+			doc Doc
+			r   float32
+		)
+
+		err = rows.Scan(&doc.ID, &doc.Case, &doc.Nature, &doc.NatureCode, &doc.Accord, &doc.AccordDate, &r)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Docs = append(result.Docs, &doc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
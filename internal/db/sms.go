@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// OptOutSMS stops the SMS to phone, in E.164
+func OptOutSMS(ctx context.Context, phone string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "INSERT INTO sms_opt_outs (phone) VALUES ($1) ON CONFLICT (phone) DO NOTHING", phone)
+
+	return err
+}
+
+// OptInSMS lets the SMS reach phone again
+func OptInSMS(ctx context.Context, phone string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(ctx, "DELETE FROM sms_opt_outs WHERE phone = $1", phone)
+
+	return err
+}
+
+// IsSMSOptedOut reports whether phone asked for no more SMS
+func IsSMSOptedOut(ctx context.Context, phone string) (bool, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	var found string
+	err = conn.QueryRow(ctx, "SELECT phone FROM sms_opt_outs WHERE phone = $1", phone).Scan(&found)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
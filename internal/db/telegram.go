@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// A TelegramChat is the chat with the bot linked to a user
+type TelegramChat struct {
+	UserId   string         `json:"userId" db:"user_id"`
+	ChatId   int64          `json:"chatId" db:"chat_id"`
+	Username sql.NullString `json:"username" db:"username"`
+	LinkedAt time.Time      `json:"linkedAt" db:"linked_at"`
+}
+
+// FindTelegramChat returns the chat linked to the user
+func FindTelegramChat(ctx context.Context, userId string) (*TelegramChat, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT user_id, chat_id, username, linked_at FROM telegram_chats WHERE user_id = $1", userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow[*TelegramChat](rows, pgx.RowToAddrOfStructByName[TelegramChat])
+}
+
+// GetUserByTelegramChat returns the user who linked the chat
+func GetUserByTelegramChat(ctx context.Context, chatId int64) (*User, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	return scanUser(conn.QueryRow(
+		ctx,
+		"SELECT "+userColumns+" FROM users u JOIN telegram_chats t ON t.user_id = u.id WHERE t.chat_id = $1",
+		chatId,
+	))
+}
+
+// TxLinkTelegramChat links the chat to the user, replacing the chat they
+// had. A chat belongs to a single user, any other one loses it
+func TxLinkTelegramChat(ctx context.Context, tx pgx.Tx, chat *TelegramChat) error {
+	_, err := tx.Exec(
+		ctx,
+		"DELETE FROM telegram_chats WHERE chat_id = $1 AND user_id <> $2",
+		chat.ChatId,
+		chat.UserId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRow(
+		ctx,
+		`INSERT INTO telegram_chats (user_id, chat_id, username) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET chat_id = EXCLUDED.chat_id, username = EXCLUDED.username, linked_at = NOW()
+		RETURNING linked_at`,
+		chat.UserId,
+		chat.ChatId,
+		chat.Username,
+	).Scan(&chat.LinkedAt)
+}
+
+// DeleteTelegramChat unlinks the chat of the user, it fails with
+// pgx.ErrNoRows when they have none
+func DeleteTelegramChat(ctx context.Context, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, "DELETE FROM telegram_chats WHERE user_id = $1", userId)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// UseTelegramOTL marks the Telegram link with code as used and links the
+// chat to its user in a single transaction, filling chat.UserId. Links
+// used, expired or for other actions are reported as non existent
+func UseTelegramOTL(ctx context.Context, code uuid.UUID, chat *TelegramChat) error {
+	tx, conn, err := GetTxAndPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(
+		ctx,
+		`UPDATE otlinks SET used = TRUE WHERE code = $1 AND action = $2 AND used = FALSE AND expires_at > NOW()
+		RETURNING user_id::text`,
+		code,
+		OTLActionTelegram,
+	).Scan(&chat.UserId)
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &NonExistentOTLError{Code: code.String()}
+		}
+
+		return err
+	}
+
+	if err := TxLinkTelegramChat(ctx, tx, chat); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
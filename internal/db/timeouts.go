@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+)
+
+// An OpClass groups the database operations that share a timeout.
+// Each class can be configured with the environment variable
+// DB_<CLASS>_TIMEOUT, e.g. DB_BULK_TIMEOUT=30m
+type OpClass string
+
+const (
+	// Single row reads and writes made while serving a request
+	OpQuery OpClass = "QUERY"
+	// Reads that go through every case or subscription
+	OpScan OpClass = "SCAN"
+	// Batched writes made by the jobs
+	OpBulk OpClass = "BULK"
+	// Schema migrations
+	OpMigration OpClass = "MIGRATION"
+)
+
+var defaultTimeouts = map[OpClass]time.Duration{
+	OpQuery:     30 * time.Second,
+	OpScan:      2 * time.Minute,
+	OpBulk:      15 * time.Minute,
+	OpMigration: 30 * time.Minute,
+}
+
+func (c OpClass) Timeout() time.Duration {
+	return internal.EnvDuration("DB_"+string(c)+"_TIMEOUT", defaultTimeouts[c])
+}
+
+// WithTimeout derives a context from ctx that expires after the timeout
+// of class. Deadlines and cancellation of ctx still apply
+func WithTimeout(ctx context.Context, class OpClass) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, class.Timeout())
+}
@@ -28,6 +28,34 @@ type User struct {
 	Alerts                []Alert
 }
 
+// The columns scanned by scanUser, from the users table as u
+const userColumns = "u.id, u.name, u.lastname, u.password, u.subscription_active, u.subscription_expires_at, u.username, u.email, u.phone_number, u.email_verified, u.phone_verified, u.golden_boy"
+
+func scanUser(row pgx.Row) (*User, error) {
+	var user User
+
+	err := row.Scan(
+		&user.Id,
+		&user.Name,
+		&user.Lastname,
+		&user.Password,
+		&user.SubscriptionActive,
+		&user.SubscriptionExpiresAt,
+		&user.Username,
+		&user.Email,
+		&user.Phone,
+		&user.EmailVerified,
+		&user.PhoneVerified,
+		&user.GoldenBoy,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
 func (u *User) ValidatePass(pw string) error {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(pw))
 
@@ -55,13 +83,13 @@ func (u *User) CreateReportDir() error {
 	return nil
 }
 
-func CreateUser(user *User) (string, error) {
-	conn, err := GetPool()
+func CreateUser(ctx context.Context, user *User) (string, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return "", err
 	}
 	defer conn.Release()
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
 	if err != nil {
@@ -99,84 +127,61 @@ func CreateUser(user *User) (string, error) {
 	return user.Id, nil
 }
 
-func GetUserById(id string) (*User, error) {
-	conn, err := GetPool()
+func GetUserById(ctx context.Context, id string) (*User, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	var user User
-
-	err = conn.QueryRow(
-		ctx,
-		"SELECT * FROM users WHERE id = $1",
-		id,
-	).Scan(
-		&user.Id,
-		&user.Name,
-		&user.Lastname,
-		&user.Password,
-		&user.SubscriptionActive,
-		&user.SubscriptionExpiresAt,
-		&user.Username,
-		&user.Email,
-		&user.Phone,
-		&user.EmailVerified,
-		&user.PhoneVerified,
-		&user.GoldenBoy,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	return scanUser(conn.QueryRow(ctx, "SELECT "+userColumns+" FROM users u WHERE u.id = $1", id))
 }
 
-func GetUserByUsername(username string) (*User, error) {
-	conn, err := GetPool()
+func GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	var user User
+	return scanUser(conn.QueryRow(ctx, "SELECT "+userColumns+" FROM users u WHERE u.username = $1", username))
+}
 
-	err = conn.QueryRow(
+func TxVerifyUserEmail(ctx context.Context, tx pgx.Tx, userId string) error {
+	tag, err := tx.Exec(
 		ctx,
-		"SELECT * FROM users WHERE username = $1",
-		username,
-	).Scan(
-		&user.Id,
-		&user.Name,
-		&user.Lastname,
-		&user.Password,
-		&user.SubscriptionActive,
-		&user.SubscriptionExpiresAt,
-		&user.Username,
-		&user.Email,
-		&user.Phone,
-		&user.EmailVerified,
-		&user.PhoneVerified,
-		&user.GoldenBoy,
+		"UPDATE users SET email_verified = TRUE WHERE id = $1",
+		userId,
 	)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &user, nil
+	if tag.RowsAffected() == 0 {
+		return errors.New(fmt.Sprintf("No se encontró usuario con id %v", userId))
+	}
+
+	return nil
 }
 
-func TxVerifyUserEmail(ctx context.Context, tx pgx.Tx, userId string) error {
-	tag, err := tx.Exec(
+func VerifyUserEmail(ctx context.Context, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(
 		ctx,
 		"UPDATE users SET email_verified = TRUE WHERE id = $1",
 		userId,
@@ -193,20 +198,39 @@ func TxVerifyUserEmail(ctx context.Context, tx pgx.Tx, userId string) error {
 	return nil
 }
 
-func VerifyUserEmail(userId string) error {
-	conn, err := GetPool()
+// GetUserByPhone returns the user who verified phone, in E.164
+func GetUserByPhone(ctx context.Context, phone string) (*User, error) {
+	conn, err := GetPool(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer conn.Release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := WithTimeout(ctx, OpQuery)
 	defer cancel()
 
-	tag, err := conn.Exec(
+	return scanUser(conn.QueryRow(ctx, "SELECT "+userColumns+" FROM users u WHERE u.phone_number = $1 AND u.phone_verified = TRUE", phone))
+}
+
+// TxVerifyUserPhone sets phone as the verified phone of the user. Any
+// other user who verified it loses it, a phone belongs to a single user
+func TxVerifyUserPhone(ctx context.Context, tx pgx.Tx, userId, phone string) error {
+	_, err := tx.Exec(
 		ctx,
-		"UPDATE users SET email_verified = TRUE WHERE id = $1",
+		"UPDATE users SET phone_verified = FALSE WHERE phone_number = $1 AND id <> $2",
+		phone,
+		userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(
+		ctx,
+		"UPDATE users SET phone_number = $2, phone_verified = TRUE WHERE id = $1",
 		userId,
+		phone,
 	)
 
 	if err != nil {
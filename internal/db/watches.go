@@ -0,0 +1,374 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal"
+)
+
+const (
+	// Matches the pattern as a sequence of words anywhere in the entry
+	WatchKindText = "text"
+	// Matches the pattern ignoring every non alphanumeric char (RFCs, ids)
+	WatchKindRFC = "rfc"
+)
+
+// A Watch is an alert that, instead of following a single case,
+// looks for a free-text pattern (party names, companies, lawyers, RFCs)
+// across the daily bulletins of every court, or only the ones in
+// NatureCodes when it's not empty
+type Watch struct {
+	Id          string         `json:"id" db:"id"`
+	UserId      string         `json:"userId" db:"user_id"`
+	Pattern     string         `json:"pattern" db:"pattern"`
+	Kind        string         `json:"kind" db:"kind"`
+	Alias       sql.NullString `json:"alias" db:"alias"`
+	NatureCodes []string       `json:"natureCodes" db:"nature_codes"`
+	Active      bool           `json:"active" db:"active"`
+	LastMatchAt sql.NullTime   `json:"lastMatchAt" db:"last_match_at"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// AppliesTo reports whether the watch should be matched against
+// the bulletin of the court with the given natureCode
+func (w *Watch) AppliesTo(natureCode string) bool {
+	if len(w.NatureCodes) == 0 {
+		return true
+	}
+
+	for _, nc := range w.NatureCodes {
+		if nc == natureCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches reports whether the given bulletin text contains the pattern
+// of the watch. The comparison is case and accent insensitive
+func (w *Watch) Matches(text string) bool {
+	if w.Kind == WatchKindRFC {
+		pattern := internal.CompactText(w.Pattern)
+		return pattern != "" && strings.Contains(internal.CompactText(text), pattern)
+	}
+
+	pattern := internal.NormalizeText(w.Pattern)
+
+	if pattern == "" {
+		return false
+	}
+
+	return strings.Contains(" "+internal.NormalizeText(text)+" ", " "+pattern+" ")
+}
+
+// A WatchMatch is a bulletin entry where the pattern of a Watch was found
+type WatchMatch struct {
+	Id         string    `json:"id" db:"id"`
+	WatchId    string    `json:"watchId" db:"watch_id"`
+	UserId     string    `json:"userId" db:"user_id"`
+	CaseId     string    `json:"caseId" db:"case_id"`
+	NatureCode string    `json:"natureCode" db:"nature_code"`
+	Nature     string    `json:"nature" db:"nature"`
+	Accord     string    `json:"accord" db:"accord"`
+	AccordDate time.Time `json:"accordDate" db:"accord_date"`
+	// Set once the notification of the match is queued
+	NotifiedAt sql.NullTime `json:"notifiedAt" db:"notified_at"`
+	CreatedAt  time.Time    `json:"createdAt" db:"created_at"`
+
+	Pattern string `json:"pattern" db:"-"`
+}
+
+func CreateWatch(ctx context.Context, data *Watch) (*Watch, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Kind == "" {
+		data.Kind = WatchKindText
+	}
+
+	if data.NatureCodes == nil {
+		data.NatureCodes = []string{}
+	}
+
+	row, err := conn.Query(
+		ctx,
+		"INSERT INTO watches (id, user_id, pattern, kind, alias, nature_codes, active) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *",
+		id,
+		data.UserId,
+		data.Pattern,
+		data.Kind,
+		data.Alias,
+		data.NatureCodes,
+		data.Active,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	watch, err := pgx.CollectExactlyOneRow[Watch](row, pgx.RowToStructByName[Watch])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &watch, nil
+}
+
+func FindWatchesByUser(ctx context.Context, userId string) ([]*Watch, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM watches WHERE user_id = $1 ORDER BY created_at DESC",
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*Watch](rows, pgx.RowToAddrOfStructByName[Watch])
+}
+
+func FindActiveWatches(ctx context.Context) ([]*Watch, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpScan)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT * FROM watches WHERE active = TRUE")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*Watch](rows, pgx.RowToAddrOfStructByName[Watch])
+}
+
+func DeleteUserWatchById(ctx context.Context, id, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	res, err := conn.Exec(
+		ctx,
+		"DELETE FROM watches WHERE id = $1 AND user_id = $2",
+		id,
+		userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return errors.New("No se encontro vigilancia con el id especificado")
+	}
+
+	return nil
+}
+
+// CreateWatchMatches stores the matches found by the update job and
+// returns only the ones that didn't exist already, so the same
+// bulletin entry is stored once for the same watch
+func CreateWatchMatches(ctx context.Context, matches []*WatchMatch) (created []*WatchMatch, err error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpBulk)
+	defer cancel()
+
+	var queryBatch pgx.Batch
+
+	for _, m := range matches {
+		id, err := uuid.NewV7()
+
+		if err != nil {
+			return nil, err
+		}
+
+		queryBatch.Queue(
+			"INSERT INTO watch_matches (id, watch_id, user_id, case_id, nature_code, nature, accord, accord_date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (watch_id, nature_code, case_id, accord_date) DO NOTHING RETURNING id, created_at",
+			id,
+			m.WatchId,
+			m.UserId,
+			m.CaseId,
+			m.NatureCode,
+			m.Nature,
+			m.Accord,
+			m.AccordDate,
+		).QueryRow(func(row pgx.Row) error {
+			err := row.Scan(&m.Id, &m.CreatedAt)
+
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			created = append(created, m)
+			return nil
+		})
+	}
+
+	err = conn.SendBatch(ctx, &queryBatch).Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(created) == 0 {
+		return created, nil
+	}
+
+	watchIds := internal.Set{}
+	for _, m := range created {
+		watchIds.Add(m.WatchId)
+	}
+
+	_, err = conn.Exec(
+		ctx,
+		"UPDATE watches SET last_match_at = NOW() WHERE id = ANY($1)",
+		watchIds.Elements(),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+func FindWatchMatchesByUser(ctx context.Context, userId string, limit int) ([]*WatchMatch, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT "+watchMatchColumns+" FROM watch_matches m JOIN watches w ON w.id = m.watch_id WHERE m.user_id = $1 ORDER BY m.accord_date DESC, m.created_at DESC LIMIT $2",
+		userId,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collectWatchMatches(rows)
+}
+
+// FindPendingWatchMatches returns the matches whose notification was
+// never queued, oldest first
+func FindPendingWatchMatches(ctx context.Context) ([]*WatchMatch, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpScan)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT "+watchMatchColumns+" FROM watch_matches m JOIN watches w ON w.id = m.watch_id WHERE m.notified_at IS NULL ORDER BY m.id",
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collectWatchMatches(rows)
+}
+
+// The columns scanned by collectWatchMatches, from watch_matches as m
+// joined with watches as w
+const watchMatchColumns = "m.id, m.watch_id, m.user_id, m.case_id, m.nature_code, m.nature, m.accord, m.accord_date, m.notified_at, m.created_at, w.pattern"
+
+func collectWatchMatches(rows pgx.Rows) ([]*WatchMatch, error) {
+	defer rows.Close()
+
+	matches := []*WatchMatch{}
+
+	for rows.Next() {
+		var m WatchMatch
+
+		err := rows.Scan(&m.Id, &m.WatchId, &m.UserId, &m.CaseId, &m.NatureCode, &m.Nature, &m.Accord, &m.AccordDate, &m.NotifiedAt, &m.CreatedAt, &m.Pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		matches = append(matches, &m)
+	}
+
+	return matches, rows.Err()
+}
+
+// MarkWatchMatchesAsNotified records that the notification of the
+// matches was queued, they are no longer pending
+func MarkWatchMatchesAsNotified(ctx context.Context, ids []string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(
+		ctx,
+		"UPDATE watch_matches SET notified_at = NOW() WHERE id = ANY($1) AND notified_at IS NULL",
+		ids,
+	)
+
+	return err
+}
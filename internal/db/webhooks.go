@@ -0,0 +1,387 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// The events sent to the webhook endpoints
+const (
+	// A new accord in a case followed by the user
+	WebhookAccordNew = "accord.new"
+	// The user started following a case
+	WebhookAlertCreated = "alert.created"
+	// The user stopped following a case
+	WebhookAlertDeleted = "alert.deleted"
+	// The update job found no accord of a case followed by the user
+	WebhookCaseNotFound = "case.not_found"
+	// Sent on demand to check an endpoint
+	WebhookTest = "webhook.test"
+)
+
+// The events endpoints can choose, with their description
+var WebhookEvents = []struct {
+	Event string
+	Label string
+}{
+	{WebhookAccordNew, "Acuerdo nuevo"},
+	{WebhookAlertCreated, "Alerta creada"},
+	{WebhookAlertDeleted, "Alerta eliminada"},
+	{WebhookCaseNotFound, "Expediente no encontrado"},
+}
+
+// What happened with a webhook delivery, like with the outbox
+const (
+	WebhookPending = "pending"
+	WebhookSent    = "sent"
+	WebhookDead    = "dead"
+)
+
+// A WebhookEndpoint receives the events of its user, or of every user
+// when it belongs to the organization (UserId isn't valid)
+type WebhookEndpoint struct {
+	Id     string         `json:"id" db:"id"`
+	UserId sql.NullString `json:"userId" db:"user_id"`
+	Url    string         `json:"url" db:"url"`
+	Secret string         `json:"-" db:"secret"`
+	// Every event is sent when empty
+	Events      []string  `json:"events" db:"events"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Wants reports whether event must be sent to the endpoint
+func (e *WebhookEndpoint) Wants(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+
+	for _, ev := range e.Events {
+		if ev == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A WebhookDelivery is an event waiting to be sent to an endpoint, or
+// the record of one that was
+type WebhookDelivery struct {
+	Id         string          `json:"id" db:"id"`
+	EndpointId string          `json:"endpointId" db:"endpoint_id"`
+	Event      string          `json:"event" db:"event"`
+	EventId    string          `json:"eventId" db:"event_id"`
+	Payload    json.RawMessage `json:"payload" db:"payload"`
+	Status     string          `json:"status" db:"status"`
+	Attempts   int             `json:"attempts" db:"attempts"`
+	// When the next attempt is due while the delivery is pending
+	NextAttemptAt  time.Time      `json:"nextAttemptAt" db:"next_attempt_at"`
+	LockedUntil    sql.NullTime   `json:"lockedUntil" db:"locked_until"`
+	ResponseStatus sql.NullInt32  `json:"responseStatus" db:"response_status"`
+	LastError      sql.NullString `json:"lastError" db:"last_error"`
+	CreatedAt      time.Time      `json:"createdAt" db:"created_at"`
+	SentAt         sql.NullTime   `json:"sentAt" db:"sent_at"`
+}
+
+// A WebhookEvent happened to a user, it's delivered to their endpoints
+// and to the ones of the organization
+type WebhookEvent struct {
+	// Identifies the event, queueing it again does nothing
+	Id     string
+	Type   string
+	UserId string
+	Data   json.RawMessage
+}
+
+// Queues the event $2 with the id $3 and the data $4 of the user $1 for
+// every endpoint that wants it
+const queueWebhookQuery = `INSERT INTO webhook_deliveries (id, endpoint_id, event, event_id, payload)
+SELECT gen_random_uuid(), e.id, $2, $3, $4 FROM webhook_endpoints e
+WHERE (e.user_id = $1 OR e.user_id IS NULL) AND (cardinality(e.events) = 0 OR $2 = ANY(e.events))
+ON CONFLICT (endpoint_id, event_id) DO NOTHING`
+
+// AlertWebhookData returns the data of the alert events
+func AlertWebhookData(alert *Alert) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"alertId":    alert.Id,
+		"userId":     alert.UserId,
+		"caseId":     alert.CaseId,
+		"natureCode": alert.NatureCode,
+		"nature":     alert.Nature,
+	})
+}
+
+// endpointOwner returns the user_id of the endpoints of userId, NULL for
+// the ones of the organization
+func endpointOwner(userId string) sql.NullString {
+	return sql.NullString{String: userId, Valid: userId != ""}
+}
+
+// CreateWebhookEndpoint stores endpoint, setting its Id and CreatedAt
+func CreateWebhookEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	if endpoint.Events == nil {
+		endpoint.Events = []string{}
+	}
+
+	err = conn.QueryRow(
+		ctx,
+		"INSERT INTO webhook_endpoints (id, user_id, url, secret, events, description) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at",
+		id,
+		endpoint.UserId,
+		endpoint.Url,
+		endpoint.Secret,
+		endpoint.Events,
+		endpoint.Description,
+	).Scan(&endpoint.CreatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	endpoint.Id = id.String()
+
+	return nil
+}
+
+// FindWebhookEndpoints returns the endpoints of the user, or the ones of
+// the organization when userId is empty, oldest first
+func FindWebhookEndpoints(ctx context.Context, userId string) ([]*WebhookEndpoint, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM webhook_endpoints WHERE user_id IS NOT DISTINCT FROM $1 ORDER BY created_at",
+		endpointOwner(userId),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*WebhookEndpoint](rows, pgx.RowToAddrOfStructByName[WebhookEndpoint])
+}
+
+// FindWebhookEndpointById returns the endpoint with id, whoever it belongs to
+func FindWebhookEndpointById(ctx context.Context, id string) (*WebhookEndpoint, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(ctx, "SELECT * FROM webhook_endpoints WHERE id = $1", id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectExactlyOneRow[*WebhookEndpoint](rows, pgx.RowToAddrOfStructByName[WebhookEndpoint])
+}
+
+// DeleteWebhookEndpoint deletes the endpoint of the user, or of the
+// organization when userId is empty, with its deliveries. It fails with
+// pgx.ErrNoRows when they have no endpoint with id
+func DeleteWebhookEndpoint(ctx context.Context, id, userId string) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(
+		ctx,
+		"DELETE FROM webhook_endpoints WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2",
+		id,
+		endpointOwner(userId),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// EnqueueWebhookEvent queues event for the endpoints of its user and of
+// the organization that want it, and returns how many it was queued for
+func EnqueueWebhookEvent(ctx context.Context, event *WebhookEvent) (int, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	tag, err := conn.Exec(ctx, queueWebhookQuery, event.UserId, event.Type, event.Id, event.Data)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// CreateWebhookDelivery stores a delivery made outside of the queue, like
+// the test events. It's claimed by the caller until its LockedUntil, who
+// stores the outcome with FinishWebhookDelivery
+func CreateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	id, err := uuid.NewV7()
+
+	if err != nil {
+		return err
+	}
+
+	err = conn.QueryRow(
+		ctx,
+		`INSERT INTO webhook_deliveries (id, endpoint_id, event, event_id, payload, attempts, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING status, created_at, next_attempt_at`,
+		id,
+		delivery.EndpointId,
+		delivery.Event,
+		delivery.EventId,
+		delivery.Payload,
+		delivery.Attempts,
+		delivery.LockedUntil,
+	).Scan(&delivery.Status, &delivery.CreatedAt, &delivery.NextAttemptAt)
+
+	if err != nil {
+		return err
+	}
+
+	delivery.Id = id.String()
+
+	return nil
+}
+
+// ClaimDueWebhookDeliveries locks up to limit pending deliveries due by
+// now for lockFor and counts the attempt, see ClaimDueNotifications
+func ClaimDueWebhookDeliveries(ctx context.Context, limit int, lockFor time.Duration) ([]*WebhookDelivery, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		`UPDATE webhook_deliveries SET locked_until = NOW() + $2::interval, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		) RETURNING *`,
+		limit,
+		lockFor,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*WebhookDelivery](rows, pgx.RowToAddrOfStructByName[WebhookDelivery])
+}
+
+// FinishWebhookDelivery stores the outcome of a claimed delivery: its
+// status, response, error and next attempt
+func FinishWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(
+		ctx,
+		`UPDATE webhook_deliveries SET status = $2, response_status = $3, last_error = $4, next_attempt_at = $5,
+		sent_at = $6, locked_until = NULL WHERE id = $1`,
+		delivery.Id,
+		delivery.Status,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.SentAt,
+	)
+
+	return err
+}
+
+// FindWebhookDeliveries returns the latest deliveries to the endpoint,
+// newest first
+func FindWebhookDeliveries(ctx context.Context, endpointId string, limit int) ([]*WebhookDelivery, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM webhook_deliveries WHERE endpoint_id = $1 ORDER BY created_at DESC LIMIT $2",
+		endpointId,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*WebhookDelivery](rows, pgx.RowToAddrOfStructByName[WebhookDelivery])
+}
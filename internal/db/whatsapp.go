@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The statuses of a WhatsApp message, in the order they happen
+const (
+	WhatsAppSent      = "sent"
+	WhatsAppDelivered = "delivered"
+	WhatsAppRead      = "read"
+	WhatsAppFailed    = "failed"
+)
+
+// WhatsAppStatusRank orders the statuses so the webhooks, which can
+// arrive out of order, never move a message back (e.g. read to delivered)
+var WhatsAppStatusRank = map[string]int{
+	WhatsAppSent:      1,
+	WhatsAppDelivered: 2,
+	WhatsAppRead:      3,
+	WhatsAppFailed:    4,
+}
+
+// A WhatsAppMessage is a message sent through the Cloud API
+type WhatsAppMessage struct {
+	// The id WhatsApp gave it
+	Id          string         `json:"id" db:"id"`
+	UserId      sql.NullString `json:"userId" db:"user_id"`
+	Phone       string         `json:"phone" db:"phone"`
+	Template    string         `json:"template" db:"template"`
+	Status      string         `json:"status" db:"status"`
+	Error       sql.NullString `json:"error" db:"error"`
+	DeliveredAt sql.NullTime   `json:"deliveredAt" db:"delivered_at"`
+	ReadAt      sql.NullTime   `json:"readAt" db:"read_at"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time      `json:"updatedAt" db:"updated_at"`
+}
+
+// CreateWhatsAppMessage records a message just sent
+func CreateWhatsAppMessage(ctx context.Context, msg *WhatsAppMessage) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	if msg.Status == "" {
+		msg.Status = WhatsAppSent
+	}
+
+	row := conn.QueryRow(
+		ctx,
+		`INSERT INTO whatsapp_messages (id, user_id, phone, template, status) VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at, updated_at`,
+		msg.Id,
+		msg.UserId,
+		msg.Phone,
+		msg.Template,
+		msg.Status,
+	)
+
+	return row.Scan(&msg.CreatedAt, &msg.UpdatedAt)
+}
+
+// UpdateWhatsAppMessageStatus stores the status a webhook reported for the
+// message with id, unless it already has a later one. The messages that
+// weren't recorded, like the ones sent from Meta's console, are ignored
+func UpdateWhatsAppMessageStatus(ctx context.Context, id, status string, at time.Time, errMsg sql.NullString) error {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	_, err = conn.Exec(
+		ctx,
+		`UPDATE whatsapp_messages SET
+			status = $2,
+			error = COALESCE($4, error),
+			delivered_at = CASE WHEN $2 IN ('delivered', 'read') THEN COALESCE(delivered_at, $3) ELSE delivered_at END,
+			read_at = CASE WHEN $2 = 'read' THEN COALESCE(read_at, $3) ELSE read_at END,
+			updated_at = NOW()
+		WHERE id = $1 AND $5 > CASE status WHEN 'sent' THEN 1 WHEN 'delivered' THEN 2 WHEN 'read' THEN 3 ELSE 4 END`,
+		id,
+		status,
+		at,
+		errMsg,
+		WhatsAppStatusRank[status],
+	)
+
+	return err
+}
+
+// FindWhatsAppMessages returns the latest messages sent to the user, newest first
+func FindWhatsAppMessages(ctx context.Context, userId string, limit int) ([]*WhatsAppMessage, error) {
+	conn, err := GetPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	ctx, cancel := WithTimeout(ctx, OpQuery)
+	defer cancel()
+
+	rows, err := conn.Query(
+		ctx,
+		"SELECT * FROM whatsapp_messages WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2",
+		userId,
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows[*WhatsAppMessage](rows, pgx.RowToAddrOfStructByName[WhatsAppMessage])
+}
@@ -0,0 +1,261 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/reader"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+type BackfillOptions struct {
+	// The nature codes of the courts to crawl, every court when empty
+	Courts []string
+	// The first and last dates crawled
+	From time.Time
+	To   time.Time
+	// How many bulletins may be requested per second, 1 when zero
+	Rate float64
+	// How many bulletins are downloaded at the same time, 2 when zero
+	Workers int
+	// Fetch again the bulletins the checkpoints mark as done
+	Force bool
+	// Reads the text of a bulletin, reader.Reader when nil
+	Fetch func(ctx context.Context, date, caseType string) (*[]byte, error)
+}
+
+type BackfillResult struct {
+	// Every bulletin in the range and the ones skipped thanks to the checkpoints
+	Bulletins int
+	Skipped   int
+	Fetched   int
+	// Bulletins the court didn't publish
+	Missing int
+	Failed  int
+	// Entries read and how many of them weren't archived yet
+	Entries     int
+	NewEntries  int
+	CourtErrors map[string]string
+	// Errors that didn't stop the backfill
+	Errors []error
+}
+
+// Record copies the counts of the backfill into run
+func (r *BackfillResult) Record(run *db.JobRun) {
+	run.BulletinsFetched += r.Fetched
+	run.FetchErrors += r.Failed
+	run.CasesFound += r.Entries
+	run.CasesUpdated += r.NewEntries
+
+	if run.CourtErrors == nil {
+		run.CourtErrors = map[string]string{}
+	}
+
+	for court, msg := range r.CourtErrors {
+		run.CourtErrors[court] = msg
+	}
+}
+
+type bulletinTask struct {
+	court string
+	date  time.Time
+}
+
+// Backfill downloads the bulletins of the chosen courts between opts.From
+// and opts.To, newest first, and archives every entry found in them. What
+// happened with every bulletin is saved as a checkpoint, so an interrupted
+// backfill continues where it stopped and running it twice stores nothing
+// new. Missing and failed bulletins are tried again on the next run
+func Backfill(ctx context.Context, store *db.Store, opts BackfillOptions) (*BackfillResult, error) {
+	if opts.Fetch == nil {
+		opts.Fetch = reader.Reader
+	}
+
+	if opts.Rate <= 0 {
+		opts.Rate = 1
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+
+	if len(opts.Courts) == 0 {
+		for code := range internal.CodesMap {
+			opts.Courts = append(opts.Courts, code)
+		}
+		sort.Strings(opts.Courts)
+	}
+
+	for _, code := range opts.Courts {
+		if _, ok := internal.CodesMap[code]; !ok {
+			return nil, fmt.Errorf("Juzgado desconocido: %v", code)
+		}
+	}
+
+	from := startOfDay(opts.From)
+	to := startOfDay(opts.To)
+
+	checkpoints, err := store.Backfill.FindBackfillCheckpoints(ctx, opts.Courts, from, to)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := BackfillResult{CourtErrors: map[string]string{}}
+	tasks := []bulletinTask{}
+
+	for date := to; !date.Before(from); date = date.AddDate(0, 0, -1) {
+		for _, court := range opts.Courts {
+			result.Bulletins++
+			cp, ok := checkpoints[db.CheckpointKey(court, date)]
+
+			if ok && cp.Final() && !opts.Force {
+				result.Skipped++
+				continue
+			}
+
+			tasks = append(tasks, bulletinTask{court, date})
+		}
+	}
+
+	log.Printf("%v bulletins in range, %v already archived, %v to fetch\n", result.Bulletins, result.Skipped, len(tasks))
+
+	var (
+		mux     sync.Mutex
+		wg      sync.WaitGroup
+		done    int
+		started = time.Now()
+		queue   = make(chan bulletinTask)
+		limiter = time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+	)
+	defer limiter.Stop()
+
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for task := range queue {
+				select {
+				case <-ctx.Done():
+					continue
+				case <-limiter.C:
+				}
+
+				summary, err := backfillBulletin(ctx, store, opts, task)
+
+				// A cancelled download says nothing about the bulletin
+				if ctx.Err() != nil {
+					continue
+				}
+
+				mux.Lock()
+				done++
+
+				switch summary.Status {
+				case db.CheckpointDone:
+					result.Fetched++
+					result.Entries += summary.Entries
+					result.NewEntries += summary.newEntries
+				case db.CheckpointMissing:
+					result.Missing++
+				default:
+					result.Failed++
+					result.CourtErrors[task.court] = summary.Error.String
+				}
+
+				if err != nil {
+					result.Errors = append(result.Errors, err)
+				}
+
+				elapsed := time.Since(started)
+				eta := time.Duration(float64(elapsed) / float64(done) * float64(len(tasks)-done))
+
+				log.Printf(
+					"[%v/%v %.1f%%] %v %v: %v, %v entries (%v new); ETA %v\n",
+					done,
+					len(tasks),
+					float64(done)*100/float64(len(tasks)),
+					task.court,
+					task.date.Format("2006-01-02"),
+					summary.Status,
+					summary.Entries,
+					summary.newEntries,
+					eta.Round(time.Second),
+				)
+				mux.Unlock()
+			}
+		}()
+	}
+
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			break
+		}
+
+		queue <- task
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return &result, ctx.Err()
+}
+
+type bulletinSummary struct {
+	db.BackfillCheckpoint
+	newEntries int
+}
+
+// backfillBulletin fetches and archives a single bulletin, saving its
+// checkpoint. The error returned is only about saving the checkpoint,
+// the errors fetching the bulletin are kept in the checkpoint
+func backfillBulletin(ctx context.Context, store *db.Store, opts BackfillOptions, task bulletinTask) (*bulletinSummary, error) {
+	summary := bulletinSummary{
+		BackfillCheckpoint: db.BackfillCheckpoint{
+			NatureCode:   task.court,
+			BulletinDate: task.date,
+			Status:       db.CheckpointDone,
+		},
+	}
+
+	data, err := opts.Fetch(ctx, task.date.Format("212006"), task.court)
+
+	if err == nil {
+		docs := tsj.BulletinDocs(*data, task.court, task.date)
+		summary.Entries = len(docs)
+		summary.newEntries, err = store.Backfill.ArchiveDocs(ctx, docs)
+	}
+
+	if errors.Is(err, reader.ErrNotFound) {
+		summary.Status = db.CheckpointMissing
+	} else if err != nil {
+		summary.Status = db.CheckpointFailed
+		summary.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	if ctx.Err() != nil {
+		return &summary, ctx.Err()
+	}
+
+	err = store.Backfill.SaveBackfillCheckpoint(ctx, &summary.BackfillCheckpoint)
+
+	if err != nil {
+		return &summary, fmt.Errorf("No se pudo guardar el avance de %v %v: %w", task.court, task.date.Format("2006-01-02"), err)
+	}
+
+	return &summary, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
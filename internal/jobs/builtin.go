@@ -0,0 +1,91 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/notify"
+	"github.com/vladwithcode/juzgados/internal/webhooks"
+)
+
+// The jobs that used to run from crontab, with their default schedule
+// and timeout. Both can be changed with JOB_<NAME>_SCHEDULE and
+// JOB_<NAME>_TIMEOUT, e.g. JOB_REPORT_SCHEDULE="30 8 * * 1-5".
+// Setting the schedule to "off" leaves the job to be run on demand
+var builtinJobs = []struct {
+	name     string
+	schedule string
+	timeout  time.Duration
+}{
+	// Checks the cases due following the frequency of their subscribers,
+	// each one in the bulletins published since its last check
+	{"update", "@hourly", 30 * time.Minute},
+	{"report", "0 9 * * 1-5", 30 * time.Minute},
+	// Delivers the notifications in the outbox and retries the failed ones
+	{"notify", "*/5 * * * *", 10 * time.Minute},
+	// Sends the email digests, every user chooses the hour of their own
+	{"digest", "0 * * * *", 30 * time.Minute},
+	// Sends the events to the webhook endpoints and retries the failed ones
+	{"webhooks", "* * * * *", 5 * time.Minute},
+}
+
+// envKey returns the environment variable for the setting of a job
+func envKey(name, setting string) string {
+	return "JOB_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_" + setting
+}
+
+// BuiltinJobs returns the jobs of the app configured from the environment
+func BuiltinJobs(store *db.Store, notifier *notify.Dispatcher, hostname string) ([]*Job, error) {
+	run := map[string]func(ctx context.Context, run *db.JobRun) error{
+		"update": func(ctx context.Context, run *db.JobRun) error {
+			return Update(ctx, store, UpdateOptions{}, run)
+		},
+		"report": func(ctx context.Context, run *db.JobRun) error {
+			return Report(ctx, store, notifier, hostname, run)
+		},
+		"notify": func(ctx context.Context, run *db.JobRun) error {
+			return Notify(ctx, store, notifier, hostname, run)
+		},
+		"digest": func(ctx context.Context, run *db.JobRun) error {
+			return Digest(ctx, store, notifier, hostname, run)
+		},
+		"webhooks": func(ctx context.Context, run *db.JobRun) error {
+			return Webhooks(ctx, store, webhooks.NewSender(), run)
+		},
+	}
+
+	jobs := []*Job{}
+
+	for _, b := range builtinJobs {
+		job := Job{
+			Name:    b.name,
+			Timeout: internal.EnvDuration(envKey(b.name, "TIMEOUT"), b.timeout),
+			Run:     run[b.name],
+		}
+
+		expr := b.schedule
+
+		if env, ok := os.LookupEnv(envKey(b.name, "SCHEDULE")); ok {
+			expr = env
+		}
+
+		if expr != "off" {
+			schedule, err := ParseSchedule(expr)
+
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", envKey(b.name, "SCHEDULE"), err)
+			}
+
+			job.Schedule = schedule
+		}
+
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule is a parsed cron expression with the five usual fields:
+// minute, hour, day of month, month and day of week
+type Schedule struct {
+	expr   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	anyDom bool
+	anyDow bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a cron expression. Every field accepts *, single
+// values, ranges (1-5), steps (*/15 or 8-18/2) and lists of them (1,3,5).
+// Sunday is both 0 and 7 in the day of week. @hourly, @daily, @weekly
+// and @monthly are accepted too
+func ParseSchedule(expr string) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	fieldsExpr := expr
+
+	if shortcut, ok := cronShortcuts[expr]; ok {
+		fieldsExpr = shortcut
+	}
+
+	fields := strings.Fields(fieldsExpr)
+
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("La expresión cron %q debe tener %v campos", expr, len(cronFields))
+	}
+
+	s := Schedule{expr: expr}
+	sets := []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+
+		if err != nil {
+			return nil, fmt.Errorf("Campo %v de %q inválido: %w", cronFields[i].name, expr, err)
+		}
+
+		*sets[i] = set
+	}
+
+	// Sunday can be written as 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	s.anyDom = fields[2] == "*"
+	s.anyDow = fields[4] == "*"
+
+	return &s, nil
+}
+
+func parseCronField(field string, f cronField) (uint64, error) {
+	var set uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("paso %q inválido", stepExpr)
+			}
+
+			step = n
+		}
+
+		start, end := f.min, f.max
+
+		if rangeExpr != "*" {
+			from, to, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("valor %q inválido", from)
+			}
+
+			end = start
+
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("valor %q inválido", to)
+				}
+			} else if hasStep {
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("%q fuera del rango %v-%v", part, f.min, f.max)
+		}
+
+		for v := start; v <= end; v += step {
+			set |= 1 << v
+		}
+	}
+
+	return set, nil
+}
+
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+
+	// Like cron, when both days are restricted either one is enough
+	if !s.anyDom && !s.anyDow {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+// Next returns the first time after t matched by the schedule, or the
+// zero time when nothing matches in the next five years
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,157 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/alerts"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+// QueueDigests queues in the outbox the email digest of every user whose
+// digest is due at now, with the changes detected since their last one.
+// Users without new accords get nothing and their next digest starts at
+// now. It returns how many digests were queued
+func QueueDigests(ctx context.Context, store *db.Store, hostname string, now time.Time) (int, error) {
+	settings, err := store.Digests.FindEnabledDigests(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+
+	for _, s := range settings {
+		if ctx.Err() != nil {
+			return queued, ctx.Err()
+		}
+
+		if !s.IsDue(now) {
+			continue
+		}
+
+		created, err := queueDigest(ctx, store, hostname, s, now)
+
+		if err != nil {
+			log.Printf("Queue digest of %v err: %v\n", s.UserId, err)
+			continue
+		}
+
+		if created {
+			queued++
+		}
+	}
+
+	return queued, nil
+}
+
+// queueDigest queues the digest of a single user and moves their
+// last digest to now, reporting whether a digest was queued
+func queueDigest(ctx context.Context, store *db.Store, hostname string, settings *db.DigestSettings, now time.Time) (bool, error) {
+	changes, err := store.Digests.FindDigestChanges(ctx, settings.UserId, settings.LastSentAt, now)
+
+	if err != nil {
+		return false, err
+	}
+
+	created := false
+
+	if len(changes) > 0 {
+		user, err := store.Users.GetUserById(ctx, settings.UserId)
+
+		if err != nil {
+			return false, err
+		}
+
+		digest := db.NewDigest(changes, settings.LastSentAt, now)
+
+		for _, court := range digest.Courts {
+			for _, c := range court.Cases {
+				c.Link = fmt.Sprintf("http://%v/alerta/%v", hostname, c.AlertId)
+			}
+		}
+
+		msg := notify.Message{
+			Event:   db.NotificationDigest,
+			User:    user,
+			Subject: fmt.Sprintf("Resumen de acuerdos nuevos al %v", internal.FormatDate(now)),
+			Text:    fmt.Sprintf("%v, hay %v acuerdos nuevos en %v de tus expedientes", user.Name, digest.Accords, digest.CaseCount()),
+			Link:    fmt.Sprintf("http://%v/dashboard", hostname),
+			Digest:  digest,
+		}
+
+		// The digest is worth sending even when the report can't be printed
+		if settings.AttachPdf {
+			docPath, err := alerts.GenReportPdfWithData(ctx, digestReport(user, digest))
+
+			if err != nil {
+				log.Printf("Digest report of %v err: %v\n", user.Id, err)
+			} else {
+				msg.Attachment = filepath.Join("web/static", docPath)
+			}
+		}
+
+		// The same slot is never queued twice, even if marking it as sent fails
+		slot := settings.Next(settings.LastSentAt)
+		key := fmt.Sprintf("%v:%v:%v", db.NotificationDigest, user.Id, slot.Unix())
+		created, err = Enqueue(ctx, store, key, &msg)
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return created, store.Digests.MarkDigestSent(ctx, settings.UserId, now)
+}
+
+// digestReport returns the report of the cases in digest with their
+// newest accord, for the PDF attached to the digest
+func digestReport(user *db.User, digest *db.Digest) db.AutoReportUser {
+	report := db.AutoReportUser{
+		Id:       user.Id,
+		Name:     user.Name,
+		Lastname: user.Lastname,
+		Email:    user.Email,
+		Phone:    user.Phone.String,
+	}
+
+	for _, court := range digest.Courts {
+		for _, c := range court.Cases {
+			last := c.Accords[len(c.Accords)-1]
+
+			report.Alerts = append(report.Alerts, db.AutoReportAlert{
+				Id:             c.AlertId,
+				CaseId:         c.CaseId,
+				NatureCode:     court.NatureCode,
+				LastAccord:     sql.NullString{String: last.Accord, Valid: true},
+				LastAccordDate: sql.NullTime{Time: last.AccordDate, Valid: true},
+			})
+		}
+	}
+
+	return report
+}
+
+// Digest queues the digests due and delivers them along with any other
+// notification due, recording both in run
+func Digest(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string, run *db.JobRun) error {
+	queued, err := QueueDigests(ctx, store, hostname, time.Now())
+	log.Printf("Queued %v digests\n", queued)
+	run.ItemsQueued += queued
+
+	if err != nil {
+		return err
+	}
+
+	result, err := DeliverOutbox(ctx, store, notifier, hostname)
+	log.Printf("Delivered %v of %v notifications, %v to retry, %v dead\n", result.Sent, result.Claimed, result.Retried, result.Dead)
+	result.Record(run)
+
+	return err
+}
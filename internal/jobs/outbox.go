@@ -0,0 +1,220 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+const (
+	// How many notifications are claimed at once
+	outboxBatch = 50
+	// How long a worker may take to deliver a claimed batch before
+	// another one claims it again
+	outboxLock = 10 * time.Minute
+	// Failed deliveries are retried until this many attempts were made,
+	// then the notification is dead until an admin requeues it
+	OutboxMaxAttempts = 8
+	outboxRetryBase   = time.Minute
+	outboxRetryMax    = 6 * time.Hour
+)
+
+// RetryDelay returns how long a notification waits after its failed
+// attempt number attempts: a minute after the first one, doubling
+// on every attempt up to six hours
+func RetryDelay(attempts int) time.Duration {
+	delay := outboxRetryBase
+
+	for i := 1; i < attempts && delay < outboxRetryMax; i++ {
+		delay *= 2
+	}
+
+	if delay > outboxRetryMax {
+		delay = outboxRetryMax
+	}
+
+	return delay
+}
+
+type OutboxResult struct {
+	Claimed int
+	Sent    int
+	// Failed deliveries waiting for another attempt
+	Retried int
+	Dead    int
+}
+
+// Record copies the counts of the delivery into run
+func (r *OutboxResult) Record(run *db.JobRun) {
+	run.ItemsProcessed += r.Claimed
+	run.ItemsSent += r.Sent
+	run.ItemsFailed += r.Retried + r.Dead
+}
+
+// Enqueue queues msg for its user in the outbox. key identifies the
+// notification, queueing the same key again does nothing
+func Enqueue(ctx context.Context, store *db.Store, key string, msg *notify.Message) (bool, error) {
+	payload, err := json.Marshal(msg)
+
+	if err != nil {
+		return false, err
+	}
+
+	return store.Outbox.EnqueueNotification(ctx, &db.OutboxItem{
+		UserId:         msg.User.Id,
+		Event:          msg.Event,
+		IdempotencyKey: key,
+		Payload:        payload,
+	})
+}
+
+// DeliverOutbox delivers the notifications due in the outbox through the
+// channels chosen by their users. A notification is retried with a growing
+// delay only through the channels that failed, so nobody gets it twice
+func DeliverOutbox(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string) (*OutboxResult, error) {
+	result := OutboxResult{}
+
+	for ctx.Err() == nil {
+		items, err := store.Outbox.ClaimDueNotifications(ctx, outboxBatch, outboxLock)
+
+		if err != nil {
+			return &result, err
+		}
+
+		for _, item := range items {
+			result.Claimed++
+
+			deliverItem(ctx, store, notifier, hostname, item)
+
+			if err := store.Outbox.FinishNotification(ctx, item); err != nil {
+				log.Printf("Finish notification %v err: %v\n", item.Id, err)
+				continue
+			}
+
+			switch item.Status {
+			case db.OutboxSent:
+				result.Sent++
+			case db.OutboxDead:
+				result.Dead++
+			default:
+				result.Retried++
+			}
+		}
+
+		if len(items) < outboxBatch {
+			break
+		}
+	}
+
+	return &result, ctx.Err()
+}
+
+// deliverItem sends a claimed notification and sets the outcome in item
+func deliverItem(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string, item *db.OutboxItem) {
+	msg := notify.Message{}
+	err := json.Unmarshal(item.Payload, &msg)
+
+	if err != nil {
+		// It will never be readable, don't retry it
+		item.Attempts = OutboxMaxAttempts
+		failNotification(item, fmt.Errorf("Contenido inválido: %w", err))
+		return
+	}
+
+	msg.Event = item.Event
+	msg.User, err = store.Users.GetUserById(ctx, item.UserId)
+
+	if err != nil {
+		failNotification(item, err)
+		return
+	}
+
+	if msg.Change != nil && msg.Subject == "" {
+		describeAccord(&msg, hostname)
+	}
+
+	deliveries, err := notifier.DispatchSkipping(ctx, &msg, item.DeliveredChannels)
+
+	if errors.Is(err, notify.ErrNoChannels) {
+		if len(deliveries) > 0 {
+			log.Printf("No channel could reach %v with notification %v, dropping it\n", item.UserId, item.Id)
+		}
+
+		err = nil
+	}
+
+	failed := []string{}
+
+	for _, d := range deliveries {
+		switch d.Status {
+		case db.DeliverySent:
+			item.DeliveredChannels = append(item.DeliveredChannels, d.Channel)
+		case db.DeliveryFailed:
+			failed = append(failed, fmt.Sprintf("%v: %v", d.Channel, d.Error.String))
+		}
+	}
+
+	if len(failed) > 0 {
+		err = errors.New(strings.Join(failed, "; "))
+	}
+
+	if err != nil {
+		failNotification(item, err)
+		return
+	}
+
+	item.Status = db.OutboxSent
+	item.SentAt = sql.NullTime{Time: time.Now(), Valid: true}
+	item.LastError = sql.NullString{}
+}
+
+// failNotification schedules the next attempt of item, or marks it
+// as dead when it ran out of attempts
+func failNotification(item *db.OutboxItem, err error) {
+	item.LastError = sql.NullString{String: err.Error(), Valid: true}
+
+	if item.Attempts >= OutboxMaxAttempts {
+		item.Status = db.OutboxDead
+		return
+	}
+
+	item.Status = db.OutboxPending
+	item.NextAttemptAt = time.Now().Add(RetryDelay(item.Attempts))
+}
+
+// describeAccord fills the text of a notification about a new accord
+func describeAccord(msg *notify.Message, hostname string) {
+	change := msg.Change
+	court := internal.CodesMap[change.NatureCode]
+
+	msg.Subject = fmt.Sprintf("Nuevo acuerdo en %v – %v", change.CaseId, court)
+	msg.Text = fmt.Sprintf(
+		"Nuevo acuerdo en el expediente %v del Juzgado %v, publicado el %v: %v",
+		change.CaseId,
+		court,
+		internal.FormatDate(change.AccordDate),
+		change.Accord,
+	)
+
+	if msg.AlertId != "" {
+		msg.Link = fmt.Sprintf("http://%v/alerta/%v", hostname, msg.AlertId)
+	}
+}
+
+// Notify delivers the notifications due and records how many in run
+func Notify(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string, run *db.JobRun) error {
+	result, err := DeliverOutbox(ctx, store, notifier, hostname)
+	log.Printf("Delivered %v of %v notifications, %v to retry, %v dead\n", result.Sent, result.Claimed, result.Retried, result.Dead)
+	result.Record(run)
+
+	return err
+}
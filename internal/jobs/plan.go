@@ -0,0 +1,142 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+// What an update would do with an accord found, see Case.IsNewAccord
+const (
+	DiffNew       = "new"
+	DiffUnchanged = "unchanged"
+	// The accord found is older than the one known, it's ignored
+	DiffOlder = "older"
+)
+
+// An AccordDiff compares the accord an update found for a case
+// with the one the case holds
+type AccordDiff struct {
+	CaseRef    string `json:"caseRef"`
+	CaseId     string `json:"caseId"`
+	NatureCode string `json:"natureCode"`
+	Court      string `json:"court"`
+	Change     string `json:"change"`
+	OldNature  string `json:"oldNature"`
+	OldAccord  string `json:"oldAccord"`
+	// nil when the case has no accord yet
+	OldAccordDate *time.Time `json:"oldAccordDate"`
+	NewNature     string     `json:"newNature"`
+	NewAccord     string     `json:"newAccord"`
+	NewAccordDate time.Time  `json:"newAccordDate"`
+}
+
+// An UpdatePlan tells what an update would change without changing it
+type UpdatePlan struct {
+	CheckedCases     int               `json:"checkedCases"`
+	Diffs            []*AccordDiff     `json:"diffs"`
+	NotFoundKeys     []string          `json:"notFoundKeys"`
+	BulletinsFetched int               `json:"bulletinsFetched"`
+	FetchErrors      int               `json:"fetchErrors"`
+	CourtErrors      map[string]string `json:"courtErrors"`
+}
+
+// Count returns how many diffs are of the given change
+func (p *UpdatePlan) Count(change string) int {
+	count := 0
+
+	for _, diff := range p.Diffs {
+		if diff.Change == change {
+			count++
+		}
+	}
+
+	return count
+}
+
+// PlanUpdate does the same lookups as UpdateCases and compares the accords
+// found with the ones stored, but only reads from store. It's meant to
+// check changes to the parser against real bulletins before deploying them
+func PlanUpdate(ctx context.Context, store *db.Store, opts UpdateOptions) (*UpdatePlan, error) {
+	if opts.FetchCases == nil {
+		opts.FetchCases = tsj.GetCasesDataSince
+	}
+
+	if opts.StartDate.IsZero() {
+		opts.StartDate = time.Now()
+	}
+
+	activeCases, since, err := casesToCheck(ctx, store, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	casesByKey := map[string][]*db.Case{}
+
+	for _, c := range activeCases {
+		casesByKey[c.GetCaseKey()] = append(casesByKey[c.GetCaseKey()], c)
+	}
+
+	log.Println("Fetching cases data")
+	resCases, err := opts.FetchCases(ctx, since, opts.StartDate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan := UpdatePlan{
+		CheckedCases:     len(since),
+		Diffs:            []*AccordDiff{},
+		NotFoundKeys:     resCases.NotFoundKeys,
+		BulletinsFetched: resCases.BulletinsFetched,
+		FetchErrors:      resCases.FetchErrors,
+		CourtErrors:      resCases.CourtErrors,
+	}
+
+	for _, doc := range resCases.Docs {
+		for _, c := range casesByKey[db.TrimField(doc.Case)+"+"+doc.NatureCode] {
+			diff := AccordDiff{
+				CaseRef:       c.Id,
+				CaseId:        c.CaseId,
+				NatureCode:    c.NatureCode,
+				Court:         internal.CodesMap[c.NatureCode],
+				Change:        DiffUnchanged,
+				OldNature:     c.Nature,
+				OldAccord:     c.LastAccord.String,
+				NewNature:     doc.Nature,
+				NewAccord:     doc.Accord,
+				NewAccordDate: doc.AccordDate,
+			}
+
+			if c.LastAccordDate.Valid {
+				diff.OldAccordDate = &c.LastAccordDate.Time
+			}
+
+			if c.IsNewAccord(doc.Accord, doc.AccordDate) {
+				diff.Change = DiffNew
+			} else if doc.AccordDate.Before(c.LastAccordDate.Time) {
+				diff.Change = DiffOlder
+			}
+
+			plan.Diffs = append(plan.Diffs, &diff)
+		}
+	}
+
+	sort.Slice(plan.Diffs, func(i, j int) bool {
+		if plan.Diffs[i].NatureCode != plan.Diffs[j].NatureCode {
+			return plan.Diffs[i].NatureCode < plan.Diffs[j].NatureCode
+		}
+
+		return plan.Diffs[i].CaseId < plan.Diffs[j].CaseId
+	})
+
+	sort.Strings(plan.NotFoundKeys)
+
+	return &plan, ctx.Err()
+}
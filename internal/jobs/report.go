@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/alerts"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+// QueueAutoReports generates the report of every user with a new accord in
+// their auto report alerts and queues its link in the outbox. Users are only
+// sent a report again once another accord is found, and the report of a
+// change is queued once even when the job runs again. It returns how many
+// reports were queued
+func QueueAutoReports(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string) (int, error) {
+	log.Println("Query auto report alerts with changes")
+	// The changes found while the reports are queued wait for the next report
+	queriedAt := time.Now()
+	userAlerts, err := store.Alerts.FindAutoReportAlertsWithUserData(ctx, true)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		queued int
+	)
+
+	log.Println("Start report generation")
+	for _, user := range userAlerts {
+		wg.Add(1)
+		go func(user *db.AutoReportUser) {
+			defer wg.Done()
+			channels, err := notifier.Channels(ctx, user.Id, db.NotificationReport)
+
+			if err != nil {
+				log.Printf("Find channels of %v err: %v\n", user.Id, err)
+				return
+			}
+
+			if len(channels) == 0 {
+				return
+			}
+
+			_, err = alerts.GenReportPdfWithData(ctx, *user)
+
+			if err != nil {
+				log.Printf("GenReport err: %v\n", err)
+				return
+			}
+
+			docHref := fmt.Sprintf("http://%v/reports/%v/report.pdf", hostname, user.Id)
+			key := fmt.Sprintf("%v:%v:%v", db.NotificationReport, user.Id, user.LastChangeId)
+			created, err := Enqueue(ctx, store, key, reportMessage(user, docHref))
+
+			if err != nil {
+				log.Printf("Queue report err: %v\n", err)
+				return
+			}
+
+			ids := []string{}
+			for _, alert := range user.Alerts {
+				ids = append(ids, alert.Id)
+			}
+
+			if err := store.Alerts.MarkAlertsNotified(ctx, ids, queriedAt); err != nil {
+				log.Printf("MarkAlertsNotified err: %v\n", err)
+			}
+
+			if created {
+				mu.Lock()
+				queued++
+				mu.Unlock()
+			}
+		}(user)
+	}
+
+	wg.Wait()
+
+	return queued, nil
+}
+
+func reportMessage(user *db.AutoReportUser, docHref string) *notify.Message {
+	return &notify.Message{
+		Event: db.NotificationReport,
+		User: &db.User{
+			Id:       user.Id,
+			Name:     user.Name,
+			Lastname: user.Lastname,
+			Email:    user.Email,
+			Phone:    sql.NullString{String: user.Phone, Valid: user.Phone != ""},
+		},
+		Subject: "Reporte de acuerdos nuevos",
+		Text:    fmt.Sprintf("%v, hay acuerdos nuevos en %v de tus expedientes", user.Name, len(user.Alerts)),
+		Link:    docHref,
+		Report:  user,
+	}
+}
+
+// Report queues the auto reports and delivers them along with any other
+// notification due, recording both in run
+func Report(ctx context.Context, store *db.Store, notifier *notify.Dispatcher, hostname string, run *db.JobRun) error {
+	queued, err := QueueAutoReports(ctx, store, notifier, hostname)
+	log.Printf("Queued %v reports\n", queued)
+	run.ItemsQueued += queued
+
+	if err != nil {
+		return err
+	}
+
+	result, err := DeliverOutbox(ctx, store, notifier, hostname)
+	log.Printf("Delivered %v of %v notifications, %v to retry, %v dead\n", result.Sent, result.Claimed, result.Retried, result.Dead)
+	result.Record(run)
+
+	return err
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+// Track records in store a run of the job named job while fn runs. fn
+// fills the counts of the run, its error decides the final status. The
+// job runs even when the run can't be recorded
+func Track(ctx context.Context, store *db.Store, job, triggeredBy string, fn func(ctx context.Context, run *db.JobRun) error) (*db.JobRun, error) {
+	run := db.JobRun{
+		Job:         job,
+		TriggeredBy: triggeredBy,
+		StartedAt:   time.Now(),
+	}
+
+	recorded := true
+	err := store.JobRuns.CreateJobRun(ctx, &run)
+
+	if err != nil {
+		recorded = false
+		log.Printf("Create job run err: %v\n", err)
+	}
+
+	err = fn(ctx, &run)
+	run.Finish(err, time.Now())
+
+	if recorded {
+		// The run is recorded even when it was cancelled
+		if ferr := store.JobRuns.FinishJobRun(context.WithoutCancel(ctx), &run); ferr != nil {
+			log.Printf("Finish job run err: %v\n", ferr)
+		}
+	}
+
+	return &run, err
+}
@@ -0,0 +1,277 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+var (
+	ErrUnknownJob = errors.New("No existe la tarea solicitada")
+	// Returned when the job is already running, here or in another instance
+	ErrJobRunning = errors.New("La tarea ya se está ejecutando")
+)
+
+// A Job is some work run by the Scheduler
+type Job struct {
+	Name string
+	// When the job runs, nil when it only runs on demand
+	Schedule *Schedule
+	// How long a run may take before its context is cancelled
+	Timeout time.Duration
+	// Does the work, filling the counts of run
+	Run func(ctx context.Context, run *db.JobRun) error
+}
+
+type JobStatus struct {
+	Name          string
+	Schedule      string
+	Timeout       time.Duration
+	Running       bool
+	NextRunAt     time.Time
+	LastRunAt     time.Time
+	LastSuccessAt time.Time
+	LastRunId     string
+	LastError     string
+}
+
+// The Scheduler runs its jobs following their schedules. Before every run
+// it takes a lock named after the job in the store, so only one instance
+// runs a job at a time even when several app servers share the database.
+// Every run is recorded in the job runs of the store
+type Scheduler struct {
+	store *db.Store
+	jobs  map[string]*Job
+
+	mu     sync.Mutex
+	status map[string]*JobStatus
+	ctx    context.Context
+	wg     sync.WaitGroup
+
+	// Used instead of time.Now when set
+	Now func() time.Time
+}
+
+func NewScheduler(store *db.Store, jobs ...*Job) *Scheduler {
+	s := Scheduler{
+		store:  store,
+		jobs:   map[string]*Job{},
+		status: map[string]*JobStatus{},
+		ctx:    context.Background(),
+	}
+
+	for _, job := range jobs {
+		s.jobs[job.Name] = job
+		s.status[job.Name] = &JobStatus{
+			Name:    job.Name,
+			Timeout: job.Timeout,
+		}
+
+		if job.Schedule != nil {
+			s.status[job.Name].Schedule = job.Schedule.String()
+		}
+	}
+
+	return &s
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+
+	return time.Now()
+}
+
+// Start runs the scheduled jobs in the background until ctx is done.
+// Runs started with RunNow are also cancelled with ctx
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		next := s.planNextRuns()
+
+		if next.IsZero() {
+			log.Println("[scheduler] No scheduled jobs")
+			return
+		}
+
+		timer := time.NewTimer(next.Sub(s.now()))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		for _, job := range s.jobs {
+			if job.Schedule == nil || s.Status(job.Name).NextRunAt.After(s.now()) {
+				continue
+			}
+
+			err := s.start(job, db.TriggerScheduler)
+
+			if err != nil {
+				log.Printf("[scheduler] Skipped %v: %v\n", job.Name, err)
+			}
+		}
+	}
+}
+
+// planNextRuns updates the next run of every scheduled job and
+// returns the earliest one
+func (s *Scheduler) planNextRuns() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	now := s.now()
+
+	for _, job := range s.jobs {
+		if job.Schedule == nil {
+			continue
+		}
+
+		status := s.status[job.Name]
+
+		if status.NextRunAt.IsZero() || !status.NextRunAt.After(now) {
+			status.NextRunAt = job.Schedule.Next(now)
+		}
+
+		if earliest.IsZero() || status.NextRunAt.Before(earliest) {
+			earliest = status.NextRunAt
+		}
+	}
+
+	return earliest
+}
+
+// RunNow starts the job named name in the background, out of its schedule
+func (s *Scheduler) RunNow(name string) error {
+	job, ok := s.jobs[name]
+
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	return s.start(job, db.TriggerAdmin)
+}
+
+// start takes the lock of job and runs it in the background
+func (s *Scheduler) start(job *Job, triggeredBy string) error {
+	s.mu.Lock()
+	status := s.status[job.Name]
+	ctx := s.ctx
+
+	if status.Running {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+
+	status.Running = true
+	s.mu.Unlock()
+
+	unlock, ok, err := s.store.Locks.TryLock(ctx, "job:"+job.Name)
+
+	if err != nil || !ok {
+		s.mu.Lock()
+		status.Running = false
+		s.mu.Unlock()
+
+		if err != nil {
+			return fmt.Errorf("No se pudo bloquear la tarea: %w", err)
+		}
+
+		return ErrJobRunning
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer unlock()
+
+		s.run(ctx, job, triggeredBy)
+	}()
+
+	return nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job, triggeredBy string) {
+	startedAt := s.now()
+	log.Printf("[scheduler] Running %v\n", job.Name)
+
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	run, err := Track(ctx, s.store, job.Name, triggeredBy, job.Run)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status[job.Name]
+	status.Running = false
+	status.LastRunAt = startedAt
+	status.LastRunId = run.Id
+	status.LastError = ""
+
+	if err != nil {
+		status.LastError = err.Error()
+		log.Printf("[scheduler] %v failed after %v: %v\n", job.Name, s.now().Sub(startedAt), err)
+		return
+	}
+
+	status.LastSuccessAt = startedAt
+	log.Printf("[scheduler] %v finished in %v\n", job.Name, s.now().Sub(startedAt))
+}
+
+// Status returns the state of the job named name, the zero
+// JobStatus when there's no such job
+func (s *Scheduler) Status(name string) JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status, ok := s.status[name]; ok {
+		return *status
+	}
+
+	return JobStatus{}
+}
+
+// Statuses returns the state of every job sorted by name
+func (s *Scheduler) Statuses() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []JobStatus{}
+
+	for _, status := range s.status {
+		statuses = append(statuses, *status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses
+}
+
+// Wait blocks until every running job finished
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,287 @@
+// Package jobs holds the work done by the scheduled commands, so it
+// can run against any db.Store
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/alerts"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+type UpdateOptions struct {
+	// Number of days to search in the past. Every case is also searched
+	// back to the day it was last checked, unless CheckAll is set
+	DaysBack uint
+	// The date the search starts from, it searches from this date backwards
+	StartDate time.Time
+	// Check every followed case, ignoring the frequency chosen for them
+	CheckAll bool
+	// Fetches the accords of every case (case_id+nature_code) in the
+	// bulletins since its date, tsj.GetCasesDataSince when nil
+	FetchCases func(ctx context.Context, since map[string]time.Time, startDate time.Time) (*tsj.GetCasesResult, error)
+}
+
+// The most days a case is searched back to its last check, the same
+// days a case must be missing from the bulletins to be reported
+const maxLookback = tsj.DEFAULT_DAYS_BACK
+
+type UpdateResult struct {
+	CheckedCases int
+	FoundDocs    []*db.Doc
+	// The accords that changed their case, UpdatedCases counts them
+	Changes          []*db.AccordChange
+	UpdatedCases     int
+	UnchangedCases   int
+	NotFoundKeys     []string
+	BulletinsFetched int
+	FetchErrors      int
+	CourtErrors      map[string]string
+	LinkedCases      int
+	LinkedAppeals    int
+	// Errors that didn't stop the update
+	Errors []error
+}
+
+// Record copies the counts of the update into run
+func (r *UpdateResult) Record(run *db.JobRun) {
+	run.CasesChecked += r.CheckedCases
+	run.CasesFound += len(r.FoundDocs)
+	run.CasesUpdated += r.UpdatedCases
+	run.CasesUnchanged += r.UnchangedCases
+	run.CasesNotFound += len(r.NotFoundKeys)
+	run.NotFoundKeys = append(run.NotFoundKeys, r.NotFoundKeys...)
+	run.BulletinsFetched += r.BulletinsFetched
+	run.FetchErrors += r.FetchErrors
+
+	if run.CourtErrors == nil {
+		run.CourtErrors = map[string]string{}
+	}
+
+	for court, msg := range r.CourtErrors {
+		run.CourtErrors[court] = msg
+	}
+}
+
+// casesToCheck returns the cases the update checks along with the day
+// the search of each key (case_id+nature_code) goes back to
+func casesToCheck(ctx context.Context, store *db.Store, opts UpdateOptions) ([]*db.Case, map[string]time.Time, error) {
+	var (
+		activeCases []*db.Case
+		err         error
+	)
+
+	if opts.CheckAll {
+		log.Println("Querying followed cases")
+		activeCases, err = store.Alerts.FindActiveCases(ctx, time.Now())
+	} else {
+		log.Println("Querying cases due for a check")
+		activeCases, err = store.Alerts.FindDueCases(ctx, time.Now())
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Found %v followed cases", len(activeCases))
+
+	since := map[string]time.Time{}
+
+	for _, c := range activeCases {
+		cK := c.GetCaseKey()
+		day := lookback(c, opts)
+
+		if current, ok := since[cK]; !ok || day.Before(current) {
+			since[cK] = day
+		}
+	}
+
+	return activeCases, since, nil
+}
+
+// lookback returns the first day of the bulletins searched for c: the
+// day of its last check, so the accords published since aren't missed,
+// or DaysBack days before the start when it goes further
+func lookback(c *db.Case, opts UpdateOptions) time.Time {
+	start := startOfDay(opts.StartDate)
+	since := start.AddDate(0, 0, -int(opts.DaysBack))
+
+	if opts.CheckAll {
+		return since
+	}
+
+	lastChecked := startOfDay(c.LastCheckedAt.In(start.Location()))
+
+	if oldest := start.AddDate(0, 0, -maxLookback); lastChecked.Before(oldest) {
+		lastChecked = oldest
+	}
+
+	if lastChecked.Before(since) {
+		return lastChecked
+	}
+
+	return since
+}
+
+// UpdateCases looks for new accords of the cases due for a check, stores
+// them and follows the transfers and appeals mentioned in them
+func UpdateCases(ctx context.Context, store *db.Store, opts UpdateOptions) (*UpdateResult, error) {
+	if opts.FetchCases == nil {
+		opts.FetchCases = tsj.GetCasesDataSince
+	}
+
+	if opts.StartDate.IsZero() {
+		opts.StartDate = time.Now()
+	}
+
+	var result UpdateResult
+
+	activeCases, since, err := casesToCheck(ctx, store, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.CheckedCases = len(since)
+
+	log.Println("Fetching cases data")
+	resCases, err := opts.FetchCases(ctx, since, opts.StartDate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result.FoundDocs = resCases.Docs
+	result.NotFoundKeys = []string{}
+	result.BulletinsFetched = resCases.BulletinsFetched
+	result.FetchErrors = resCases.FetchErrors
+	result.CourtErrors = resCases.CourtErrors
+	log.Printf("Found data for %v cases\n", len(resCases.Docs))
+
+	for court, msg := range resCases.CourtErrors {
+		log.Printf("No bulletin could be read for %v: %v\n", court, msg)
+	}
+
+	// Nothing is known of the cases of a court without a readable bulletin
+	for _, key := range resCases.NotFoundKeys {
+		_, natureCode, _ := strings.Cut(key, "+")
+
+		if _, failed := resCases.CourtErrors[natureCode]; !failed {
+			result.NotFoundKeys = append(result.NotFoundKeys, key)
+		}
+	}
+
+	log.Println("Updating db cases")
+	updates, err := store.Alerts.UpdateAlertsForCases(ctx, resCases.Docs)
+
+	if err != nil {
+		return &result, err
+	}
+
+	// The cases of the courts without a readable bulletin stay due, so
+	// the next run looks for them again
+	caseIds := []string{}
+
+	for _, c := range activeCases {
+		if _, failed := resCases.CourtErrors[c.NatureCode]; !failed {
+			caseIds = append(caseIds, c.Id)
+		}
+	}
+
+	if err := store.Alerts.MarkCasesChecked(ctx, caseIds); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+
+	result.Changes = updates.Changes
+	result.UpdatedCases = len(updates.Changes)
+	result.UnchangedCases = updates.Unchanged
+
+	missing := missingCases(activeCases, result.NotFoundKeys, opts.StartDate)
+	result.Errors = append(result.Errors, notifyNotFound(ctx, store, missing, opts.StartDate)...)
+
+	for _, key := range updates.Missing {
+		result.Errors = append(result.Errors, fmt.Errorf("No se encontró información nueva para el caso %v", key))
+	}
+
+	log.Printf("Found %v new accords, %v cases unchanged\n", result.UpdatedCases, result.UnchangedCases)
+
+	// Only the new accords can mention a transfer or appeal not seen yet
+	changedDocs := updates.Docs()
+
+	log.Println("Looking for transferred cases")
+	linked, errs := alerts.FollowTransfers(ctx, store, changedDocs)
+	result.LinkedCases = linked
+	result.Errors = append(result.Errors, errs...)
+	log.Printf("Linked %v alerts to their receiving court\n", result.LinkedCases)
+
+	log.Println("Looking for the origin of appeal tocas")
+	result.LinkedAppeals, errs = alerts.LinkAppeals(ctx, store, changedDocs)
+	result.Errors = append(result.Errors, errs...)
+	log.Printf("Linked %v tocas to their original case\n", result.LinkedAppeals)
+
+	return &result, nil
+}
+
+// missingCases returns the keys of notFound whose cases had no accord in
+// the bulletins of the maxLookback days before start. A case missing
+// only from the days since its last check isn't missing yet
+func missingCases(cases []*db.Case, notFound []string, start time.Time) []string {
+	oldest := startOfDay(start).AddDate(0, 0, -maxLookback)
+	seen := map[string]bool{}
+
+	for _, c := range cases {
+		if c.LastAccordDate.Valid && !c.LastAccordDate.Time.Before(oldest) {
+			seen[c.GetCaseKey()] = true
+		}
+	}
+
+	missing := []string{}
+
+	for _, key := range notFound {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing
+}
+
+// Update runs UpdateCases and then matches the watches over the same
+// dates, logging a summary of the errors that didn't stop the update.
+// The counts of the update are added to run
+func Update(ctx context.Context, store *db.Store, opts UpdateOptions, run *db.JobRun) error {
+	if opts.StartDate.IsZero() {
+		opts.StartDate = time.Now()
+	}
+
+	result, err := UpdateCases(ctx, store, opts)
+
+	if result != nil {
+		result.Record(run)
+	}
+
+	if result != nil && len(result.Errors) > 0 {
+		log.Printf("%v errors occurred while updating", len(result.Errors))
+		log.Printf("Last error: %v", result.Errors[len(result.Errors)-1])
+	}
+
+	if err != nil {
+		return fmt.Errorf("Update cases: %w", err)
+	}
+
+	log.Println("Updated Alerts successfully")
+
+	log.Println("Matching active watches")
+	err = MatchWatches(ctx, store, opts.StartDate, opts.DaysBack)
+
+	if err != nil {
+		return fmt.Errorf("Match watches: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+func TestUpdateCases(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.NewDB()
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	now := created
+	d.Now = func() time.Time { return now }
+	store := d.Store()
+
+	for _, alert := range []*db.Alert{
+		{UserId: "u1", CaseId: "1/2024", NatureCode: "civ1", Active: true},
+		{UserId: "u1", CaseId: "2/2024", NatureCode: "civ1", Active: true},
+		{UserId: "u1", CaseId: "3/2024", NatureCode: "fam1", Active: true},
+	} {
+		if _, err := store.Alerts.CreateAlertWithData(ctx, alert); err != nil {
+			t.Fatalf("CreateAlertWithData: %v", err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	accordDate := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// A new accord for 1/2024, the known one for 2/2024 and no bulletin of fam1
+	fetch := func(ctx context.Context, since map[string]time.Time, startDate time.Time) (*tsj.GetCasesResult, error) {
+		return &tsj.GetCasesResult{
+			Docs: []*db.Doc{
+				{Case: "1/2024", NatureCode: "civ1", Accord: "Se admite la demanda", AccordDate: accordDate},
+				{Case: "2/2024", NatureCode: "civ1", Accord: "Se tiene por contestada", AccordDate: accordDate},
+			},
+			NotFoundKeys: []string{"3/2024+fam1"},
+			FetchErrors:  1,
+			CourtErrors:  map[string]string{"fam1": "timeout"},
+		}, nil
+	}
+
+	if _, err := store.Alerts.UpdateAlertsForCases(ctx, []*db.Doc{{Case: "2/2024", NatureCode: "civ1", Accord: "Se tiene por contestada", AccordDate: accordDate}}); err != nil {
+		t.Fatalf("UpdateAlertsForCases: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+
+	result, err := UpdateCases(ctx, store, UpdateOptions{CheckAll: true, FetchCases: fetch})
+
+	if err != nil {
+		t.Fatalf("UpdateCases: %v", err)
+	}
+
+	if result.CheckedCases != 3 || result.UpdatedCases != 1 || result.UnchangedCases != 1 {
+		t.Errorf("got %v checked, %v updated and %v unchanged, want 3, 1 and 1",
+			result.CheckedCases, result.UpdatedCases, result.UnchangedCases)
+	}
+
+	run := db.JobRun{}
+	result.Record(&run)
+
+	if run.CasesUnchanged != 1 || run.CasesUpdated != 1 || run.FetchErrors != 1 {
+		t.Errorf("recorded %v unchanged, %v updated and %v fetch errors, want 1, 1 and 1",
+			run.CasesUnchanged, run.CasesUpdated, run.FetchErrors)
+	}
+
+	cases, err := store.Alerts.FindActiveCases(ctx, now)
+
+	if err != nil {
+		t.Fatalf("FindActiveCases: %v", err)
+	}
+
+	for _, c := range cases {
+		checked := c.LastCheckedAt.Equal(now)
+
+		// The court of fam1 had no bulletin, its case is still due
+		if want := c.NatureCode != "fam1"; checked != want {
+			t.Errorf("%v+%v: checked %v, want %v", c.CaseId, c.NatureCode, checked, want)
+		}
+	}
+}
+
+func TestUpdateCasesLookback(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.NewDB()
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	d.Now = func() time.Time { return now }
+	store := d.Store()
+
+	// The cases are created, so checked, on the days before the start
+	for _, c := range []struct {
+		caseId    string
+		frequency db.Frequency
+		checked   time.Time
+	}{
+		{"1/2024", db.FrequencyHourly, start.Add(-2 * time.Hour)},
+		{"2/2024", db.FrequencyDaily, start.AddDate(0, 0, -1)},
+		{"3/2024", db.FrequencyWeekly, start.AddDate(0, 0, -7)},
+		{"4/2024", db.FrequencyDaily, start.AddDate(0, 0, -100)},
+	} {
+		now = c.checked
+		alert := db.Alert{UserId: "u1", CaseId: c.caseId, NatureCode: "civ1", Active: true, Frequency: c.frequency}
+
+		if _, err := store.Alerts.CreateAlertWithData(ctx, &alert); err != nil {
+			t.Fatalf("CreateAlertWithData: %v", err)
+		}
+	}
+
+	now = start
+	day := func(daysBack int) time.Time { return time.Date(2024, 3, 15-daysBack, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name string
+		opts UpdateOptions
+		want map[string]time.Time
+	}{
+		{
+			"since the last check",
+			UpdateOptions{StartDate: start},
+			map[string]time.Time{"1/2024+civ1": day(0), "2/2024+civ1": day(1), "3/2024+civ1": day(7), "4/2024+civ1": day(maxLookback)},
+		},
+		{
+			"days back further than the last check",
+			UpdateOptions{StartDate: start, DaysBack: 3},
+			map[string]time.Time{"1/2024+civ1": day(3), "2/2024+civ1": day(3), "3/2024+civ1": day(7), "4/2024+civ1": day(maxLookback)},
+		},
+		{
+			"every case the same days",
+			UpdateOptions{StartDate: start, DaysBack: 2, CheckAll: true},
+			map[string]time.Time{"1/2024+civ1": day(2), "2/2024+civ1": day(2), "3/2024+civ1": day(2), "4/2024+civ1": day(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		var got map[string]time.Time
+
+		tt.opts.FetchCases = func(ctx context.Context, since map[string]time.Time, startDate time.Time) (*tsj.GetCasesResult, error) {
+			got = since
+			return &tsj.GetCasesResult{}, nil
+		}
+
+		// Planned so the cases stay due for the next ones
+		if _, err := PlanUpdate(ctx, store, tt.opts); err != nil {
+			t.Fatalf("%v: PlanUpdate: %v", tt.name, err)
+		}
+
+		for key, want := range tt.want {
+			if !got[key].Equal(want) {
+				t.Errorf("%v: %v searched since %v, want %v", tt.name, key, got[key], want)
+			}
+		}
+	}
+}
+
+func TestUpdateCasesNotFound(t *testing.T) {
+	ctx := context.Background()
+	d := memdb.NewDB()
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	d.Now = func() time.Time { return start.AddDate(0, 0, -1) }
+	store := d.Store()
+
+	endpoint := db.WebhookEndpoint{
+		UserId: sql.NullString{String: "u1", Valid: true},
+		Url:    "https://example.com/hook",
+		Secret: "s",
+		Events: []string{db.WebhookCaseNotFound},
+	}
+
+	if err := store.Webhooks.CreateWebhookEndpoint(ctx, &endpoint); err != nil {
+		t.Fatalf("CreateWebhookEndpoint: %v", err)
+	}
+
+	lastAccord := func(date time.Time) (sql.NullString, sql.NullTime) {
+		return sql.NullString{String: "Se admite la demanda", Valid: true}, sql.NullTime{Time: date, Valid: true}
+	}
+
+	for _, alert := range []*db.Alert{
+		// Published a few days ago, missing only since its last check
+		{UserId: "u1", CaseId: "1/2024", NatureCode: "civ1", Active: true},
+		// Last published before the days a case is searched back
+		{UserId: "u1", CaseId: "2/2024", NatureCode: "civ1", Active: true},
+		// Never published
+		{UserId: "u1", CaseId: "3/2024", NatureCode: "civ1", Active: true},
+		// Its court had no readable bulletin
+		{UserId: "u1", CaseId: "4/2024", NatureCode: "fam1", Active: true},
+	} {
+		switch alert.CaseId {
+		case "1/2024":
+			alert.LastAccord, alert.LastAccordDate = lastAccord(start.AddDate(0, 0, -3))
+		case "2/2024":
+			alert.LastAccord, alert.LastAccordDate = lastAccord(start.AddDate(0, 0, -maxLookback-1))
+		}
+
+		if _, err := store.Alerts.CreateAlertWithData(ctx, alert); err != nil {
+			t.Fatalf("CreateAlertWithData: %v", err)
+		}
+	}
+
+	d.Now = func() time.Time { return start }
+
+	fetch := func(ctx context.Context, since map[string]time.Time, startDate time.Time) (*tsj.GetCasesResult, error) {
+		return &tsj.GetCasesResult{
+			NotFoundKeys: []string{"1/2024+civ1", "2/2024+civ1", "3/2024+civ1", "4/2024+fam1"},
+			CourtErrors:  map[string]string{"fam1": "timeout"},
+		}, nil
+	}
+
+	result, err := UpdateCases(ctx, store, UpdateOptions{StartDate: start, FetchCases: fetch})
+
+	if err != nil {
+		t.Fatalf("UpdateCases: %v", err)
+	}
+
+	if len(result.NotFoundKeys) != 3 {
+		t.Errorf("got not found %v, want the cases of civ1", result.NotFoundKeys)
+	}
+
+	deliveries, err := store.Webhooks.FindWebhookDeliveries(ctx, endpoint.Id, 10)
+
+	if err != nil {
+		t.Fatalf("FindWebhookDeliveries: %v", err)
+	}
+
+	got := map[string]bool{}
+
+	for _, delivery := range deliveries {
+		data := struct {
+			CaseId string `json:"caseId"`
+		}{}
+
+		if err := json.Unmarshal(delivery.Payload, &data); err != nil {
+			t.Fatalf("payload %s: %v", delivery.Payload, err)
+		}
+
+		got[data.CaseId] = true
+	}
+
+	if len(got) != 2 || !got["2/2024"] || !got["3/2024"] {
+		t.Errorf("got %v not found, want 2/2024 and 3/2024", got)
+	}
+}
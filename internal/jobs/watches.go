@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/notify"
+	"github.com/vladwithcode/juzgados/internal/tsj"
+)
+
+// MatchWatches looks for the active watches in the bulletins of the
+// given dates and queues a notification of the matches for their users
+func MatchWatches(ctx context.Context, store *db.Store, startDate time.Time, daysBack uint) error {
+	watches, err := store.Watches.FindActiveWatches(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Found %v active watches", len(watches))
+
+	if len(watches) > 0 {
+		matches, err := tsj.MatchWatches(ctx, watches, startDate, daysBack)
+
+		if err != nil {
+			return err
+		}
+
+		created, err := store.Watches.CreateWatchMatches(ctx, matches)
+
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Found %v new watch matches", len(created))
+	}
+
+	return notifyWatchMatches(ctx, store)
+}
+
+// notifyWatchMatches queues a notification for every user with pending
+// matches, the ones of a run whose queueing failed are sent by the next
+func notifyWatchMatches(ctx context.Context, store *db.Store) error {
+	pending, err := store.Watches.FindPendingWatchMatches(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	userMatches := map[string][]*db.WatchMatch{}
+	for _, m := range pending {
+		userMatches[m.UserId] = append(userMatches[m.UserId], m)
+	}
+
+	for userId, matches := range userMatches {
+		user, err := store.Users.GetUserById(ctx, userId)
+
+		if err != nil {
+			log.Printf("Find user %v err: %v\n", userId, err)
+			continue
+		}
+
+		ids := []string{}
+		cases := []string{}
+		for _, m := range matches {
+			ids = append(ids, m.Id)
+			cases = append(cases, fmt.Sprintf("%v (%v)", m.CaseId, m.NatureCode))
+		}
+
+		// The oldest pending match identifies the notification, a retry
+		// after a failed mark doesn't queue it twice. WhatsApp is their
+		// default channel, users without phone get them by email instead
+		// of losing them
+		_, err = Enqueue(ctx, store, db.NotificationWatchMatch+":"+ids[0], &notify.Message{
+			Event:    db.NotificationWatchMatch,
+			User:     user,
+			Subject:  "Coincidencias en los boletines",
+			Text:     fmt.Sprintf("%v, encontramos %v coincidencias de tus vigilancias en los boletines: %v", user.Name, len(matches), strings.Join(cases, ", ")),
+			Matches:  matches,
+			Fallback: db.ChannelEmail,
+		})
+
+		if err != nil {
+			log.Printf("Queue watch matches of %v err: %v\n", userId, err)
+			continue
+		}
+
+		if err := store.Watches.MarkWatchMatchesAsNotified(ctx, ids); err != nil {
+			log.Printf("Mark matches err: %v\n", err)
+		}
+	}
+
+	return nil
+}
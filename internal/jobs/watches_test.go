@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+)
+
+// failingOutbox fails to queue any notification while err is set
+type failingOutbox struct {
+	db.OutboxRepository
+	err error
+}
+
+func (o *failingOutbox) EnqueueNotification(ctx context.Context, item *db.OutboxItem) (bool, error) {
+	if o.err != nil {
+		return false, o.err
+	}
+
+	return o.OutboxRepository.EnqueueNotification(ctx, item)
+}
+
+func TestNotifyWatchMatches(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.New()
+	outbox := &failingOutbox{OutboxRepository: store.Outbox, err: errors.New("timeout")}
+	store.Outbox = outbox
+
+	if _, err := store.Users.CreateUser(ctx, &db.User{Id: "u1", Username: "ana", Email: "ana@example.com", Password: "secreto"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	watch, err := store.Watches.CreateWatch(ctx, &db.Watch{UserId: "u1", Pattern: "Pérez", Active: true})
+
+	if err != nil {
+		t.Fatalf("CreateWatch: %v", err)
+	}
+
+	match := db.WatchMatch{WatchId: watch.Id, UserId: "u1", CaseId: "1/2024", NatureCode: "civ1", AccordDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	if _, err := store.Watches.CreateWatchMatches(ctx, []*db.WatchMatch{&match}); err != nil {
+		t.Fatalf("CreateWatchMatches: %v", err)
+	}
+
+	// The failed run leaves the match pending for the next one
+	if err := notifyWatchMatches(ctx, store); err != nil {
+		t.Fatalf("notifyWatchMatches: %v", err)
+	}
+
+	if pending, _ := store.Watches.FindPendingWatchMatches(ctx); len(pending) != 1 {
+		t.Fatalf("after a failed queue: got %v pending matches, want 1", len(pending))
+	}
+
+	outbox.err = nil
+
+	if err := notifyWatchMatches(ctx, store); err != nil {
+		t.Fatalf("notifyWatchMatches: %v", err)
+	}
+
+	if pending, _ := store.Watches.FindPendingWatchMatches(ctx); len(pending) != 0 {
+		t.Errorf("after queueing: got %v pending matches, want 0", len(pending))
+	}
+
+	items, err := store.Outbox.ClaimDueNotifications(ctx, 10, time.Minute)
+
+	if err != nil || len(items) != 1 || items[0].Event != db.NotificationWatchMatch {
+		t.Errorf("got %v queued notifications, %v, want the watch match", len(items), err)
+	}
+}
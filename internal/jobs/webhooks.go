@@ -0,0 +1,197 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/webhooks"
+)
+
+const (
+	webhookBatch = 50
+	webhookLock  = 5 * time.Minute
+	// Failed deliveries are retried with RetryDelay until this many
+	// attempts were made
+	WebhookMaxAttempts = 8
+)
+
+// DeliverWebhooks sends the webhook deliveries due to their endpoints,
+// retrying the failed ones with a growing delay
+func DeliverWebhooks(ctx context.Context, store *db.Store, sender *webhooks.Sender) (*OutboxResult, error) {
+	result := OutboxResult{}
+	// The endpoints of the batch by id, most deliveries share a few
+	endpoints := map[string]*db.WebhookEndpoint{}
+
+	for ctx.Err() == nil {
+		deliveries, err := store.Webhooks.ClaimDueWebhookDeliveries(ctx, webhookBatch, webhookLock)
+
+		if err != nil {
+			return &result, err
+		}
+
+		for _, delivery := range deliveries {
+			result.Claimed++
+
+			endpoint, ok := endpoints[delivery.EndpointId]
+
+			if !ok {
+				endpoint, err = store.Webhooks.FindWebhookEndpointById(ctx, delivery.EndpointId)
+
+				// Deleted while it was claimed, the delivery is gone too
+				if errors.Is(err, pgx.ErrNoRows) {
+					continue
+				}
+
+				if err != nil {
+					return &result, err
+				}
+
+				endpoints[endpoint.Id] = endpoint
+			}
+
+			sendWebhook(ctx, sender, endpoint, delivery, WebhookMaxAttempts)
+
+			if err := store.Webhooks.FinishWebhookDelivery(ctx, delivery); err != nil {
+				log.Printf("Finish webhook delivery %v err: %v\n", delivery.Id, err)
+				continue
+			}
+
+			switch delivery.Status {
+			case db.WebhookSent:
+				result.Sent++
+			case db.WebhookDead:
+				result.Dead++
+			default:
+				result.Retried++
+			}
+		}
+
+		if len(deliveries) < webhookBatch {
+			break
+		}
+	}
+
+	return &result, ctx.Err()
+}
+
+// sendWebhook POSTs a claimed delivery and sets the outcome in it. It's
+// dead once it failed maxAttempts times
+func sendWebhook(ctx context.Context, sender *webhooks.Sender, endpoint *db.WebhookEndpoint, delivery *db.WebhookDelivery, maxAttempts int) {
+	status, err := sender.Send(ctx, endpoint, delivery)
+	delivery.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: status != 0}
+
+	if err == nil {
+		delivery.Status = db.WebhookSent
+		delivery.SentAt = sql.NullTime{Time: time.Now(), Valid: true}
+		delivery.LastError = sql.NullString{}
+		return
+	}
+
+	delivery.LastError = sql.NullString{String: err.Error(), Valid: true}
+
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = db.WebhookDead
+		return
+	}
+
+	delivery.Status = db.WebhookPending
+	delivery.NextAttemptAt = time.Now().Add(RetryDelay(delivery.Attempts))
+}
+
+// SendTestWebhook sends a db.WebhookTest event to endpoint right away and
+// records it in its deliveries. It isn't retried, the returned delivery
+// tells whether it was received
+func SendTestWebhook(ctx context.Context, store *db.Store, sender *webhooks.Sender, endpoint *db.WebhookEndpoint) (*db.WebhookDelivery, error) {
+	now := time.Now()
+	data, err := json.Marshal(map[string]any{
+		"endpointId": endpoint.Id,
+		"message":    "Evento de prueba",
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := db.WebhookDelivery{
+		EndpointId:  endpoint.Id,
+		Event:       db.WebhookTest,
+		EventId:     fmt.Sprintf("%v:%v:%v", db.WebhookTest, endpoint.Id, now.UnixNano()),
+		Payload:     data,
+		Attempts:    1,
+		LockedUntil: sql.NullTime{Time: now.Add(webhookLock), Valid: true},
+	}
+
+	if err := store.Webhooks.CreateWebhookDelivery(ctx, &delivery); err != nil {
+		return nil, err
+	}
+
+	sendWebhook(ctx, sender, endpoint, &delivery, 1)
+
+	return &delivery, store.Webhooks.FinishWebhookDelivery(ctx, &delivery)
+}
+
+// Webhooks delivers the webhook events due and records how many in run
+func Webhooks(ctx context.Context, store *db.Store, sender *webhooks.Sender, run *db.JobRun) error {
+	result, err := DeliverWebhooks(ctx, store, sender)
+	log.Printf("Delivered %v of %v webhook events, %v to retry, %v dead\n", result.Sent, result.Claimed, result.Retried, result.Dead)
+	result.Record(run)
+
+	return err
+}
+
+// notifyNotFound queues a db.WebhookCaseNotFound for the subscribers of the
+// cases of keys (case_id+nature_code), once a day per case and user
+func notifyNotFound(ctx context.Context, store *db.Store, keys []string, day time.Time) []error {
+	errs := []error{}
+
+	for _, key := range keys {
+		caseId, natureCode, ok := strings.Cut(key, "+")
+
+		if !ok {
+			continue
+		}
+
+		subscribers, err := store.Alerts.FindActiveAlertsByCase(ctx, caseId, natureCode)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, alert := range subscribers {
+			data, err := json.Marshal(map[string]any{
+				"alertId":    alert.Id,
+				"userId":     alert.UserId,
+				"caseId":     caseId,
+				"natureCode": natureCode,
+				"checkedAt":  day,
+			})
+
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			_, err = store.Webhooks.EnqueueWebhookEvent(ctx, &db.WebhookEvent{
+				Id:     fmt.Sprintf("%v:%v:%v:%v", db.WebhookCaseNotFound, key, day.Format(time.DateOnly), alert.UserId),
+				Type:   db.WebhookCaseNotFound,
+				UserId: alert.UserId,
+				Data:   data,
+			})
+
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs
+}
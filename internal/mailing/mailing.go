@@ -6,33 +6,29 @@ import (
 	"fmt"
 	"html/template"
 	"os"
+	textTemplate "text/template"
 
+	"github.com/vladwithcode/juzgados/internal"
 	"github.com/vladwithcode/juzgados/internal/db"
 	"gopkg.in/gomail.v2"
 )
 
+// The sender when MAIL_FROM is not set
 const SEND_AS = "no-reply@certx-mx.org"
 
 func SendVerificationMail(recipient string, otl *db.OTLink) error {
-	pw := os.Getenv("GOOGLE_MAIL_APP_PASS")
-	emailAddress := os.Getenv("GOOGLE_MAIL_ADDRESS")
 	siteHostname := os.Getenv("TSJ_SITE_HOSTNAME")
 
-	if pw == "" || emailAddress == "" {
-		fmt.Printf("[Mailing] Env is not set-up correctly. pw:%v email:%v", pw, emailAddress)
-		return errors.New("Env Missing")
-	}
+	msg, transport, err := newMessage(recipient, "Confirma tu registro")
 
-	msg := gomail.NewMessage()
-	msg.SetAddressHeader("From", SEND_AS, "TSJ Search")
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("Subject", "Confirma tu registro")
-	//msg.SetBody("text/html", "<a href=\"http://localhost:8080\">Verifica tu correo aquí</a>")
+	if err != nil {
+		return err
+	}
 
 	templ, err := template.ParseFiles("web/templates/emails/layout.html", "web/templates/emails/confirm-signup.html")
 
 	if err != nil {
-		return nil
+		return err
 	}
 
 	var b bytes.Buffer
@@ -53,8 +49,7 @@ func SendVerificationMail(recipient string, otl *db.OTLink) error {
 
 	msg.SetBody("text/html", b.String())
 
-	d := gomail.NewDialer("smtp.gmail.com", 587, emailAddress, pw)
-	if err := d.DialAndSend(msg); err != nil {
+	if err := transport.Send(msg); err != nil {
 		fmt.Printf("Send err: %v\n", err)
 		return err
 	}
@@ -62,28 +57,118 @@ func SendVerificationMail(recipient string, otl *db.OTLink) error {
 	return nil
 }
 
-func Test() error {
-	var pw = os.Getenv("GOOGLE_MAIL_APP_PASS")
-	var email_address = os.Getenv("GOOGLE_MAIL_ADDRESS")
+// SendNotificationMail sends a short notification with a link to
+// see the details in the site, when link is not empty
+func SendNotificationMail(recipient, subject, text, link string) error {
+	msg, transport, err := newMessage(recipient, subject)
+
+	if err != nil {
+		return err
+	}
+
+	templ, err := template.ParseFiles("web/templates/emails/layout.html", "web/templates/emails/notification.html")
+
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+
+	err = templ.Execute(&b, map[string]string{
+		"DocTitle": subject,
+		"Text":     text,
+		"Link":     link,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	msg.SetBody("text/plain", text+"\n\n"+link)
+	msg.AddAlternative("text/html", b.String())
+
+	if err := transport.Send(msg); err != nil {
+		fmt.Printf("Send err: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// SendDigestMail sends the digest of the new accords in the cases of a
+// user, as HTML and plain text, with the file at attachment when it's
+// not empty
+func SendDigestMail(recipient, name, subject string, digest *db.Digest, attachment string) error {
+	if digest == nil {
+		return errors.New("El resumen está vacío")
+	}
+
+	msg, transport, err := newMessage(recipient, subject)
 
-	if pw == "" || email_address == "" {
-		fmt.Printf("[Mailing] Env is not set-up correctly. pw:%v email:%v", pw, email_address)
-		return errors.New("Env Missing")
+	if err != nil {
+		return err
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", "no-reply@certx-mx.org")
-	m.SetHeader("To", "vladwithcode@gmail.com")
-	m.SetHeader("Subject", "Hello!")
-	m.SetBody("text/html", "<h1>Tsj Search</h1><p>Hello world!</p>")
+	funcs := map[string]any{
+		"FormatDate": internal.FormatDate,
+	}
+	data := map[string]any{
+		"DocTitle": subject,
+		"Name":     name,
+		"Digest":   digest,
+	}
 
-	d := gomail.NewDialer("smtp.gmail.com", 587, email_address, pw)
+	templ, err := template.New("layout.html").Funcs(funcs).ParseFiles("web/templates/emails/layout.html", "web/templates/emails/digest.html")
+
+	if err != nil {
+		return err
+	}
 
-	if err := d.DialAndSend(m); err != nil {
+	textTempl, err := textTemplate.New("digest.txt").Funcs(funcs).ParseFiles("web/templates/emails/digest.txt")
+
+	if err != nil {
+		return err
+	}
+
+	var html, text bytes.Buffer
+
+	if err := templ.Execute(&html, data); err != nil {
+		return err
+	}
+
+	if err := textTempl.Execute(&text, data); err != nil {
+		return err
+	}
+
+	msg.SetBody("text/plain", text.String())
+	msg.AddAlternative("text/html", html.String())
+
+	if attachment != "" {
+		if _, err := os.Stat(attachment); err != nil {
+			return err
+		}
+
+		msg.Attach(attachment, gomail.Rename("acuerdos.pdf"))
+	}
+
+	if err := transport.Send(msg); err != nil {
 		fmt.Printf("Send err: %v\n", err)
 		return err
 	}
 
-	fmt.Printf("Testing mail: %t\n", true)
 	return nil
 }
+
+// Test sends a short message to recipient to check the mail settings
+func Test(recipient string) error {
+	msg, transport, err := newMessage(recipient, "Correo de prueba")
+
+	if err != nil {
+		return err
+	}
+
+	msg.SetBody("text/plain", "Si recibiste este correo, el envío de TSJ Search funciona")
+	msg.AddAlternative("text/html", "<h1>TSJ Search</h1><p>Si recibiste este correo, el envío de TSJ Search funciona</p>")
+
+	return transport.Send(msg)
+}
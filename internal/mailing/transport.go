@@ -0,0 +1,413 @@
+package mailing
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// The transports the mail can be delivered with
+const (
+	// Sends through an SMTP server
+	TransportSMTP = "smtp"
+	// Writes every message as an .eml file to Config.Dir
+	TransportFile = "file"
+	// Prints every message to the log
+	TransportLog = "log"
+)
+
+// How the SMTP connection is secured
+const (
+	// Upgrades the connection with STARTTLS, failing when the server can't
+	TLSStartTLS = "starttls"
+	// Connects with TLS from the start, usually on port 465
+	TLSImplicit = "tls"
+	// Plain text, only for local servers like a mail sink
+	TLSNone = "none"
+)
+
+// Config tells how the mail is delivered and who it's sent as
+type Config struct {
+	Transport string
+	Host      string
+	Port      int
+	TLS       string
+	// No authentication when empty
+	Username string
+	Password string
+	From     string
+	FromName string
+	// Where TransportFile writes the messages
+	Dir string
+}
+
+// A Transport delivers the messages built by this package
+type Transport interface {
+	Send(msg *gomail.Message) error
+}
+
+// ConfigFromEnv reads the Config from MAIL_TRANSPORT, MAIL_HOST, MAIL_PORT,
+// MAIL_TLS, MAIL_USERNAME, MAIL_PASSWORD, MAIL_FROM, MAIL_FROM_NAME and
+// MAIL_DIR. Without MAIL_TRANSPORT the old GOOGLE_MAIL_ADDRESS and
+// GOOGLE_MAIL_APP_PASS send through Gmail, and without those the mail is
+// only logged, so a new setup never sends real mail by accident
+func ConfigFromEnv() (*Config, error) {
+	cfg := Config{
+		Transport: os.Getenv("MAIL_TRANSPORT"),
+		Host:      os.Getenv("MAIL_HOST"),
+		TLS:       os.Getenv("MAIL_TLS"),
+		Username:  os.Getenv("MAIL_USERNAME"),
+		Password:  os.Getenv("MAIL_PASSWORD"),
+		From:      os.Getenv("MAIL_FROM"),
+		FromName:  os.Getenv("MAIL_FROM_NAME"),
+		Dir:       os.Getenv("MAIL_DIR"),
+	}
+
+	if cfg.Transport == "" {
+		cfg.Transport = TransportLog
+
+		if address := os.Getenv("GOOGLE_MAIL_ADDRESS"); address != "" {
+			cfg.Transport = TransportSMTP
+			cfg.Host = "smtp.gmail.com"
+			cfg.Port = 587
+			cfg.Username = address
+			cfg.Password = os.Getenv("GOOGLE_MAIL_APP_PASS")
+		}
+	}
+
+	if port := os.Getenv("MAIL_PORT"); port != "" {
+		p, err := strconv.Atoi(port)
+
+		if err != nil {
+			return nil, fmt.Errorf("MAIL_PORT no es un número: %v", port)
+		}
+
+		cfg.Port = p
+	}
+
+	if cfg.TLS == "" {
+		cfg.TLS = TLSStartTLS
+	}
+
+	if cfg.Port == 0 {
+		cfg.Port = 587
+
+		if cfg.TLS == TLSImplicit {
+			cfg.Port = 465
+		}
+	}
+
+	if cfg.From == "" {
+		cfg.From = SEND_AS
+	}
+
+	if cfg.FromName == "" {
+		cfg.FromName = "TSJ Search"
+	}
+
+	if cfg.Dir == "" {
+		cfg.Dir = "tmp/mail"
+	}
+
+	return &cfg, nil
+}
+
+// NewTransport returns the Transport set in cfg
+func NewTransport(cfg *Config) (Transport, error) {
+	switch cfg.Transport {
+	case TransportSMTP:
+		if cfg.Host == "" {
+			return nil, errors.New("Falta el servidor SMTP (MAIL_HOST)")
+		}
+
+		if cfg.TLS != TLSStartTLS && cfg.TLS != TLSImplicit && cfg.TLS != TLSNone {
+			return nil, fmt.Errorf("Modo TLS desconocido: %v", cfg.TLS)
+		}
+
+		return &SMTPTransport{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			TLS:      cfg.TLS,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}, nil
+	case TransportFile:
+		return &FileTransport{Dir: cfg.Dir}, nil
+	case TransportLog:
+		return LogTransport{}, nil
+	default:
+		return nil, fmt.Errorf("Transporte de correo desconocido: %v", cfg.Transport)
+	}
+}
+
+var (
+	mu        sync.Mutex
+	transport Transport
+	sender    *Config
+)
+
+// Configure makes the package send the mail with cfg
+func Configure(cfg *Config) error {
+	t, err := NewTransport(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	Use(t, cfg)
+	return nil
+}
+
+// Use makes the package send the mail through t as the sender of cfg
+func Use(t Transport, cfg *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	transport = t
+	sender = cfg
+}
+
+// current returns the transport in use, configured from the environment
+// the first time unless one was set with Use
+func current() (Transport, *Config, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if transport != nil {
+		return transport, sender, nil
+	}
+
+	cfg, err := ConfigFromEnv()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := NewTransport(cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport = t
+	sender = cfg
+
+	return transport, sender, nil
+}
+
+// newMessage returns a message to recipient from the configured sender
+func newMessage(recipient, subject string) (*gomail.Message, Transport, error) {
+	t, cfg, err := current()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetAddressHeader("From", cfg.From, cfg.FromName)
+	msg.SetHeader("To", recipient)
+	msg.SetHeader("Subject", subject)
+
+	return msg, t, nil
+}
+
+// SMTPTransport sends the messages through an SMTP server
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	TLS      string
+	Username string
+	Password string
+	// How long it may take to connect, 30 seconds when zero
+	Timeout time.Duration
+}
+
+func (t *SMTPTransport) Send(msg *gomail.Message) error {
+	from, to, err := envelope(msg)
+
+	if err != nil {
+		return err
+	}
+
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	addr := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+	tlsConfig := &tls.Config{ServerName: t.Host}
+
+	var conn net.Conn
+
+	if t.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, t.Host)
+
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if t.TLS == TLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%v no admite STARTTLS", t.Host)
+		}
+
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	// smtp.PlainAuth refuses to send the password without TLS unless the
+	// server is local
+	if t.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// envelope returns the addresses in the From and To headers of msg
+func envelope(msg *gomail.Message) (string, []string, error) {
+	from := msg.GetHeader("From")
+
+	if len(from) == 0 {
+		return "", nil, errors.New("El correo no tiene remitente")
+	}
+
+	sender, err := parseAddress(from[0])
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	to := []string{}
+
+	for _, header := range []string{"To", "Cc", "Bcc"} {
+		for _, value := range msg.GetHeader(header) {
+			addr, err := parseAddress(value)
+
+			if err != nil {
+				return "", nil, err
+			}
+
+			to = append(to, addr)
+		}
+	}
+
+	if len(to) == 0 {
+		return "", nil, errors.New("El correo no tiene destinatarios")
+	}
+
+	return sender, to, nil
+}
+
+// parseAddress returns the bare address of "Name <address>"
+func parseAddress(value string) (string, error) {
+	if i := strings.LastIndex(value, "<"); i >= 0 {
+		value = strings.TrimSuffix(value[i+1:], ">")
+	}
+
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "@") {
+		return "", fmt.Errorf("Dirección de correo inválida: %v", value)
+	}
+
+	return value, nil
+}
+
+// FileTransport writes every message as an .eml file to Dir, where they
+// can be opened with any mail client
+type FileTransport struct {
+	Dir string
+}
+
+func (t *FileTransport) Send(msg *gomail.Message) error {
+	if err := os.MkdirAll(t.Dir, 0755); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%v-%v.eml", time.Now().Format("20060102-150405.000"), hex.EncodeToString(suffix))
+	file, err := os.Create(filepath.Join(t.Dir, name))
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := msg.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// LogTransport prints every message to the standard logger
+type LogTransport struct{}
+
+func (LogTransport) Send(msg *gomail.Message) error {
+	var b strings.Builder
+
+	if _, err := msg.WriteTo(&b); err != nil {
+		return err
+	}
+
+	log.Printf("[Mailing] To: %v Subject: %v\n%v\n", msg.GetHeader("To"), msg.GetHeader("Subject"), b.String())
+	return nil
+}
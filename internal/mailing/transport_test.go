@@ -0,0 +1,62 @@
+package mailing
+
+import (
+	"sync"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+// recorder keeps the messages instead of sending them
+type recorder struct {
+	mu       sync.Mutex
+	messages []*gomail.Message
+}
+
+func (r *recorder) Send(msg *gomail.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func TestUse(t *testing.T) {
+	rec := &recorder{}
+	Use(rec, &Config{From: "avisos@example.com", FromName: "TSJ Search"})
+
+	if err := Test("ana@example.com"); err != nil {
+		t.Fatalf("Test: %v", err)
+	}
+
+	if len(rec.messages) != 1 {
+		t.Fatalf("got %v messages, want 1", len(rec.messages))
+	}
+
+	from, to, err := envelope(rec.messages[0])
+
+	if err != nil || from != "avisos@example.com" || len(to) != 1 || to[0] != "ana@example.com" {
+		t.Errorf("got envelope %v %v, %v", from, to, err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"log", Config{Transport: TransportLog}, true},
+		{"file", Config{Transport: TransportFile, Dir: t.TempDir()}, true},
+		{"smtp", Config{Transport: TransportSMTP, Host: "localhost", TLS: TLSNone}, true},
+		{"smtp without host", Config{Transport: TransportSMTP, TLS: TLSStartTLS}, false},
+		{"smtp with unknown TLS", Config{Transport: TransportSMTP, Host: "localhost", TLS: "ssl"}, false},
+		{"unknown", Config{Transport: "pigeon"}, false},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewTransport(&tt.cfg); (err == nil) != tt.valid {
+			t.Errorf("%v: got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+)
+
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"Á", "A", "É", "E", "Í", "I", "Ó", "O", "Ú", "U", "Ü", "U", "Ñ", "N",
+	"à", "a", "è", "e", "ì", "i", "ò", "o", "ù", "u",
+	"À", "A", "È", "E", "Ì", "I", "Ò", "O", "Ù", "U",
+)
+
+// FoldAccents replaces the accented letters used in spanish
+// with their unaccented counterpart
+func FoldAccents(s string) string {
+	return accentReplacer.Replace(s)
+}
+
+// NormalizeText lowercases s, removes accents and replaces every run of
+// punctuation and white-space with a single space, so that texts taken
+// from the TSJ bulletins can be compared regardless of the formatting
+func NormalizeText(s string) string {
+	s = strings.ToLower(FoldAccents(s))
+
+	var b strings.Builder
+	b.Grow(len(s))
+	lastSpace := true
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			lastSpace = false
+			continue
+		}
+
+		if !lastSpace {
+			b.WriteRune(' ')
+			lastSpace = true
+		}
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// CompactText works like NormalizeText but drops every non alphanumeric
+// character, useful for identifiers like RFCs which are often written
+// with dashes or spaces in between
+func CompactText(s string) string {
+	return strings.ReplaceAll(NormalizeText(s), " ", "")
+}
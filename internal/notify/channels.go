@@ -0,0 +1,339 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/mailing"
+	"github.com/vladwithcode/juzgados/internal/sms"
+	"github.com/vladwithcode/juzgados/internal/telegram"
+	"github.com/vladwithcode/juzgados/internal/webpush"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+// Email sends the messages to the email of the user
+type Email struct{}
+
+func (Email) Channel() string { return db.ChannelEmail }
+
+func (Email) Send(ctx context.Context, msg *Message, _ string) error {
+	if msg.User.Email == "" {
+		return ErrNoAddress
+	}
+
+	switch msg.Event {
+	case db.NotificationVerification:
+		return mailing.SendVerificationMail(msg.User.Email, msg.OTLink)
+	case db.NotificationDigest:
+		return mailing.SendDigestMail(msg.User.Email, msg.User.Name, msg.Subject, msg.Digest, msg.Attachment)
+	}
+
+	return mailing.SendNotificationMail(msg.User.Email, msg.Subject, msg.Text, msg.Link)
+}
+
+// WhatsApp sends the messages to the phone of the user with the
+// templates approved for every event, and records the messages sent so
+// the webhook can track their status
+type WhatsApp struct {
+	Client   *whatsapp.Client
+	Messages db.WhatsAppRepository
+}
+
+// NewWhatsApp returns a WhatsApp channel configured from the env
+func NewWhatsApp(store *db.Store) *WhatsApp {
+	return &WhatsApp{
+		Client:   whatsapp.NewClient(whatsapp.ConfigFromEnv()),
+		Messages: store.WhatsApp,
+	}
+}
+
+func (*WhatsApp) Channel() string { return db.ChannelWhatsApp }
+
+func (w *WhatsApp) Send(ctx context.Context, msg *Message, _ string) error {
+	if !msg.User.Phone.Valid || msg.User.Phone.String == "" {
+		return ErrNoAddress
+	}
+
+	var messageId, template string
+
+	// A phone WhatsApp won't take is like having none, retrying won't help
+	phone, err := whatsapp.NormalizePhone(msg.User.Phone.String, w.Client.Config().DefaultCountryCode)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoAddress, err)
+	}
+
+	switch msg.Event {
+	case db.NotificationReport:
+		template = whatsapp.TemplateReport
+		messageId, err = w.Client.SendReport(ctx, phone, *msg.Report, msg.Link)
+	case db.NotificationWatchMatch:
+		if len(msg.Matches) == 0 {
+			return nil
+		}
+
+		template = whatsapp.TemplateWatchMatch
+		messageId, err = w.Client.SendWatchMatches(ctx, phone, msg.User.Name, msg.Matches)
+	default:
+		return ErrUnsupported
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// It was sent already, failing here would send it again
+	err = w.Messages.CreateWhatsAppMessage(ctx, &db.WhatsAppMessage{
+		Id:       messageId,
+		UserId:   sql.NullString{String: msg.User.Id, Valid: msg.User.Id != ""},
+		Phone:    phone,
+		Template: template,
+	})
+
+	if err != nil {
+		fmt.Printf("[WhatsApp err]: Failed to record message %v: %v\n", messageId, err)
+	}
+
+	return nil
+}
+
+// Telegram sends the subject, text and link of the messages to the chat
+// the user linked
+type Telegram struct {
+	Client *telegram.Client
+	Chats  db.TelegramRepository
+}
+
+// NewTelegram returns a Telegram channel configured from the env
+func NewTelegram(store *db.Store) *Telegram {
+	return &Telegram{
+		Client: telegram.NewClient(telegram.ConfigFromEnv()),
+		Chats:  store.Telegram,
+	}
+}
+
+func (*Telegram) Channel() string { return db.ChannelTelegram }
+
+func (t *Telegram) Send(ctx context.Context, msg *Message, _ string) error {
+	// The verification link must only reach the owner of the email, and
+	// the digests are made for the email
+	if msg.Event == db.NotificationVerification || msg.Event == db.NotificationDigest {
+		return ErrUnsupported
+	}
+
+	chat, err := t.Chats.FindTelegramChat(ctx, msg.User.Id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoAddress
+	} else if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("<b>%v</b>", html.EscapeString(msg.Subject))
+
+	if msg.Text != "" {
+		text += "\n\n" + html.EscapeString(msg.Text)
+	}
+
+	if msg.Link != "" {
+		text += "\n\n" + html.EscapeString(msg.Link)
+	}
+
+	_, err = t.Client.SendMessage(ctx, chat.ChatId, text)
+
+	return err
+}
+
+// Push shows the messages in every browser the user subscribed, and
+// forgets the browsers that unsubscribed
+type Push struct {
+	Client *webpush.Client
+	Subs   db.PushRepository
+}
+
+// NewPush returns a Push channel with the VAPID keys in the env
+func NewPush(store *db.Store) *Push {
+	return &Push{
+		Client: webpush.NewClient(webpush.ConfigFromEnv()),
+		Subs:   store.Push,
+	}
+}
+
+// How long the push services keep a message while the browser is offline
+const pushTTL = 24 * time.Hour
+
+// The longest text shown in a push notification, the browsers cut them
+// short anyway
+const pushTextLength = 240
+
+// The JSON the service worker gets, see web/static/sw.js
+type PushPayload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Url   string `json:"url"`
+	// Notifications with the same tag replace each other
+	Tag string `json:"tag"`
+}
+
+func (*Push) Channel() string { return db.ChannelPush }
+
+func (p *Push) Send(ctx context.Context, msg *Message, _ string) error {
+	// The verification link must only reach the owner of the email, and
+	// the digests are made for the email
+	if msg.Event == db.NotificationVerification || msg.Event == db.NotificationDigest {
+		return ErrUnsupported
+	}
+
+	subs, err := p.Subs.FindPushSubscriptions(ctx, msg.User.Id)
+
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		return ErrNoAddress
+	}
+
+	tag := msg.Event
+
+	if msg.Change != nil {
+		tag += ":" + msg.Change.Id
+	}
+
+	text := []rune(msg.Text)
+
+	if len(text) > pushTextLength {
+		text = append(text[:pushTextLength-1], '…')
+	}
+
+	payload, err := json.Marshal(PushPayload{
+		Title: msg.Subject,
+		Body:  string(text),
+		Url:   msg.Link,
+		Tag:   tag,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	errs := []error{}
+
+	for _, s := range subs {
+		sub := webpush.Subscription{Endpoint: s.Endpoint}
+		sub.Keys.P256dh = s.P256dh
+		sub.Keys.Auth = s.Auth
+
+		err := p.Client.Send(ctx, &sub, payload, pushTTL, webpush.UrgencyHigh)
+
+		if errors.Is(err, webpush.ErrGone) {
+			if err := p.Subs.DeletePushSubscription(ctx, s.UserId, s.Endpoint); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				fmt.Printf("[Push err]: Failed to delete subscription %v: %v\n", s.Id, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		sent++
+	}
+
+	// Retrying would show it again in the browsers that got it
+	if sent > 0 {
+		return nil
+	}
+
+	if len(errs) == 0 {
+		// Every browser unsubscribed
+		return ErrNoAddress
+	}
+
+	return errors.Join(errs...)
+}
+
+// SMS sends the subject and link of the messages to the verified phone of
+// the user, shortened to fit in the segments allowed
+type SMS struct {
+	Provider sms.Provider
+	Config   *sms.Config
+	OptOuts  db.SMSRepository
+	// Why Provider couldn't be made, returned by every Send
+	err error
+}
+
+// NewSMS returns an SMS channel with the provider set in the env
+func NewSMS(store *db.Store) *SMS {
+	cfg := sms.ConfigFromEnv()
+	provider, err := sms.NewProvider(cfg)
+
+	if err != nil {
+		fmt.Printf("[SMS err]: %v\n", err)
+	}
+
+	return &SMS{Provider: provider, Config: cfg, OptOuts: store.SMS, err: err}
+}
+
+// Added to the messages when it takes no segment more
+const smsFooter = "Responde BAJA para no recibir mas"
+
+func (*SMS) Channel() string { return db.ChannelSMS }
+
+func (s *SMS) Send(ctx context.Context, msg *Message, _ string) error {
+	// The verification link must only reach the owner of the email, and
+	// the digests are made for the email
+	if msg.Event == db.NotificationVerification || msg.Event == db.NotificationDigest {
+		return ErrUnsupported
+	}
+
+	// Only the phones the user proved are theirs
+	if !msg.User.Phone.Valid || msg.User.Phone.String == "" || !msg.User.PhoneVerified {
+		return ErrNoAddress
+	}
+
+	phone, err := whatsapp.NormalizePhone(msg.User.Phone.String, s.Config.DefaultCountryCode)
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoAddress, err)
+	}
+
+	optedOut, err := s.OptOuts.IsSMSOptedOut(ctx, phone)
+
+	if err != nil {
+		return err
+	}
+
+	if optedOut {
+		return fmt.Errorf("%w: el teléfono pidió la baja de los SMS", ErrNoAddress)
+	}
+
+	if s.err != nil {
+		return s.err
+	}
+
+	text := sms.Compose(msg.Subject, msg.Link, smsFooter, s.Config.MaxSegments)
+	_, err = s.Provider.Send(ctx, phone, text)
+
+	// The phone opted out with the provider, keep it so it's not tried again
+	if errors.Is(err, sms.ErrOptedOut) {
+		if err := s.OptOuts.OptOutSMS(ctx, phone); err != nil {
+			fmt.Printf("[SMS err]: Failed to record the opt out of %v: %v\n", phone, err)
+		}
+
+		return fmt.Errorf("%w: %v", ErrNoAddress, err)
+	}
+
+	return err
+}
@@ -0,0 +1,268 @@
+// Package notify delivers the notifications of the app through the
+// channels every user chose for each kind of event, recording the
+// outcome of every delivery
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+var (
+	// The channel can't deliver the event of the message
+	ErrUnsupported = errors.New("El canal no admite este tipo de notificación")
+	// The user has no address for the channel, e.g. no phone for WhatsApp
+	ErrNoAddress = errors.New("El usuario no tiene a dónde enviar por este canal")
+	// The user turned off every channel for the event
+	ErrNoChannels = errors.New("El usuario no tiene canales activos para esta notificación")
+)
+
+// A Notifier delivers messages through a single channel
+type Notifier interface {
+	// The channel of the notifier, one of the db.Channel constants
+	Channel() string
+	// Send delivers msg to its user. target is the one set in the
+	// preference of the user, empty for the channels that don't need it
+	Send(ctx context.Context, msg *Message, target string) error
+}
+
+// A Message is a notification for a single user. Every channel can send
+// Subject, Text and Link, the rest is the data of the event for the
+// channels with their own formats (e.g. the WhatsApp templates). Messages
+// are queued in the outbox as JSON, without their user
+type Message struct {
+	// One of the db.Notification events
+	Event   string   `json:"event"`
+	User    *db.User `json:"-"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	// Where the details can be seen in the site
+	Link string `json:"link"`
+
+	AlertId string             `json:"alertId,omitempty"`
+	Change  *db.AccordChange   `json:"change,omitempty"`
+	Report  *db.AutoReportUser `json:"report,omitempty"`
+	Matches []*db.WatchMatch   `json:"matches,omitempty"`
+	Digest  *db.Digest         `json:"digest,omitempty"`
+	// The path of a file sent along with the message by the channels
+	// that can, e.g. the PDF report of a digest
+	Attachment string `json:"attachment,omitempty"`
+	// Verification links are sent right away, never queued
+	OTLink *db.OTLink `json:"-"`
+	// The channel tried when none of the ones of the user could reach
+	// them, e.g. the email of a user without phone for WhatsApp
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// A Dispatcher sends messages through the channels chosen by their users
+type Dispatcher struct {
+	store     *db.Store
+	notifiers map[string]Notifier
+}
+
+func NewDispatcher(store *db.Store, notifiers ...Notifier) *Dispatcher {
+	d := Dispatcher{store: store, notifiers: map[string]Notifier{}}
+
+	for _, n := range notifiers {
+		d.Register(n)
+	}
+
+	return &d
+}
+
+// Default returns a Dispatcher with every channel of the app
+func Default(store *db.Store) *Dispatcher {
+	return NewDispatcher(store, Email{}, NewWhatsApp(store), NewTelegram(store), NewPush(store), NewSMS(store))
+}
+
+// Register adds n to the channels, replacing the one with the same name
+func (d *Dispatcher) Register(n Notifier) {
+	d.notifiers[n.Channel()] = n
+}
+
+// A route is a channel chosen for an event with its target
+type route struct {
+	channel string
+	target  string
+}
+
+// routes returns the channels the user wants the event through. The
+// preferences saved replace the defaults channel by channel
+func (d *Dispatcher) routes(ctx context.Context, userId, event string) ([]route, error) {
+	enabled := map[string]bool{}
+	targets := map[string]string{}
+
+	for _, channel := range db.DefaultChannels[event] {
+		enabled[channel] = true
+	}
+
+	if event != db.NotificationVerification {
+		prefs, err := d.store.Notifications.FindNotificationPreferences(ctx, userId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pref := range prefs {
+			if pref.Event != event {
+				continue
+			}
+
+			enabled[pref.Channel] = pref.Enabled
+			targets[pref.Channel] = pref.Target.String
+		}
+	}
+
+	routes := []route{}
+
+	// Keep the order of the registry so deliveries are predictable
+	for _, c := range db.NotificationChannels {
+		if enabled[c.Channel] {
+			routes = append(routes, route{c.Channel, targets[c.Channel]})
+		}
+	}
+
+	return routes, nil
+}
+
+// Channels returns the channels msg.Event would be sent through to the
+// user, so callers can skip the work of preparing a message nobody gets
+func (d *Dispatcher) Channels(ctx context.Context, userId, event string) ([]string, error) {
+	routes, err := d.routes(ctx, userId, event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	channels := []string{}
+
+	for _, r := range routes {
+		channels = append(channels, r.channel)
+	}
+
+	return channels, nil
+}
+
+// Dispatch sends msg through every channel its user chose for the event
+// and records the outcome of each one. It only returns an error when no
+// channel could deliver the message, ErrNoChannels when there were none
+func (d *Dispatcher) Dispatch(ctx context.Context, msg *Message) ([]*db.NotificationDelivery, error) {
+	return d.DispatchSkipping(ctx, msg, nil)
+}
+
+// DispatchSkipping works like Dispatch but leaves out the channels in
+// skip, the ones that already got msg in a previous attempt
+func (d *Dispatcher) DispatchSkipping(ctx context.Context, msg *Message, skip []string) ([]*db.NotificationDelivery, error) {
+	routes, err := d.routes(ctx, msg.User.Id, msg.Event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	skipped := map[string]bool{}
+	for _, channel := range skip {
+		skipped[channel] = true
+	}
+
+	pending := []route{}
+	for _, r := range routes {
+		if !skipped[r.channel] {
+			pending = append(pending, r)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil, ErrNoChannels
+	}
+
+	var (
+		deliveries = []*db.NotificationDelivery{}
+		errs       = []error{}
+		sent       = false
+	)
+
+	for _, r := range pending {
+		delivery, err := d.deliver(ctx, msg, r)
+		deliveries = append(deliveries, delivery)
+
+		if delivery.Status == db.DeliverySent {
+			sent = true
+		} else if delivery.Status == db.DeliveryFailed {
+			errs = append(errs, fmt.Errorf("%v: %w", r.channel, err))
+		}
+	}
+
+	// Every channel was skipped, try the fallback unless it was one of them
+	if !sent && len(errs) == 0 && msg.Fallback != "" && !skipped[msg.Fallback] && !hasRoute(routes, msg.Fallback) {
+		log.Printf("No channel of %v could deliver %v, sending by %v\n", msg.User.Id, msg.Event, msg.Fallback)
+
+		delivery, err := d.deliver(ctx, msg, route{channel: msg.Fallback})
+		deliveries = append(deliveries, delivery)
+
+		if delivery.Status == db.DeliverySent {
+			sent = true
+		} else if delivery.Status == db.DeliveryFailed {
+			errs = append(errs, fmt.Errorf("%v: %w", msg.Fallback, err))
+		}
+	}
+
+	if sent {
+		return deliveries, nil
+	}
+
+	if len(errs) == 0 {
+		return deliveries, ErrNoChannels
+	}
+
+	return deliveries, errors.Join(errs...)
+}
+
+// deliver sends msg through the channel of r and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, msg *Message, r route) (*db.NotificationDelivery, error) {
+	delivery := db.NotificationDelivery{
+		UserId:  msg.User.Id,
+		Event:   msg.Event,
+		Channel: r.channel,
+		Status:  db.DeliverySent,
+	}
+
+	var err error
+	n, ok := d.notifiers[r.channel]
+
+	if ok {
+		err = n.Send(ctx, msg, r.target)
+	} else {
+		err = ErrUnsupported
+	}
+
+	if errors.Is(err, ErrUnsupported) || errors.Is(err, ErrNoAddress) {
+		delivery.Status = db.DeliverySkipped
+	} else if err != nil {
+		delivery.Status = db.DeliveryFailed
+	}
+
+	if err != nil {
+		delivery.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	if err := d.store.Notifications.CreateNotificationDelivery(ctx, &delivery); err != nil {
+		log.Printf("Record delivery to %v err: %v\n", msg.User.Id, err)
+	}
+
+	return &delivery, err
+}
+
+func hasRoute(routes []route, channel string) bool {
+	for _, r := range routes {
+		if r.channel == channel {
+			return true
+		}
+	}
+
+	return false
+}
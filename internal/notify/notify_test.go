@@ -0,0 +1,309 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+	"github.com/vladwithcode/juzgados/internal/sms"
+	"github.com/vladwithcode/juzgados/internal/webpush"
+)
+
+// recorder is a channel that keeps the messages sent through it, or
+// fails with err when set
+type recorder struct {
+	channel string
+	err     error
+	sent    []*Message
+}
+
+func (r *recorder) Channel() string { return r.channel }
+
+func (r *recorder) Send(ctx context.Context, msg *Message, target string) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+func TestDispatchPreferences(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.New()
+	email := recorder{channel: db.ChannelEmail}
+	whatsapp := recorder{channel: db.ChannelWhatsApp}
+	d := NewDispatcher(store, &email, &whatsapp)
+
+	user := db.User{Id: "u1", Email: "ana@example.com"}
+	msg := Message{Event: db.NotificationReport, User: &user, Subject: "Reporte de acuerdos nuevos"}
+
+	// The reports go by WhatsApp unless the user chose otherwise
+	if _, err := d.Dispatch(ctx, &msg); err != nil || len(email.sent) != 0 || len(whatsapp.sent) != 1 {
+		t.Fatalf("defaults: got %v, %v emails and %v WhatsApp, want 1 WhatsApp", err, len(email.sent), len(whatsapp.sent))
+	}
+
+	prefs := []*db.NotificationPreference{
+		{UserId: "u1", Event: db.NotificationReport, Channel: db.ChannelEmail, Enabled: true},
+		{UserId: "u1", Event: db.NotificationReport, Channel: db.ChannelWhatsApp, Enabled: false},
+	}
+
+	for _, pref := range prefs {
+		if err := store.Notifications.SaveNotificationPreference(ctx, pref); err != nil {
+			t.Fatalf("SaveNotificationPreference: %v", err)
+		}
+	}
+
+	deliveries, err := d.Dispatch(ctx, &msg)
+
+	if err != nil || len(deliveries) != 1 || deliveries[0].Channel != db.ChannelEmail || deliveries[0].Status != db.DeliverySent {
+		t.Fatalf("got %v deliveries, %v, want the one sent by email", len(deliveries), err)
+	}
+
+	if len(email.sent) != 1 || len(whatsapp.sent) != 1 {
+		t.Errorf("got %v emails and %v WhatsApp, want 1 and 1", len(email.sent), len(whatsapp.sent))
+	}
+
+	if recorded, _ := store.Notifications.FindNotificationDeliveries(ctx, "u1", 10); len(recorded) != 2 {
+		t.Errorf("recorded %v deliveries, want 2", len(recorded))
+	}
+}
+
+func TestDispatchFailures(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status string
+		want   error
+	}{
+		{"no address", ErrNoAddress, db.DeliverySkipped, ErrNoChannels},
+		{"unsupported", ErrUnsupported, db.DeliverySkipped, ErrNoChannels},
+		{"failed", errors.New("timeout"), db.DeliveryFailed, nil},
+	}
+
+	for _, tt := range tests {
+		d := NewDispatcher(memdb.New(), &recorder{channel: db.ChannelWhatsApp, err: tt.err})
+		msg := Message{Event: db.NotificationReport, User: &db.User{Id: "u1"}}
+
+		deliveries, err := d.Dispatch(context.Background(), &msg)
+
+		if len(deliveries) != 1 || deliveries[0].Status != tt.status || !deliveries[0].Error.Valid {
+			t.Errorf("%v: got %v deliveries, want the WhatsApp %v", tt.name, len(deliveries), tt.status)
+		}
+
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, err, tt.want)
+		}
+
+		if tt.want == nil && !errors.Is(err, tt.err) {
+			t.Errorf("%v: got %v, want the error of the channel", tt.name, err)
+		}
+	}
+}
+
+func TestDispatchWithoutChannels(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.New()
+	d := NewDispatcher(store, &recorder{channel: db.ChannelWhatsApp})
+
+	pref := db.NotificationPreference{UserId: "u1", Event: db.NotificationReport, Channel: db.ChannelWhatsApp, Enabled: false}
+
+	if err := store.Notifications.SaveNotificationPreference(ctx, &pref); err != nil {
+		t.Fatalf("SaveNotificationPreference: %v", err)
+	}
+
+	msg := Message{Event: db.NotificationReport, User: &db.User{Id: "u1"}}
+
+	if _, err := d.Dispatch(ctx, &msg); !errors.Is(err, ErrNoChannels) {
+		t.Errorf("got %v, want ErrNoChannels", err)
+	}
+}
+
+const phone = "+526181234567"
+
+// newDispatcher returns a Dispatcher with the push and SMS channels backed
+// by fakes, for a user who wants the accords through both of them
+func newDispatcher(t *testing.T) (*Dispatcher, *db.Store, *webpush.FakeService, *sms.Fake, *db.User) {
+	t.Helper()
+	ctx := context.Background()
+	store := memdb.New()
+
+	service := webpush.NewFakeService()
+	srv := httptest.NewTLSServer(service)
+	t.Cleanup(srv.Close)
+
+	publicKey, privateKey, err := webpush.GenerateKeys()
+
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+
+	client := webpush.NewClient(&webpush.Config{PublicKey: publicKey, PrivateKey: privateKey, Subject: "mailto:soporte@example.com"})
+	client.HTTP = srv.Client()
+
+	for i := 0; i < 2; i++ {
+		sub, err := service.Subscribe(srv.URL)
+
+		if err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+
+		err = store.Push.SavePushSubscription(ctx, &db.PushSubscription{
+			UserId:   "u1",
+			Endpoint: sub.Endpoint,
+			P256dh:   sub.Keys.P256dh,
+			Auth:     sub.Keys.Auth,
+		})
+
+		if err != nil {
+			t.Fatalf("SavePushSubscription: %v", err)
+		}
+
+		// The second browser revoked the permission
+		if i == 1 {
+			service.Unsubscribe(sub)
+		}
+	}
+
+	provider := sms.NewFake()
+	d := NewDispatcher(store,
+		&Push{Client: client, Subs: store.Push},
+		&SMS{Provider: provider, Config: &sms.Config{DefaultCountryCode: "52", MaxSegments: 2}, OptOuts: store.SMS},
+	)
+
+	prefs := []*db.NotificationPreference{
+		{UserId: "u1", Event: db.NotificationAccord, Channel: db.ChannelEmail, Enabled: false},
+		{UserId: "u1", Event: db.NotificationAccord, Channel: db.ChannelPush, Enabled: true},
+		{UserId: "u1", Event: db.NotificationAccord, Channel: db.ChannelSMS, Enabled: true},
+	}
+
+	for _, pref := range prefs {
+		if err := store.Notifications.SaveNotificationPreference(ctx, pref); err != nil {
+			t.Fatalf("SaveNotificationPreference: %v", err)
+		}
+	}
+
+	user := db.User{Id: "u1", Phone: sql.NullString{String: phone, Valid: true}, PhoneVerified: true}
+
+	return d, store, service, provider, &user
+}
+
+func accordMessage(user *db.User) *Message {
+	return &Message{
+		Event:   db.NotificationAccord,
+		User:    user,
+		Subject: "Nuevo acuerdo en 123/2024",
+		Text:    "Se admite la demanda",
+		Link:    "https://example.com/alertas/a1",
+		Change:  &db.AccordChange{Id: "c1"},
+	}
+}
+
+func TestDispatchPushAndSMS(t *testing.T) {
+	ctx := context.Background()
+	d, store, service, provider, user := newDispatcher(t)
+
+	deliveries, err := d.Dispatch(ctx, accordMessage(user))
+
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if len(deliveries) != 2 || deliveries[0].Channel != db.ChannelPush || deliveries[1].Channel != db.ChannelSMS {
+		t.Fatalf("got %v deliveries, want push and sms", len(deliveries))
+	}
+
+	for _, delivery := range deliveries {
+		if delivery.Status != db.DeliverySent {
+			t.Errorf("%v: got %v, want %v", delivery.Channel, delivery.Status, db.DeliverySent)
+		}
+	}
+
+	messages := service.Messages()
+
+	if len(messages) != 1 {
+		t.Fatalf("got %v push messages, want 1", len(messages))
+	}
+
+	payload := PushPayload{}
+
+	if err := json.Unmarshal(messages[0].Payload, &payload); err != nil || payload.Tag != db.NotificationAccord+":c1" {
+		t.Errorf("got payload %+v, %v", payload, err)
+	}
+
+	// The browser that unsubscribed is forgotten
+	if subs, _ := store.Push.FindPushSubscriptions(ctx, "u1"); len(subs) != 1 {
+		t.Errorf("got %v subscriptions, want 1", len(subs))
+	}
+
+	if sent := provider.Messages(); len(sent) != 1 || sent[0].To != phone {
+		t.Errorf("got %v SMS, want 1 to %v", len(sent), phone)
+	}
+
+	if recorded, _ := store.Notifications.FindNotificationDeliveries(ctx, "u1", 10); len(recorded) != 2 {
+		t.Errorf("recorded %v deliveries, want 2", len(recorded))
+	}
+}
+
+func TestDispatchSMSOptedOut(t *testing.T) {
+	ctx := context.Background()
+	d, store, _, provider, user := newDispatcher(t)
+	provider.OptOut(phone)
+
+	deliveries, err := d.DispatchSkipping(ctx, accordMessage(user), []string{db.ChannelPush})
+
+	// Skipped, there is nothing to retry
+	if !errors.Is(err, ErrNoChannels) || len(deliveries) != 1 || deliveries[0].Status != db.DeliverySkipped {
+		t.Fatalf("got %v deliveries, %v, want the SMS skipped", len(deliveries), err)
+	}
+
+	if out, _ := store.SMS.IsSMSOptedOut(ctx, phone); !out {
+		t.Errorf("the opt out of the provider wasn't recorded")
+	}
+}
+
+func TestDispatchSkippingEveryChannel(t *testing.T) {
+	d, _, _, _, user := newDispatcher(t)
+
+	_, err := d.DispatchSkipping(context.Background(), accordMessage(user), []string{db.ChannelPush, db.ChannelSMS})
+
+	if !errors.Is(err, ErrNoChannels) {
+		t.Errorf("got %v, want ErrNoChannels", err)
+	}
+}
+
+func TestDispatchFallback(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.New()
+	email := recorder{channel: db.ChannelEmail}
+	d := NewDispatcher(store, &email, &WhatsApp{})
+
+	// WhatsApp is the default channel of the matches and the user has no phone
+	user := db.User{Id: "u1", Email: "ana@example.com"}
+	msg := Message{Event: db.NotificationWatchMatch, User: &user, Subject: "Coincidencias en los boletines"}
+
+	if _, err := d.Dispatch(ctx, &msg); !errors.Is(err, ErrNoChannels) || len(email.sent) != 0 {
+		t.Fatalf("without fallback: got %v and %v emails, want ErrNoChannels and none", err, len(email.sent))
+	}
+
+	msg.Fallback = db.ChannelEmail
+	deliveries, err := d.Dispatch(ctx, &msg)
+
+	if err != nil || len(email.sent) != 1 {
+		t.Fatalf("got %v and %v emails, want the message sent by email", err, len(email.sent))
+	}
+
+	if len(deliveries) != 2 || deliveries[0].Status != db.DeliverySkipped || deliveries[1].Status != db.DeliverySent {
+		t.Errorf("got %v deliveries, want WhatsApp skipped and the email sent", len(deliveries))
+	}
+
+	// The email already got it in a previous attempt
+	if _, err := d.DispatchSkipping(ctx, &msg, []string{db.ChannelEmail}); !errors.Is(err, ErrNoChannels) || len(email.sent) != 1 {
+		t.Errorf("skipping the fallback: got %v and %v emails, want ErrNoChannels and 1", err, len(email.sent))
+	}
+}
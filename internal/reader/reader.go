@@ -2,28 +2,51 @@ package reader
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
 )
 
 const fileUrl = "http://tsjdgo.gob.mx/Recursos/images/flash/ListasAcuerdos/%v/%v.pdf"
 
-func GetFile(date, caseType string) (pdfData []byte, err error) {
+// Returned when the court didn't publish a bulletin on the date
+var ErrNotFound = errors.New("No se encontró documento para la fecha solicitada")
+
+// How long a single bulletin may take to download and parse,
+// configurable with TSJ_FETCH_TIMEOUT
+func FetchTimeout() time.Duration {
+	return internal.EnvDuration("TSJ_FETCH_TIMEOUT", 2*time.Minute)
+}
+
+func GetFile(ctx context.Context, date, caseType string) (pdfData []byte, err error) {
 	fetchUrl := fmt.Sprintf(fileUrl, date, caseType)
 
-	response, err := http.Get(fetchUrl)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fetchUrl, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.DefaultClient.Do(request)
 
 	if err != nil {
 		return nil, err
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+
 	if response.StatusCode < 200 || response.StatusCode >= 400 {
-		return nil, errors.New("No se encontró documento para la fecha solicitada")
+		return nil, fmt.Errorf("El servidor respondió %v", response.Status)
 	}
 
 	pdfData, err = io.ReadAll(response.Body)
@@ -35,12 +58,12 @@ func GetFile(date, caseType string) (pdfData []byte, err error) {
 	return pdfData, nil
 }
 
-func PipeLargeFile(fileData []byte) (*[]byte, error) {
+func PipeLargeFile(ctx context.Context, fileData []byte) (*[]byte, error) {
 	pr, pw, _ := os.Pipe()
 	outPr, outPw, _ := os.Pipe()
 	outputBuf := new(bytes.Buffer)
 
-	pttCmd := exec.Command("pdftotext", "-layout", "-", "-")
+	pttCmd := exec.CommandContext(ctx, "pdftotext", "-layout", "-", "-")
 
 	pttCmd.Stdin = pr
 	pttCmd.Stdout = outPw
@@ -72,14 +95,14 @@ func PipeLargeFile(fileData []byte) (*[]byte, error) {
 }
 
 // Uses poppler-utils' pdftotext to parse the pdf
-func ParseFile(fileData []byte) (*[]byte, error) {
+func ParseFile(ctx context.Context, fileData []byte) (*[]byte, error) {
 	if len(fileData) > 65_000 {
-		return PipeLargeFile(fileData)
+		return PipeLargeFile(ctx, fileData)
 	}
 
 	// $ pdftotext [options] [PDF-File [text-file]]
 	// If text-file is '-'. "-" means pipe from/to stdin/stdout
-	pttCmd := exec.Command("pdftotext", "-layout", "-", "-")
+	pttCmd := exec.CommandContext(ctx, "pdftotext", "-layout", "-", "-")
 	pipe, err := pttCmd.StdinPipe()
 
 	if err != nil {
@@ -107,11 +130,14 @@ func ParseFile(fileData []byte) (*[]byte, error) {
 	return &output, nil
 }
 
-func Reader(date, caseType string) (result *[]byte, err error) {
-	pdfData, err := GetFile(date, caseType)
+func Reader(ctx context.Context, date, caseType string) (result *[]byte, err error) {
+	ctx, cancel := context.WithTimeout(ctx, FetchTimeout())
+	defer cancel()
+
+	pdfData, err := GetFile(ctx, date, caseType)
 	if err != nil {
 		return nil, err
 	}
 
-	return ParseFile(pdfData)
+	return ParseFile(ctx, pdfData)
 }
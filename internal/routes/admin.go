@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+)
+
+func (h *Handler) RegisterAdminRoutes(router *httprouter.Router) {
+	router.GET("/admin/tareas", h.withAdmin(h.RenderJobsPage))
+	router.GET("/api/admin/jobs", h.withAdmin(h.GetJobs))
+	router.POST("/api/admin/jobs/:name/run", h.withAdmin(h.RunJob))
+	router.GET("/admin/notificaciones", h.withAdmin(h.RenderOutboxPage))
+	router.POST("/api/admin/outbox/:id/requeue", h.withAdmin(h.RequeueNotification))
+}
+
+// withAdmin works like auth.WithAuthMiddleware but only lets the
+// administrators (users with golden_boy set) through
+func (h *Handler) withAdmin(next auth.AuthedHandler) httprouter.Handle {
+	return auth.WithAuthMiddleware(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+		user, err := h.store.Users.GetUserById(r.Context(), auth.Id)
+
+		if err != nil {
+			fmt.Printf("[Find user err]: %v\n", err)
+			respondWithError(w, 403, "No tienes permiso para realizar esta acción")
+			return
+		}
+
+		if !user.GoldenBoy {
+			respondWithError(w, 403, "No tienes permiso para realizar esta acción")
+			return
+		}
+
+		next(w, r, ps, auth)
+	})
+}
+
+func (h *Handler) GetJobs(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	if h.scheduler == nil {
+		respondWithJSON(w, 200, map[string]any{"jobs": []jobs.JobStatus{}})
+		return
+	}
+
+	respondWithJSON(w, 200, map[string]any{"jobs": h.scheduler.Statuses()})
+}
+
+func (h *Handler) RunJob(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	if h.scheduler == nil {
+		respondWithError(w, 404, jobs.ErrUnknownJob.Error())
+		return
+	}
+
+	err := h.scheduler.RunNow(ps.ByName("name"))
+
+	if errors.Is(err, jobs.ErrUnknownJob) {
+		respondWithError(w, 404, err.Error())
+		return
+	}
+
+	if errors.Is(err, jobs.ErrJobRunning) {
+		respondWithError(w, 409, err.Error())
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("[Run job err]: %v\n", err)
+		respondWithError(w, 500, "No se pudo iniciar la tarea")
+		return
+	}
+
+	respondWithJSON(w, 202, map[string]any{"job": h.scheduler.Status(ps.ByName("name"))})
+}
+
+// RenderJobsPage shows the state of the jobs, their recent runs and a
+// daily summary. The runs can be filtered by job and by a case they
+// didn't find, to tell why a user didn't get an alert
+func (h *Handler) RenderJobsPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	query := r.URL.Query()
+	filter := db.JobRunFilter{
+		Job:   query.Get("job"),
+		Limit: 100,
+	}
+
+	caseId := strings.TrimSpace(query.Get("caseId"))
+	natureCode := query.Get("natureCode")
+
+	if caseId != "" && natureCode != "" {
+		filter.NotFoundKey = fmt.Sprintf("%v+%v", strings.TrimLeft(caseId, "0"), natureCode)
+	}
+
+	runs, err := h.store.JobRuns.FindJobRuns(r.Context(), filter)
+
+	if err != nil {
+		fmt.Printf("[Find job runs err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	days, err := h.store.JobRuns.FindJobRunDays(r.Context(), time.Now().AddDate(0, 0, -14))
+
+	if err != nil {
+		fmt.Printf("[Find job run days err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	statuses := []jobs.JobStatus{}
+	if h.scheduler != nil {
+		statuses = h.scheduler.Statuses()
+	}
+
+	templ, err := template.New("layout.html").Funcs(template.FuncMap{
+		"FormatTime": internal.FormatTimestampToString,
+		"GetNature": func(code string) string {
+			return internal.CodesMap[code]
+		},
+	}).ParseFiles("web/templates/layout.html", "web/templates/admin-jobs.html")
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	err = templ.Execute(w, map[string]any{
+		"User":       auth,
+		"Jobs":       statuses,
+		"Runs":       runs,
+		"Days":       days,
+		"Filter":     filter,
+		"CaseId":     caseId,
+		"NatureCode": natureCode,
+		"Courts":     internal.CodesMap,
+	})
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+	}
+}
+
+// RenderOutboxPage shows how many notifications there are in every state
+// of the outbox and the latest ones with the status chosen, the dead ones
+// by default so they can be requeued
+func (h *Handler) RenderOutboxPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	filter := db.OutboxFilter{
+		Status: r.URL.Query().Get("status"),
+		Limit:  100,
+	}
+
+	if filter.Status == "" {
+		filter.Status = db.OutboxDead
+	}
+
+	items, err := h.store.Outbox.FindNotifications(r.Context(), filter)
+
+	if err != nil {
+		fmt.Printf("[Find notifications err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	counts, err := h.store.Outbox.CountNotifications(r.Context())
+
+	if err != nil {
+		fmt.Printf("[Count notifications err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	templ, err := template.New("layout.html").Funcs(template.FuncMap{
+		"FormatTime": internal.FormatTimestampToString,
+	}).ParseFiles("web/templates/layout.html", "web/templates/admin-outbox.html")
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	err = templ.Execute(w, map[string]any{
+		"User":     auth,
+		"Items":    items,
+		"Counts":   counts,
+		"Status":   filter.Status,
+		"Statuses": []string{db.OutboxDead, db.OutboxPending, db.OutboxSent},
+	})
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+	}
+}
+
+func (h *Handler) RequeueNotification(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	err := h.store.Outbox.RequeueNotification(r.Context(), ps.ByName("id"))
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondWithError(w, 404, "No se encontró una notificación fallida con ese id")
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("[Requeue notification err]: %v\n", err)
+		respondWithError(w, 500, "No se pudo reintentar la notificación")
+		return
+	}
+
+	respondWithJSON(w, 200, map[string]any{"id": ps.ByName("id"), "status": db.OutboxPending})
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"html/template"
@@ -19,22 +20,28 @@ import (
 	"github.com/vladwithcode/juzgados/internal/tsj"
 )
 
-func RegisterAlertRoutes(router *httprouter.Router) {
-	router.GET("/alerta/:id", auth.WithAuthMiddleware(RenderSingleAlertPage))
+func (h *Handler) RegisterAlertRoutes(router *httprouter.Router) {
+	router.GET("/alerta/:id", auth.WithAuthMiddleware(h.RenderSingleAlertPage))
 
-	router.GET("/api/alerts/all", TestAllAlerts)
-	router.POST("/api/alerts", auth.WithAuthMiddleware(CreateAlert))
+	router.GET("/api/alerts/all", h.TestAllAlerts)
+	router.POST("/api/alerts", auth.WithAuthMiddleware(h.CreateAlert))
 
-	router.GET("/api/alerts/report/:userId", GetReportForUser)
-	router.POST("/api/alerts/report/:userId", CreatePDFForReport)
-	router.PUT("/api/alerts", auth.WithAuthMiddleware(UpdateAlertsForUser))
-	router.DELETE("/api/alert/:id", auth.WithAuthMiddleware(DeleteAlertById))
+	router.GET("/api/alerts/report/:userId", h.GetReportForUser)
+	router.POST("/api/alerts/report/:userId", h.CreatePDFForReport)
+	router.PUT("/api/alerts", auth.WithAuthMiddleware(h.UpdateAlertsForUser))
+	router.DELETE("/api/alert/:id", auth.WithAuthMiddleware(h.DeleteAlertById))
+	// Update the alias, notes and preferences of the user for the alert
+	router.PUT("/api/alert/:id", auth.WithAuthMiddleware(h.UpdateAlertSubscription))
 
 	// Update the accord data for the alert with the provided id
-	router.PUT("/api/alert-refresh/:id", auth.WithAuthMiddleware(RefreshAlertById))
+	router.PUT("/api/alert-refresh/:id", auth.WithAuthMiddleware(h.RefreshAlertById))
+
+	// Links between alerts of the same matter in different courts
+	router.POST("/api/alert-link/:id/confirm", auth.WithAuthMiddleware(h.ConfirmAlertLink))
+	router.DELETE("/api/alert-link/:id", auth.WithAuthMiddleware(h.DismissAlertLink))
 }
 
-func CreateAlert(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) CreateAlert(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	err := r.ParseForm()
 
 	if err != nil {
@@ -49,25 +56,10 @@ func CreateAlert(w http.ResponseWriter, r *http.Request, _ httprouter.Params, au
 		userId     string = auth.Id
 	)
 
-	doc, _ := tsj.GetCaseData(caseId, natureCode, nil, tsj.DEFAULT_DAYS_BACK)
-	alert := db.Alert{
-		UserId:        userId,
-		CaseId:        db.TrimField(caseId),
-		NatureCode:    db.TrimField(natureCode),
-		LastCheckedAt: time.Now(),
-		LastUpdatedAt: time.Now(),
-		Active:        true,
-	}
+	alert := alerts.NewAlertForCase(r.Context(), userId, caseId, natureCode)
+	alert.Frequency = db.ParseFrequency(r.Form.Get("frequency"))
 
-	if doc != nil {
-		alert.LastAccord.String = doc.Accord
-		alert.LastAccord.Valid = true
-		alert.LastAccordDate.Time = doc.AccordDate
-		alert.LastAccordDate.Valid = true
-		alert.Nature = doc.Nature
-	}
-
-	_, err = db.CreateAlertWithData(&alert)
+	_, err = h.store.Alerts.CreateAlertWithData(r.Context(), alert)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -101,9 +93,9 @@ func CreateAlert(w http.ResponseWriter, r *http.Request, _ httprouter.Params, au
 	}
 }
 
-func RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+func (h *Handler) RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
 	id := ps.ByName("id")
-	user, err := db.GetUserById(auth.Id)
+	user, err := h.store.Users.GetUserById(r.Context(), auth.Id)
 
 	if err != nil {
 		fmt.Printf("[Find user err]: %v\n", err)
@@ -113,7 +105,7 @@ func RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
-	alert, err := db.FindAlertById(id)
+	alert, err := h.store.Alerts.FindAlertById(r.Context(), id)
 
 	if err != nil {
 		fmt.Printf("[Find alert err]: %v\n", err)
@@ -142,6 +134,7 @@ func RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter
 		"GetNature": func(code string) string {
 			return internal.CodesMap[code]
 		},
+		"dict": dict,
 	}).ParseFiles("web/templates/layout.html", "web/templates/alerts/single-alert.html")
 
 	if err != nil {
@@ -152,9 +145,29 @@ func RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter
 		return
 	}
 
+	links, err := h.store.Links.FindLinkedAlerts(r.Context(), alert.Id)
+
+	if err != nil {
+		fmt.Printf("[Find links err]: %v\n", err)
+	}
+
+	firstInstance, secondInstance := db.AppealInstances(alert, links)
+
+	history, err := h.store.Alerts.FindCaseAccords(r.Context(), alert.CaseRef, 50)
+
+	if err != nil {
+		fmt.Printf("[Find history err]: %v\n", err)
+	}
+
 	data := map[string]any{
-		"User":  user,
-		"Alert": alert,
+		"User":           user,
+		"Alert":          alert,
+		"Links":          links,
+		"Courts":         internal.CodesMap,
+		"FirstInstance":  firstInstance,
+		"SecondInstance": secondInstance,
+		"History":        history,
+		"Frequencies":    db.Frequencies,
 	}
 
 	err = templ.Execute(w, data)
@@ -166,11 +179,117 @@ func RenderSingleAlertPage(w http.ResponseWriter, r *http.Request, ps httprouter
 		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
 		return
 	}
+
+	// The page was rendered with the new accords, they're seen from now on
+	err = h.store.Alerts.MarkAlertViewed(r.Context(), alert.Id, user.Id)
+
+	if err != nil {
+		fmt.Printf("[Mark viewed err]: %v\n", err)
+	}
+}
+
+func (h *Handler) UpdateAlertSubscription(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	err := r.ParseForm()
+
+	if err != nil {
+		fmt.Printf("[Parse Form Err]: %v\n", err)
+		respondWithError(w, 400, "La información proporcionada no es válida")
+		return
+	}
+
+	var alias, notes sql.NullString
+
+	alias.String = strings.TrimSpace(r.Form.Get("alias"))
+	alias.Valid = alias.String != ""
+	notes.String = strings.TrimSpace(r.Form.Get("notes"))
+	notes.Valid = notes.String != ""
+	autoReport := r.Form.Get("autoReport") != ""
+	frequency := db.ParseFrequency(r.Form.Get("frequency"))
+
+	err = h.store.Alerts.UpdateUserSubscription(r.Context(), ps.ByName("id"), auth.Id, alias, notes, autoReport, frequency)
+
+	if err != nil {
+		fmt.Printf("[Update subscription err]: %v\n", err)
+		respondWithError(w, 404, "No se encontró la alerta solicitada")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(200)
+}
+
+// ConfirmAlertLink creates (or reuses) the alert for the receiving court
+// of a suggested link and marks the link as confirmed. The court and case
+// number can be corrected by the user before confirming
+func (h *Handler) ConfirmAlertLink(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	err := r.ParseForm()
+
+	if err != nil {
+		fmt.Printf("[Parse Form Err]: %v\n", err)
+		respondWithError(w, 400, "La información proporcionada no es válida")
+		return
+	}
+
+	link, err := h.store.Links.FindAlertLinkById(r.Context(), ps.ByName("id"), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Find link err]: %v\n", err)
+		respondWithError(w, 404, "No se encontró la relación solicitada")
+		return
+	}
+
+	caseId := db.TrimField(r.Form.Get("caseId"))
+	natureCode := r.Form.Get("natureCode")
+
+	if caseId == "" {
+		caseId = link.ToCaseId
+	}
+
+	if natureCode == "" {
+		natureCode = link.ToNatureCode
+	}
+
+	if _, ok := internal.CodesMap[natureCode]; !ok || caseId == "" {
+		respondWithError(w, 400, "Indica el expediente y el juzgado al que se remitió el caso")
+		return
+	}
+
+	target, err := alerts.FindOrCreateAlert(r.Context(), h.store.Alerts, auth.Id, caseId, natureCode)
+
+	if err != nil {
+		fmt.Printf("[Create alert err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error al crear la alerta")
+		return
+	}
+
+	err = h.store.Links.ConfirmAlertLink(r.Context(), link.Id, auth.Id, target.Id)
+
+	if err != nil {
+		fmt.Printf("[Confirm link err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error al confirmar la relación")
+		return
+	}
+
+	w.Header().Add("HX-Location", fmt.Sprintf("/alerta/%v", target.Id))
+	w.WriteHeader(204)
+}
+
+func (h *Handler) DismissAlertLink(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	err := h.store.Links.DismissAlertLink(r.Context(), ps.ByName("id"), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Dismiss link err]: %v\n", err)
+		respondWithError(w, 404, "No se encontró la relación solicitada")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(200)
 }
 
-func RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+func (h *Handler) RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
 	id := ps.ByName("id")
-	alert, err := db.FindAlertById(id)
+	alert, err := h.store.Alerts.FindAlertById(r.Context(), id)
 
 	// For htmx request to
 	w.Header().Add("HX-Reswap", "beforeend")
@@ -193,7 +312,7 @@ func RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		return
 	}
 
-	doc, err := tsj.GetCaseData(alert.CaseId, alert.NatureCode, nil, tsj.DEFAULT_DAYS_BACK)
+	doc, err := tsj.GetCaseData(r.Context(), alert.CaseId, alert.NatureCode, nil, tsj.DEFAULT_DAYS_BACK)
 
 	if err != nil {
 		var NotFoundErr *tsj.NotFoundError
@@ -225,7 +344,7 @@ func RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	alert.LastAccordDate.Time = doc.AccordDate
 	alert.LastAccordDate.Valid = doc.AccordDate != (time.Time{})
 
-	err = db.UpdateAlertAccord(auth.Id, alert.CaseId, alert.NatureCode, alert)
+	err = h.store.Alerts.UpdateAlertAccord(r.Context(), auth.Id, alert.CaseId, alert.NatureCode, alert)
 
 	if err != nil {
 		fmt.Printf("UpdateAlert err: %v\n", err)
@@ -249,6 +368,7 @@ func RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		"GetNature": func(code string) string {
 			return internal.CodesMap[code]
 		},
+		"dict":       dict,
 		"FormatDate": internal.FormatDate,
 	}).ParseFiles("web/templates/alerts/single-alert.html")
 
@@ -273,8 +393,8 @@ func RefreshAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	}
 }
 
-func UpdateAlertsForUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
-	alerts, err := db.FindAlertsByUser(auth.Id, true)
+func (h *Handler) UpdateAlertsForUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	alerts, err := h.store.Alerts.FindAlertsByUser(r.Context(), auth.Id, true)
 	if err != nil {
 		fmt.Printf("[Find err]: %v\n", err)
 		w.WriteHeader(500)
@@ -291,7 +411,7 @@ func UpdateAlertsForUser(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		alertMap[cK] = alert
 	}
 
-	docs, err := tsj.GetCasesData(caseKeys, tsj.DEFAULT_DAYS_BACK, time.Now())
+	docs, err := tsj.GetCasesData(r.Context(), caseKeys, tsj.DEFAULT_DAYS_BACK, time.Now())
 
 	if err != nil {
 		fmt.Printf("[GetCasesData err]: %v\n", err)
@@ -311,7 +431,7 @@ func UpdateAlertsForUser(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 		alert.Nature = doc.Nature
 	}
 
-	err = db.UpdateAlertAccords(alerts)
+	err = h.store.Alerts.UpdateAlertAccords(r.Context(), alerts)
 
 	if err != nil {
 		fmt.Printf("err: %v\n", err)
@@ -344,9 +464,9 @@ func UpdateAlertsForUser(w http.ResponseWriter, r *http.Request, _ httprouter.Pa
 	}
 }
 
-func DeleteAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+func (h *Handler) DeleteAlertById(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
 	id := ps.ByName("id")
-	err := db.DeleteUserAlertById(id, auth.Id)
+	err := h.store.Alerts.DeleteUserAlertById(r.Context(), id, auth.Id)
 
 	if err != nil {
 		fmt.Printf("[Find alert err]: %v\n", err)
@@ -399,8 +519,8 @@ type subscriberMeta struct {
 // subscriberMap is a caseKey (ie caseId-natureCode) to []subscriberMeta map
 type subscriberMap map[string][]subscriberMeta
 
-func TestAllAlerts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	userAlerts, err := db.FindAutoReportAlertsWithUserData()
+func (h *Handler) TestAllAlerts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userAlerts, err := h.store.Alerts.FindAutoReportAlertsWithUserData(r.Context(), false)
 
 	if err != nil {
 		fmt.Printf("err: %v\n", err)
@@ -432,7 +552,7 @@ func TestAllAlerts(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
 		caseKeys = append(caseKeys, k)
 	}
 
-	resCases, err := tsj.GetCasesData(caseKeys, 30, time.Now())
+	resCases, err := tsj.GetCasesData(r.Context(), caseKeys, 30, time.Now())
 
 	if err != nil {
 		fmt.Printf("GetCases err: %v\n", err)
@@ -461,7 +581,7 @@ func TestAllAlerts(w http.ResponseWriter, r *http.Request, _ httprouter.Params)
 		wg.Add(1)
 		go func(user *db.AutoReportUser) {
 			defer wg.Done()
-			docPath, err := alerts.GenReportPdfWithData(*user)
+			docPath, err := alerts.GenReportPdfWithData(r.Context(), *user)
 
 			if err != nil {
 				fmt.Printf("GenReport err: %v\n", err)
@@ -2,7 +2,6 @@ package routes
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -17,19 +16,19 @@ import (
 	"github.com/vladwithcode/juzgados/internal/tsj"
 )
 
-func RegisterCaseRoutes(router *httprouter.Router) {
-	router.GET("/api/case", searchCase)
-	router.GET("/api/cases", searchCases)
-	router.GET("/api/cases/accord", auth.WithAuthMiddleware(SearchAccord))
+func (h *Handler) RegisterCaseRoutes(router *httprouter.Router) {
+	router.GET("/api/case", h.searchCase)
+	router.GET("/api/cases", h.searchCases)
+	router.GET("/api/cases/accord", auth.WithAuthMiddleware(h.SearchAccord))
 }
 
-func searchCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) searchCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	caseID := r.URL.Query().Get("id")
 	caseType := r.URL.Query().Get("type")
 
 	d := time.Now()
 
-	doc, err := tsj.GetCaseData(caseID, caseType, &d, tsj.DEFAULT_DAYS_BACK)
+	doc, err := tsj.GetCaseData(r.Context(), caseID, caseType, &d, tsj.DEFAULT_DAYS_BACK)
 
 	if err != nil {
 		fmt.Println(err)
@@ -50,7 +49,7 @@ func searchCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	rowTempl.ExecuteTemplate(w, "case-card", doc)
 }
 
-func searchCases(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) searchCases(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	cases := r.URL.Query()["cases"]
 	goBackStr := r.URL.Query()["goBack"]
 
@@ -63,7 +62,7 @@ func searchCases(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		goBack = tsj.DEFAULT_DAYS_BACK
 	}
 
-	result, err := tsj.GetCasesData(cases, uint(goBack), time.Now())
+	result, err := tsj.GetCasesData(r.Context(), cases, uint(goBack), time.Now())
 
 	if len(result.NotFoundKeys) == len(cases) {
 		respondWithError(w, 500, "No se encontró ningun documento solicitado")
@@ -84,7 +83,7 @@ func searchCases(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	templ.Execute(w, result.Docs)
 }
 
-func SearchAccord(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) SearchAccord(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	err := r.ParseForm()
 
 	if err != nil {
@@ -107,7 +106,7 @@ func SearchAccord(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 	natureCode := params[1]
 
 	// Start search in TSJ
-	doc, err := tsj.GetCaseData(caseId, natureCode, nil, 31)
+	doc, err := tsj.GetCaseData(r.Context(), caseId, natureCode, nil, 31)
 
 	if err != nil {
 		fmt.Printf("GetCase Err: %v\n", err)
@@ -144,21 +143,7 @@ func SearchAccord(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 		"GetNature": func(nc string) string {
 			return internal.CodesMap[nc]
 		},
-		// Refer to https://stackoverflow.com/questions/18276173/calling-a-template-with-several-pipeline-parameters
-		"dict": func(values ...interface{}) (map[string]interface{}, error) {
-			if len(values)%2 != 0 {
-				return nil, errors.New("invalid dict call")
-			}
-			dict := make(map[string]interface{}, len(values)/2)
-			for i := 0; i < len(values); i += 2 {
-				key, ok := values[i].(string)
-				if !ok {
-					return nil, errors.New("dict keys must be strings")
-				}
-				dict[key] = values[i+1]
-			}
-			return dict, nil
-		},
+		"dict": dict,
 	}).ParseFiles("web/templates/dashboard.html")
 
 	if err != nil {
@@ -176,7 +161,7 @@ func SearchAccord(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 	}
 
 	// Save alert data to DB
-	err = db.UpdateAlertAccord(auth.Id, caseId, natureCode, &alert)
+	err = h.store.Alerts.UpdateAlertAccord(r.Context(), auth.Id, caseId, natureCode, &alert)
 
 	if err != nil {
 		fmt.Printf("Update Alert Err: %v\n", err)
@@ -3,21 +3,29 @@ package routes
 import (
 	"encoding/json"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/auth"
 	"github.com/vladwithcode/juzgados/internal/db"
 )
 
-func RegisterDocRoutes(router *httprouter.Router) {
-	router.GET("/api/docs", getDocs)
-	router.GET("/api/docs/by-case/:caseID", getDocByCase)
-	router.POST("/api/doc", createDoc)
-	router.GET("/api/doc/:ID", getDocByID)
+func (h *Handler) RegisterDocRoutes(router *httprouter.Router) {
+	router.GET("/buscar", auth.WithAuthMiddleware(h.RenderSearchPage))
+
+	router.GET("/api/docs", auth.WithAuthMiddleware(h.getDocs))
+	router.GET("/api/docs/search", auth.WithAuthMiddleware(h.SearchDocs))
+	router.GET("/api/docs/by-case/:caseID", h.getDocByCase)
+	router.POST("/api/doc", h.createDoc)
+	router.GET("/api/doc/:ID", h.getDocByID)
 }
 
-func getDocByCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) getDocByCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	rawCaseId := ps.ByName("caseID")
 
 	caseID, err := url.PathUnescape(rawCaseId)
@@ -27,7 +35,7 @@ func getDocByCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 		return
 	}
 
-	doc, err := db.GetDocByCase(caseID)
+	doc, err := h.store.Docs.GetDocByCase(r.Context(), caseID)
 
 	if err != nil {
 		respondWithError(w, 500, "No se encontró entrada para el caso solicitado")
@@ -37,9 +45,9 @@ func getDocByCase(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	respondWithJSON(w, 200, doc)
 }
 
-func getDocByID(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) getDocByID(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	id := ps.ByName("ID")
-	doc, err := db.GetDocByID(id)
+	doc, err := h.store.Docs.GetDocByID(r.Context(), id)
 
 	if err != nil {
 		fmt.Println(err)
@@ -50,8 +58,9 @@ func getDocByID(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	respondWithJSON(w, 200, doc)
 }
 
-func getDocs(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	docs, err := db.GetDocs()
+// getDocs responds with the docs of the cases the user has alerts on
+func (h *Handler) getDocs(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	docs, err := h.store.Docs.GetDocs(r.Context(), auth.Id)
 
 	if err != nil {
 		fmt.Println(err)
@@ -62,7 +71,7 @@ func getDocs(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	respondWithJSON(w, 200, docs)
 }
 
-func createDoc(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) createDoc(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	data := db.Doc{}
 	decoder := json.NewDecoder(r.Body)
 
@@ -74,7 +83,7 @@ func createDoc(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		return
 	}
 
-	err = db.CreateDoc(data.ID, data.Case, data.Nature, data.NatureCode, data.Accord, data.AccordDate)
+	err = h.store.Docs.CreateDoc(r.Context(), data.ID, data.Case, data.Nature, data.NatureCode, data.Accord, data.AccordDate)
 
 	if err != nil {
 		fmt.Println(err)
@@ -86,3 +95,136 @@ func createDoc(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	w.WriteHeader(200)
 	w.Write([]byte("<p>Creación exitosa</p>"))
 }
+
+func (h *Handler) RenderSearchPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	templ, err := parseSearchTemplates("layout.html", "web/templates/layout.html", "web/templates/search.html")
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	err = templ.Execute(w, map[string]any{
+		"User":   auth,
+		"Courts": internal.CodesMap,
+	})
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+	}
+}
+
+// SearchDocs responds with the search-results fragment for htmx
+// requests and with the raw DocSearchResult as JSON otherwise
+func (h *Handler) SearchDocs(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	params, err := parseDocSearchParams(r.URL.Query())
+
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	result, err := h.store.Docs.SearchDocs(r.Context(), *params)
+
+	if err != nil {
+		fmt.Printf("[Search err]: %v\n", err)
+		respondWithError(w, 500, "No se pudo realizar la búsqueda")
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		respondWithJSON(w, 200, result)
+		return
+	}
+
+	templ, err := parseSearchTemplates("search.html", "web/templates/search.html")
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	query := r.URL.Query()
+	err = templ.ExecuteTemplate(w, "search-results", map[string]any{
+		"Result": result,
+		"Query":  query,
+	})
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+	}
+}
+
+func parseDocSearchParams(query url.Values) (*db.DocSearchParams, error) {
+	params := db.DocSearchParams{
+		Query:  query.Get("q"),
+		Nature: query.Get("nature"),
+		CaseId: query.Get("caseId"),
+		Sort:   query.Get("sort"),
+	}
+
+	for _, nc := range query["natureCode"] {
+		if _, ok := internal.CodesMap[nc]; ok {
+			params.NatureCodes = append(params.NatureCodes, nc)
+		}
+	}
+
+	var err error
+
+	if from := query.Get("from"); from != "" {
+		params.FromDate, err = time.Parse("2006-01-02", from)
+
+		if err != nil {
+			return nil, fmt.Errorf("La fecha inicial es inválida")
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		params.ToDate, err = time.Parse("2006-01-02", to)
+
+		if err != nil {
+			return nil, fmt.Errorf("La fecha final es inválida")
+		}
+	}
+
+	if page := query.Get("page"); page != "" {
+		params.Page, err = strconv.Atoi(page)
+
+		if err != nil {
+			return nil, fmt.Errorf("La página es inválida")
+		}
+	}
+
+	if pageSize := query.Get("pageSize"); pageSize != "" {
+		params.PageSize, err = strconv.Atoi(pageSize)
+
+		if err != nil {
+			return nil, fmt.Errorf("El tamaño de página es inválido")
+		}
+	}
+
+	return &params, nil
+}
+
+func parseSearchTemplates(name string, files ...string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"FormatDate": internal.FormatDate,
+		"GetNature": func(code string) string {
+			return internal.CodesMap[code]
+		},
+		// Returns the query string for the requested page keeping the current filters
+		"PageQuery": func(query url.Values, page int) string {
+			q := url.Values{}
+			for k, v := range query {
+				q[k] = v
+			}
+			q.Set("page", strconv.Itoa(page))
+			return q.Encode()
+		},
+		"Add": func(a, b int) int {
+			return a + b
+		},
+	}).ParseFiles(files...)
+}
@@ -0,0 +1,257 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+func (h *Handler) RegisterNotificationRoutes(router *httprouter.Router) {
+	router.GET("/notificaciones", auth.WithAuthMiddleware(h.RenderNotificationsPage))
+	router.PUT("/api/notifications", auth.WithAuthMiddleware(h.UpdateNotificationPreferences))
+	router.PUT("/api/notifications/digest", auth.WithAuthMiddleware(h.UpdateDigestSettings))
+}
+
+// The channels of an event as shown in the preferences form
+type notificationOption struct {
+	Event    string
+	Label    string
+	Channels []channelOption
+}
+
+type channelOption struct {
+	Channel string
+	Label   string
+	Enabled bool
+}
+
+func (h *Handler) RenderNotificationsPage(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	options := []notificationOption{}
+
+	for _, e := range db.NotificationEvents {
+		channels, err := h.notifier.Channels(r.Context(), auth.Id, e.Event)
+
+		if err != nil {
+			fmt.Printf("[Find channels err]: %v\n", err)
+			respondWithError(w, 500, "Ocurrio un error con el servidor")
+			return
+		}
+
+		enabled := internal.Set{}
+		for _, c := range channels {
+			enabled.Add(c)
+		}
+
+		option := notificationOption{Event: e.Event, Label: e.Label}
+
+		for _, c := range db.NotificationChannels {
+			option.Channels = append(option.Channels, channelOption{
+				Channel: c.Channel,
+				Label:   c.Label,
+				Enabled: enabled.Contains(c.Channel),
+			})
+		}
+
+		options = append(options, option)
+	}
+
+	digest, err := h.store.Digests.FindDigestSettings(r.Context(), auth.Id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		digest = &db.DigestSettings{Frequency: db.FrequencyDaily, Hour: 8, Weekday: int(time.Monday)}
+	} else if err != nil {
+		fmt.Printf("[Find digest settings err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrio un error con el servidor")
+		return
+	}
+
+	user, err := h.store.Users.GetUserById(r.Context(), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Find user err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrio un error con el servidor")
+		return
+	}
+
+	telegramChat, err := h.store.Telegram.FindTelegramChat(r.Context(), auth.Id)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("[Find telegram chat err]: %v\n", err)
+	}
+
+	smsOptedOut := false
+	// Only a phone can be shown, not the id of a messaging service
+	smsFrom := ""
+
+	if strings.HasPrefix(h.sms.Config.From, "+") {
+		smsFrom = h.sms.Config.From
+	}
+
+	if user.PhoneVerified {
+		phone, err := whatsapp.NormalizePhone(user.Phone.String, h.sms.Config.DefaultCountryCode)
+
+		if err == nil {
+			smsOptedOut, err = h.store.SMS.IsSMSOptedOut(r.Context(), phone)
+		}
+
+		if err != nil {
+			fmt.Printf("[Find sms opt out err]: %v\n", err)
+		}
+	}
+
+	pushSubs, err := h.store.Push.FindPushSubscriptions(r.Context(), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Find push subscriptions err]: %v\n", err)
+	}
+
+	deliveries, err := h.store.Notifications.FindNotificationDeliveries(r.Context(), auth.Id, 20)
+
+	if err != nil {
+		fmt.Printf("[Find deliveries err]: %v\n", err)
+	}
+
+	hours := make([]int, 24)
+	for i := range hours {
+		hours[i] = i
+	}
+
+	templ, err := template.New("layout.html").Funcs(template.FuncMap{
+		"FormatTime": internal.FormatTimestampToString,
+	}).ParseFiles("web/templates/layout.html", "web/templates/notifications.html")
+
+	if err != nil {
+		fmt.Printf("Parse err: %v\n", err)
+		respondWithError(w, 500, "Ocurrio un error inesperado")
+		return
+	}
+
+	data := map[string]any{
+		"User":        auth,
+		"Options":     options,
+		"Deliveries":  deliveries,
+		"Digest":      digest,
+		"DigestFreqs": db.DigestFrequencies,
+		"Weekdays":    internal.WEEKDAYS,
+		"Hours":       hours,
+		"Phone":       user.Phone.String,
+		// Only the verified phones can use the WhatsApp bot
+		"PhoneVerified": user.PhoneVerified,
+		// nil when no chat is linked
+		"TelegramChat": telegramChat,
+		// The browser section is hidden without VAPID keys
+		"PushConfigured":    h.push.Configured(),
+		"PushSubscriptions": pushSubs,
+		"SMSOptedOut":       smsOptedOut,
+		"SMSFrom":           smsFrom,
+	}
+
+	err = templ.Execute(w, data)
+
+	if err != nil {
+		fmt.Printf("Execute err: %v\n", err)
+	}
+}
+
+// UpdateNotificationPreferences saves a preference for every event and
+// channel of the form, the checkboxes are named <event>:<channel>
+func (h *Handler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	err := r.ParseForm()
+
+	if err != nil {
+		fmt.Printf("[Parse Form Err]: %v\n", err)
+		respondWithError(w, 400, "La información proporcionada no es válida")
+		return
+	}
+
+	for _, e := range db.NotificationEvents {
+		for _, c := range db.NotificationChannels {
+			pref := db.NotificationPreference{
+				UserId:  auth.Id,
+				Event:   e.Event,
+				Channel: c.Channel,
+				Enabled: r.Form.Get(e.Event+":"+c.Channel) == "on",
+			}
+
+			err := h.store.Notifications.SaveNotificationPreference(r.Context(), &pref)
+
+			if err != nil {
+				fmt.Printf("[Save preference err]: %v\n", err)
+				respondWithError(w, 500, "No se pudieron guardar tus preferencias")
+				return
+			}
+		}
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(200)
+	w.Write([]byte("<p>Preferencias guardadas</p>"))
+}
+
+// UpdateDigestSettings saves when the user gets the email digest of the
+// new accords in their cases
+func (h *Handler) UpdateDigestSettings(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	err := r.ParseForm()
+
+	if err != nil {
+		fmt.Printf("[Parse Form Err]: %v\n", err)
+		respondWithError(w, 400, "La información proporcionada no es válida")
+		return
+	}
+
+	settings := db.DigestSettings{
+		UserId:    auth.Id,
+		Enabled:   r.Form.Get("digestEnabled") == "on",
+		Frequency: db.Frequency(r.Form.Get("digestFrequency")),
+		AttachPdf: r.Form.Get("digestPdf") == "on",
+	}
+
+	if settings.Frequency != db.FrequencyDaily && settings.Frequency != db.FrequencyWeekly {
+		respondWithError(w, 400, "La frecuencia del resumen no es válida")
+		return
+	}
+
+	settings.Hour, err = strconv.Atoi(r.Form.Get("digestHour"))
+
+	if err != nil || settings.Hour < 0 || settings.Hour > 23 {
+		respondWithError(w, 400, "La hora del resumen no es válida")
+		return
+	}
+
+	settings.Weekday, err = strconv.Atoi(r.Form.Get("digestWeekday"))
+
+	if err != nil || settings.Weekday < 0 || settings.Weekday > 6 {
+		respondWithError(w, 400, "El día del resumen no es válido")
+		return
+	}
+
+	err = h.store.Digests.SaveDigestSettings(r.Context(), &settings)
+
+	if err != nil {
+		fmt.Printf("[Save digest settings err]: %v\n", err)
+		respondWithError(w, 500, "No se pudo guardar tu resumen")
+		return
+	}
+
+	message := "Resumen desactivado"
+
+	if settings.Enabled {
+		next := settings.Next(time.Now())
+		message = fmt.Sprintf("Recibirás el próximo resumen el %v a las %02d:00", internal.FormatDate(next), next.Hour())
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(200)
+	w.Write([]byte("<p>" + message + "</p>"))
+}
@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/webpush"
+)
+
+func (h *Handler) RegisterPushRoutes(router *httprouter.Router) {
+	router.GET("/api/push/key", auth.WithAuthMiddleware(h.GetPushKey))
+	router.POST("/api/push/subscriptions", auth.WithAuthMiddleware(h.CreatePushSubscription))
+	router.DELETE("/api/push/subscriptions", auth.WithAuthMiddleware(h.DeletePushSubscription))
+}
+
+// GetPushKey returns the VAPID public key the browsers subscribe with
+func (h *Handler) GetPushKey(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	if !h.push.Configured() {
+		respondWithError(w, 404, "Las notificaciones del navegador no están disponibles")
+		return
+	}
+
+	respondWithJSON(w, 200, map[string]any{"publicKey": h.push.Config().PublicKey})
+}
+
+// CreatePushSubscription stores the subscription the browser made with
+// PushManager.subscribe(). The first one turns on the new accords through
+// the browser unless the user already chose otherwise
+func (h *Handler) CreatePushSubscription(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	sub := webpush.Subscription{}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&sub); err != nil {
+		respondWithError(w, 400, "La información proporcionada es inválida")
+		return
+	}
+
+	if err := sub.Validate(); err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	userAgent := r.UserAgent()
+	stored := db.PushSubscription{
+		UserId:    auth.Id,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.Keys.P256dh,
+		Auth:      sub.Keys.Auth,
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+	}
+
+	if err := h.store.Push.SavePushSubscription(r.Context(), &stored); err != nil {
+		fmt.Printf("[Save push subscription err]: %v\n", err)
+		respondWithError(w, 500, "No se pudo guardar la suscripción")
+		return
+	}
+
+	prefs, err := h.store.Notifications.FindNotificationPreferences(r.Context(), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Find preferences err]: %v\n", err)
+		respondWithJSON(w, 201, stored)
+		return
+	}
+
+	chosen := false
+
+	for _, pref := range prefs {
+		if pref.Event == db.NotificationAccord && pref.Channel == db.ChannelPush {
+			chosen = true
+		}
+	}
+
+	if !chosen {
+		err := h.store.Notifications.SaveNotificationPreference(r.Context(), &db.NotificationPreference{
+			UserId:  auth.Id,
+			Event:   db.NotificationAccord,
+			Channel: db.ChannelPush,
+			Enabled: true,
+		})
+
+		if err != nil {
+			fmt.Printf("[Save preference err]: %v\n", err)
+		}
+	}
+
+	respondWithJSON(w, 201, stored)
+}
+
+// DeletePushSubscription forgets the subscription of the browser, which
+// sends its endpoint after unsubscribing
+func (h *Handler) DeletePushSubscription(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	data := struct {
+		Endpoint string `json:"endpoint"`
+	}{}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&data); err != nil || data.Endpoint == "" {
+		respondWithError(w, 400, "La información proporcionada es inválida")
+		return
+	}
+
+	err := h.store.Push.DeletePushSubscription(r.Context(), auth.Id, data.Endpoint)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		respondWithError(w, 404, "No se encontró la suscripción")
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("[Delete push subscription err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"context"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -15,13 +16,13 @@ import (
 	"github.com/vladwithcode/juzgados/internal/tsj"
 )
 
-func RegisterReportRoutes(router *httprouter.Router) {
-	router.GET("/report", auth.WithAuthMiddleware(ReportHandler))
+func (h *Handler) RegisterReportRoutes(router *httprouter.Router) {
+	router.GET("/report", auth.WithAuthMiddleware(h.ReportHandler))
 }
 
-func GetReportForUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) GetReportForUser(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	userId := ps.ByName("userId")
-	alerts, err := FindAlerts(userId)
+	alerts, err := h.FindAlerts(r.Context(), userId)
 
 	if err != nil {
 		fmt.Printf("FindAlerts: %v\n", err)
@@ -51,8 +52,8 @@ func GetReportForUser(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	}
 }
 
-func ReportHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
-	alerts, err := FindAlerts(auth.Id)
+func (h *Handler) ReportHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	alerts, err := h.FindAlerts(r.Context(), auth.Id)
 
 	if err != nil {
 		fmt.Printf("FindAlerts: %v\n", err)
@@ -81,9 +82,9 @@ func ReportHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params,
 	}
 }
 
-func CreatePDFForReport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+func (h *Handler) CreatePDFForReport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	userId := ps.ByName("userId")
-	docPath, err := alerts.GenReportPdf(userId)
+	docPath, err := alerts.GenReportPdf(r.Context(), userId)
 
 	if err != nil {
 		fmt.Printf("err: %v\n", err)
@@ -94,8 +95,8 @@ func CreatePDFForReport(w http.ResponseWriter, r *http.Request, ps httprouter.Pa
 	respondWithJSON(w, 201, fmt.Sprintf("Documento disponible en %v%v%v", r.URL.Scheme, r.URL.Hostname(), docPath))
 }
 
-func FindAlerts(userId string) (*[]db.Alert, error) {
-	alerts, err := db.FindAutoReportAlertsForUser(userId)
+func (h *Handler) FindAlerts(ctx context.Context, userId string) (*[]db.Alert, error) {
+	alerts, err := h.store.Alerts.FindAutoReportAlertsForUser(ctx, userId)
 
 	if err != nil {
 		return nil, err
@@ -110,7 +111,7 @@ func FindAlerts(userId string) (*[]db.Alert, error) {
 		foundAlertMap[cK] = &alert
 	}
 
-	result, err := tsj.GetCasesData(caseKeys, tsj.DEFAULT_DAYS_BACK, time.Now())
+	result, err := tsj.GetCasesData(ctx, caseKeys, tsj.DEFAULT_DAYS_BACK, time.Now())
 
 	if err != nil {
 		return nil, err
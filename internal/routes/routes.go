@@ -2,6 +2,7 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -11,29 +12,82 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/bot"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/notify"
 	"github.com/vladwithcode/juzgados/internal/reader"
+	"github.com/vladwithcode/juzgados/internal/telegram"
+	"github.com/vladwithcode/juzgados/internal/webhooks"
+	"github.com/vladwithcode/juzgados/internal/webpush"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
 )
 
-func NewRouter() http.Handler {
+// Handler holds the dependencies of the route handlers
+type Handler struct {
+	store *db.Store
+	// Runs the background jobs, nil when there are none
+	scheduler *jobs.Scheduler
+	notifier  *notify.Dispatcher
+	// Verify the webhooks of WhatsApp and Telegram and answer their messages
+	whatsapp *whatsapp.Client
+	telegram *telegram.Client
+	bot      *bot.Bot
+	// Sends the test events of the webhook endpoints
+	webhooks *webhooks.Sender
+	// Has the VAPID public key the browsers subscribe with
+	push *webpush.Client
+	// Reads the messages sent to the SMS number
+	sms *notify.SMS
+}
+
+func NewRouter(store *db.Store, scheduler *jobs.Scheduler, notifier *notify.Dispatcher) http.Handler {
 	router := httprouter.New()
+	h := &Handler{
+		store:     store,
+		scheduler: scheduler,
+		notifier:  notifier,
+		whatsapp:  whatsapp.NewClient(whatsapp.ConfigFromEnv()),
+		telegram:  telegram.NewClient(telegram.ConfigFromEnv()),
+		bot:       bot.New(store),
+		webhooks:  webhooks.NewSender(),
+		push:      webpush.NewClient(webpush.ConfigFromEnv()),
+		sms:       notify.NewSMS(store),
+	}
 
 	// Static Routes
-	router.GET("/", auth.CheckAuthMiddleware(indexHandler))
-	router.GET("/error/500", auth.CheckAuthMiddleware(Render500Error))
+	router.GET("/", auth.CheckAuthMiddleware(h.indexHandler))
+	router.GET("/error/500", auth.CheckAuthMiddleware(h.Render500Error))
 
 	// API Routes
-	router.GET("/api/file", getFile)
+	router.GET("/api/file", h.getFile)
 
 	// Doc Routes
-	RegisterDocRoutes(router)
+	h.RegisterDocRoutes(router)
 	// Case Routes
-	RegisterCaseRoutes(router)
+	h.RegisterCaseRoutes(router)
 	// User Routes
-	RegisterUserRoutes(router)
+	h.RegisterUserRoutes(router)
 	// Report Routes
-	RegisterReportRoutes(router)
+	h.RegisterReportRoutes(router)
 	// Alert Routes
-	RegisterAlertRoutes(router)
+	h.RegisterAlertRoutes(router)
+	// Watch Routes
+	h.RegisterWatchRoutes(router)
+	// Notification Routes
+	h.RegisterNotificationRoutes(router)
+	// WhatsApp Routes
+	h.RegisterWhatsAppRoutes(router)
+	// Telegram Routes
+	h.RegisterTelegramRoutes(router)
+	// Webhook Routes
+	h.RegisterWebhookRoutes(router)
+	// Push Routes
+	h.RegisterPushRoutes(router)
+	// SMS Routes
+	h.RegisterSMSRoutes(router)
+	// Admin Routes
+	h.RegisterAdminRoutes(router)
 
 	// Serve static content
 	router.NotFound = http.FileServer(http.Dir("web/static"))
@@ -41,7 +95,7 @@ func NewRouter() http.Handler {
 	return router
 }
 
-func indexHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) indexHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	templ, err := template.ParseFiles("web/templates/layout.html", "web/templates/index.html")
 
 	if err != nil {
@@ -57,7 +111,7 @@ func indexHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 	templ.Execute(w, data)
 }
 
-func Render500Error(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) Render500Error(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	templ, err := template.ParseFiles("web/templates/layout.html", "web/templates/500.html")
 
 	if err != nil {
@@ -74,7 +128,7 @@ func Render500Error(w http.ResponseWriter, r *http.Request, _ httprouter.Params,
 	templ.Execute(w, data)
 }
 
-func getFile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) getFile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	caseType := r.URL.Query().Get("type")
 	date := r.URL.Query().Get("date")
 
@@ -90,7 +144,7 @@ func getFile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 
 	date = fmt.Sprintf("%d%d%s", day, month, segments[0])
 
-	content, err := reader.Reader(date, caseType)
+	content, err := reader.Reader(r.Context(), date, caseType)
 
 	if err != nil {
 		fmt.Println(err)
@@ -101,6 +155,22 @@ func getFile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	fmt.Fprintln(w, string(*content))
 }
 
+// Refer to https://stackoverflow.com/questions/18276173/calling-a-template-with-several-pipeline-parameters
+func dict(values ...interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid dict call")
+	}
+	dict := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, errors.New("dict keys must be strings")
+		}
+		dict[key] = values[i+1]
+	}
+	return dict, nil
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	dat, err := json.Marshal(payload)
 
@@ -0,0 +1,254 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/db/memdb"
+	"github.com/vladwithcode/juzgados/internal/notify"
+)
+
+func TestMain(m *testing.M) {
+	// The handlers read the templates from web/templates
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+
+	os.Setenv("JWT_SECRET", "secreto de prueba")
+	os.Exit(m.Run())
+}
+
+// newServer returns the router backed by an in-memory store with the
+// users ana (u1) and beto (u2), both with the password "secreto"
+func newServer(t *testing.T) (http.Handler, *db.Store) {
+	t.Helper()
+
+	store := memdb.New()
+
+	for _, user := range []*db.User{
+		{Id: "u1", Username: "ana", Email: "ana@example.com", Password: "secreto"},
+		{Id: "u2", Username: "beto", Email: "beto@example.com", Password: "secreto"},
+	} {
+		if _, err := store.Users.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("CreateUser(%v): %v", user.Username, err)
+		}
+	}
+
+	return NewRouter(store, nil, notify.NewDispatcher(store)), store
+}
+
+// serve sends a form request through h with the session cookie, if any
+func serve(h http.Handler, method, target string, form url.Values, session *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if session != nil {
+		req.AddCookie(session)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+// signIn returns the session cookie of the user
+func signIn(t *testing.T, h http.Handler, username string) *http.Cookie {
+	t.Helper()
+
+	w := serve(h, "POST", "/sign-in", url.Values{"username": {username}, "password": {"secreto"}}, nil)
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "auth_token" && c.Value != "" {
+			return c
+		}
+	}
+
+	t.Fatalf("sign in %v: got %v without session, want a session", username, w.Code)
+	return nil
+}
+
+func TestSignIn(t *testing.T) {
+	h, _ := newServer(t)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		code     int
+		session  bool
+	}{
+		{"right password", "ana", "secreto", 204, true},
+		{"wrong password", "ana", "otro", 400, false},
+		{"unknown user", "carla", "secreto", 400, false},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, "POST", "/sign-in", url.Values{"username": {tt.username}, "password": {tt.password}}, nil)
+		session := false
+
+		for _, c := range w.Result().Cookies() {
+			session = session || (c.Name == "auth_token" && c.Value != "")
+		}
+
+		if w.Code != tt.code || session != tt.session {
+			t.Errorf("%v: got %v with session %v, want %v with session %v", tt.name, w.Code, session, tt.code, tt.session)
+		}
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	h, _ := newServer(t)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.AuthClaims{Id: "u1", Username: "ana"}).SignedString([]byte("otro secreto"))
+
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		session *http.Cookie
+	}{
+		{"docs without session", "GET", "/api/docs", nil},
+		{"docs with a forged session", "GET", "/api/docs", &http.Cookie{Name: "auth_token", Value: forged}},
+		{"alert without session", "PUT", "/api/alert/a1", nil},
+		{"preferences without session", "PUT", "/api/notifications", nil},
+	}
+
+	for _, tt := range tests {
+		if w := serve(h, tt.method, tt.target, nil, tt.session); w.Code != 401 {
+			t.Errorf("%v: got %v, want 401", tt.name, w.Code)
+		}
+	}
+}
+
+func TestUpdateAlertSubscription(t *testing.T) {
+	ctx := context.Background()
+	h, store := newServer(t)
+	session := signIn(t, h, "ana")
+
+	own, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{UserId: "u1", CaseId: "1/2024", NatureCode: "civ1", Active: true})
+
+	if err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	other, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{UserId: "u2", CaseId: "1/2024", NatureCode: "civ1", Active: true})
+
+	if err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	form := url.Values{"alias": {"Arrendamiento"}, "frequency": {string(db.FrequencyWeekly)}}
+
+	if w := serve(h, "PUT", "/api/alert/"+own.Id, form, session); w.Code != 200 {
+		t.Fatalf("own alert: got %v, want 200", w.Code)
+	}
+
+	if w := serve(h, "PUT", "/api/alert/"+other.Id, form, session); w.Code != 404 {
+		t.Errorf("alert of another user: got %v, want 404", w.Code)
+	}
+
+	tests := []struct {
+		id        string
+		alias     string
+		frequency db.Frequency
+	}{
+		{own.Id, "Arrendamiento", db.FrequencyWeekly},
+		{other.Id, "", other.Frequency},
+	}
+
+	for _, tt := range tests {
+		alert, err := store.Alerts.FindAlertById(ctx, tt.id)
+
+		if err != nil {
+			t.Fatalf("FindAlertById: %v", err)
+		}
+
+		if alert.Alias.String != tt.alias || alert.Frequency != tt.frequency {
+			t.Errorf("%v: got %q %v, want %q %v", alert.UserId, alert.Alias.String, alert.Frequency, tt.alias, tt.frequency)
+		}
+	}
+}
+
+func TestDocsOfTheUser(t *testing.T) {
+	ctx := context.Background()
+	h, store := newServer(t)
+
+	if _, err := store.Alerts.CreateAlertWithData(ctx, &db.Alert{UserId: "u1", CaseId: "1/2024", NatureCode: "civ1", Active: true}); err != nil {
+		t.Fatalf("CreateAlertWithData: %v", err)
+	}
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, doc := range []db.Doc{{ID: "d1", Case: "1/2024", NatureCode: "civ1"}, {ID: "d2", Case: "2/2024", NatureCode: "civ1"}} {
+		if err := store.Docs.CreateDoc(ctx, doc.ID, doc.Case, doc.Nature, doc.NatureCode, doc.Accord, date); err != nil {
+			t.Fatalf("CreateDoc: %v", err)
+		}
+	}
+
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"ana", `"id":"d1"`},
+		{"beto", `[]`},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, "GET", "/api/docs", nil, signIn(t, h, tt.username))
+		body := w.Body.String()
+
+		if w.Code != 200 || !strings.Contains(body, tt.want) || strings.Contains(body, `"id":"d2"`) {
+			t.Errorf("%v: got %v %v, want 200 with %v", tt.username, w.Code, body, tt.want)
+		}
+	}
+}
+
+func TestUpdateNotificationPreferences(t *testing.T) {
+	ctx := context.Background()
+	h, store := newServer(t)
+	session := signIn(t, h, "ana")
+
+	form := url.Values{db.NotificationReport + ":" + db.ChannelEmail: {"on"}}
+
+	if w := serve(h, "PUT", "/api/notifications", form, session); w.Code != 200 {
+		t.Fatalf("got %v, want 200", w.Code)
+	}
+
+	prefs, err := store.Notifications.FindNotificationPreferences(ctx, "u1")
+
+	if err != nil {
+		t.Fatalf("FindNotificationPreferences: %v", err)
+	}
+
+	want := len(db.NotificationEvents) * len(db.NotificationChannels)
+
+	if len(prefs) != want {
+		t.Errorf("got %v preferences, want one for every event and channel (%v)", len(prefs), want)
+	}
+
+	for _, pref := range prefs {
+		enabled := pref.Event == db.NotificationReport && pref.Channel == db.ChannelEmail
+
+		if pref.Enabled != enabled {
+			t.Errorf("%v:%v: got enabled %v, want %v", pref.Event, pref.Channel, pref.Enabled, enabled)
+		}
+	}
+
+	if others, _ := store.Notifications.FindNotificationPreferences(ctx, "u2"); len(others) != 0 {
+		t.Errorf("got %v preferences for another user, want 0", len(others))
+	}
+}
@@ -0,0 +1,100 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/sms"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+// The answers to the keywords, see sms.ParseKeyword
+const (
+	smsStopReply      = "Ya no recibirás SMS de Juzgados. Responde ALTA para volver a recibirlos"
+	smsStartReply     = "Listo, recibirás por SMS los acuerdos nuevos de tus expedientes. Responde BAJA para dejar de recibirlos"
+	smsStartNoAccount = "Listo, puedes volver a recibir SMS de Juzgados. Para recibir avisos vincula este número en la sección Notificaciones de tu panel"
+	smsHelpReply      = "Juzgados: avisos de acuerdos nuevos en tus expedientes. Responde BAJA para dejar de recibirlos o ALTA para volver a recibirlos"
+)
+
+func (h *Handler) RegisterSMSRoutes(router *httprouter.Router) {
+	router.POST("/api/sms/inbound", h.ReceiveSMS)
+}
+
+// ReceiveSMS handles the messages sent to the number: BAJA opts the
+// phone out of the SMS, ALTA opts it back in, anything else gets the help
+func (h *Handler) ReceiveSMS(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.sms.Provider == nil {
+		respondWithError(w, 503, "El proveedor de SMS no está configurado")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	inbound, err := h.sms.Provider.ParseInbound(r)
+
+	if errors.Is(err, sms.ErrUnauthorized) {
+		respondWithError(w, 401, "Firma inválida")
+		return
+	}
+
+	if err != nil {
+		respondWithError(w, 400, "Petición inválida")
+		return
+	}
+
+	phone, err := whatsapp.NormalizePhone(inbound.From, h.sms.Config.DefaultCountryCode)
+
+	if err != nil {
+		// Nothing can be sent back to a number that isn't one
+		h.sms.Provider.WriteReply(w, "")
+		return
+	}
+
+	reply, err := h.answerSMS(r.Context(), phone, sms.ParseKeyword(inbound.Text))
+
+	// The provider retries the requests that fail, which is right
+	// for an opt out that wasn't saved
+	if err != nil {
+		fmt.Printf("[SMS inbound err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error inesperado")
+		return
+	}
+
+	h.sms.Provider.WriteReply(w, sms.ToGSM7(reply))
+}
+
+// answerSMS applies the keyword sent by phone and returns the reply
+func (h *Handler) answerSMS(ctx context.Context, phone, keyword string) (string, error) {
+	switch keyword {
+	case sms.KeywordStop:
+		return smsStopReply, h.store.SMS.OptOutSMS(ctx, phone)
+	case sms.KeywordStart:
+		if err := h.store.SMS.OptInSMS(ctx, phone); err != nil {
+			return "", err
+		}
+
+		user, err := h.store.Users.GetUserByPhone(ctx, phone)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			return smsStartNoAccount, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		// Replying ALTA is asking for the SMS, turn on the new accords
+		err = h.store.Notifications.SaveNotificationPreference(ctx, &db.NotificationPreference{
+			UserId:  user.Id,
+			Event:   db.NotificationAccord,
+			Channel: db.ChannelSMS,
+			Enabled: true,
+		})
+
+		return smsStartReply, err
+	}
+
+	return smsHelpReply, nil
+}
@@ -0,0 +1,173 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/bot"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/telegram"
+)
+
+func (h *Handler) RegisterTelegramRoutes(router *httprouter.Router) {
+	// Without the secret anyone who knows the URL could write as any chat
+	if h.telegram.Config().WebhookSecret == "" {
+		fmt.Println("[Telegram err]: TELEGRAM_WEBHOOK_SECRET is not set, the webhook is disabled")
+	} else {
+		router.POST("/api/telegram/webhook", h.ReceiveTelegramWebhook)
+	}
+
+	router.POST("/api/telegram/link", auth.WithAuthMiddleware(h.CreateTelegramLink))
+	router.DELETE("/api/telegram/link", auth.WithAuthMiddleware(h.DeleteTelegramLink))
+}
+
+// ReceiveTelegramWebhook answers the messages written to the bot, see
+// the telegram command to set the webhook
+func (h *Handler) ReceiveTelegramWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !h.telegram.VerifySecret(r.Header.Get("X-Telegram-Bot-Api-Secret-Token")) {
+		respondWithError(w, 401, "Token inválido")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+
+	if err != nil {
+		respondWithError(w, 400, "No se pudo leer la petición")
+		return
+	}
+
+	msg, err := telegram.ParseUpdate(body)
+
+	if err != nil {
+		respondWithError(w, 400, "Petición inválida")
+		return
+	}
+
+	// Telegram retries the updates until they get an answer, the replies
+	// are sent afterwards
+	if msg != nil && msg.Text != "" {
+		go h.answerTelegram(*msg)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// answerTelegram replies to msg. Only the linked chats can use the bot,
+// the rest can only link themselves
+func (h *Handler) answerTelegram(msg telegram.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), botReplyTimeout)
+	defer cancel()
+
+	var reply string
+	cmd := bot.Parse(msg.Text)
+
+	if cmd.Name == bot.CmdLink {
+		reply = h.linkTelegram(ctx, msg, cmd)
+	} else {
+		user, err := h.store.Telegram.GetUserByTelegramChat(ctx, msg.ChatId)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			reply = "Este chat no está vinculado a ninguna cuenta. Entra a Notificaciones en tu panel y elige Vincular Telegram"
+		} else if err != nil {
+			fmt.Printf("[Telegram bot err]: %v\n", err)
+			reply = "Ocurrió un error, intenta más tarde"
+		} else {
+			reply = h.bot.Reply(ctx, user, cmd)
+		}
+	}
+
+	_, err := h.telegram.SendMessage(ctx, msg.ChatId, telegram.FormatText(reply))
+
+	if err != nil {
+		fmt.Printf("[Telegram reply err]: %v\n", err)
+	}
+}
+
+// linkTelegram links the chat of msg to the user who created the code
+// sent, see CreateTelegramLink
+func (h *Handler) linkTelegram(ctx context.Context, msg telegram.Message, cmd bot.Command) string {
+	invalid := "El código no es válido o ya expiró, genera otro en la sección Notificaciones de tu panel"
+
+	if len(cmd.Args) == 0 {
+		return invalid
+	}
+
+	code, err := uuid.Parse(cmd.Args[0])
+
+	if err != nil {
+		return invalid
+	}
+
+	err = h.store.OTLinks.UseTelegramOTL(ctx, code, &db.TelegramChat{
+		ChatId:   msg.ChatId,
+		Username: sql.NullString{String: msg.Username, Valid: msg.Username != ""},
+	})
+
+	if err != nil {
+		var otlErr *db.NonExistentOTLError
+		if errors.As(err, &otlErr) {
+			return invalid
+		}
+
+		fmt.Printf("[Telegram link err]: %v\n", err)
+		return "Ocurrió un error al vincular este chat, intenta más tarde"
+	}
+
+	return "Listo, este chat quedó vinculado a tu cuenta. Activa Telegram en tus notificaciones para recibir los acuerdos nuevos aquí.\n\n" + bot.Help()
+}
+
+// CreateTelegramLink returns the link that opens the chat with the bot
+// and links it, or the message to send when the bot has no username
+func (h *Handler) CreateTelegramLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	w.Header().Set("Content-Type", "text/html")
+
+	userUUID, err := uuid.Parse(auth.Id)
+
+	if err != nil {
+		fmt.Printf("Parse id err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrio un error inesperado"))
+		return
+	}
+
+	otl, err := h.store.OTLinks.CreateOTLink(r.Context(), userUUID, db.OTLActionTelegram)
+
+	if err != nil {
+		fmt.Printf("Create OTL err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrio un error inesperado"))
+		return
+	}
+
+	if link := h.telegram.StartLink(otl.Code.String()); link != "" {
+		fmt.Fprintf(w, `<p><a class="underline" href="%v" target="_blank">Abre el chat con el bot</a> y presiona Iniciar en los próximos 15 minutos</p>`, template.HTMLEscapeString(link))
+		return
+	}
+
+	fmt.Fprintf(w, "<p>Envía al bot en los próximos 15 minutos el mensaje <b>/%v %v</b></p>", bot.CmdLink, otl.Code)
+}
+
+// DeleteTelegramLink unlinks the chat of the user
+func (h *Handler) DeleteTelegramLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	w.Header().Set("Content-Type", "text/html")
+
+	err := h.store.Telegram.DeleteTelegramChat(r.Context(), auth.Id)
+
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		fmt.Printf("[Delete telegram chat err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrio un error inesperado"))
+		return
+	}
+
+	w.Write([]byte("<p>Telegram desvinculado</p>"))
+}
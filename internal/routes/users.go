@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,41 +16,53 @@ import (
 	"github.com/vladwithcode/juzgados/internal"
 	"github.com/vladwithcode/juzgados/internal/auth"
 	"github.com/vladwithcode/juzgados/internal/db"
-	"github.com/vladwithcode/juzgados/internal/mailing"
+	"github.com/vladwithcode/juzgados/internal/notify"
 )
 
-func RegisterUserRoutes(router *httprouter.Router) {
-	router.GET("/dashboard", auth.WithAuthMiddleware(RenderDashboard))
-	router.GET("/iniciar-sesion", auth.CheckAuthMiddleware(RenderSignin))
-	router.GET("/registrarse", auth.CheckAuthMiddleware(RenderSignup))
-	router.GET("/sign-out", auth.CheckAuthMiddleware(SignOutUser))
-	router.POST("/sign-up", SignUpUser)
-	router.POST("/sign-in", SignInUser)
+func (h *Handler) RegisterUserRoutes(router *httprouter.Router) {
+	router.GET("/dashboard", auth.WithAuthMiddleware(h.RenderDashboard))
+	router.GET("/iniciar-sesion", auth.CheckAuthMiddleware(h.RenderSignin))
+	router.GET("/registrarse", auth.CheckAuthMiddleware(h.RenderSignup))
+	router.GET("/sign-out", auth.CheckAuthMiddleware(h.SignOutUser))
+	router.POST("/sign-up", h.SignUpUser)
+	router.POST("/sign-in", h.SignInUser)
 
-	router.GET("/api/users/verification", RenderVerification)
-	router.POST("/api/user", CreateUser)
+	router.GET("/api/users/verification", h.RenderVerification)
+	router.POST("/api/user", h.CreateUser)
 }
 
-func RenderDashboard(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
-	user, err := db.GetUserByUsername(auth.Username)
+func (h *Handler) RenderDashboard(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	user, err := h.store.Users.GetUserByUsername(r.Context(), auth.Username)
 
 	if err != nil {
 		respondWithError(w, 500, "Ocurrio un error con el servidor")
 		return
 	}
 
-	alerts, err := db.FindAlertsByUser(auth.Id, false)
+	alerts, err := h.store.Alerts.FindAlertsByUser(r.Context(), auth.Id, false)
 
 	if err != nil {
 		fmt.Printf("[Alert Find Err]: %v\n", err)
 	}
 
+	watches, err := h.store.Watches.FindWatchesByUser(r.Context(), auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Watch Find Err]: %v\n", err)
+	}
+
+	watchMatches, err := h.store.Watches.FindWatchMatchesByUser(r.Context(), auth.Id, 10)
+
+	if err != nil {
+		fmt.Printf("[Watch Match Find Err]: %v\n", err)
+	}
+
 	templ, err := template.New("layout.html").Funcs(template.FuncMap{
 		"FormatDate": internal.FormatDate,
 		"GetNature": func(code string) string {
 			return internal.CodesMap[code]
 		},
-	}).ParseFiles("web/templates/layout.html", "web/templates/alert-card.html", "web/templates/dashboard.html")
+	}).ParseFiles("web/templates/layout.html", "web/templates/alert-card.html", "web/templates/watch-card.html", "web/templates/dashboard.html")
 
 	if err != nil {
 		fmt.Printf("Parse err: %v\n", err)
@@ -60,11 +71,15 @@ func RenderDashboard(w http.ResponseWriter, r *http.Request, _ httprouter.Params
 	}
 
 	data := struct {
-		User   *db.User
-		Alerts []*db.Alert
+		User         *db.User
+		Alerts       []*db.Alert
+		Watches      []*db.Watch
+		WatchMatches []*db.WatchMatch
 	}{
-		User:   user,
-		Alerts: alerts,
+		User:         user,
+		Alerts:       alerts,
+		Watches:      watches,
+		WatchMatches: watchMatches,
 	}
 
 	err = templ.Execute(
@@ -80,7 +95,7 @@ func RenderDashboard(w http.ResponseWriter, r *http.Request, _ httprouter.Params
 	}
 }
 
-func RenderSignup(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) RenderSignup(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	if auth.Id != "" {
 		http.Redirect(w, r, "/dashboard", 302)
 		return
@@ -97,7 +112,7 @@ func RenderSignup(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 	templ.Execute(w, nil)
 }
 
-func RenderSignin(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) RenderSignin(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	if auth.Id != "" {
 		http.Redirect(w, r, "/dashboard", 302)
 		return
@@ -118,21 +133,10 @@ func RenderSignin(w http.ResponseWriter, r *http.Request, _ httprouter.Params, a
 	templ.Execute(w, data)
 }
 
-func RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	code := r.URL.Query().Get("code")
 	userId := r.URL.Query().Get("userId")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
-	defer cancel()
-	tx, conn, err := db.GetTxAndPool(ctx)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte("Ocurrio un error inesperado"))
-		return
-	}
-	defer conn.Release()
-	defer tx.Rollback(ctx)
-
 	templ, err := template.ParseFiles("web/templates/layout.html", "web/templates/email-verification.html")
 
 	if err != nil {
@@ -149,7 +153,7 @@ func RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 	cId, _ := uuid.Parse(code)
 	uId, _ := uuid.Parse(userId)
 
-	otl, err := db.TxFindUserOTLinkByCode(ctx, tx, cId, uId)
+	otl, err := h.store.OTLinks.FindUserOTLinkByCode(r.Context(), cId, uId)
 
 	if err != nil {
 		var target *db.NonExistentOTLError
@@ -192,15 +196,7 @@ func RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 		return
 	}
 
-	err = db.TxMarkOTLinkAsUsed(ctx, tx, cId, uId)
-	if err != nil {
-		fmt.Printf("err: %v\n", err)
-		w.WriteHeader(500)
-		w.Write([]byte("Ocurrio un error inesperado"))
-		return
-	}
-
-	err = db.TxVerifyUserEmail(ctx, tx, userId)
+	err = h.store.OTLinks.UseVerifyOTL(r.Context(), cId, uId)
 	if err != nil {
 		fmt.Printf("err: %v\n", err)
 		w.WriteHeader(500)
@@ -208,14 +204,6 @@ func RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 		return
 	}
 
-	err = tx.Commit(ctx)
-	if err != nil {
-		fmt.Printf("Commit err: %v\n", err)
-		w.WriteHeader(500)
-		w.Write([]byte("Ocurrio un error inesperado"))
-		return
-	}
-
 	data["VerificationSuccess"] = true
 
 	err = templ.Execute(w, data)
@@ -228,7 +216,7 @@ func RenderVerification(w http.ResponseWriter, r *http.Request, _ httprouter.Par
 	}
 }
 
-func CreateUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	data := db.User{}
 	decoder := json.NewDecoder(r.Body)
 	defer r.Body.Close()
@@ -241,7 +229,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		return
 	}
 
-	_, err = db.CreateUser(&data)
+	_, err = h.store.Users.CreateUser(r.Context(), &data)
 
 	if err != nil {
 		fmt.Println(err)
@@ -267,7 +255,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	}
 }
 
-func SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	err := r.ParseForm()
 
 	if err != nil {
@@ -316,7 +304,7 @@ func SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		Password: pw,
 	}
 
-	_, err = db.CreateUser(user)
+	_, err = h.store.Users.CreateUser(r.Context(), user)
 
 	if err != nil {
 		var PgErr *pgconn.PgError
@@ -352,7 +340,7 @@ func SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		return
 	}
 
-	otl, err := db.CreateVerifyOTL(userUUID)
+	otl, err := h.store.OTLinks.CreateOTLink(r.Context(), userUUID, db.OTLActionVerify)
 
 	if err != nil {
 		fmt.Printf("Create OTL err: %v\n", err)
@@ -362,7 +350,15 @@ func SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		return
 	}
 
-	if err := mailing.SendVerificationMail(user.Email, otl); err != nil {
+	_, err = h.notifier.Dispatch(r.Context(), &notify.Message{
+		Event:   db.NotificationVerification,
+		User:    user,
+		Subject: "Confirma tu registro",
+		OTLink:  otl,
+	})
+
+	if err != nil {
+		fmt.Printf("Send verification err: %v\n", err)
 		w.Header().Set("Content-Type", "text/html")
 		w.WriteHeader(500)
 		w.Write([]byte("Ocurrio un error inesperado"))
@@ -388,7 +384,7 @@ func SignUpUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	}
 }
 
-func SignInUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+func (h *Handler) SignInUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	err := r.ParseForm()
 
 	if err != nil {
@@ -405,7 +401,7 @@ func SignInUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		Password: r.Form.Get("password"),
 	}
 
-	user, err := db.GetUserByUsername(data.Username)
+	user, err := h.store.Users.GetUserByUsername(r.Context(), data.Username)
 
 	if err != nil {
 		fmt.Printf("Get Error: %v\n", err)
@@ -445,7 +441,7 @@ func SignInUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	respondWithJSON(w, 204, user)
 }
 
-func SignOutUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+func (h *Handler) SignOutUser(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
 	if auth.Id == "" {
 		http.Redirect(w, r, "/", 401)
 		return
@@ -0,0 +1,145 @@
+package routes
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+func (h *Handler) RegisterWatchRoutes(router *httprouter.Router) {
+	router.POST("/api/watches", auth.WithAuthMiddleware(h.CreateWatch))
+	router.GET("/api/watches/matches", auth.WithAuthMiddleware(h.GetWatchMatches))
+	router.DELETE("/api/watch/:id", auth.WithAuthMiddleware(h.DeleteWatchById))
+}
+
+func (h *Handler) CreateWatch(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	err := r.ParseForm()
+
+	if err != nil {
+		fmt.Printf("[Parse Form Err]: %v\n", err)
+		respondWithError(w, 400, "La información proporcionada no es válida")
+		return
+	}
+
+	var (
+		pattern = strings.TrimSpace(r.Form.Get("pattern"))
+		alias   = strings.TrimSpace(r.Form.Get("alias"))
+		kind    = r.Form.Get("kind")
+	)
+
+	if len(internal.NormalizeText(pattern)) < 3 {
+		respondWithError(w, 400, "El texto a vigilar debe tener al menos 3 caracteres")
+		return
+	}
+
+	if kind != db.WatchKindRFC {
+		kind = db.WatchKindText
+	}
+
+	natureCodes := []string{}
+	for _, nc := range r.Form["natureCodes"] {
+		if _, ok := internal.CodesMap[nc]; ok {
+			natureCodes = append(natureCodes, nc)
+		}
+	}
+
+	watch := db.Watch{
+		UserId:      auth.Id,
+		Pattern:     pattern,
+		Kind:        kind,
+		NatureCodes: natureCodes,
+		Active:      true,
+	}
+	watch.Alias.String = alias
+	watch.Alias.Valid = alias != ""
+
+	created, err := h.store.Watches.CreateWatch(r.Context(), &watch)
+
+	if err != nil {
+		fmt.Printf("[Create Err]: %v\n", err)
+		respondWithError(w, 500, "Ocurrió un error al crear la vigilancia")
+		return
+	}
+
+	templ, err := parseWatchTemplates()
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Header().Add("Content-Type", "text/html")
+		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+		return
+	}
+
+	err = templ.ExecuteTemplate(w, "watch-card", created)
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Header().Add("Content-Type", "text/html")
+		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+		return
+	}
+}
+
+func (h *Handler) GetWatchMatches(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	matches, err := h.store.Watches.FindWatchMatchesByUser(r.Context(), auth.Id, 50)
+
+	if err != nil {
+		fmt.Printf("[Find matches err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Header().Add("Content-Type", "text/html")
+		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+		return
+	}
+
+	templ, err := parseWatchTemplates()
+
+	if err != nil {
+		fmt.Printf("[Parse err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Header().Add("Content-Type", "text/html")
+		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+		return
+	}
+
+	err = templ.ExecuteTemplate(w, "watch-matches", matches)
+
+	if err != nil {
+		fmt.Printf("[Execute err]: %v\n", err)
+		w.WriteHeader(500)
+		w.Header().Add("Content-Type", "text/html")
+		w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+	}
+}
+
+func (h *Handler) DeleteWatchById(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+	id := ps.ByName("id")
+	err := h.store.Watches.DeleteUserWatchById(r.Context(), id, auth.Id)
+
+	if err != nil {
+		fmt.Printf("[Delete watch err]: %v\n", err)
+		w.Header().Add("Content-Type", "text/html")
+		w.WriteHeader(404)
+		w.Write([]byte(fmt.Sprintf("<p>No se encontró vigilancia con id: %v</p>", id)))
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(200)
+}
+
+func parseWatchTemplates() (*template.Template, error) {
+	return template.New("watch-card.html").Funcs(template.FuncMap{
+		"FormatDate": internal.FormatDate,
+		"GetNature": func(code string) string {
+			return internal.CodesMap[code]
+		},
+	}).ParseFiles("web/templates/watch-card.html")
+}
@@ -0,0 +1,249 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/jobs"
+	"github.com/vladwithcode/juzgados/internal/webhooks"
+)
+
+// How many deliveries of every endpoint are shown in its log
+const webhookLogSize = 20
+
+func (h *Handler) RegisterWebhookRoutes(router *httprouter.Router) {
+	router.GET("/webhooks", auth.WithAuthMiddleware(h.RenderWebhooksPage(false)))
+	router.POST("/api/webhooks", auth.WithAuthMiddleware(h.CreateWebhookEndpoint(false)))
+	router.DELETE("/api/webhooks/:id", auth.WithAuthMiddleware(h.DeleteWebhookEndpoint(false)))
+	router.POST("/api/webhooks/:id/test", auth.WithAuthMiddleware(h.TestWebhookEndpoint(false)))
+
+	// The endpoints of the organization get the events of every user
+	router.GET("/admin/webhooks", h.withAdmin(h.RenderWebhooksPage(true)))
+	router.POST("/api/admin/webhooks", h.withAdmin(h.CreateWebhookEndpoint(true)))
+	router.DELETE("/api/admin/webhooks/:id", h.withAdmin(h.DeleteWebhookEndpoint(true)))
+	router.POST("/api/admin/webhooks/:id/test", h.withAdmin(h.TestWebhookEndpoint(true)))
+}
+
+// webhookOwner returns whose endpoints a request manages, empty for the
+// ones of the organization
+func webhookOwner(org bool, auth *auth.Auth) string {
+	if org {
+		return ""
+	}
+
+	return auth.Id
+}
+
+// webhookPaths returns the page and the API path of the endpoints
+func webhookPaths(org bool) (page, api string) {
+	if org {
+		return "/admin/webhooks", "/api/admin/webhooks"
+	}
+
+	return "/webhooks", "/api/webhooks"
+}
+
+// An endpoint with its latest deliveries, as shown in the page
+type webhookView struct {
+	*db.WebhookEndpoint
+	Deliveries []*db.WebhookDelivery
+}
+
+func (h *Handler) RenderWebhooksPage(org bool) auth.AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+		endpoints, err := h.store.Webhooks.FindWebhookEndpoints(r.Context(), webhookOwner(org, auth))
+
+		if err != nil {
+			fmt.Printf("[Find webhook endpoints err]: %v\n", err)
+			respondWithError(w, 500, "Ocurrió un error inesperado")
+			return
+		}
+
+		views := []webhookView{}
+
+		for _, endpoint := range endpoints {
+			deliveries, err := h.store.Webhooks.FindWebhookDeliveries(r.Context(), endpoint.Id, webhookLogSize)
+
+			if err != nil {
+				fmt.Printf("[Find webhook deliveries err]: %v\n", err)
+				respondWithError(w, 500, "Ocurrió un error inesperado")
+				return
+			}
+
+			views = append(views, webhookView{WebhookEndpoint: endpoint, Deliveries: deliveries})
+		}
+
+		templ, err := template.New("layout.html").Funcs(template.FuncMap{
+			"FormatTime": internal.FormatTimestampToString,
+		}).ParseFiles("web/templates/layout.html", "web/templates/webhooks.html")
+
+		if err != nil {
+			fmt.Printf("[Parse err]: %v\n", err)
+			respondWithError(w, 500, "Ocurrió un error inesperado")
+			return
+		}
+
+		_, apiPath := webhookPaths(org)
+
+		err = templ.Execute(w, map[string]any{
+			"User":            auth,
+			"Org":             org,
+			"ApiPath":         apiPath,
+			"Endpoints":       views,
+			"Events":          db.WebhookEvents,
+			"SignatureHeader": webhooks.SignatureHeader,
+			"EventHeader":     webhooks.EventHeader,
+		})
+
+		if err != nil {
+			fmt.Printf("[Execute err]: %v\n", err)
+		}
+	}
+}
+
+// CreateWebhookEndpoint adds the endpoint in the form with a new secret and
+// reloads the page, where the secret is shown
+func (h *Handler) CreateWebhookEndpoint(org bool) auth.AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+		w.Header().Set("Content-Type", "text/html")
+
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(400)
+			w.Write([]byte("<p>La información proporcionada no es válida</p>"))
+			return
+		}
+
+		endpointUrl := strings.TrimSpace(r.Form.Get("url"))
+
+		if err := webhooks.ValidateURL(r.Context(), endpointUrl); err != nil {
+			w.WriteHeader(400)
+			fmt.Fprintf(w, "<p>%v</p>", template.HTMLEscapeString(err.Error()))
+			return
+		}
+
+		events := []string{}
+
+		for _, e := range db.WebhookEvents {
+			if r.Form.Get(e.Event) == "on" {
+				events = append(events, e.Event)
+			}
+		}
+
+		secret, err := webhooks.NewSecret()
+
+		if err != nil {
+			fmt.Printf("[Webhook secret err]: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+			return
+		}
+
+		owner := webhookOwner(org, auth)
+		endpoint := db.WebhookEndpoint{
+			UserId:      endpointOwner(owner),
+			Url:         endpointUrl,
+			Secret:      secret,
+			Events:      events,
+			Description: strings.TrimSpace(r.Form.Get("description")),
+		}
+
+		if err := h.store.Webhooks.CreateWebhookEndpoint(r.Context(), &endpoint); err != nil {
+			fmt.Printf("[Create webhook endpoint err]: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("<p>No se pudo agregar el endpoint</p>"))
+			return
+		}
+
+		page, _ := webhookPaths(org)
+		w.Header().Add("HX-Location", page)
+		w.WriteHeader(201)
+	}
+}
+
+func (h *Handler) DeleteWebhookEndpoint(org bool) auth.AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+		w.Header().Set("Content-Type", "text/html")
+
+		err := h.store.Webhooks.DeleteWebhookEndpoint(r.Context(), ps.ByName("id"), webhookOwner(org, auth))
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.WriteHeader(404)
+			w.Write([]byte("<p>No se encontró el endpoint</p>"))
+			return
+		}
+
+		if err != nil {
+			fmt.Printf("[Delete webhook endpoint err]: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+			return
+		}
+
+		page, _ := webhookPaths(org)
+		w.Header().Add("HX-Location", page)
+	}
+}
+
+// TestWebhookEndpoint sends a test event to the endpoint and tells how
+// it answered
+func (h *Handler) TestWebhookEndpoint(org bool) auth.AuthedHandler {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params, auth *auth.Auth) {
+		w.Header().Set("Content-Type", "text/html")
+
+		endpoint, err := h.store.Webhooks.FindWebhookEndpointById(r.Context(), ps.ByName("id"))
+
+		if err == nil && endpoint.UserId.String != webhookOwner(org, auth) {
+			err = pgx.ErrNoRows
+		}
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			w.WriteHeader(404)
+			w.Write([]byte("<p>No se encontró el endpoint</p>"))
+			return
+		}
+
+		if err != nil {
+			fmt.Printf("[Find webhook endpoint err]: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+			return
+		}
+
+		delivery, err := jobs.SendTestWebhook(r.Context(), h.store, h.webhooks, endpoint)
+
+		if err != nil {
+			fmt.Printf("[Test webhook err]: %v\n", err)
+			w.WriteHeader(500)
+			w.Write([]byte("<p>Ocurrió un error inesperado</p>"))
+			return
+		}
+
+		if delivery.Status == db.WebhookSent {
+			fmt.Fprintf(w, "<p>El endpoint recibió el evento de prueba (%v)</p>", delivery.ResponseStatus.Int32)
+			return
+		}
+
+		// Only the status, the errors may tell about the network of the server
+		if delivery.ResponseStatus.Valid {
+			fmt.Fprintf(w, `<p class="text-secondary-500">El endpoint respondió %v al evento de prueba</p>`, delivery.ResponseStatus.Int32)
+			return
+		}
+
+		w.Write([]byte(`<p class="text-secondary-500">No se pudo conectar con el endpoint</p>`))
+	}
+}
+
+// endpointOwner returns the user of an endpoint, none for the ones of
+// the organization
+func endpointOwner(userId string) sql.NullString {
+	return sql.NullString{String: userId, Valid: userId != ""}
+}
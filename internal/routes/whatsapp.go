@@ -0,0 +1,190 @@
+package routes
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/julienschmidt/httprouter"
+	"github.com/vladwithcode/juzgados/internal/auth"
+	"github.com/vladwithcode/juzgados/internal/bot"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/whatsapp"
+)
+
+// How long the bot has to answer a message, the case lookups read
+// several bulletins
+const botReplyTimeout = 2 * time.Minute
+
+func (h *Handler) RegisterWhatsAppRoutes(router *httprouter.Router) {
+	// Without the secret anyone could POST messages as any phone and run
+	// the bot commands of its user
+	if h.whatsapp.Config().AppSecret == "" {
+		fmt.Println("[WhatsApp err]: WHATSAPP_APP_SECRET is not set, the webhook is disabled")
+	} else {
+		router.GET("/api/whatsapp/webhook", h.VerifyWhatsAppWebhook)
+		router.POST("/api/whatsapp/webhook", h.ReceiveWhatsAppWebhook)
+	}
+
+	router.POST("/api/whatsapp/link", auth.WithAuthMiddleware(h.CreateWhatsAppLink))
+}
+
+// VerifyWhatsAppWebhook answers the challenge Meta sends when the webhook
+// is registered with WHATSAPP_VERIFY_TOKEN
+func (h *Handler) VerifyWhatsAppWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	query := r.URL.Query()
+
+	if !h.whatsapp.VerifySubscription(query.Get("hub.mode"), query.Get("hub.verify_token")) {
+		respondWithError(w, 403, "Token inválido")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, query.Get("hub.challenge"))
+}
+
+// ReceiveWhatsAppWebhook records the statuses of the messages sent and
+// answers the messages of the users with the bot
+func (h *Handler) ReceiveWhatsAppWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+
+	if err != nil {
+		respondWithError(w, 400, "No se pudo leer la petición")
+		return
+	}
+
+	if !h.whatsapp.VerifySignature(body, r.Header.Get("X-Hub-Signature-256")) {
+		respondWithError(w, 401, "Firma inválida")
+		return
+	}
+
+	event, err := whatsapp.ParseWebhook(body)
+
+	if err != nil {
+		respondWithError(w, 400, "Petición inválida")
+		return
+	}
+
+	for _, status := range event.Statuses {
+		errMsg := sql.NullString{String: status.Error, Valid: status.Error != ""}
+		err := h.store.WhatsApp.UpdateWhatsAppMessageStatus(r.Context(), status.MessageId, status.Status, status.At, errMsg)
+
+		// Meta retries the webhooks that don't get a 200
+		if err != nil {
+			fmt.Printf("[WhatsApp webhook err]: %v\n", err)
+			respondWithError(w, 500, "Error al guardar el estado")
+			return
+		}
+	}
+
+	// Meta expects a quick answer, the replies are sent afterwards
+	for _, msg := range event.Messages {
+		go h.answerWhatsApp(msg)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// answerWhatsApp replies to msg. Only the verified phones can use the
+// bot, the rest can only link themselves
+func (h *Handler) answerWhatsApp(msg whatsapp.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), botReplyTimeout)
+	defer cancel()
+
+	var reply string
+	cmd := bot.Parse(msg.Text)
+
+	if cmd.Name == bot.CmdLink {
+		reply = h.linkWhatsApp(ctx, msg.From, cmd)
+	} else {
+		user, err := h.store.Users.GetUserByPhone(ctx, msg.From)
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			reply = "Este número no está vinculado a ninguna cuenta. Entra a Notificaciones en tu panel y elige Vincular WhatsApp"
+		} else if err != nil {
+			fmt.Printf("[WhatsApp bot err]: %v\n", err)
+			reply = "Ocurrió un error, intenta más tarde"
+		} else {
+			reply = h.bot.Reply(ctx, user, cmd)
+		}
+	}
+
+	_, err := h.whatsapp.SendText(ctx, msg.From, reply)
+
+	if err != nil {
+		fmt.Printf("[WhatsApp reply err]: %v\n", err)
+	}
+}
+
+// linkWhatsApp makes phone the verified phone of the user who created
+// the code sent, see CreateWhatsAppLink
+func (h *Handler) linkWhatsApp(ctx context.Context, phone string, cmd bot.Command) string {
+	invalid := "El código no es válido o ya expiró, genera otro en la sección Notificaciones de tu panel"
+
+	if len(cmd.Args) == 0 {
+		return invalid
+	}
+
+	code, err := uuid.Parse(cmd.Args[0])
+
+	if err != nil {
+		return invalid
+	}
+
+	_, err = h.store.OTLinks.UsePhoneOTL(ctx, code, phone)
+
+	if err != nil {
+		var otlErr *db.NonExistentOTLError
+		if errors.As(err, &otlErr) {
+			return invalid
+		}
+
+		fmt.Printf("[WhatsApp link err]: %v\n", err)
+		return "Ocurrió un error al vincular tu número, intenta más tarde"
+	}
+
+	return "Listo, este número quedó vinculado a tu cuenta.\n\n" + bot.Help()
+}
+
+// CreateWhatsAppLink returns the message the user has to send from their
+// phone to link it, it expires with its code
+func (h *Handler) CreateWhatsAppLink(w http.ResponseWriter, r *http.Request, _ httprouter.Params, auth *auth.Auth) {
+	w.Header().Set("Content-Type", "text/html")
+
+	userUUID, err := uuid.Parse(auth.Id)
+
+	if err != nil {
+		fmt.Printf("Parse id err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrio un error inesperado"))
+		return
+	}
+
+	otl, err := h.store.OTLinks.CreateOTLink(r.Context(), userUUID, db.OTLActionPhone)
+
+	if err != nil {
+		fmt.Printf("Create OTL err: %v\n", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Ocurrio un error inesperado"))
+		return
+	}
+
+	text := bot.CmdLink + " " + otl.Code.String()
+	html := fmt.Sprintf("<p>Envía desde tu WhatsApp en los próximos 15 minutos el mensaje <b>%v</b>", template.HTMLEscapeString(text))
+
+	if phone := h.whatsapp.Config().BusinessPhone; phone != "" {
+		link := fmt.Sprintf("https://wa.me/%v?text=%v", strings.TrimPrefix(phone, "+"), url.PathEscape(text))
+		html += fmt.Sprintf(` al %v o <a class="underline" href="%v" target="_blank">ábrelo en WhatsApp</a>`, template.HTMLEscapeString(phone), template.HTMLEscapeString(link))
+	}
+
+	w.Write([]byte(html + "</p>"))
+}
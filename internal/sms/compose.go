@@ -0,0 +1,165 @@
+package sms
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// The encodings a message is sent with. GSM-7 fits 160 characters in a
+// segment, but a single character outside of it makes the whole message
+// UCS-2, which only fits 70
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// The units that fit in a single segment and in each segment of a longer
+// message, which loses some to the header that joins them
+const (
+	gsm7Single = 160
+	gsm7Part   = 153
+	ucs2Single = 70
+	ucs2Part   = 67
+)
+
+// The GSM 03.38 alphabet, every character takes a septet
+const gsm7Basic = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// The extension of the alphabet, every character takes an escape and a septet
+const gsm7Extension = "^{}\\[~]|€\f"
+
+// The characters people write that aren't in GSM-7, with the ones that
+// replace them. The accents lost are cheaper than a message in UCS-2
+var gsm7Replacer = strings.NewReplacer(
+	"–", "-", "—", "-", "‘", "'", "’", "'", "“", "\"", "”", "\"", "…", "...", "«", "\"", "»", "\"",
+	"á", "a", "í", "i", "ó", "o", "ú", "u", "Á", "A", "Í", "I", "Ó", "O", "Ú", "U",
+	"ê", "e", "â", "a", "ô", "o", "ç", "c", " ", " ", "\t", " ",
+)
+
+// septets returns the septets r takes in GSM-7, 0 when it isn't in it
+func septets(r rune) int {
+	if strings.ContainsRune(gsm7Basic, r) {
+		return 1
+	}
+
+	if strings.ContainsRune(gsm7Extension, r) {
+		return 2
+	}
+
+	return 0
+}
+
+// IsGSM7 reports whether text can be sent in GSM-7
+func IsGSM7(text string) bool {
+	for _, r := range text {
+		if septets(r) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToGSM7 replaces the characters of text that GSM-7 lacks with similar
+// ones it has, e.g. the dashes and the accents of á, í, ó and ú. Others,
+// like emojis, are left and still need UCS-2
+func ToGSM7(text string) string {
+	return gsm7Replacer.Replace(text)
+}
+
+// Segments returns the encoding text is sent with and how many segments
+// it takes. A character is never split between two segments
+func Segments(text string) (encoding string, segments int) {
+	units := []int{}
+	single, part := gsm7Single, gsm7Part
+	encoding = EncodingGSM7
+
+	if IsGSM7(text) {
+		for _, r := range text {
+			units = append(units, septets(r))
+		}
+	} else {
+		single, part = ucs2Single, ucs2Part
+		encoding = EncodingUCS2
+
+		for _, r := range text {
+			units = append(units, len(utf16.Encode([]rune{r})))
+		}
+	}
+
+	total := 0
+
+	for _, u := range units {
+		total += u
+	}
+
+	if total <= single {
+		return encoding, 1
+	}
+
+	segments, used := 1, 0
+
+	for _, u := range units {
+		if used+u > part {
+			segments++
+			used = 0
+		}
+
+		used += u
+	}
+
+	return encoding, segments
+}
+
+// Compose returns the message for subject and link in GSM-7 when it can,
+// shortening the subject so it fits in maxSegments. The link is never cut,
+// and footer, e.g. how to opt out, is only added when it takes no segment
+// more
+func Compose(subject, link, footer string, maxSegments int) string {
+	subject = strings.TrimSpace(ToGSM7(subject))
+	link = strings.TrimSpace(link)
+	footer = strings.TrimSpace(ToGSM7(footer))
+
+	join := func(subject string) string {
+		if link == "" {
+			return subject
+		}
+
+		if subject == "" {
+			return link
+		}
+
+		return subject + "\n" + link
+	}
+
+	text := join(subject)
+
+	if _, segments := Segments(text); segments > maxSegments {
+		runes := []rune(subject)
+
+		for len(runes) > 0 {
+			runes = runes[:len(runes)-1]
+			text = join(strings.TrimSpace(string(runes)) + "...")
+
+			if _, segments := Segments(text); segments <= maxSegments {
+				break
+			}
+		}
+
+		if len(runes) == 0 {
+			text = join("")
+		}
+	}
+
+	if footer != "" {
+		_, before := Segments(text)
+		_, after := Segments(text + "\n" + footer)
+
+		if after <= before {
+			text += "\n" + footer
+		}
+	}
+
+	return text
+}
@@ -0,0 +1,84 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fake keeps the messages instead of sending them and prints them to the
+// log, it's the provider of a new setup and of the tests. The phones can
+// opt out with the provider like in Twilio, see OptOut
+type Fake struct {
+	mu       sync.Mutex
+	messages []*FakeMessage
+	optedOut map[string]bool
+}
+
+// A FakeMessage is a message sent through a Fake
+type FakeMessage struct {
+	Id     string
+	To     string
+	Text   string
+	SentAt time.Time
+}
+
+func NewFake() *Fake {
+	return &Fake{optedOut: map[string]bool{}}
+}
+
+// OptOut makes the messages to phone fail with ErrOptedOut
+func (f *Fake) OptOut(phone string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.optedOut[phone] = true
+}
+
+// Messages returns the messages sent so far
+func (f *Fake) Messages() []*FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*FakeMessage{}, f.messages...)
+}
+
+func (f *Fake) Send(ctx context.Context, to, text string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.optedOut[to] {
+		return "", ErrOptedOut
+	}
+
+	msg := FakeMessage{
+		Id:     fmt.Sprintf("fake-%v", len(f.messages)+1),
+		To:     to,
+		Text:   text,
+		SentAt: time.Now(),
+	}
+	f.messages = append(f.messages, &msg)
+
+	encoding, segments := Segments(text)
+	log.Printf("[SMS] To: %v (%v, %v segments)\n%v\n", to, encoding, segments, text)
+
+	return msg.Id, nil
+}
+
+// ParseInbound takes the form a Gateway takes, without a token
+func (f *Fake) ParseInbound(r *http.Request) (*Inbound, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	return &Inbound{From: r.PostForm.Get("from"), Text: r.PostForm.Get("text")}, nil
+}
+
+func (f *Fake) WriteReply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"reply": text})
+}
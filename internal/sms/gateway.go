@@ -0,0 +1,119 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Gateway sends the messages through an HTTP gateway: it POSTs
+//
+//	{"to": "+526181234567", "from": "<From>", "text": "..."}
+//
+// to URL with the token as a bearer token, and takes any 2xx answer as
+// sent, reading the id of the message from {"id": "..."} if there is one.
+// The gateway POSTs the messages received to the inbound route the same
+// way, as JSON or a form with from and text, with the same token
+type Gateway struct {
+	URL   string
+	Token string
+	From  string
+	HTTP  *http.Client
+}
+
+// The body of the messages sent and received through a Gateway
+type gatewayMessage struct {
+	To   string `json:"to,omitempty"`
+	From string `json:"from,omitempty"`
+	Text string `json:"text"`
+}
+
+func (g *Gateway) Send(ctx context.Context, to, text string) (string, error) {
+	body, err := json.Marshal(gatewayMessage{To: to, From: g.From, Text: text})
+
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.URL, bytes.NewReader(body))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+
+	resp, err := g.HTTP.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &ProviderError{Status: resp.StatusCode, Message: strings.TrimSpace(string(respBody))}
+	}
+
+	// The id is optional, some gateways answer with plain text
+	message := struct {
+		Id string `json:"id"`
+	}{}
+	json.Unmarshal(respBody, &message)
+
+	return message.Id, nil
+}
+
+// ParseInbound checks the request carries the token, in the Authorization
+// header or the token parameter of the URL. Without a token every request
+// is refused
+func (g *Gateway) ParseInbound(r *http.Request) (*Inbound, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	if g.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(g.Token)) != 1 {
+		return nil, ErrUnauthorized
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == "application/json" {
+		message := gatewayMessage{}
+
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&message); err != nil {
+			return nil, err
+		}
+
+		return &Inbound{From: message.From, Text: message.Text}, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	return &Inbound{From: r.PostForm.Get("from"), Text: r.PostForm.Get("text")}, nil
+}
+
+// WriteReply answers with {"reply": text}, the gateway sends it back to
+// the phone unless it's empty
+func (g *Gateway) WriteReply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"reply": text})
+}
@@ -0,0 +1,47 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+)
+
+// What the people who reply to the messages can ask for
+const (
+	// Stop getting messages
+	KeywordStop = "stop"
+	// Get messages again, or for the first time
+	KeywordStart = "start"
+	KeywordHelp  = "help"
+)
+
+// The words of every keyword, in Spanish and the English ones the
+// carriers and Twilio already handle
+var keywords = map[string]string{
+	"BAJA":        KeywordStop,
+	"ALTO":        KeywordStop,
+	"CANCELAR":    KeywordStop,
+	"PARAR":       KeywordStop,
+	"STOP":        KeywordStop,
+	"STOPALL":     KeywordStop,
+	"UNSUBSCRIBE": KeywordStop,
+	"END":         KeywordStop,
+	"QUIT":        KeywordStop,
+	"ALTA":        KeywordStart,
+	"INICIO":      KeywordStart,
+	"START":       KeywordStart,
+	"UNSTOP":      KeywordStart,
+	"AYUDA":       KeywordHelp,
+	"INFO":        KeywordHelp,
+	"HELP":        KeywordHelp,
+}
+
+// ParseKeyword returns the keyword of a message received, empty when the
+// first word of text isn't one
+func ParseKeyword(text string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(text), " ")
+	word = strings.ToUpper(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}))
+
+	return keywords[word]
+}
@@ -0,0 +1,149 @@
+// Package sms sends text messages through a pluggable provider: Twilio,
+// any HTTP gateway that takes JSON, or a fake one that only keeps them.
+// It also reads the messages people send to the number, so they can opt
+// out with BAJA and back in with ALTA
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// The providers the messages can be sent with
+const (
+	// The Messages API of Twilio or a compatible one
+	ProviderTwilio = "twilio"
+	// POSTs {to, from, text} as JSON to Config.GatewayURL
+	ProviderGateway = "gateway"
+	// Keeps the messages in memory and prints them to the log
+	ProviderFake = "fake"
+)
+
+var (
+	// The provider refused the message because the phone opted out with
+	// the provider itself, e.g. replying STOP to a Twilio number
+	ErrOptedOut = errors.New("El teléfono pidió no recibir más mensajes")
+	// The request to the inbound route wasn't signed by the provider
+	ErrUnauthorized = errors.New("La petición no viene del proveedor de SMS")
+)
+
+// Config tells which provider sends the messages and how to reach it
+type Config struct {
+	Provider string
+	// The number or sender id the messages are sent from. For Twilio it
+	// can be the id of a messaging service (MG...)
+	From string
+	// The country code of the 10 digit phones, 52 by default
+	DefaultCountryCode string
+	// How many segments a message may take, they are charged one by one
+	MaxSegments int
+
+	TwilioURL        string
+	TwilioAccountSid string
+	TwilioAuthToken  string
+
+	GatewayURL string
+	// Sent as a bearer token to the gateway, which must send it back
+	// when it POSTs the messages received
+	GatewayToken string
+}
+
+// ConfigFromEnv reads the Config from SMS_PROVIDER, SMS_FROM,
+// SMS_COUNTRY_CODE, TWILIO_API_URL, TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN,
+// SMS_GATEWAY_URL and SMS_GATEWAY_TOKEN. Without SMS_PROVIDER the fake
+// provider is used, so a new setup never sends real messages by accident
+func ConfigFromEnv() *Config {
+	cfg := Config{
+		Provider:           envOr("SMS_PROVIDER", ProviderFake),
+		From:               os.Getenv("SMS_FROM"),
+		DefaultCountryCode: envOr("SMS_COUNTRY_CODE", "52"),
+		MaxSegments:        2,
+		TwilioURL:          envOr("TWILIO_API_URL", "https://api.twilio.com"),
+		TwilioAccountSid:   os.Getenv("TWILIO_ACCOUNT_SID"),
+		TwilioAuthToken:    os.Getenv("TWILIO_AUTH_TOKEN"),
+		GatewayURL:         os.Getenv("SMS_GATEWAY_URL"),
+		GatewayToken:       os.Getenv("SMS_GATEWAY_TOKEN"),
+	}
+
+	return &cfg
+}
+
+func envOr(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// A Provider sends the messages and reads the ones received
+type Provider interface {
+	// Send delivers text to the phone in E.164 and returns the id the
+	// provider gave to the message
+	Send(ctx context.Context, to, text string) (string, error)
+	// ParseInbound authenticates and reads a message received by the
+	// number, POSTed by the provider to the inbound route
+	ParseInbound(r *http.Request) (*Inbound, error)
+	// WriteReply answers the inbound request with text, which the
+	// provider sends back to the phone
+	WriteReply(w http.ResponseWriter, text string)
+}
+
+// An Inbound is a message sent to the number
+type Inbound struct {
+	// The phone as the provider sent it, usually E.164
+	From string
+	Text string
+}
+
+// NewProvider returns the Provider set in cfg
+func NewProvider(cfg *Config) (Provider, error) {
+	switch cfg.Provider {
+	case ProviderTwilio:
+		if cfg.TwilioAccountSid == "" || cfg.TwilioAuthToken == "" || cfg.From == "" {
+			return nil, errors.New("Faltan TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN o SMS_FROM")
+		}
+
+		return &Twilio{
+			BaseURL:    cfg.TwilioURL,
+			AccountSid: cfg.TwilioAccountSid,
+			AuthToken:  cfg.TwilioAuthToken,
+			From:       cfg.From,
+			HTTP:       defaultClient(),
+		}, nil
+	case ProviderGateway:
+		if cfg.GatewayURL == "" {
+			return nil, errors.New("Falta la URL del gateway de SMS (SMS_GATEWAY_URL)")
+		}
+
+		return &Gateway{
+			URL:   cfg.GatewayURL,
+			Token: cfg.GatewayToken,
+			From:  cfg.From,
+			HTTP:  defaultClient(),
+		}, nil
+	case ProviderFake:
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("Proveedor de SMS desconocido: %v", cfg.Provider)
+	}
+}
+
+// A ProviderError is a response of the provider that didn't take the message
+type ProviderError struct {
+	Status int
+	// The code of the error for the provider, if it has one
+	Code    int
+	Message string
+}
+
+func (e *ProviderError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("El proveedor de SMS respondió %v (%v): %v", e.Status, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("El proveedor de SMS respondió %v: %v", e.Status, e.Message)
+}
@@ -0,0 +1,195 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding string
+		segments int
+	}{
+		{"empty", "", EncodingGSM7, 1},
+		{"160 septets", strings.Repeat("a", 160), EncodingGSM7, 1},
+		{"161 septets", strings.Repeat("a", 161), EncodingGSM7, 2},
+		{"extension", strings.Repeat("€", 80), EncodingGSM7, 1},
+		{"extension not split", strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), EncodingGSM7, 3},
+		{"70 UCS-2", strings.Repeat("á", 70), EncodingUCS2, 1},
+		{"71 UCS-2", strings.Repeat("á", 71), EncodingUCS2, 2},
+		{"emoji", "👍", EncodingUCS2, 1},
+	}
+
+	for _, tt := range tests {
+		encoding, segments := Segments(tt.text)
+
+		if encoding != tt.encoding || segments != tt.segments {
+			t.Errorf("%v: got %v in %v segments, want %v in %v", tt.name, encoding, segments, tt.encoding, tt.segments)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	link := "https://example.com/alertas/1"
+
+	text := Compose("Nuevo acuerdo en el expediente 123/2024 — Civil", link, "Responde BAJA para no recibir más", 1)
+
+	if !IsGSM7(text) {
+		t.Errorf("%q isn't GSM-7", text)
+	}
+
+	if _, segments := Segments(text); segments != 1 {
+		t.Errorf("%q takes %v segments, want 1", text, segments)
+	}
+
+	if !strings.HasSuffix(text, link) && !strings.Contains(text, link+"\n") {
+		t.Errorf("%q lost the link", text)
+	}
+
+	long := Compose(strings.Repeat("Acuerdo largo ", 30), link, "Responde BAJA", 1)
+
+	if _, segments := Segments(long); segments != 1 {
+		t.Errorf("a long subject takes %v segments, want 1", segments)
+	}
+
+	if !strings.HasSuffix(long, "...\n"+link) {
+		t.Errorf("%q wasn't shortened before the link", long)
+	}
+}
+
+func TestParseKeyword(t *testing.T) {
+	tests := map[string]string{
+		"BAJA":           KeywordStop,
+		"  baja.":        KeywordStop,
+		"Stop por favor": KeywordStop,
+		"alta":           KeywordStart,
+		"¿Ayuda?":        KeywordHelp,
+		"Hola":           "",
+		"":               "",
+	}
+
+	for text, want := range tests {
+		if got := ParseKeyword(text); got != want {
+			t.Errorf("ParseKeyword(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+	provider, err := NewProvider(&Config{Provider: ProviderFake})
+
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+
+	fake := provider.(*Fake)
+	fake.OptOut("+526180000000")
+
+	id, err := fake.Send(context.Background(), "+526181234567", "Hola")
+
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if messages := fake.Messages(); len(messages) != 1 || messages[0].Id != id || messages[0].To != "+526181234567" {
+		t.Errorf("got %v messages, want the one sent with id %v", len(messages), id)
+	}
+
+	if _, err := fake.Send(context.Background(), "+526180000000", "Hola"); !errors.Is(err, ErrOptedOut) {
+		t.Errorf("opted out phone: got %v, want ErrOptedOut", err)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"twilio without credentials", Config{Provider: ProviderTwilio}},
+		{"gateway without URL", Config{Provider: ProviderGateway}},
+		{"unknown", Config{Provider: "pigeon"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewProvider(&tt.cfg); err == nil {
+			t.Errorf("%v: got no error", tt.name)
+		}
+	}
+}
+
+// The example of the documentation of Twilio about its signatures
+func TestTwilioSignature(t *testing.T) {
+	twilio := Twilio{AuthToken: "12345"}
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+
+	if got := twilio.signature("https://mycompany.com/myapp.php?foo=1&bar=2", params); got != "0/KCTR6DLpKmkAf8muzZqo1nDgQ=" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestTwilioParseInbound(t *testing.T) {
+	twilio := Twilio{AuthToken: "12345"}
+	params := url.Values{"From": {"+526181234567"}, "Body": {"BAJA"}}
+
+	newRequest := func(signature string) *http.Request {
+		r := httptest.NewRequest("POST", "https://example.com/api/sms/inbound", strings.NewReader(params.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Twilio-Signature", signature)
+
+		return r
+	}
+
+	inbound, err := twilio.ParseInbound(newRequest(twilio.signature("https://example.com/api/sms/inbound", params)))
+
+	if err != nil || inbound.From != "+526181234567" || inbound.Text != "BAJA" {
+		t.Errorf("got %+v, %v, want BAJA from +526181234567", inbound, err)
+	}
+
+	if _, err := twilio.ParseInbound(newRequest("forged")); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("forged signature: got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestGatewayParseInbound(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		err    error
+	}{
+		{"valid", "secret", "Bearer secret", nil},
+		{"other token", "secret", "Bearer other", ErrUnauthorized},
+		{"without token", "", "", ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		gateway := Gateway{Token: tt.token}
+		r := httptest.NewRequest("POST", "/api/sms/inbound", strings.NewReader(`{"from":"+526181234567","text":"ALTA"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", tt.header)
+
+		inbound, err := gateway.ParseInbound(r)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: got %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+
+		if err == nil && (inbound.From != "+526181234567" || inbound.Text != "ALTA") {
+			t.Errorf("%v: got %+v, want ALTA from +526181234567", tt.name, inbound)
+		}
+	}
+}
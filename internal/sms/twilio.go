@@ -0,0 +1,162 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The error of Twilio for the phones that replied STOP
+const twilioUnsubscribed = 21610
+
+func defaultClient() *http.Client {
+	return &http.Client{Timeout: 15 * time.Second}
+}
+
+// Twilio sends the messages with the Messages API of Twilio. Any provider
+// with the same API works by changing BaseURL
+type Twilio struct {
+	BaseURL    string
+	AccountSid string
+	AuthToken  string
+	// A phone in E.164 or the id of a messaging service (MG...)
+	From string
+	HTTP *http.Client
+}
+
+func (t *Twilio) Send(ctx context.Context, to, text string) (string, error) {
+	form := url.Values{"To": {to}, "Body": {text}}
+
+	if strings.HasPrefix(t.From, "MG") {
+		form.Set("MessagingServiceSid", t.From)
+	} else {
+		form.Set("From", t.From)
+	}
+
+	endpoint := fmt.Sprintf("%v/2010-04-01/Accounts/%v/Messages.json", strings.TrimSuffix(t.BaseURL, "/"), url.PathEscape(t.AccountSid))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSid, t.AuthToken)
+
+	resp, err := t.HTTP.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(body, &apiErr)
+
+		if apiErr.Code == twilioUnsubscribed {
+			return "", ErrOptedOut
+		}
+
+		if apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+
+		return "", &ProviderError{Status: resp.StatusCode, Code: apiErr.Code, Message: apiErr.Message}
+	}
+
+	message := struct {
+		Sid string `json:"sid"`
+	}{}
+
+	if err := json.Unmarshal(body, &message); err != nil {
+		return "", fmt.Errorf("Respuesta inválida de Twilio: %w", err)
+	}
+
+	return message.Sid, nil
+}
+
+// ParseInbound checks the X-Twilio-Signature of the request, made with
+// the auth token over its URL and its parameters
+func (t *Twilio) ParseInbound(r *http.Request) (*Inbound, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	signature := r.Header.Get("X-Twilio-Signature")
+
+	// Behind a proxy the scheme Twilio used isn't known, the webhook is
+	// usually set with https
+	valid := false
+
+	for _, scheme := range []string{"https", "http"} {
+		fullURL := scheme + "://" + r.Host + r.URL.RequestURI()
+
+		if hmac.Equal([]byte(signature), []byte(t.signature(fullURL, r.PostForm))) {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return nil, ErrUnauthorized
+	}
+
+	return &Inbound{From: r.PostForm.Get("From"), Text: r.PostForm.Get("Body")}, nil
+}
+
+// signature returns the signature Twilio sends for a request to fullURL
+// with params: the base64 HMAC-SHA1 of the URL followed by every
+// parameter and its value, sorted by name
+func (t *Twilio) signature(fullURL string, params url.Values) string {
+	names := make([]string, 0, len(params))
+
+	for name := range params {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	mac := hmac.New(sha1.New, []byte(t.AuthToken))
+	mac.Write([]byte(fullURL))
+
+	for _, name := range names {
+		for _, value := range params[name] {
+			mac.Write([]byte(name + value))
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// WriteReply answers with TwiML, without a message when text is empty
+func (t *Twilio) WriteReply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "text/xml")
+
+	if text == "" {
+		fmt.Fprint(w, xml.Header+"<Response></Response>")
+		return
+	}
+
+	fmt.Fprint(w, xml.Header+"<Response><Message>")
+	xml.EscapeText(w, []byte(text))
+	fmt.Fprint(w, "</Message></Response>")
+}
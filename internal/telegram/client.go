@@ -0,0 +1,189 @@
+// Package telegram sends messages through the Telegram Bot API and reads
+// the updates it POSTs to the webhook of the app
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// The token of the bot is missing
+var ErrNotConfigured = errors.New("Telegram no está configurado, falta TELEGRAM_BOT_TOKEN")
+
+type Config struct {
+	// The Bot API, https://api.telegram.org unless it's a fake
+	BaseURL string
+	Token   string
+	// The username of the bot without the @, used for the links that
+	// open a chat with it
+	Username string
+	// Sent by Telegram in every webhook request when set with SetWebhook,
+	// the webhook is refused without it
+	WebhookSecret string
+}
+
+// ConfigFromEnv reads the Config from the TELEGRAM_* variables
+func ConfigFromEnv() *Config {
+	baseURL := os.Getenv("TELEGRAM_API_URL")
+
+	if baseURL == "" {
+		baseURL = "https://api.telegram.org"
+	}
+
+	return &Config{
+		BaseURL:       baseURL,
+		Token:         os.Getenv("TELEGRAM_BOT_TOKEN"),
+		Username:      strings.TrimPrefix(os.Getenv("TELEGRAM_BOT_USERNAME"), "@"),
+		WebhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+	}
+}
+
+// An APIError is an error returned by the Bot API
+type APIError struct {
+	Code        int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Telegram respondió %v: %v", e.Code, e.Description)
+}
+
+// A Client calls the methods of the bot of the Config
+type Client struct {
+	cfg  Config
+	HTTP *http.Client
+}
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: *cfg, HTTP: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Configured reports whether the client has what it needs to send
+func (c *Client) Configured() bool {
+	return c.cfg.Token != ""
+}
+
+// StartLink returns the link that opens a chat with the bot and sends it
+// "/start payload", empty when the username of the bot is unknown
+func (c *Client) StartLink(payload string) string {
+	if c.cfg.Username == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("https://t.me/%v?start=%v", c.cfg.Username, payload)
+}
+
+// The body of every response of the Bot API
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// call runs method with params and decodes its result into result
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	reqBody, err := json.Marshal(params)
+
+	if err != nil {
+		return err
+	}
+
+	reqUrl := fmt.Sprintf("%v/bot%v/%v", strings.TrimSuffix(c.cfg.BaseURL, "/"), c.cfg.Token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqUrl, bytes.NewReader(reqBody))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+
+	if err != nil {
+		// The URL has the token, it must not end in the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("Telegram %v: %w", method, urlErr.Err)
+		}
+
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if err != nil {
+		return err
+	}
+
+	var parsed apiResponse
+
+	if err := json.Unmarshal(respBody, &parsed); err != nil {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &APIError{Code: resp.StatusCode, Description: strings.TrimSpace(string(respBody))}
+		}
+
+		return fmt.Errorf("Respuesta inválida de Telegram: %w", err)
+	}
+
+	if !parsed.Ok {
+		return &APIError{Code: parsed.ErrorCode, Description: parsed.Description}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(parsed.Result, result)
+}
+
+// SendMessage sends text to the chat, formatted as the HTML Telegram
+// takes (see FormatText), and returns the id of the message
+func (c *Client) SendMessage(ctx context.Context, chatId int64, text string) (int64, error) {
+	var sent struct {
+		MessageId int64 `json:"message_id"`
+	}
+
+	err := c.call(ctx, "sendMessage", map[string]any{
+		"chat_id":                  chatId,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}, &sent)
+
+	return sent.MessageId, err
+}
+
+// SetWebhook makes Telegram POST the updates of the bot to webhookUrl, with the
+// WebhookSecret of the Config
+func (c *Client) SetWebhook(ctx context.Context, webhookUrl string) error {
+	if c.cfg.WebhookSecret == "" {
+		return errors.New("Falta TELEGRAM_WEBHOOK_SECRET, sin él no se aceptan los mensajes del bot")
+	}
+
+	params := map[string]any{
+		"url":             webhookUrl,
+		"allowed_updates": []string{"message"},
+		"secret_token":    c.cfg.WebhookSecret,
+	}
+
+	return c.call(ctx, "setWebhook", params, nil)
+}
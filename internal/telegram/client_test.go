@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakeClient(t *testing.T, secret string) (*Client, *FakeAPI) {
+	t.Helper()
+
+	api := NewFakeAPI("123:abc")
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client := NewClient(&Config{BaseURL: srv.URL, Token: "123:abc", Username: "juzgados_bot", WebhookSecret: secret})
+
+	return client, api
+}
+
+func TestSendMessage(t *testing.T) {
+	client, api := newFakeClient(t, "")
+
+	id, err := client.SendMessage(context.Background(), 42, FormatText("*Nuevo acuerdo* en 123/2024"))
+
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	messages := api.Messages()
+
+	if len(messages) != 1 || messages[0].Id != id || messages[0].ChatId != 42 || messages[0].ParseMode != "HTML" {
+		t.Fatalf("got %v messages, want the one sent to 42 with id %v", len(messages), id)
+	}
+
+	if messages[0].Text != "<b>Nuevo acuerdo</b> en 123/2024" {
+		t.Errorf("sent %q", messages[0].Text)
+	}
+}
+
+func TestSendMessageBlocked(t *testing.T) {
+	client, api := newFakeClient(t, "")
+	api.Block(42)
+
+	_, err := client.SendMessage(context.Background(), 42, "Hola")
+	apiErr := &APIError{}
+
+	if !errors.As(err, &apiErr) || apiErr.Code != 403 {
+		t.Errorf("got %v, want a 403 APIError", err)
+	}
+}
+
+func TestSendMessageNotConfigured(t *testing.T) {
+	client := NewClient(&Config{})
+
+	if _, err := client.SendMessage(context.Background(), 42, "Hola"); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("got %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestSetWebhook(t *testing.T) {
+	client, api := newFakeClient(t, "")
+
+	if err := client.SetWebhook(context.Background(), "https://example.com/api/telegram/webhook"); err == nil {
+		t.Errorf("set the webhook without a secret")
+	}
+
+	client, api = newFakeClient(t, "secret")
+
+	if err := client.SetWebhook(context.Background(), "https://example.com/api/telegram/webhook"); err != nil {
+		t.Fatalf("SetWebhook: %v", err)
+	}
+
+	if api.Webhook() != "https://example.com/api/telegram/webhook" {
+		t.Errorf("got webhook %q", api.Webhook())
+	}
+}
+
+func TestVerifySecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		header string
+		want   bool
+	}{
+		{"secret", "secret", true},
+		{"secret", "other", false},
+		{"secret", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		client := NewClient(&Config{WebhookSecret: tt.secret})
+
+		if got := client.VerifySecret(tt.header); got != tt.want {
+			t.Errorf("VerifySecret(%q) with %q = %v, want %v", tt.header, tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestParseUpdate(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+
+	msg, err := ParseUpdate(FakeUpdate(7, 42, "ana", "/start abc", at))
+
+	if err != nil {
+		t.Fatalf("ParseUpdate: %v", err)
+	}
+
+	if msg == nil || msg.ChatId != 42 || msg.Username != "ana" || msg.Text != "/start abc" || !msg.At.Equal(at) {
+		t.Errorf("got %+v, want /start abc from ana in 42", msg)
+	}
+
+	msg, err = ParseUpdate([]byte(`{"update_id":8,"edited_message":{}}`))
+
+	if err != nil || msg != nil {
+		t.Errorf("an update without a message: got %+v, %v, want nil", msg, err)
+	}
+}
+
+func TestStartLink(t *testing.T) {
+	client := NewClient(&Config{Username: "juzgados_bot"})
+
+	if got := client.StartLink("abc"); got != "https://t.me/juzgados_bot?start=abc" {
+		t.Errorf("got %q", got)
+	}
+
+	if got := NewClient(&Config{}).StartLink("abc"); got != "" {
+		t.Errorf("without a username: got %q, want empty", got)
+	}
+}
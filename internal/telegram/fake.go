@@ -0,0 +1,150 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeAPI imitates the methods of the Bot API used by the client so it
+// can run without a real bot: point TELEGRAM_API_URL to a server with
+// it. It keeps every message sent and the webhook set
+type FakeAPI struct {
+	Token string
+
+	mu       sync.Mutex
+	messages []*FakeMessage
+	webhook  string
+	// Chats that blocked the bot, see Block
+	blocked map[int64]bool
+}
+
+// A FakeMessage is a message sent through a FakeAPI
+type FakeMessage struct {
+	Id        int64
+	ChatId    int64
+	Text      string
+	ParseMode string
+	SentAt    time.Time
+}
+
+func NewFakeAPI(token string) *FakeAPI {
+	return &FakeAPI{Token: token, blocked: map[int64]bool{}}
+}
+
+// Block makes the messages to chatId fail like when the user blocks the bot
+func (f *FakeAPI) Block(chatId int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.blocked[chatId] = true
+}
+
+// Messages returns the messages sent so far
+func (f *FakeAPI) Messages() []*FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*FakeMessage{}, f.messages...)
+}
+
+// Webhook returns the URL set with setWebhook
+func (f *FakeAPI) Webhook() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.webhook
+}
+
+func (f *FakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /bot<token>/<method>
+	token, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+
+	if token != f.Token {
+		fakeReply(w, 401, nil, "Unauthorized")
+		return
+	}
+
+	var params struct {
+		ChatId    int64  `json:"chat_id"`
+		Text      string `json:"text"`
+		ParseMode string `json:"parse_mode"`
+		Url       string `json:"url"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		fakeReply(w, 400, nil, "Bad Request: invalid JSON")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch method {
+	case "sendMessage":
+		if params.ChatId == 0 || params.Text == "" {
+			fakeReply(w, 400, nil, "Bad Request: chat_id and text are required")
+			return
+		}
+
+		if f.blocked[params.ChatId] {
+			fakeReply(w, 403, nil, "Forbidden: bot was blocked by the user")
+			return
+		}
+
+		msg := FakeMessage{
+			Id:        int64(len(f.messages) + 1),
+			ChatId:    params.ChatId,
+			Text:      params.Text,
+			ParseMode: params.ParseMode,
+			SentAt:    time.Now(),
+		}
+		f.messages = append(f.messages, &msg)
+
+		fakeReply(w, 200, map[string]any{
+			"message_id": msg.Id,
+			"chat":       map[string]any{"id": msg.ChatId},
+			"date":       msg.SentAt.Unix(),
+			"text":       msg.Text,
+		}, "")
+	case "setWebhook":
+		f.webhook = params.Url
+		fakeReply(w, 200, true, "")
+	default:
+		fakeReply(w, 404, nil, "Not Found: method not found")
+	}
+}
+
+func fakeReply(w http.ResponseWriter, status int, result any, description string) {
+	body := map[string]any{"ok": status == 200}
+
+	if status == 200 {
+		body["result"] = result
+	} else {
+		body["error_code"] = status
+		body["description"] = description
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// FakeUpdate returns the body Telegram POSTs to the webhook when the user
+// with username writes text in the chat
+func FakeUpdate(updateId, chatId int64, username, text string, at time.Time) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"update_id": updateId,
+		"message": map[string]any{
+			"message_id": updateId,
+			"date":       at.Unix(),
+			"text":       text,
+			"chat":       map[string]any{"id": chatId, "type": "private"},
+			"from":       map[string]any{"id": chatId, "username": username},
+		},
+	})
+
+	return body
+}
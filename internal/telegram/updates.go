@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"html"
+	"regexp"
+	"time"
+)
+
+// A Message written to the bot
+type Message struct {
+	Id     int64
+	ChatId int64
+	// The username of the sender without the @, it may have none
+	Username string
+	Text     string
+	At       time.Time
+}
+
+// The update POSTed to the webhook, only with the fields used
+type update struct {
+	UpdateId int64 `json:"update_id"`
+	Message  *struct {
+		MessageId int64  `json:"message_id"`
+		Date      int64  `json:"date"`
+		Text      string `json:"text"`
+		Chat      struct {
+			Id   int64  `json:"id"`
+			Type string `json:"type"`
+		} `json:"chat"`
+		From struct {
+			Username string `json:"username"`
+		} `json:"from"`
+	} `json:"message"`
+}
+
+// ParseUpdate reads the body of a webhook request, it returns nil for
+// the updates that aren't messages
+func ParseUpdate(body []byte) (*Message, error) {
+	var u update
+
+	if err := json.Unmarshal(body, &u); err != nil {
+		return nil, err
+	}
+
+	if u.Message == nil {
+		return nil, nil
+	}
+
+	return &Message{
+		Id:       u.Message.MessageId,
+		ChatId:   u.Message.Chat.Id,
+		Username: u.Message.From.Username,
+		Text:     u.Message.Text,
+		At:       time.Unix(u.Message.Date, 0),
+	}, nil
+}
+
+// VerifySecret reports whether header, the X-Telegram-Bot-Api-Secret-Token
+// of a webhook request, is the WebhookSecret. Without one every request
+// is refused
+func (c *Client) VerifySecret(header string) bool {
+	if c.cfg.WebhookSecret == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(header), []byte(c.cfg.WebhookSecret)) == 1
+}
+
+var boldExp = regexp.MustCompile(`\*([^*\n]+)\*`)
+
+// FormatText escapes text for the HTML parse mode, turning the *bold*
+// of the WhatsApp format into <b>bold</b>
+func FormatText(text string) string {
+	return boldExp.ReplaceAllString(html.EscapeString(text), "<b>$1</b>")
+}
@@ -0,0 +1,18 @@
+package internal
+
+import (
+	"os"
+	"time"
+)
+
+// EnvDuration reads a duration like "30s" or "15m" from the environment
+// variable key, returning def when it's unset or invalid
+func EnvDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+
+	if err != nil || d <= 0 {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,61 @@
+package tsj
+
+import (
+	"regexp"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+// The Salas publish the tocas with their own numbering, the
+// expediente of the lower court is only mentioned in the accord
+var originCaseExp = regexp.MustCompile(`(expediente|exp\.|juicio|autos)(?: numero| num\.?| no\.?)?\s*(\d+/\d{4})`)
+
+// An AppealOrigin is the lower court case a toca was formed from
+type AppealOrigin struct {
+	NatureCode string
+	CaseId     string
+	// Whether the entry names the court and a single expediente, called
+	// as such. The rest are only suggestions
+	Confident bool
+}
+
+// IsSala reports whether natureCode belongs to one of the Salas,
+// where the appeals (tocas) are resolved
+func IsSala(natureCode string) bool {
+	return natureCode == "seccc" || natureCode == "seccu"
+}
+
+// DetectAppealOrigin extracts the court and expediente a toca comes from
+// out of its entry in a Sala bulletin. It returns nil for docs of other
+// courts and when no expediente is mentioned, the court is left empty
+// when the entry doesn't name it
+func DetectAppealOrigin(doc *db.Doc) *AppealOrigin {
+	if !IsSala(doc.NatureCode) {
+		return nil
+	}
+
+	text := foldText(doc.Nature + " " + doc.Accord)
+	matches := originCaseExp.FindAllStringSubmatch(text, -1)
+
+	if matches == nil {
+		return nil
+	}
+
+	origin := AppealOrigin{
+		NatureCode: lowerCourtFromText(text),
+		CaseId:     db.TrimField(matches[0][2]),
+	}
+
+	single := true
+	for _, m := range matches[1:] {
+		if db.TrimField(m[2]) != origin.CaseId {
+			single = false
+		}
+	}
+
+	// "juicio" and "autos" also introduce the numbers of other cases
+	explicit := matches[0][1] == "expediente" || matches[0][1] == "exp."
+	origin.Confident = explicit && single && origin.NatureCode != ""
+
+	return &origin
+}
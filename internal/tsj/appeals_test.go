@@ -0,0 +1,68 @@
+package tsj
+
+import (
+	"testing"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+func TestDetectAppealOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		natureCode string
+		accord     string
+		// nil when no origin must be detected
+		want *AppealOrigin
+	}{
+		{
+			name:       "named court and expediente",
+			natureCode: "seccu",
+			accord:     "Se radica el recurso de apelación contra la sentencia dictada en el expediente 84/2023 del Juzgado Segundo Civil.",
+			want:       &AppealOrigin{NatureCode: "civ2", CaseId: "84/2023", Confident: true},
+		},
+		{
+			name:       "expediente without court",
+			natureCode: "seccu",
+			accord:     "Se radica el recurso de apelación interpuesto en el expediente 84/2023.",
+			want:       &AppealOrigin{CaseId: "84/2023"},
+		},
+		{
+			name:       "autos aren't always the origin",
+			natureCode: "seccc",
+			accord:     "Se tienen a la vista los autos 12/2022 remitidos por el Juzgado Primero Familiar.",
+			want:       &AppealOrigin{NatureCode: "fam1", CaseId: "12/2022"},
+		},
+		{
+			name:       "more than one expediente",
+			natureCode: "seccu",
+			accord:     "Se acumula al expediente 84/2023 el expediente 90/2023 del Juzgado Segundo Civil.",
+			want:       &AppealOrigin{NatureCode: "civ2", CaseId: "84/2023"},
+		},
+		{
+			name:       "no expediente",
+			natureCode: "seccu",
+			accord:     "Se señala fecha para la audiencia de alegatos.",
+		},
+		{
+			name:       "not a Sala",
+			natureCode: "civ1",
+			accord:     "Se admite la demanda en el expediente 84/2023 del Juzgado Segundo Civil.",
+		},
+	}
+
+	for _, tt := range tests {
+		got := DetectAppealOrigin(&db.Doc{Case: "45/2024", NatureCode: tt.natureCode, Accord: tt.accord})
+
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("%v: got %+v, want nil", tt.name, got)
+			}
+
+			continue
+		}
+
+		if got == nil || *got != *tt.want {
+			t.Errorf("%v: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
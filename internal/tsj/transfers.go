@@ -0,0 +1,148 @@
+package tsj
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+// A Transfer describes a case being sent to another court, as detected
+// from the text of an accord. ToNatureCode and ToCaseId are empty when
+// the accord doesn't name them
+type Transfer struct {
+	ToNatureCode string
+	ToCaseId     string
+	// The fragment of the accord where the transfer was detected
+	Excerpt string
+	// Whether the accord orders the remission and names both the court
+	// and the number the case got there. The rest are only suggestions
+	Confident bool
+}
+
+var (
+	spacesExp = regexp.MustCompile(`\s+`)
+
+	// Phrases used by the courts when the autos leave the court: a
+	// remission to a court or Sala in the same sentence, or a declined
+	// competence. The sentence goes on past abbreviations like "no."
+	remissionExp = regexp.MustCompile(`(?:(?:se remite|se remiten|remitase|remitanse|se ordena remitir|se envia|se envian|enviese|envianse)(?:\b(?:no|num|h)\.|[^.]){0,120}?(?:al juzgado|a la (?:h\. )?sala|al (?:h\. )?tribunal)|se declina|declina (?:la )?competencia|por incompetencia)(?:\b(?:no|num|h)\.|[^.]){0,250}`)
+
+	ordinals = map[string]string{
+		"primero": "1", "primer": "1", "1o": "1",
+		"segundo": "2", "2o": "2",
+		"tercero": "3", "tercer": "3", "3o": "3",
+		"cuarto": "4", "4o": "4",
+		"quinto": "5", "5o": "5",
+	}
+
+	courtExp   = regexp.MustCompile(`juzgado (primero|primer|segundo|tercero|tercer|cuarto|quinto|[1-5]o)(?: de lo| en materia| del ramo)? (auxiliar|civil|familiar|mercantil)`)
+	specialExp = regexp.MustCompile(`juzgado (primero|primer|segundo|[12]o)[a-z ]{0,30}especializad`)
+	oralExp    = regexp.MustCompile(`juzgado (?:de lo )?(?:mercantil oral|oral mercantil|oral de lo mercantil)`)
+	salaExp    = regexp.MustCompile(`sala civil (colegiada|unitaria)`)
+	laborExp   = regexp.MustCompile(`tribunal laboral`)
+	tocaExp    = regexp.MustCompile(`toca(?: numero| num\.?| no\.?)?\s*(\d+/\d{4})`)
+	// The number the case got in the court it was sent to, never the
+	// expediente it leaves
+	targetCaseExp = regexp.MustCompile(`(?:radicad[oa]|registrad[oa]|formad[oa])(?: con el| bajo el)? (?:numero|num\.?|no\.?)\s*(\d+/\d{4})`)
+)
+
+var materiaCodes = map[string]string{
+	"auxiliar":  "aux",
+	"civil":     "civ",
+	"familiar":  "fam",
+	"mercantil": "mer",
+}
+
+// foldText lowercases and removes accents from s keeping the
+// punctuation, so case numbers like 84/2003 stay intact
+func foldText(s string) string {
+	return spacesExp.ReplaceAllString(strings.ToLower(internal.FoldAccents(s)), " ")
+}
+
+// CourtFromText returns the nature code of the first court mentioned in text
+func CourtFromText(text string) string {
+	text = foldText(text)
+
+	if m := salaExp.FindStringSubmatch(text); m != nil {
+		if m[1] == "colegiada" {
+			return "seccc"
+		}
+		return "seccu"
+	}
+
+	return lowerCourtFromText(text)
+}
+
+// lowerCourtFromText works like CourtFromText ignoring the Salas,
+// text must be already folded
+func lowerCourtFromText(text string) string {
+	if m := specialExp.FindStringSubmatch(text); m != nil {
+		return "cjmf" + ordinals[m[1]]
+	}
+
+	if oralExp.MatchString(text) {
+		return "merOral"
+	}
+
+	if laborExp.MatchString(text) {
+		return "trib1"
+	}
+
+	if m := courtExp.FindStringSubmatch(text); m != nil {
+		code := materiaCodes[m[2]] + ordinals[m[1]]
+
+		if _, ok := internal.CodesMap[code]; ok {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// DetectTransfer looks for a remission of the autos to another court in
+// the accord of doc. It returns nil when the accord doesn't mention one or
+// when the court it mentions is the one the doc belongs to
+func DetectTransfer(doc *db.Doc) *Transfer {
+	text := foldText(doc.Accord)
+	excerpt := remissionExp.FindString(text)
+	remission := excerpt != ""
+
+	if !remission {
+		// Appeals are admitted "en ambos efectos" and the toca is formed in the Sala
+		if !tocaExp.MatchString(text) || !strings.Contains(text, "sala") {
+			return nil
+		}
+
+		excerpt = text
+	}
+
+	transfer := Transfer{
+		ToNatureCode: CourtFromText(excerpt),
+		Excerpt:      strings.TrimSpace(excerpt),
+	}
+
+	// The whole accord names the court of the doc too, only a Sala is
+	// taken from it
+	if !remission && !IsSala(transfer.ToNatureCode) {
+		transfer.ToNatureCode = ""
+	}
+
+	if transfer.ToNatureCode != "" && transfer.ToNatureCode == doc.NatureCode {
+		return nil
+	}
+
+	m := tocaExp.FindStringSubmatch(excerpt)
+	if m == nil {
+		m = targetCaseExp.FindStringSubmatch(excerpt)
+	}
+
+	if m != nil && db.TrimField(m[1]) != db.TrimField(doc.Case) {
+		transfer.ToCaseId = db.TrimField(m[1])
+	}
+
+	transfer.Confident = remission && transfer.ToNatureCode != "" && transfer.ToCaseId != ""
+
+	return &transfer
+}
@@ -0,0 +1,85 @@
+package tsj
+
+import (
+	"testing"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+func TestDetectTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		natureCode string
+		accord     string
+		// nil when no transfer must be detected
+		want *Transfer
+	}{
+		{
+			name:       "named court and number",
+			natureCode: "civ1",
+			accord:     "Se declara incompetente y se ordena remitir los autos al Juzgado Segundo Civil, donde quedó radicado con el No. 45/2024.",
+			want:       &Transfer{ToNatureCode: "civ2", ToCaseId: "45/2024", Confident: true},
+		},
+		{
+			name:       "court without number",
+			natureCode: "civ1",
+			accord:     "Remítanse los autos al Juzgado Primero Familiar para su conocimiento.",
+			want:       &Transfer{ToNatureCode: "fam1"},
+		},
+		{
+			name:       "the expediente it leaves isn't the destination",
+			natureCode: "civ1",
+			accord:     "Remítase el expediente 123/2024 al Juzgado Tercero Mercantil.",
+			want:       &Transfer{ToNatureCode: "mer3"},
+		},
+		{
+			name:       "appeal with its toca",
+			natureCode: "civ1",
+			accord:     "Se admite el recurso en ambos efectos, fórmese el toca 12/2024 en la Sala Civil Unitaria.",
+			want:       &Transfer{ToNatureCode: "seccu", ToCaseId: "12/2024"},
+		},
+		{
+			name:       "a toca mentioned without its Sala isn't guessed",
+			natureCode: "civ1",
+			accord:     "Agréguese el oficio de la sala relativo al toca 12/2024 del Juzgado Primero Civil.",
+			want:       &Transfer{ToCaseId: "12/2024"},
+		},
+		{
+			name:       "generic remission",
+			natureCode: "civ1",
+			accord:     "Se ordena remitir copia certificada a la parte actora y se envía el oficio correspondiente.",
+		},
+		{
+			name:       "toca as another word",
+			natureCode: "civ1",
+			accord:     "En lo que toca a la sala de audiencias, se señala nueva fecha.",
+		},
+		{
+			name:       "the same court",
+			natureCode: "civ2",
+			accord:     "Remítanse los autos al Juzgado Segundo Civil.",
+		},
+	}
+
+	for _, tt := range tests {
+		got := DetectTransfer(&db.Doc{Case: "123/2024", NatureCode: tt.natureCode, Accord: tt.accord})
+
+		if tt.want == nil {
+			if got != nil {
+				t.Errorf("%v: got %+v, want nil", tt.name, got)
+			}
+
+			continue
+		}
+
+		if got == nil {
+			t.Errorf("%v: got nil, want %+v", tt.name, tt.want)
+			continue
+		}
+
+		if got.ToNatureCode != tt.want.ToNatureCode || got.ToCaseId != tt.want.ToCaseId || got.Confident != tt.want.Confident {
+			t.Errorf("%v: got %v %v %v, want %v %v %v", tt.name,
+				got.ToNatureCode, got.ToCaseId, got.Confident, tt.want.ToNatureCode, tt.want.ToCaseId, tt.want.Confident)
+		}
+	}
+}
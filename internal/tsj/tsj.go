@@ -1,6 +1,7 @@
 package tsj
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,7 +10,6 @@ import (
 	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/vladwithcode/juzgados/internal"
 	"github.com/vladwithcode/juzgados/internal/db"
 	"github.com/vladwithcode/juzgados/internal/reader"
 )
@@ -32,23 +32,40 @@ func (e *NotFoundError) Error() string {
 	return e.Msg
 }
 
-// For optimal doc search, this struct holds information about the cases pending search
-// as well as the Docs generated for the cases found.
-// This way, multiple case searches can be done on the same file fetched from TSJ
-// avoiding multiple net requests for the same file
-// PendingCases maps in the form of [caseType]: [caseKey1, caseKey2, ..., caseKeyN]
-// e.g. [mer2]: [12/2003, 45/2006]
-type MultiCaseSearch struct {
-	PendingCases map[string][]string
+type GetCasesResult struct {
 	Docs         []*db.Doc
+	NotFoundKeys []string
+	// How many bulletins were read and how many couldn't be fetched
+	BulletinsFetched int
+	FetchErrors      int
+	// The last error of every court whose bulletins couldn't be read
+	// on any of the dates searched
+	CourtErrors map[string]string
+	mux         sync.Mutex
+}
 
-	mux sync.Mutex
+func (r *GetCasesResult) addBulletin() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.BulletinsFetched++
 }
 
-type GetCasesResult struct {
-	Docs         []*db.Doc
-	NotFoundKeys []string
-	mux          sync.Mutex
+// addFetchError counts a failed fetch, the error is kept for the
+// court while none of its bulletins could be read
+func (r *GetCasesResult) addFetchError(court string, err error, keep bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.FetchErrors++
+
+	if keep {
+		r.CourtErrors[court] = err.Error()
+	}
+}
+
+func (r *GetCasesResult) clearCourtError(court string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.CourtErrors, court)
 }
 
 func (r *GetCasesResult) AppendCase(caseDoc *db.Doc) {
@@ -67,7 +84,7 @@ func GenRegExp(caseId string) (*regexp.Regexp, error) {
 	return regexp.Compile(fmt.Sprintf(`(?m)^(\d+.+%v[^\n]+(?:[\n][^\d].*)+)`, caseId))
 }
 
-func GetCaseData(caseId, caseType string, searchDate *time.Time, daysBack int) (*db.Doc, error) {
+func GetCaseData(ctx context.Context, caseId, caseType string, searchDate *time.Time, daysBack int) (*db.Doc, error) {
 	var localDate time.Time
 
 	if searchDate == nil {
@@ -80,9 +97,13 @@ func GetCaseData(caseId, caseType string, searchDate *time.Time, daysBack int) (
 	var err error
 
 	for i := 0; i <= daysBack; i++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		y, m, d := localDate.Date()
 		date := fmt.Sprintf("%d%d%d", d, m, y)
-		data, err = FetchAndReadDoc(caseId, date, caseType)
+		data, err = FetchAndReadDoc(ctx, caseId, date, caseType)
 
 		if data != nil {
 			break
@@ -109,7 +130,7 @@ func GetCaseData(caseId, caseType string, searchDate *time.Time, daysBack int) (
 	return doc, nil
 }
 
-func GetCasesDataV1(caseKeys []string, daysBack uint, startDate time.Time) (*GetCasesResult, error) {
+func GetCasesDataV1(ctx context.Context, caseKeys []string, daysBack uint, startDate time.Time) (*GetCasesResult, error) {
 	result := GetCasesResult{
 		Docs:         []*db.Doc{},
 		NotFoundKeys: []string{},
@@ -124,7 +145,7 @@ func GetCasesDataV1(caseKeys []string, daysBack uint, startDate time.Time) (*Get
 			params := strings.Split(cK, "+")
 			caseId, caseType := params[0], params[1]
 
-			doc, err := GetCaseData(caseId, caseType, &startDate, int(daysBack))
+			doc, err := GetCaseData(ctx, caseId, caseType, &startDate, int(daysBack))
 
 			if err != nil {
 				result.AppendNotFound(cK)
@@ -137,7 +158,7 @@ func GetCasesDataV1(caseKeys []string, daysBack uint, startDate time.Time) (*Get
 
 	wg.Wait()
 
-	return &result, nil
+	return &result, ctx.Err()
 }
 
 // This approach attempts to improve efficency
@@ -146,26 +167,76 @@ func GetCasesDataV1(caseKeys []string, daysBack uint, startDate time.Time) (*Get
 // date and caseType once and then executing
 // all the searches for the pending case Ids
 // GetCasesDataV2
-func GetCasesData(caseKeys []string, daysBack uint, startDate time.Time) (*GetCasesResult, error) {
-	searchData := MultiCaseSearch{
-		PendingCases: genCaseMap(caseKeys),
+func GetCasesData(ctx context.Context, caseKeys []string, daysBack uint, startDate time.Time) (*GetCasesResult, error) {
+	since := map[string]time.Time{}
+	oldest := startOfDay(startDate).AddDate(0, 0, -int(daysBack))
+
+	for _, cK := range caseKeys {
+		since[cK] = oldest
+	}
+
+	return GetCasesDataSince(ctx, since, startDate)
+}
+
+// GetCasesDataSince works like GetCasesData but searches every case
+// (case_id+nature_code) of since only in the bulletins from startDate
+// back to its own day, each bulletin is still fetched once. The cases
+// of the courts whose bulletins couldn't be read aren't reported as not
+// found, their court is in CourtErrors
+func GetCasesDataSince(ctx context.Context, since map[string]time.Time, startDate time.Time) (*GetCasesResult, error) {
+	result := GetCasesResult{
+		Docs:         []*db.Doc{},
+		NotFoundKeys: []string{},
+		CourtErrors:  map[string]string{},
 	}
 	wg := sync.WaitGroup{}
 
-	for cType, cIds := range searchData.PendingCases {
+	caseKeys := []string{}
+	for cK := range since {
+		caseKeys = append(caseKeys, cK)
+	}
+
+	for cType, cIds := range genCaseMap(caseKeys) {
+		// The first day searched for every case of the court
+		firstDays := map[string]time.Time{}
+		oldest := startOfDay(startDate)
+
+		for _, cId := range cIds {
+			day := startOfDay(since[fmt.Sprintf("%v+%v", cId, cType)])
+			firstDays[cId] = day
+
+			if day.Before(oldest) {
+				oldest = day
+			}
+		}
+
 		wg.Add(1)
-		go func(cType string, cIds []string, startDate time.Time, daysBack uint) {
+		go func(cType string, cIds []string, startDate time.Time) {
 			defer wg.Done()
-			var pendingIds = internal.Set{}
-			iDaysBack := int(daysBack)
+			fetched := false
+			notFound := []string{}
+
+			for !startOfDay(startDate).Before(oldest) && len(cIds) > 0 && ctx.Err() == nil {
+				// The cases whose days were all searched are done
+				pendingIds := []string{}
+
+				for _, cId := range cIds {
+					if firstDays[cId].After(startOfDay(startDate)) {
+						notFound = append(notFound, cId)
+					} else {
+						pendingIds = append(pendingIds, cId)
+					}
+				}
 
-			for i := 0; i <= iDaysBack; i++ {
-				tsjFile, err := reader.Reader(startDate.Format("212006"), cType)
+				cIds = pendingIds
+				tsjFile, err := reader.Reader(ctx, startDate.Format("212006"), cType)
 
 				// When an error is found, skip to next try
 				if err != nil {
+					result.addFetchError(cType, err, !fetched)
+
 					// Only print errors for the last try
-					if i == iDaysBack {
+					if !startOfDay(startDate).After(oldest) {
 						fmt.Printf("[%v on date %v] Failed to find file: %v\n", cType, startDate.Format("02/01/06"), err)
 					}
 
@@ -173,50 +244,64 @@ func GetCasesData(caseKeys []string, daysBack uint, startDate time.Time) (*GetCa
 					continue
 				}
 
-				if tsjFile != nil {
-					for _, cId := range cIds {
-						searchExp, _ := GenRegExp(cId)
-						idxs := searchExp.FindIndex(*tsjFile)
-
-						if idxs == nil {
-							if !pendingIds.Contains(cId) {
-								pendingIds.Add(cId)
-							}
-							continue
-						}
+				if !fetched {
+					fetched = true
+					result.clearCourtError(cType)
+				}
 
-						start, end := idxs[0], idxs[1]
+				result.addBulletin()
+				pendingIds = []string{}
 
-						doc := DataToDoc((*tsjFile)[start:end])
-						doc.Case = strings.TrimSpace(cId)
-						doc.NatureCode = cType
-						doc.AccordDate = startDate
+				for _, cId := range cIds {
+					searchExp, _ := GenRegExp(cId)
+					idxs := searchExp.FindIndex(*tsjFile)
 
-						searchData.mux.Lock()
-						searchData.Docs = append(searchData.Docs, doc)
-						searchData.mux.Unlock()
+					if idxs == nil {
+						pendingIds = append(pendingIds, cId)
+						continue
 					}
-				}
 
-				if len(pendingIds) == 0 {
-					break
+					start, end := idxs[0], idxs[1]
+
+					doc := DataToDoc((*tsjFile)[start:end])
+					doc.Case = strings.TrimSpace(cId)
+					doc.NatureCode = cType
+					doc.AccordDate = startDate
+
+					result.AppendCase(doc)
 				}
 
-				cIds = pendingIds.Elements()
+				// Only the cases not found yet are searched in older bulletins
+				cIds = pendingIds
 				startDate = startDate.AddDate(0, 0, -1)
 			}
-		}(cType, cIds, startDate, daysBack)
+
+			// A cancelled search doesn't say anything about the cases left,
+			// nor does one where no bulletin could be read
+			if ctx.Err() != nil || !fetched {
+				return
+			}
+
+			for _, cId := range append(notFound, cIds...) {
+				result.AppendNotFound(fmt.Sprintf("%v+%v", strings.TrimSpace(cId), cType))
+			}
+		}(cType, cIds, startDate)
 	}
 
 	wg.Wait()
 
-	return &GetCasesResult{
-		Docs: searchData.Docs,
-	}, nil
+	// The docs found before a cancellation are returned along the error
+	return &result, ctx.Err()
+}
+
+// startOfDay returns the midnight that starts the day of t
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
 }
 
-func FetchAndReadDoc(caseId, searchDate, caseType string) ([]byte, error) {
-	pdfContent, err := reader.Reader(searchDate, caseType)
+func FetchAndReadDoc(ctx context.Context, caseId, searchDate, caseType string) ([]byte, error) {
+	pdfContent, err := reader.Reader(ctx, searchDate, caseType)
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,126 @@
+package tsj
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal"
+	"github.com/vladwithcode/juzgados/internal/db"
+	"github.com/vladwithcode/juzgados/internal/reader"
+)
+
+// Every entry in a bulletin starts with its index number at the start of
+// a line, the lines that follow it (until the next index) belong to it
+var entryExp = regexp.MustCompile(`(?m)^\d+[^\n]+(?:\n[^\d\n][^\n]*)*`)
+
+// SplitEntries returns every entry found in the text of a bulletin
+func SplitEntries(data []byte) [][]byte {
+	return entryExp.FindAll(data, -1)
+}
+
+// BulletinDocs returns a Doc for every case entry in the text of the
+// bulletin published by the court natureCode on date
+func BulletinDocs(data []byte, natureCode string, date time.Time) []*db.Doc {
+	docs := []*db.Doc{}
+
+	for _, entry := range SplitEntries(data) {
+		doc := DataToDoc(entry)
+
+		if doc.Case == "" {
+			continue
+		}
+
+		doc.NatureCode = natureCode
+		doc.AccordDate = date
+		docs = append(docs, doc)
+	}
+
+	return docs
+}
+
+// MatchWatches fetches the bulletins for every court a watch applies to,
+// from startDate going back daysBack days, and returns a WatchMatch for
+// every entry that matches the pattern of a watch
+func MatchWatches(ctx context.Context, watches []*db.Watch, startDate time.Time, daysBack uint) ([]*db.WatchMatch, error) {
+	courts := internal.Set{}
+
+	for _, w := range watches {
+		if len(w.NatureCodes) == 0 {
+			for code := range internal.CodesMap {
+				courts.Add(code)
+			}
+			break
+		}
+
+		for _, code := range w.NatureCodes {
+			courts.Add(code)
+		}
+	}
+
+	var (
+		matches []*db.WatchMatch
+		mux     sync.Mutex
+		wg      sync.WaitGroup
+	)
+
+	for _, cType := range courts.Elements() {
+		wg.Add(1)
+		go func(cType string) {
+			defer wg.Done()
+			date := startDate
+
+			for i := 0; i <= int(daysBack) && ctx.Err() == nil; i++ {
+				tsjFile, err := reader.Reader(ctx, date.Format("212006"), cType)
+
+				if err != nil {
+					// Most days won't have a bulletin for every court
+					date = date.AddDate(0, 0, -1)
+					continue
+				}
+
+				found := matchEntries(watches, *tsjFile, cType, date)
+
+				mux.Lock()
+				matches = append(matches, found...)
+				mux.Unlock()
+
+				date = date.AddDate(0, 0, -1)
+			}
+		}(cType)
+	}
+
+	wg.Wait()
+
+	return matches, ctx.Err()
+}
+
+func matchEntries(watches []*db.Watch, data []byte, cType string, date time.Time) []*db.WatchMatch {
+	var matches []*db.WatchMatch
+
+	for _, entry := range SplitEntries(data) {
+		doc := DataToDoc(entry)
+		text := fmt.Sprintf("%v %v %v", doc.Case, doc.Nature, doc.Accord)
+
+		for _, w := range watches {
+			if !w.AppliesTo(cType) || !w.Matches(text) {
+				continue
+			}
+
+			matches = append(matches, &db.WatchMatch{
+				WatchId:    w.Id,
+				UserId:     w.UserId,
+				CaseId:     doc.Case,
+				NatureCode: cType,
+				Nature:     doc.Nature,
+				Accord:     doc.Accord,
+				AccordDate: date,
+				Pattern:    w.Pattern,
+			})
+		}
+	}
+
+	return matches
+}
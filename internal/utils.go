@@ -20,6 +20,17 @@ var MONTHS = [12]string{
 	"Diciembre",
 }
 
+// Indexed by time.Weekday, sunday first
+var WEEKDAYS = [7]string{
+	"Domingo",
+	"Lunes",
+	"Martes",
+	"Miércoles",
+	"Jueves",
+	"Viernes",
+	"Sábado",
+}
+
 func FormatDate(date time.Time) string {
 	var (
 		d    int    = date.Day()
@@ -0,0 +1,98 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// The endpoints can't be in the network of the server, or anyone could
+// make it send requests to the services only it can reach
+var ErrBlockedAddress = errors.New("El endpoint no puede apuntar a una dirección privada o local")
+
+// The shared address space of the carriers (RFC 6598), not covered by
+// net.IP.IsPrivate
+var sharedSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// blockedIP reports whether ip is loopback, private, link-local or any
+// other address that isn't on the internet
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || sharedSpace.Contains(ip)
+}
+
+// ValidateURL checks raw is an https URL whose host resolves only to
+// public addresses. The addresses are checked again when every request
+// is made, the host may resolve to others later
+func ValidateURL(ctx context.Context, raw string) error {
+	parsed, err := url.Parse(raw)
+
+	if err != nil || parsed.Scheme != "https" || parsed.Hostname() == "" {
+		return errors.New("La URL debe comenzar con https://")
+	}
+
+	host := parsed.Hostname()
+
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return ErrBlockedAddress
+		}
+
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("No se encontró el servidor %v", host)
+	}
+
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+
+	return nil
+}
+
+// dialControl refuses the connections to blocked addresses, it runs after
+// the host is resolved so it can't be dodged with DNS
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return ErrBlockedAddress
+	}
+
+	return nil
+}
+
+// newClient returns the client the Sender uses: it only connects to
+// public addresses, never through a proxy, and doesn't follow redirects
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialControl}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
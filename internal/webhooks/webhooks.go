@@ -0,0 +1,202 @@
+// Package webhooks POSTs the events of the users to the endpoints they
+// and the organization set up, signed so the receivers can tell the
+// requests come from the app
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+// The headers of every request
+const (
+	// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">", the key
+	// is the secret of the endpoint
+	SignatureHeader = "X-Juzgados-Signature"
+	EventHeader     = "X-Juzgados-Event"
+	// The id of the delivery, the same in every retry
+	DeliveryHeader = "X-Juzgados-Delivery"
+)
+
+// How old a signature Verify takes by default
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	ErrBadSignature = errors.New("La firma del webhook no es válida")
+	ErrExpired      = errors.New("La firma del webhook expiró")
+)
+
+// The body POSTed to the endpoints
+type Payload struct {
+	// The id of the event, the same for every endpoint that gets it
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewSecret returns a random secret for a new endpoint
+func NewSecret() (string, error) {
+	key := make([]byte, 24)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Sign returns the value of the SignatureHeader of body sent at at
+func Sign(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	return fmt.Sprintf("t=%v,v1=%v", timestamp, signature(secret, timestamp, body))
+}
+
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the SignatureHeader of a request received at now, like
+// the receivers of the webhooks should. A signature older than tolerance
+// fails with ErrExpired, so a request can't be replayed later
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	signatures := []string{}
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil || len(signatures) == 0 {
+		return ErrBadSignature
+	}
+
+	expected := signature(secret, timestamp, body)
+	valid := false
+
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return ErrBadSignature
+	}
+
+	if now.Sub(time.Unix(sentAt, 0)) > tolerance {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// A ResponseError is the response of an endpoint that didn't take the
+// event. Its body is left out, the users see the error and must not read
+// what a server answers through the app
+type ResponseError struct {
+	Status int
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("El endpoint respondió %v", e.Status)
+}
+
+// A Sender POSTs the deliveries to their endpoints
+type Sender struct {
+	Client *http.Client
+	// Used instead of time.Now when set
+	Now func() time.Time
+}
+
+func NewSender() *Sender {
+	return &Sender{Client: newClient()}
+}
+
+// Send POSTs delivery to endpoint and returns the status of the response,
+// 0 when there was none. Any status but 2xx fails with a *ResponseError
+func (s *Sender) Send(ctx context.Context, endpoint *db.WebhookEndpoint, delivery *db.WebhookDelivery) (int, error) {
+	data := delivery.Payload
+
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+
+	body, err := json.Marshal(Payload{
+		Id:        delivery.EventId,
+		Type:      delivery.Event,
+		CreatedAt: delivery.CreatedAt,
+		Data:      data,
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	// The endpoints saved before https was required
+	if !strings.HasPrefix(endpoint.Url, "https://") {
+		return 0, errors.New("La URL del endpoint debe comenzar con https://")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(body))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "juzgados-webhooks/1")
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, now, body))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.Id)
+
+	resp, err := s.Client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &ResponseError{Status: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,129 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vladwithcode/juzgados/internal/db"
+)
+
+func TestVerify(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+	header := Sign("whsec_1", at, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+		err    error
+	}{
+		{"valid", "whsec_1", header, string(body), at.Add(time.Minute), nil},
+		{"other secret", "whsec_2", header, string(body), at, ErrBadSignature},
+		{"other body", "whsec_1", header, `{"id":"2"}`, at, ErrBadSignature},
+		{"without v1", "whsec_1", "t=1700000000", string(body), at, ErrBadSignature},
+		{"expired", "whsec_1", header, string(body), at.Add(DefaultTolerance + time.Second), ErrExpired},
+	}
+
+	for _, tt := range tests {
+		err := Verify(tt.secret, tt.header, []byte(tt.body), tt.now, DefaultTolerance)
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		blocked bool
+		valid   bool
+	}{
+		{"https://93.184.215.14/hook", false, true},
+		{"http://93.184.215.14/hook", false, false},
+		{"https://127.0.0.1/hook", true, false},
+		{"https://10.0.0.8/hook", true, false},
+		{"https://100.64.1.1/hook", true, false},
+		{"https://169.254.169.254/latest/meta-data", true, false},
+		{"https://[::1]/hook", true, false},
+		{"https://[::ffff:127.0.0.1]/hook", true, false},
+		{"https://0.0.0.0/hook", true, false},
+		{"ftp://example.com", false, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateURL(context.Background(), tt.url)
+
+		if tt.valid != (err == nil) || tt.blocked != errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("ValidateURL(%q) = %v", tt.url, err)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	var got *http.Request
+	var body []byte
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+
+		if r.Header.Get(EventHeader) == db.WebhookAlertDeleted {
+			http.Error(w, "internal details", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	sender := Sender{Client: srv.Client(), Now: func() time.Time { return at }}
+	endpoint := db.WebhookEndpoint{Url: srv.URL, Secret: "whsec_1"}
+	delivery := db.WebhookDelivery{
+		Id:      "d1",
+		Event:   db.WebhookAccordNew,
+		EventId: "e1",
+		Payload: json.RawMessage(`{"alertId":"a1"}`),
+	}
+
+	status, err := sender.Send(context.Background(), &endpoint, &delivery)
+
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("Send: got %v, %v, want 200", status, err)
+	}
+
+	if got.Header.Get(DeliveryHeader) != "d1" || got.Header.Get(EventHeader) != db.WebhookAccordNew {
+		t.Errorf("got headers %v", got.Header)
+	}
+
+	if err := Verify("whsec_1", got.Header.Get(SignatureHeader), body, at, DefaultTolerance); err != nil {
+		t.Errorf("the signature doesn't verify: %v", err)
+	}
+
+	delivery.Event = db.WebhookAlertDeleted
+	status, err = sender.Send(context.Background(), &endpoint, &delivery)
+	respErr := &ResponseError{}
+
+	if !errors.As(err, &respErr) || status != http.StatusInternalServerError {
+		t.Errorf("failed endpoint: got %v, %v, want a ResponseError with 500", status, err)
+	}
+}
+
+func TestSendBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	endpoint := db.WebhookEndpoint{Url: srv.URL, Secret: "whsec_1"}
+	_, err := NewSender().Send(context.Background(), &endpoint, &db.WebhookDelivery{Id: "d1"})
+
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("got %v, want ErrBlockedAddress", err)
+	}
+}
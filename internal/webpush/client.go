@@ -0,0 +1,255 @@
+// Package webpush sends notifications to the browsers subscribed with the
+// Push API. The messages are encrypted for every subscription (RFC 8291)
+// and the requests are signed with the VAPID keys of the app (RFC 8292)
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// The VAPID keys are missing
+	ErrNotConfigured = errors.New("Web Push no está configurado, faltan VAPID_PUBLIC_KEY y VAPID_PRIVATE_KEY")
+	// The browser unsubscribed or the subscription expired, it must be deleted
+	ErrGone = errors.New("La suscripción del navegador ya no existe")
+)
+
+// The Urgency of a message, the push services may hold the ones with low
+// urgency until the device is charging or on wifi
+const (
+	UrgencyNormal = "normal"
+	UrgencyHigh   = "high"
+)
+
+type Config struct {
+	// The keys as base64url without padding, like GenerateKeys returns
+	// them. The browsers get the public one to subscribe
+	PublicKey  string
+	PrivateKey string
+	// How the push services can reach the operator of the app, a mailto:
+	// or https: URL, the site by default
+	Subject string
+}
+
+// ConfigFromEnv reads the Config from VAPID_PUBLIC_KEY, VAPID_PRIVATE_KEY
+// and VAPID_SUBJECT
+func ConfigFromEnv() *Config {
+	subject := os.Getenv("VAPID_SUBJECT")
+
+	if subject == "" && os.Getenv("TSJ_SITE_HOSTNAME") != "" {
+		subject = "https://" + os.Getenv("TSJ_SITE_HOSTNAME")
+	}
+
+	return &Config{
+		PublicKey:  os.Getenv("VAPID_PUBLIC_KEY"),
+		PrivateKey: os.Getenv("VAPID_PRIVATE_KEY"),
+		Subject:    subject,
+	}
+}
+
+// GenerateKeys returns a new pair of VAPID keys for the Config
+func GenerateKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return encode(key.PublicKey().Bytes()), encode(key.Bytes()), nil
+}
+
+// A Subscription is what PushSubscription.toJSON() returns in the browser
+type Subscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		// The public key of the browser, a point of P-256
+		P256dh string `json:"p256dh"`
+		// The secret shared with the browser, 16 bytes
+		Auth string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Validate checks the endpoint is a URL and the keys have the right size
+func (s *Subscription) Validate() error {
+	endpoint, err := url.Parse(s.Endpoint)
+
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		return errors.New("El endpoint de la suscripción no es válido")
+	}
+
+	if key, err := decode(s.Keys.P256dh); err != nil || len(key) != 65 {
+		return errors.New("La llave p256dh de la suscripción no es válida")
+	}
+
+	if secret, err := decode(s.Keys.Auth); err != nil || len(secret) != 16 {
+		return errors.New("El secreto auth de la suscripción no es válido")
+	}
+
+	return nil
+}
+
+// A PushError is a response of a push service that didn't take the message
+type PushError struct {
+	Status int
+	Body   string
+}
+
+func (e *PushError) Error() string {
+	return fmt.Sprintf("El servicio de push respondió %v: %v", e.Status, e.Body)
+}
+
+// A Client sends messages signed with the keys of its Config
+type Client struct {
+	cfg  Config
+	HTTP *http.Client
+}
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: *cfg, HTTP: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Configured reports whether the client has the keys to send
+func (c *Client) Configured() bool {
+	return c.cfg.PublicKey != "" && c.cfg.PrivateKey != ""
+}
+
+// signingKey returns the private key of the Config for ES256
+func (c *Client) signingKey() (*ecdsa.PrivateKey, error) {
+	raw, err := decode(c.cfg.PrivateKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+
+	key, err := ecdh.P256().NewPrivateKey(raw)
+
+	if err != nil {
+		return nil, fmt.Errorf("VAPID_PRIVATE_KEY: %w", err)
+	}
+
+	// The uncompressed point: 0x04 || x || y
+	point := key.PublicKey().Bytes()
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(point[1:33]),
+			Y:     new(big.Int).SetBytes(point[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}, nil
+}
+
+// authorization returns the VAPID Authorization header for a request to
+// endpoint, valid for 12 hours
+func (c *Client) authorization(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+
+	if err != nil {
+		return "", err
+	}
+
+	key, err := c.signingKey()
+
+	if err != nil {
+		return "", err
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": c.cfg.Subject,
+	}).SignedString(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("vapid t=%v, k=%v", token, c.cfg.PublicKey), nil
+}
+
+// Send encrypts payload for sub and hands it to its push service, which
+// keeps it up to ttl while the browser is offline. It fails with ErrGone
+// when the subscription doesn't exist anymore
+func (c *Client) Send(ctx context.Context, sub *Subscription, payload []byte, ttl time.Duration, urgency string) error {
+	if !c.Configured() {
+		return ErrNotConfigured
+	}
+
+	body, err := Encrypt(sub, payload)
+
+	if err != nil {
+		return err
+	}
+
+	auth, err := c.authorization(sub.Endpoint)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+	req.Header.Set("Authorization", auth)
+
+	if urgency != "" {
+		req.Header.Set("Urgency", urgency)
+	}
+
+	resp, err := c.HTTP.Do(req)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return ErrGone
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+		return &PushError{Status: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+	}
+
+	return nil
+}
+
+// The keys travel as base64url, the browsers leave the padding out
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
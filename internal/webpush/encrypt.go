@@ -0,0 +1,195 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The size of the single record every message is sent in. The payload,
+// its delimiter and the tag of AES-GCM must fit in it
+const recordSize = 4096
+
+// The header of the aes128gcm body: salt, record size, key id length
+// and the public key of the server as key id
+const headerSize = 16 + 4 + 1 + 65
+
+// The largest payload Encrypt takes. Push services only take bodies of
+// up to 4096 bytes, header included
+const MaxPayload = recordSize - headerSize - 16 - 1
+
+var ErrPayloadTooLarge = fmt.Errorf("El mensaje no puede tener más de %v bytes", MaxPayload)
+
+// Encrypt returns the body of the request that delivers payload to the
+// browser of sub, encrypted with the aes128gcm content coding of RFC 8188
+// as RFC 8291 tells
+func Encrypt(sub *Subscription, payload []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// A new key for every message, the browser gets its public half in
+	// the header of the body
+	serverKey, err := ecdh.P256().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return encrypt(sub, payload, salt, serverKey)
+}
+
+func encrypt(sub *Subscription, payload, salt []byte, serverKey *ecdh.PrivateKey) ([]byte, error) {
+	if len(payload) > MaxPayload {
+		return nil, ErrPayloadTooLarge
+	}
+
+	rawKey, err := decode(sub.Keys.P256dh)
+
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+
+	authSecret, err := decode(sub.Keys.Auth)
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+
+	browserKey, err := ecdh.P256().NewPublicKey(rawKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("p256dh: %w", err)
+	}
+
+	sharedSecret, err := serverKey.ECDH(browserKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	serverPublic := serverKey.PublicKey().Bytes()
+
+	// The input keying material mixes the shared secret with the secret
+	// of the subscription: "WebPush: info" || 0x00 || ua_public || as_public
+	keyInfo := append([]byte("WebPush: info\x00"), rawKey...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdfExpand(hkdfExtract(authSecret, sharedSecret), keyInfo, 32)
+
+	prk := hkdfExtract(salt, ikm)
+	contentKey := hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(contentKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The only record is the last one, its delimiter is 0x02
+	record := append(append([]byte{}, payload...), 0x02)
+
+	// Header: salt || record size || key id length || key id (as_public)
+	body := make([]byte, 0, 16+4+1+len(serverPublic)+len(record)+gcm.Overhead())
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(serverPublic)))
+	body = append(body, serverPublic...)
+
+	return gcm.Seal(body, nonce, record, nil), nil
+}
+
+// decrypt reads a body made by Encrypt with the keys of the browser, the
+// fake push service uses it to check what the app sends
+func decrypt(body []byte, browserKey *ecdh.PrivateKey, authSecret []byte) ([]byte, error) {
+	if len(body) < 21 {
+		return nil, errors.New("El cuerpo es demasiado corto")
+	}
+
+	salt := body[:16]
+	keyLen := int(body[20])
+
+	if len(body) < 21+keyLen {
+		return nil, errors.New("El cuerpo es demasiado corto")
+	}
+
+	serverPublic := body[21 : 21+keyLen]
+	serverKey, err := ecdh.P256().NewPublicKey(serverPublic)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sharedSecret, err := browserKey.ECDH(serverKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), browserKey.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, serverPublic...)
+	ikm := hkdfExpand(hkdfExtract(authSecret, sharedSecret), keyInfo, 32)
+
+	prk := hkdfExtract(salt, ikm)
+	block, err := aes.NewCipher(hkdfExpand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16))
+
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := gcm.Open(nil, hkdfExpand(prk, []byte("Content-Encoding: nonce\x00"), 12), body[21+keyLen:], nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the padding and the delimiter
+	for i := len(record) - 1; i >= 0; i-- {
+		if record[i] == 0x02 {
+			return record[:i], nil
+		}
+
+		if record[i] != 0 {
+			break
+		}
+	}
+
+	return nil, errors.New("El registro no tiene delimitador")
+}
+
+// hkdfExtract and hkdfExpand are HKDF with SHA-256 (RFC 5869)
+func hkdfExtract(salt, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+
+	return mac.Sum(nil)
+}
+
+// hkdfExpand only makes up to 32 bytes, one block, which is all Web Push needs
+func hkdfExpand(prk, info []byte, length int) []byte {
+	mac := hmac.New(sha256.New, prk)
+	mac.Write(info)
+	mac.Write([]byte{0x01})
+
+	return mac.Sum(nil)[:length]
+}
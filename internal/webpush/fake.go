@@ -0,0 +1,188 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FakeService imitates a push service and the browsers subscribed to it
+// so the messages can be checked without a browser: serve it with TLS,
+// make subscriptions with Subscribe and read what they got with Messages.
+// It decrypts every message and checks its VAPID signature like a real
+// service would
+type FakeService struct {
+	mu sync.Mutex
+	// The keys of the browsers by the path of their endpoint
+	browsers map[string]*fakeBrowser
+	messages []*FakeMessage
+}
+
+type fakeBrowser struct {
+	key    *ecdh.PrivateKey
+	auth   []byte
+	active bool
+}
+
+// A FakeMessage is a message received by a FakeService, decrypted
+type FakeMessage struct {
+	Endpoint string
+	Payload  []byte
+	TTL      int
+	Urgency  string
+}
+
+func NewFakeService() *FakeService {
+	return &FakeService{browsers: map[string]*fakeBrowser{}}
+}
+
+// Subscribe returns a new subscription with an endpoint in baseURL, the
+// URL the service is served at
+func (f *FakeService) Subscribe(baseURL string) (*Subscription, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	browser := fakeBrowser{key: key, auth: make([]byte, 16), active: true}
+
+	if _, err := rand.Read(browser.auth); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := fmt.Sprintf("/push/%v", len(f.browsers)+1)
+	f.browsers[path] = &browser
+
+	sub := Subscription{Endpoint: strings.TrimSuffix(baseURL, "/") + path}
+	sub.Keys.P256dh = encode(key.PublicKey().Bytes())
+	sub.Keys.Auth = encode(browser.auth)
+
+	return &sub, nil
+}
+
+// Unsubscribe makes the service answer 410 Gone to the messages for sub,
+// like when the user revokes the permission
+func (f *FakeService) Unsubscribe(sub *Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for path, browser := range f.browsers {
+		if strings.HasSuffix(sub.Endpoint, path) {
+			browser.active = false
+		}
+	}
+}
+
+// Messages returns the messages received so far
+func (f *FakeService) Messages() []*FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*FakeMessage{}, f.messages...)
+}
+
+func (f *FakeService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	browser, ok := f.browsers[r.URL.Path]
+
+	if !ok || !browser.active {
+		http.Error(w, "push subscription has unsubscribed or expired", http.StatusGone)
+		return
+	}
+
+	if err := checkVapid(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		http.Error(w, "unsupported content encoding", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Like the real services, which only take up to 4096 bytes
+	if len(body) > recordSize {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	payload, err := decrypt(body, browser.key, browser.auth)
+
+	if err != nil {
+		http.Error(w, "decrypt: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ttl, _ := strconv.Atoi(r.Header.Get("TTL"))
+
+	f.messages = append(f.messages, &FakeMessage{
+		Endpoint: r.URL.Path,
+		Payload:  payload,
+		TTL:      ttl,
+		Urgency:  r.Header.Get("Urgency"),
+	})
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// checkVapid verifies the "vapid t=<jwt>, k=<public key>" Authorization
+// of a request
+func checkVapid(r *http.Request) error {
+	params := map[string]string{}
+
+	for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "vapid "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = value
+	}
+
+	rawKey, err := decode(params["k"])
+
+	if err != nil || len(rawKey) != 65 {
+		return fmt.Errorf("invalid vapid key")
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(rawKey[1:33]),
+		Y:     new(big.Int).SetBytes(rawKey[33:]),
+	}
+
+	token, err := jwt.Parse(params["t"], func(t *jwt.Token) (any, error) {
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithExpirationRequired())
+
+	if err != nil {
+		return err
+	}
+
+	aud, _ := token.Claims.GetAudience()
+
+	if len(aud) != 1 || aud[0] != "https://"+r.Host {
+		return fmt.Errorf("invalid audience %v", aud)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := decode(s)
+
+	if err != nil {
+		t.Fatalf("decode(%q): %v", s, err)
+	}
+
+	return b
+}
+
+// The example of Appendix A of RFC 8291
+func TestEncryptRFC8291(t *testing.T) {
+	serverKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browserKey, err := ecdh.P256().NewPrivateKey(mustDecode(t, "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := Subscription{Endpoint: "https://push.example.net/push/JzLQ3raZJfFBR0aqvOMsLrt54w4rJUsV"}
+	sub.Keys.P256dh = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	sub.Keys.Auth = "BTBZMqHH6r4Tts7J_aSIgg"
+
+	payload := []byte("When I grow up, I want to be a watermelon")
+	body, err := encrypt(&sub, payload, mustDecode(t, "DGv6ra1nlYgDCS1FRnbzlw"), serverKey)
+
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	want := "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+
+	if got := encode(body); got != want {
+		t.Fatalf("got body\n%v\nwant\n%v", got, want)
+	}
+
+	decrypted, err := decrypt(body, browserKey, mustDecode(t, sub.Keys.Auth))
+
+	if err != nil || !bytes.Equal(decrypted, payload) {
+		t.Errorf("decrypt: got %q, %v, want the payload", decrypted, err)
+	}
+}
+
+func newFakeClient(t *testing.T) (*Client, *FakeService, string) {
+	t.Helper()
+
+	service := NewFakeService()
+	srv := httptest.NewTLSServer(service)
+	t.Cleanup(srv.Close)
+
+	publicKey, privateKey, err := GenerateKeys()
+
+	if err != nil {
+		t.Fatalf("GenerateKeys: %v", err)
+	}
+
+	client := NewClient(&Config{PublicKey: publicKey, PrivateKey: privateKey, Subject: "mailto:soporte@example.com"})
+	client.HTTP = srv.Client()
+
+	return client, service, srv.URL
+}
+
+func TestSend(t *testing.T) {
+	client, service, baseURL := newFakeClient(t)
+	sub, err := service.Subscribe(baseURL)
+
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	payload := []byte(`{"title":"Nuevo acuerdo","body":"123/2024"}`)
+
+	if err := client.Send(context.Background(), sub, payload, time.Hour, UrgencyHigh); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := service.Messages()
+
+	if len(messages) != 1 || !bytes.Equal(messages[0].Payload, payload) {
+		t.Fatalf("got %v messages, want the payload sent", len(messages))
+	}
+
+	if messages[0].TTL != 3600 || messages[0].Urgency != UrgencyHigh {
+		t.Errorf("got TTL %v and urgency %q, want 3600 and high", messages[0].TTL, messages[0].Urgency)
+	}
+
+	service.Unsubscribe(sub)
+
+	if err := client.Send(context.Background(), sub, payload, time.Hour, ""); !errors.Is(err, ErrGone) {
+		t.Errorf("unsubscribed: got %v, want ErrGone", err)
+	}
+}
+
+func TestSendTooLarge(t *testing.T) {
+	client, service, baseURL := newFakeClient(t)
+	sub, err := service.Subscribe(baseURL)
+
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := client.Send(context.Background(), sub, make([]byte, MaxPayload), time.Hour, ""); err != nil {
+		t.Errorf("a payload of MaxPayload bytes: %v", err)
+	}
+
+	if messages := service.Messages(); len(messages) != 1 {
+		t.Errorf("got %v messages, want the one of MaxPayload bytes", len(messages))
+	}
+
+	if err := client.Send(context.Background(), sub, make([]byte, MaxPayload+1), time.Hour, ""); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("a payload over MaxPayload: got %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+func TestSendNotConfigured(t *testing.T) {
+	client := NewClient(&Config{})
+
+	if err := client.Send(context.Background(), &Subscription{}, nil, time.Hour, ""); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("got %v, want ErrNotConfigured", err)
+	}
+}
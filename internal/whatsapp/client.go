@@ -0,0 +1,198 @@
+// Package whatsapp sends the template messages of the app through the
+// WhatsApp Cloud API and reads the webhooks it sends back
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// The kinds of template messages sent, the name and language of each
+// one approved in Meta are set in Config.Templates
+const (
+	TemplateReport     = "report"
+	TemplateWatchMatch = "watch_match"
+)
+
+// The phone number id or the access token are missing
+var ErrNotConfigured = errors.New("WhatsApp no está configurado, faltan FB_PHONE_NUMBER_ID o FB_ACCESS_TOKEN")
+
+// A Template approved in Meta
+type Template struct {
+	Name     string
+	Language string
+}
+
+type Config struct {
+	// The Graph API, https://graph.facebook.com unless it's a fake
+	BaseURL       string
+	APIVersion    string
+	PhoneNumberId string
+	AccessToken   string
+	// The phone users write to, shown to link their phones
+	BusinessPhone string
+	// Prepended to the phones written without one, see NormalizePhone
+	DefaultCountryCode string
+	// The templates by kind, one of the Template constants
+	Templates map[string]Template
+	// The token Meta sends when the webhook is registered
+	VerifyToken string
+	// Signs the webhook requests, the webhook is refused without it
+	AppSecret string
+}
+
+// ConfigFromEnv reads the Config from FB_PHONE_NUMBER_ID, FB_ACCESS_TOKEN
+// and the WHATSAPP_* variables. Every template can be changed with
+// WHATSAPP_TEMPLATE_<KIND> as "name" or "name:language"
+func ConfigFromEnv() *Config {
+	cfg := Config{
+		BaseURL:            envOr("WHATSAPP_API_URL", "https://graph.facebook.com"),
+		APIVersion:         envOr("WHATSAPP_API_VERSION", "v18.0"),
+		PhoneNumberId:      os.Getenv("FB_PHONE_NUMBER_ID"),
+		AccessToken:        os.Getenv("FB_ACCESS_TOKEN"),
+		BusinessPhone:      os.Getenv("WHATSAPP_BUSINESS_PHONE"),
+		DefaultCountryCode: envOr("WHATSAPP_COUNTRY_CODE", "52"),
+		VerifyToken:        os.Getenv("WHATSAPP_VERIFY_TOKEN"),
+		AppSecret:          os.Getenv("WHATSAPP_APP_SECRET"),
+		Templates:          map[string]Template{},
+	}
+
+	language := envOr("WHATSAPP_LANGUAGE", "es")
+	defaults := map[string]string{
+		TemplateReport:     "report_file",
+		TemplateWatchMatch: "watch_match",
+	}
+
+	for kind, name := range defaults {
+		template := Template{Name: name, Language: language}
+		value := os.Getenv("WHATSAPP_TEMPLATE_" + strings.ToUpper(kind))
+
+		if value != "" {
+			template.Name, template.Language, _ = strings.Cut(value, ":")
+
+			if template.Language == "" {
+				template.Language = language
+			}
+		}
+
+		cfg.Templates[kind] = template
+	}
+
+	return &cfg
+}
+
+func envOr(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// An APIError is an error returned by the Graph API
+type APIError struct {
+	// The HTTP status of the response
+	Status    int    `json:"-"`
+	Message   string `json:"message"`
+	Type      string `json:"type"`
+	Code      int    `json:"code"`
+	Subcode   int    `json:"error_subcode,omitempty"`
+	FBTraceId string `json:"fbtrace_id,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("WhatsApp respondió %v: %v (%v %v)", e.Status, e.Message, e.Type, e.Code)
+}
+
+// A Client sends messages from the phone number of the Config
+type Client struct {
+	cfg  Config
+	HTTP *http.Client
+}
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: *cfg, HTTP: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (c *Client) Config() Config {
+	return c.cfg
+}
+
+// Configured reports whether the client has what it needs to send
+func (c *Client) Configured() bool {
+	return c.cfg.PhoneNumberId != "" && c.cfg.AccessToken != ""
+}
+
+// The body of the responses of the messages endpoint
+type messageResponse struct {
+	Messages []struct {
+		Id string `json:"id"`
+	} `json:"messages"`
+	Error *APIError `json:"error"`
+}
+
+// post sends a message and returns the id WhatsApp gave it
+func (c *Client) post(ctx context.Context, payload any) (string, error) {
+	if !c.Configured() {
+		return "", ErrNotConfigured
+	}
+
+	reqBody, err := json.Marshal(payload)
+
+	if err != nil {
+		return "", err
+	}
+
+	reqUrl := fmt.Sprintf("%v/%v/%v/messages", strings.TrimSuffix(c.cfg.BaseURL, "/"), c.cfg.APIVersion, c.cfg.PhoneNumberId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqUrl, bytes.NewReader(reqBody))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.cfg.AccessToken)
+
+	resp, err := c.HTTP.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if err != nil {
+		return "", err
+	}
+
+	var parsed messageResponse
+	jsonErr := json.Unmarshal(respBody, &parsed)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if jsonErr == nil && parsed.Error != nil {
+			parsed.Error.Status = resp.StatusCode
+			return "", parsed.Error
+		}
+
+		return "", &APIError{Status: resp.StatusCode, Message: strings.TrimSpace(string(respBody))}
+	}
+
+	if jsonErr != nil {
+		return "", fmt.Errorf("Respuesta inválida de WhatsApp: %w", jsonErr)
+	}
+
+	if len(parsed.Messages) == 0 || parsed.Messages[0].Id == "" {
+		return "", errors.New("WhatsApp no devolvió el id del mensaje")
+	}
+
+	return parsed.Messages[0].Id, nil
+}
@@ -0,0 +1,184 @@
+package whatsapp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFakeClient(t *testing.T) (*Client, *FakeAPI) {
+	t.Helper()
+
+	api := NewFakeAPI("token")
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	client := NewClient(&Config{
+		BaseURL:       srv.URL,
+		APIVersion:    "v18.0",
+		PhoneNumberId: "1234",
+		AccessToken:   "token",
+		Templates:     map[string]Template{TemplateReport: {Name: "report_file", Language: "es"}},
+	})
+
+	return client, api
+}
+
+func TestSendText(t *testing.T) {
+	client, api := newFakeClient(t)
+
+	id, err := client.SendText(context.Background(), "+526181234567", "Hola")
+
+	if err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+
+	messages := api.Messages()
+
+	if len(messages) != 1 || messages[0].Id != id || messages[0].To != "+526181234567" || messages[0].Text != "Hola" {
+		t.Fatalf("got %v messages, want the one sent with id %v", len(messages), id)
+	}
+
+	if messages[0].PhoneNumberId != "1234" {
+		t.Errorf("sent from %q, want 1234", messages[0].PhoneNumberId)
+	}
+}
+
+func TestSendTemplate(t *testing.T) {
+	client, api := newFakeClient(t)
+
+	id, err := client.SendTemplate(context.Background(), "+526181234567", TemplateData{Template: TemplateReport})
+
+	if err != nil {
+		t.Fatalf("SendTemplate: %v", err)
+	}
+
+	messages := api.Messages()
+
+	if len(messages) != 1 || messages[0].Id != id || messages[0].To != "+526181234567" || messages[0].Template != "report_file" {
+		t.Fatalf("got %v messages, want the one sent with id %v", len(messages), id)
+	}
+
+	if messages[0].PhoneNumberId != "1234" {
+		t.Errorf("sent from %q, want 1234", messages[0].PhoneNumberId)
+	}
+
+	if _, err := client.SendTemplate(context.Background(), "+526181234567", TemplateData{Template: TemplateWatchMatch}); err == nil {
+		t.Errorf("sent a template that isn't configured")
+	}
+}
+
+func TestSendFails(t *testing.T) {
+	client, api := newFakeClient(t)
+	api.Fail(&APIError{Status: 400, Message: "Recipient not in allowed list", Type: "OAuthException", Code: 131030})
+
+	_, err := client.SendText(context.Background(), "+526181234567", "Hola")
+	apiErr := &APIError{}
+
+	if !errors.As(err, &apiErr) || apiErr.Status != 400 || apiErr.Code != 131030 {
+		t.Fatalf("got %v, want the APIError of the fake", err)
+	}
+
+	if len(api.Messages()) != 0 {
+		t.Errorf("the fake kept a failed message")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"object":"whatsapp_business_account"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	header := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		want   bool
+	}{
+		{"valid", "secret", header, true},
+		{"other secret", "other", header, false},
+		{"without prefix", "secret", header[len("sha256="):], false},
+		{"without secret", "", header, false},
+		{"without header", "", "", false},
+	}
+
+	for _, tt := range tests {
+		client := NewClient(&Config{AppSecret: tt.secret})
+
+		if got := client.VerifySignature(body, tt.header); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+
+	event, err := ParseWebhook(FakeStatusWebhook("wamid.1", StatusFailed, "5216181234567", "Message undeliverable", at))
+
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+
+	if len(event.Statuses) != 1 {
+		t.Fatalf("got %v statuses, want 1", len(event.Statuses))
+	}
+
+	status := event.Statuses[0]
+
+	if status.MessageId != "wamid.1" || status.Status != StatusFailed || !status.At.Equal(at) || status.Error == "" {
+		t.Errorf("got %+v, want the failure of wamid.1", status)
+	}
+
+	event, err = ParseWebhook(FakeMessageWebhook("wamid.2", "5216181234567", "Ana", "BAJA", at))
+
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+
+	if len(event.Messages) != 1 {
+		t.Fatalf("got %v messages, want 1", len(event.Messages))
+	}
+
+	msg := event.Messages[0]
+
+	if msg.From != "+526181234567" || msg.Name != "Ana" || msg.Text != "BAJA" {
+		t.Errorf("got %+v, want BAJA from +526181234567", msg)
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"618 123 4567", "+526181234567"},
+		{"(618) 123-45.67", "+526181234567"},
+		{"0052 618 123 4567", "+526181234567"},
+		{"+1 202 555 0100", "+12025550100"},
+		{"12345", ""},
+		{"+0123456789", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.phone, "52")
+
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("NormalizePhone(%q) = %q, want an error", tt.phone, got)
+			}
+
+			continue
+		}
+
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v, want %q", tt.phone, got, err, tt.want)
+		}
+	}
+}
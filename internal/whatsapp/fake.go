@@ -0,0 +1,180 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeAPI imitates the messages endpoint of the Graph API so the client
+// can be run without sending real messages: point WHATSAPP_API_URL to a
+// server with it. It checks the token and the phone like the real one
+// and keeps every message it accepts
+type FakeAPI struct {
+	AccessToken string
+
+	mu       sync.Mutex
+	messages []*FakeMessage
+	fail     *APIError
+}
+
+// A FakeMessage is a message accepted by a FakeAPI
+type FakeMessage struct {
+	Id            string
+	PhoneNumberId string
+	To            string
+	// The template sent, empty for the other kinds of messages
+	Template string
+	// The text sent, empty for the other kinds of messages
+	Text   string
+	Body   map[string]any
+	SentAt time.Time
+}
+
+func NewFakeAPI(accessToken string) *FakeAPI {
+	return &FakeAPI{AccessToken: accessToken}
+}
+
+// Fail makes every request fail with err until it's called with nil
+func (f *FakeAPI) Fail(err *APIError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fail = err
+}
+
+// Messages returns the messages accepted so far
+func (f *FakeAPI) Messages() []*FakeMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*FakeMessage{}, f.messages...)
+}
+
+func (f *FakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /<version>/<phone number id>/messages
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if r.Method != http.MethodPost || len(parts) != 3 || parts[2] != "messages" {
+		fakeError(w, &APIError{Status: 400, Message: "Unsupported request", Type: "GraphMethodException", Code: 100})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+f.AccessToken {
+		fakeError(w, &APIError{Status: 401, Message: "Invalid OAuth access token", Type: "OAuthException", Code: 190})
+		return
+	}
+
+	var body map[string]any
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		fakeError(w, &APIError{Status: 400, Message: "Invalid JSON", Type: "OAuthException", Code: 100})
+		return
+	}
+
+	to, _ := body["to"].(string)
+
+	if body["messaging_product"] != "whatsapp" || !e164.MatchString(to) {
+		fakeError(w, &APIError{Status: 400, Message: "Invalid parameter", Type: "OAuthException", Code: 100})
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil {
+		fakeError(w, f.fail)
+		return
+	}
+
+	msg := FakeMessage{
+		Id:            "wamid.fake" + strconv.Itoa(len(f.messages)+1),
+		PhoneNumberId: parts[1],
+		To:            to,
+		Body:          body,
+		SentAt:        time.Now(),
+	}
+
+	if template, ok := body["template"].(map[string]any); ok {
+		msg.Template, _ = template["name"].(string)
+	}
+
+	if text, ok := body["text"].(map[string]any); ok {
+		msg.Text, _ = text["body"].(string)
+	}
+
+	f.messages = append(f.messages, &msg)
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"messaging_product":"whatsapp","contacts":[{"input":%q,"wa_id":%q}],"messages":[{"id":%q}]}`,
+		to, strings.TrimPrefix(to, "+"), msg.Id)
+}
+
+func fakeError(w http.ResponseWriter, err *APIError) {
+	status := err.Status
+	if status == 0 {
+		status = 400
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": err})
+}
+
+// FakeStatusWebhook returns the body Meta POSTs to the webhook when the
+// message with id changes to status, errorTitle is only used for failures
+func FakeStatusWebhook(id, status, recipient, errorTitle string, at time.Time) []byte {
+	s := map[string]any{
+		"id":           id,
+		"status":       status,
+		"timestamp":    strconv.FormatInt(at.Unix(), 10),
+		"recipient_id": recipient,
+	}
+
+	if status == StatusFailed {
+		s["errors"] = []map[string]any{{"code": 131026, "title": errorTitle}}
+	}
+
+	return fakeWebhook(map[string]any{
+		"messaging_product": "whatsapp",
+		"statuses":          []map[string]any{s},
+	})
+}
+
+// FakeMessageWebhook returns the body Meta POSTs to the webhook when the
+// user with waId, the phone without the plus sign, writes text
+func FakeMessageWebhook(id, waId, name, text string, at time.Time) []byte {
+	return fakeWebhook(map[string]any{
+		"messaging_product": "whatsapp",
+		"contacts": []map[string]any{{
+			"wa_id":   waId,
+			"profile": map[string]any{"name": name},
+		}},
+		"messages": []map[string]any{{
+			"id":        id,
+			"from":      waId,
+			"timestamp": strconv.FormatInt(at.Unix(), 10),
+			"type":      "text",
+			"text":      map[string]any{"body": text},
+		}},
+	})
+}
+
+func fakeWebhook(value map[string]any) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"object": "whatsapp_business_account",
+		"entry": []map[string]any{{
+			"id": "0",
+			"changes": []map[string]any{{
+				"field": "messages",
+				"value": value,
+			}},
+		}},
+	})
+
+	return body
+}
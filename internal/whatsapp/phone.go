@@ -0,0 +1,50 @@
+package whatsapp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A phone in E.164: a plus sign, the country code and up to 15 digits
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone returns phone in E.164 without the spaces, dashes, dots
+// and parentheses people write. The 10 digit numbers written without a
+// country code get countryCode, Mexican numbers are written like that
+func NormalizePhone(phone, countryCode string) (string, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+
+		return r
+	}, strings.TrimSpace(phone))
+
+	if strings.HasPrefix(cleaned, "00") {
+		cleaned = "+" + cleaned[2:]
+	}
+
+	if !strings.HasPrefix(cleaned, "+") && len(cleaned) == 10 && countryCode != "" {
+		cleaned = "+" + countryCode + cleaned
+	}
+
+	if !e164.MatchString(cleaned) {
+		return "", fmt.Errorf("El teléfono %q no es válido, escríbelo con su código de país, p.ej. +52 618 123 4567", phone)
+	}
+
+	return cleaned, nil
+}
+
+// PhoneFromWaId returns the phone in E.164 of a WhatsApp id, the phone
+// without the plus sign. Mexican mobiles come with the 1 that was dialed
+// before them until 2020 (521...), it's removed so they match the phones
+// people write
+func PhoneFromWaId(waId string) string {
+	if strings.HasPrefix(waId, "521") && len(waId) == 13 {
+		waId = "52" + waId[3:]
+	}
+
+	return "+" + waId
+}
@@ -0,0 +1,199 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The statuses of a message sent, in the order they happen
+const (
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusFailed    = "failed"
+)
+
+// A Status tells what happened with a message sent
+type Status struct {
+	MessageId string
+	// One of the Status constants
+	Status      string
+	At          time.Time
+	RecipientId string
+	// Why it failed, empty unless the status is StatusFailed
+	Error string
+}
+
+// A Message sent by a user to the phone number of the app
+type Message struct {
+	Id string
+	// The phone of the user in E.164, see PhoneFromWaId
+	From string
+	// The name of the WhatsApp profile of the user
+	Name string
+	// The text written, empty for the messages that aren't text
+	Text string
+	At   time.Time
+}
+
+// An Event is what a webhook request tells
+type Event struct {
+	Statuses []Status
+	Messages []Message
+}
+
+// The body Meta POSTs to the webhook, only with the fields used
+type webhookPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		Changes []struct {
+			Field string `json:"field"`
+			Value struct {
+				Contacts []struct {
+					WaId    string `json:"wa_id"`
+					Profile struct {
+						Name string `json:"name"`
+					} `json:"profile"`
+				} `json:"contacts"`
+				Messages []struct {
+					Id        string `json:"id"`
+					From      string `json:"from"`
+					Timestamp string `json:"timestamp"`
+					Type      string `json:"type"`
+					Text      struct {
+						Body string `json:"body"`
+					} `json:"text"`
+				} `json:"messages"`
+				Statuses []struct {
+					Id          string `json:"id"`
+					Status      string `json:"status"`
+					Timestamp   string `json:"timestamp"`
+					RecipientId string `json:"recipient_id"`
+					Errors      []struct {
+						Code      int    `json:"code"`
+						Title     string `json:"title"`
+						Message   string `json:"message"`
+						ErrorData struct {
+							Details string `json:"details"`
+						} `json:"error_data"`
+					} `json:"errors"`
+				} `json:"statuses"`
+			} `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+// ParseWebhook reads the body of a webhook request
+func ParseWebhook(body []byte) (*Event, error) {
+	var payload webhookPayload
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	event := Event{}
+
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+
+			for _, s := range change.Value.Statuses {
+				status := Status{
+					MessageId:   s.Id,
+					Status:      s.Status,
+					At:          parseTimestamp(s.Timestamp),
+					RecipientId: s.RecipientId,
+				}
+
+				errs := []string{}
+				for _, e := range s.Errors {
+					msg := e.Title
+
+					if e.ErrorData.Details != "" {
+						msg += ": " + e.ErrorData.Details
+					} else if e.Message != "" && e.Message != e.Title {
+						msg += ": " + e.Message
+					}
+
+					errs = append(errs, strconv.Itoa(e.Code)+" "+msg)
+				}
+
+				status.Error = strings.Join(errs, "; ")
+				event.Statuses = append(event.Statuses, status)
+			}
+
+			names := map[string]string{}
+			for _, c := range change.Value.Contacts {
+				names[c.WaId] = c.Profile.Name
+			}
+
+			for _, m := range change.Value.Messages {
+				msg := Message{
+					Id:   m.Id,
+					From: PhoneFromWaId(m.From),
+					Name: names[m.From],
+					At:   parseTimestamp(m.Timestamp),
+				}
+
+				if m.Type == "text" {
+					msg.Text = m.Text.Body
+				}
+
+				event.Messages = append(event.Messages, msg)
+			}
+		}
+	}
+
+	return &event, nil
+}
+
+// parseTimestamp reads the unix seconds WhatsApp sends as strings, now
+// when they are missing
+func parseTimestamp(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil {
+		return time.Now()
+	}
+
+	return time.Unix(seconds, 0)
+}
+
+// VerifySubscription reports whether the request Meta sends to register
+// the webhook, with hub.mode and hub.verify_token, is ours
+func (c *Client) VerifySubscription(mode, token string) bool {
+	return mode == "subscribe" && c.cfg.VerifyToken != "" &&
+		hmac.Equal([]byte(token), []byte(c.cfg.VerifyToken))
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook
+// request against its body. Without an AppSecret every request is refused
+func (c *Client) VerifySignature(body []byte, header string) bool {
+	if c.cfg.AppSecret == "" {
+		return false
+	}
+
+	sig, ok := strings.CutPrefix(header, "sha256=")
+
+	if !ok {
+		return false
+	}
+
+	expected, err := hex.DecodeString(sig)
+
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.cfg.AppSecret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
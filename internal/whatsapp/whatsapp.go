@@ -1,12 +1,9 @@
 package whatsapp
 
 import (
-	"bytes"
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"net/http"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/vladwithcode/juzgados/internal/db"
@@ -20,9 +17,10 @@ type TemplateComponent struct {
 }
 
 type TemplateData struct {
-	TemplateName string
-	BodyVars     []TemplateVar
-	HeaderVars   []TemplateVar
+	// One of the Template constants
+	Template   string
+	BodyVars   []TemplateVar
+	HeaderVars []TemplateVar
 }
 
 type templatePayload struct {
@@ -33,45 +31,21 @@ type templatePayload struct {
 	} `json:"language"`
 }
 
-func postCloudAPIMessage(requestPayload interface{}) error {
-	phoneNumberId := os.Getenv("FB_PHONE_NUMBER_ID")
-	fbAccessToken := os.Getenv("FB_ACCESS_TOKEN")
-
-	if phoneNumberId == "" {
-		panic("FB_PHONE_NUMBER_ID not in ENV")
-	}
-	if fbAccessToken == "" {
-		panic("FB_ACCESS_TOKEN not in ENV")
-	}
-
-	reqBody, err := json.Marshal(requestPayload)
-
-	if err != nil {
-		return err
-	}
-
-	reqUrl := fmt.Sprintf("https://graph.facebook.com/v18.0/%v/messages", phoneNumberId)
-	req, err := http.NewRequest("post", reqUrl, bytes.NewReader(reqBody))
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", fbAccessToken))
-
-	resp, err := http.DefaultClient.Do(req)
+// SendTemplate sends the template of data to phoneNumber and returns the
+// id of the message, the one its status webhooks come with
+func (c *Client) SendTemplate(ctx context.Context, phoneNumber string, data TemplateData) (string, error) {
+	to, err := NormalizePhone(phoneNumber, c.cfg.DefaultCountryCode)
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		fmt.Printf("resp: %+v\n", resp)
+	template, ok := c.cfg.Templates[data.Template]
 
-		return errors.New(fmt.Sprintf("Request failed with code: %v", resp.StatusCode))
+	if !ok {
+		return "", fmt.Errorf("No hay una plantilla de WhatsApp para %v", data.Template)
 	}
 
-	return nil
-}
-
-func SendTemplateMessage(phoneNumber string, data TemplateData) error {
 	var reqPayload struct {
 		MessagingProduct string          `json:"messaging_product"`
 		MessageType      string          `json:"type"`
@@ -97,69 +71,96 @@ func SendTemplateMessage(phoneNumber string, data TemplateData) error {
 
 	reqPayload.MessageType = "template"
 	reqPayload.MessagingProduct = "whatsapp"
-	reqPayload.ToPhone = phoneNumber
+	reqPayload.ToPhone = to
 	reqPayload.Template = templatePayload{
-		Name: data.TemplateName,
+		Name: template.Name,
 		Language: struct {
 			Code string `json:"code"`
-		}{Code: "es"},
+		}{Code: template.Language},
 		Components: components,
 	}
 
-	return postCloudAPIMessage(reqPayload)
+	return c.post(ctx, reqPayload)
 }
 
-func SendReportMessage(userData db.AutoReportUser, pdfUrl string) error {
-	var (
-		headerVars []TemplateVar
-		bodyVars   []TemplateVar
-	)
-
-	y, m, d := time.Now().Date()
+// SendText sends text to phoneNumber. WhatsApp only delivers it within
+// the 24 hours after the user wrote to the app, the rest of the messages
+// must be templates
+func (c *Client) SendText(ctx context.Context, phoneNumber, text string) (string, error) {
+	to, err := NormalizePhone(phoneNumber, c.cfg.DefaultCountryCode)
 
-	var (
-		dStr string
-		mStr string
-	)
+	if err != nil {
+		return "", err
+	}
 
-	if d < 10 {
-		dStr = fmt.Sprintf("0%d", d)
-	} else {
-		dStr = fmt.Sprintf("%d", d)
+	type textPayload struct {
+		Body       string `json:"body"`
+		PreviewURL bool   `json:"preview_url"`
 	}
 
-	if m < 10 {
-		mStr = fmt.Sprintf("0%d", m)
-	} else {
-		mStr = fmt.Sprintf("%d", m)
+	reqPayload := struct {
+		MessagingProduct string      `json:"messaging_product"`
+		MessageType      string      `json:"type"`
+		ToPhone          string      `json:"to"`
+		Text             textPayload `json:"text"`
+	}{
+		MessagingProduct: "whatsapp",
+		MessageType:      "text",
+		ToPhone:          to,
+		Text:             textPayload{Body: text},
 	}
 
-	headerVars = append(headerVars, TemplateVar{
+	return c.post(ctx, reqPayload)
+}
+
+// SendReport sends the PDF report at pdfUrl to phoneNumber
+func (c *Client) SendReport(ctx context.Context, phoneNumber string, userData db.AutoReportUser, pdfUrl string) (string, error) {
+	headerVars := []TemplateVar{{
 		"type": "document",
 		"document": struct {
 			Link     string `json:"link"`
 			Filename string `json:"filename"`
 		}{Link: pdfUrl, Filename: "reporte.pdf"},
-	})
+	}}
 
-	bodyVars = append(bodyVars, TemplateVar{
-		"type": "text",
-		"text": fmt.Sprintf("%v %v", userData.Name, userData.Lastname),
-	})
-	bodyVars = append(bodyVars, TemplateVar{
-		"type": "date_time",
-		"date_time": struct {
-			FallbackValue string `json:"fallback_value"`
-		}{
-			FallbackValue: fmt.Sprintf("%v-%v-%v", y, mStr, dStr),
+	bodyVars := []TemplateVar{
+		{
+			"type": "text",
+			"text": fmt.Sprintf("%v %v", userData.Name, userData.Lastname),
 		},
-	})
+		{
+			"type": "date_time",
+			"date_time": struct {
+				FallbackValue string `json:"fallback_value"`
+			}{
+				FallbackValue: time.Now().Format("2006-01-02"),
+			},
+		},
+	}
 
-	err := SendTemplateMessage("+526183188452", TemplateData{
-		TemplateName: "report_file",
-		HeaderVars:   headerVars,
-		BodyVars:     bodyVars,
+	return c.SendTemplate(ctx, phoneNumber, TemplateData{
+		Template:   TemplateReport,
+		HeaderVars: headerVars,
+		BodyVars:   bodyVars,
 	})
+}
+
+// SendWatchMatches notifies the user that the patterns they watch
+// were found in the latest bulletins
+func (c *Client) SendWatchMatches(ctx context.Context, phoneNumber, name string, matches []*db.WatchMatch) (string, error) {
+	cases := []string{}
+	for _, m := range matches {
+		cases = append(cases, fmt.Sprintf("%v (%v)", m.CaseId, m.NatureCode))
+	}
 
-	return err
+	bodyVars := []TemplateVar{
+		{"type": "text", "text": name},
+		{"type": "text", "text": fmt.Sprint(len(matches))},
+		{"type": "text", "text": strings.Join(cases, ", ")},
+	}
+
+	return c.SendTemplate(ctx, phoneNumber, TemplateData{
+		Template: TemplateWatchMatch,
+		BodyVars: bodyVars,
+	})
 }
@@ -0,0 +1,7 @@
+#!/bin/bash
+# Sends the email digests that are due, run it every hour from crontab.
+# The logs go to $TSJ_LOG_DIR when it's set in the environment or the .env
+set -e
+export TSJ_DIR=${TSJ_DIR:-$(cd "$(dirname "$0")/.." && pwd)}
+
+exec "$TSJ_DIR/bin/juzgados" digest
@@ -1,11 +1,8 @@
 #!/bin/bash
+# The logs go to $TSJ_LOG_DIR when it's set in the environment or the .env
+# Only the cases due are checked, each one in the bulletins published since
+# its last check, so it's safe along with auto-update.hourly.sh
 set -e
-tsjDir=/home/vladwithcode/web/tsj
-export TSJ_DIR=$tsjDir
-export PATH=$PATH:/usr/local/go/bin
+export TSJ_DIR=${TSJ_DIR:-$(cd "$(dirname "$0")/.." && pwd)}
 
-errorFile="$HOME/.local/log/tsj/auto-update.daily.log"
-
-cd $tsjDir
-
-/home/vladwithcode/web/tsj/cmd/auto-update -d 60 >> $errorFile 2>&1
+exec "$TSJ_DIR/bin/juzgados" update
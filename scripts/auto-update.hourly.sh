@@ -1,11 +1,6 @@
 #!/bin/bash
+# The logs go to $TSJ_LOG_DIR when it's set in the environment or the .env
 set -e
-tsjDir=/home/vladwithcode/web/tsj
-export TSJ_DIR=$tsjDir
-export PATH=$PATH:/usr/local/go/bin
+export TSJ_DIR=${TSJ_DIR:-$(cd "$(dirname "$0")/.." && pwd)}
 
-errorFile="$HOME/.local/log/tsj/auto-update.log"
-
-cd $tsjDir
-
-/home/vladwithcode/web/tsj/cmd/auto-update >> $errorFile 2>&1
+exec "$TSJ_DIR/bin/juzgados" update
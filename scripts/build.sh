@@ -1,2 +1,2 @@
 #!/bin/bash
-go build -o cmd/main/main cmd/main/main.go
+go build -o bin/juzgados ./cmd/juzgados
@@ -60,3 +60,93 @@ document.addEventListener("DOMContentLoaded", () => {
 })
 
 
+
+// The key of PushManager.subscribe() comes as base64url
+function urlBase64ToUint8Array(base64) {
+    const padding = "=".repeat((4 - base64.length % 4) % 4)
+    const raw = atob((base64 + padding).replace(/-/g, "+").replace(/_/g, "/"))
+
+    return Uint8Array.from(raw, c => c.charCodeAt(0))
+}
+
+// enablePush subscribes this browser to the notifications and sends the
+// subscription to the server, the result is written in the element resultSel
+async function enablePush(resultSel) {
+    const result = document.querySelector(resultSel)
+
+    if (!("serviceWorker" in navigator) || !("PushManager" in window)) {
+        result.textContent = "Tu navegador no admite notificaciones"
+        return
+    }
+
+    try {
+        const permission = await Notification.requestPermission()
+
+        if (permission !== "granted") {
+            result.textContent = "No diste permiso para mostrar notificaciones"
+            return
+        }
+
+        const keyResp = await fetch("/api/push/key")
+
+        if (!keyResp.ok) {
+            result.textContent = "Las notificaciones del navegador no están disponibles"
+            return
+        }
+
+        const { publicKey } = await keyResp.json()
+        const registration = await navigator.serviceWorker.register("/sw.js")
+        await navigator.serviceWorker.ready
+
+        const subscription = await registration.pushManager.subscribe({
+            userVisibleOnly: true,
+            applicationServerKey: urlBase64ToUint8Array(publicKey),
+        })
+
+        const resp = await fetch("/api/push/subscriptions", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(subscription),
+        })
+
+        if (!resp.ok) {
+            const data = await resp.json().catch(() => ({}))
+            result.textContent = data.error || "No se pudo activar las notificaciones"
+            return
+        }
+
+        result.textContent = "Este navegador recibirá tus notificaciones"
+    } catch (err) {
+        console.error(err)
+        result.textContent = "No se pudo activar las notificaciones"
+    }
+}
+
+// disablePush unsubscribes this browser and tells the server to forget it
+async function disablePush(resultSel) {
+    const result = document.querySelector(resultSel)
+
+    try {
+        const registration = await navigator.serviceWorker.getRegistration("/")
+        const subscription = registration && await registration.pushManager.getSubscription()
+
+        if (!subscription) {
+            result.textContent = "Este navegador no está suscrito"
+            return
+        }
+
+        const endpoint = subscription.endpoint
+        await subscription.unsubscribe()
+
+        await fetch("/api/push/subscriptions", {
+            method: "DELETE",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ endpoint }),
+        })
+
+        result.textContent = "Este navegador ya no recibirá tus notificaciones"
+    } catch (err) {
+        console.error(err)
+        result.textContent = "No se pudo desactivar las notificaciones"
+    }
+}
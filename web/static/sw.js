@@ -0,0 +1,36 @@
+// Shows the notifications sent through Web Push, see internal/notify Push
+self.addEventListener("push", e => {
+    let data = {}
+
+    try {
+        data = e.data ? e.data.json() : {}
+    } catch {
+        data = { body: e.data.text() }
+    }
+
+    e.waitUntil(
+        self.registration.showNotification(data.title || "Juzgados", {
+            body: data.body || "",
+            tag: data.tag || undefined,
+            data: { url: data.url || "/" },
+        })
+    )
+})
+
+self.addEventListener("notificationclick", e => {
+    e.notification.close()
+
+    const url = new URL(e.notification.data.url, self.location.origin).href
+
+    e.waitUntil(
+        clients.matchAll({ type: "window", includeUncontrolled: true }).then(windows => {
+            for (const w of windows) {
+                if (w.url === url && "focus" in w) {
+                    return w.focus()
+                }
+            }
+
+            return clients.openWindow(url)
+        })
+    )
+})